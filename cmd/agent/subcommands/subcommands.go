@@ -27,6 +27,7 @@ import (
 	cmdstreamlogs "github.com/DataDog/datadog-agent/cmd/agent/subcommands/streamlogs"
 	cmdtaggerlist "github.com/DataDog/datadog-agent/cmd/agent/subcommands/taggerlist"
 	cmdtroubleshooting "github.com/DataDog/datadog-agent/cmd/agent/subcommands/troubleshooting"
+	cmduploadbundles "github.com/DataDog/datadog-agent/cmd/agent/subcommands/uploadbundles"
 	cmdversion "github.com/DataDog/datadog-agent/cmd/agent/subcommands/version"
 	cmdworkloadlist "github.com/DataDog/datadog-agent/cmd/agent/subcommands/workloadlist"
 )
@@ -56,6 +57,7 @@ func AgentSubcommands() []command.SubcommandFactory {
 		cmdstreamlogs.Commands,
 		cmdtaggerlist.Commands,
 		cmdtroubleshooting.Commands,
+		cmduploadbundles.Commands,
 		cmdversion.Commands,
 		cmdworkloadlist.Commands,
 	}
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package uploadbundles implements 'agent upload-bundles'.
+package uploadbundles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/command"
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+)
+
+var (
+	bundlesPath string
+	timeout     time.Duration
+)
+
+// Commands returns a slice of subcommands for the 'agent' command.
+func Commands(globalArgs *command.GlobalArgs) []*cobra.Command {
+	uploadBundlesCmd := &cobra.Command{
+		Use:   "upload-bundles",
+		Short: "Upload the bundles recorded by an Agent in offline mode",
+		Long: `Send the transactions recorded by an Agent with 'forwarder_record_path' set,
+oldest first, to the endpoints configured for this Agent with its API keys. Each bundle is
+removed once sent. The bundles which cannot be sent, or whose API keys are rejected, are kept to
+be uploaded again, and their next upload only sends the transactions which were not sent yet.
+The transactions rejected by the intake as invalid or too large are dropped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The secrets backend may resolve the API keys and the storage encryption keys.
+			err := common.SetupConfig(globalArgs.ConfFilePath)
+			if err != nil {
+				return fmt.Errorf("unable to set up global agent configuration: %v", err)
+			}
+
+			err = config.SetupLogger(config.CoreLoggerName, config.GetEnvDefault("DD_LOG_LEVEL", "off"), "", "", false, true, false)
+			if err != nil {
+				fmt.Printf("Cannot setup logger, exiting: %v\n", err)
+				return err
+			}
+
+			if bundlesPath == "" {
+				bundlesPath = config.Datadog.GetString("forwarder_record_path")
+			}
+			if bundlesPath == "" {
+				return fmt.Errorf("no bundle folder: use the --path flag")
+			}
+			return uploadBundles()
+		},
+	}
+	uploadBundlesCmd.Flags().StringVarP(&bundlesPath, "path", "p", "", "Folder of the bundles to upload (default: forwarder_record_path)")
+	uploadBundlesCmd.Flags().DurationVarP(&timeout, "timeout", "t", 20*time.Second, "Timeout of each request")
+
+	return []*cobra.Command{uploadBundlesCmd}
+}
+
+func uploadBundles() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop after the current transaction on SIGINT or SIGTERM
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	keysPerDomain, err := config.GetMultipleEndpoints()
+	if err != nil {
+		return fmt.Errorf("misconfiguration of agent endpoints: %v", err)
+	}
+	uploader, err := forwarder.NewBundleUploader(forwarder.NewOptions(keysPerDomain).DomainResolvers, timeout)
+	if err != nil {
+		return err
+	}
+
+	bundles, err := forwarder.ListRecordedBundles(bundlesPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Uploading %d bundle(s) from %s\n", len(bundles), bundlesPath)
+
+	total, totalDropped := 0, 0
+	for _, bundle := range bundles {
+		sent, dropped, err := uploader.UploadBundle(ctx, bundle)
+		total += sent
+		totalDropped += dropped
+		if ctx.Err() != nil {
+			return fmt.Errorf("upload interrupted, %s is kept", bundle)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot upload %s, the bundle is kept: %v", bundle, err)
+		}
+		fmt.Printf("Uploaded %s: %d transaction(s), %d rejected by the intake\n", bundle, sent, dropped)
+	}
+	fmt.Printf("Upload done: %d transaction(s) sent, %d rejected by the intake\n", total, totalDropped)
+	return nil
+}
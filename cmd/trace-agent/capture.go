@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/replay"
+)
+
+// receiverURL returns the base URL of the trace receiver of the running agent.
+func receiverURL(cfg *config.AgentConfig) string {
+	return fmt.Sprintf("http://%s:%d", cfg.ReceiverHost, cfg.ReceiverPort)
+}
+
+// runCapture asks the running agent to capture the traces and stats it receives for
+// the duration d, and writes the path of the capture file to w.
+func runCapture(w io.Writer, cfg *config.AgentConfig, d time.Duration) error {
+	u := receiverURL(cfg) + "/debug/capture?duration=" + url.QueryEscape(d.String())
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(u, "", nil)
+	if err != nil {
+		return fmt.Errorf("could not reach the trace agent on port %d: %v", cfg.ReceiverPort, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Path     string `json:"path"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Capturing the traffic of the trace agent to %s for %s\n", out.Path, out.Duration)
+	return nil
+}
+
+// runReplay replays the capture file at path to the running agent at the given speed
+// and writes a summary to w.
+func runReplay(ctx context.Context, w io.Writer, cfg *config.AgentConfig, path string, speed float64) error {
+	if speed < 0 {
+		return fmt.Errorf("invalid replay speed %v", speed)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := replay.NewReader(f)
+	if err != nil {
+		return err
+	}
+	rp := replay.Replayer{
+		URL:    receiverURL(cfg),
+		Speed:  speed,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+	fmt.Fprintf(w, "Replaying %s to %s\n", path, rp.URL)
+	stats, err := rp.Replay(ctx, r)
+	fmt.Fprintf(w, "Replayed %d requests, %d failed\n", stats.Sent, stats.Failed)
+	return err
+}
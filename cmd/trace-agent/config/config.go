@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"html"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -24,6 +25,7 @@ import (
 	coreconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/config/remote"
 	"github.com/DataDog/datadog-agent/pkg/config/remote/data"
+	"github.com/DataDog/datadog-agent/pkg/obfuscate"
 	"github.com/DataDog/datadog-agent/pkg/otlp"
 	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
@@ -233,6 +235,19 @@ func applyDatadogConfig(c *config.AgentConfig) error {
 	if coreconfig.Datadog.IsSet("apm_config.rare_sampler.cardinality") {
 		c.RareSamplerCardinality = coreconfig.Datadog.GetInt("apm_config.rare_sampler.cardinality")
 	}
+	if k := "apm_config.latency_sampler.enabled"; coreconfig.Datadog.IsSet(k) {
+		c.LatencySamplerEnabled = coreconfig.Datadog.GetBool(k)
+	}
+	if k := "apm_config.latency_sampler.percentile"; coreconfig.Datadog.IsSet(k) {
+		if p := coreconfig.Datadog.GetFloat64(k); p > 0 && p < 1 {
+			c.LatencySamplerPercentile = p
+		} else {
+			log.Warnf("Ignoring invalid %s %v: it must be between 0 and 1 exclusive", k, p)
+		}
+	}
+	if k := "apm_config.latency_sampler.tps"; coreconfig.Datadog.IsSet(k) {
+		c.LatencySamplerTPS = coreconfig.Datadog.GetFloat64(k)
+	}
 
 	if coreconfig.Datadog.IsSet("apm_config.max_remote_traces_per_second") {
 		c.MaxRemoteTPS = coreconfig.Datadog.GetFloat64("apm_config.max_remote_traces_per_second")
@@ -257,6 +272,18 @@ func applyDatadogConfig(c *config.AgentConfig) error {
 		}
 	}
 
+	if k := "apm_config.filter_spans"; coreconfig.Datadog.IsSet(k) {
+		rules := make([]*config.SpanFilterRule, 0)
+		if err := coreconfig.Datadog.UnmarshalKey(k, &rules); err != nil {
+			log.Errorf(`Bad format for %q it should be of the form '[{"name":"name_pattern","service":"service_pattern","meta":{"key":"pattern"},"metrics":{"key":"> 1"}}]', error: %v`, k, err)
+		} else {
+			if err := compileSpanFilterRules(rules); err != nil {
+				osutil.Exitf("filter_spans: %s", err)
+			}
+			c.FilterSpans = rules
+		}
+	}
+
 	if coreconfig.Datadog.IsSet("bind_host") || coreconfig.Datadog.IsSet("apm_config.apm_non_local_traffic") {
 		if coreconfig.Datadog.IsSet("bind_host") {
 			host := coreconfig.Datadog.GetString("bind_host")
@@ -290,6 +317,8 @@ func applyDatadogConfig(c *config.AgentConfig) error {
 		SpanNameRemappings:     coreconfig.Datadog.GetStringMapString("otlp_config.traces.span_name_remappings"),
 		SpanNameAsResourceName: coreconfig.Datadog.GetBool("otlp_config.traces.span_name_as_resource_name"),
 	}
+	c.ZipkinReceiverEnabled = coreconfig.Datadog.GetBool("apm_config.zipkin_receiver.enabled")
+	c.JaegerReceiverEnabled = coreconfig.Datadog.GetBool("apm_config.jaeger_receiver.enabled")
 
 	if coreconfig.Datadog.GetBool("apm_config.telemetry.enabled") {
 		c.TelemetryConfig.Enabled = true
@@ -320,6 +349,19 @@ func applyDatadogConfig(c *config.AgentConfig) error {
 		if coreconfig.Datadog.IsSet("apm_config.obfuscation.credit_cards.luhn") {
 			c.Obfuscation.CreditCards.Luhn = coreconfig.Datadog.GetBool("apm_config.obfuscation.credit_cards.luhn")
 		}
+		if coreconfig.Datadog.IsSet("apm_config.obfuscation.pii.enabled") {
+			c.Obfuscation.PII.Enabled = coreconfig.Datadog.GetBool("apm_config.obfuscation.pii.enabled")
+		}
+	}
+	if err := compilePIIConfig(&c.Obfuscation.PII); err != nil {
+		osutil.Exitf("obfuscation.pii: %s", err)
+	}
+
+	if k := "apm_config.extra_aggregators"; coreconfig.Datadog.IsSet(k) {
+		c.ExtraAggregators = coreconfig.Datadog.GetStringSlice(k)
+	}
+	if k := "apm_config.extra_aggregators_max_values"; coreconfig.Datadog.IsSet(k) {
+		c.ExtraAggregatorsMaxValues = coreconfig.Datadog.GetInt(k)
 	}
 
 	if coreconfig.Datadog.IsSet("apm_config.filter_tags.require") {
@@ -355,6 +397,44 @@ func applyDatadogConfig(c *config.AgentConfig) error {
 	if coreconfig.Datadog.IsSet("apm_config.connection_reset_interval") {
 		c.ConnectionResetInterval = getDuration(coreconfig.Datadog.GetInt("apm_config.connection_reset_interval"))
 	}
+	if k := "apm_config.capture_path"; coreconfig.Datadog.IsSet(k) {
+		c.CapturePath = coreconfig.Datadog.GetString(k)
+	}
+	if k := "apm_config.capture_max_size_in_bytes"; coreconfig.Datadog.IsSet(k) {
+		if n := coreconfig.Datadog.GetInt64(k); n > 0 {
+			c.CaptureMaxSize = n
+		} else {
+			log.Warnf("Ignoring invalid %s %d: it must be positive", k, n)
+		}
+	}
+	if k := "apm_config.disk_retry.path"; coreconfig.Datadog.IsSet(k) {
+		c.DiskRetryPath = coreconfig.Datadog.GetString(k)
+	}
+	if k := "apm_config.disk_retry.max_size_in_bytes"; coreconfig.Datadog.IsSet(k) {
+		if n := coreconfig.Datadog.GetInt64(k); n > 0 {
+			c.DiskRetryMaxSize = n
+		} else {
+			log.Warnf("Ignoring invalid %s %d: it must be positive", k, n)
+		}
+	}
+	if k := "apm_config.otlp_exporter.endpoint"; coreconfig.Datadog.IsSet(k) {
+		c.OTLPExporter.Endpoint = coreconfig.Datadog.GetString(k)
+	}
+	if k := "apm_config.otlp_exporter.protocol"; coreconfig.Datadog.IsSet(k) {
+		c.OTLPExporter.Protocol = strings.ToLower(coreconfig.Datadog.GetString(k))
+	}
+	if k := "apm_config.otlp_exporter.headers"; coreconfig.Datadog.IsSet(k) {
+		c.OTLPExporter.Headers = coreconfig.Datadog.GetStringMapString(k)
+	}
+	if k := "apm_config.otlp_exporter.insecure"; coreconfig.Datadog.IsSet(k) {
+		c.OTLPExporter.Insecure = coreconfig.Datadog.GetBool(k)
+	}
+	if k := "apm_config.otlp_exporter.queue_size"; coreconfig.Datadog.IsSet(k) {
+		c.OTLPExporter.QueueSize = coreconfig.Datadog.GetInt(k)
+	}
+	if err := validateOTLPExporterConfig(c.OTLPExporter); err != nil {
+		osutil.Exitf("otlp_exporter: %s", err)
+	}
 	if coreconfig.Datadog.IsSet("apm_config.sync_flushing") {
 		c.SynchronousFlushing = coreconfig.Datadog.GetBool("apm_config.sync_flushing")
 	}
@@ -517,6 +597,102 @@ func compileReplaceRules(rules []*config.ReplaceRule) error {
 	return nil
 }
 
+// compileSpanFilterRules compiles the regular expressions and comparisons found in the
+// span filter rules. If it fails it returns the first error.
+func compileSpanFilterRules(rules []*config.SpanFilterRule) error {
+	compile := func(field, pattern string) (*regexp.Regexp, error) {
+		if pattern == "" {
+			return nil, nil
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		return re, nil
+	}
+	for i, r := range rules {
+		if r.Name == "" && r.Service == "" && r.Resource == "" && len(r.Meta) == 0 && len(r.Metrics) == 0 {
+			return fmt.Errorf("rule %d: all rules must have at least one condition", i)
+		}
+		var err error
+		if r.NameRe, err = compile("name", r.Name); err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+		if r.ServiceRe, err = compile("service", r.Service); err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+		if r.ResourceRe, err = compile("resource", r.Resource); err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+		r.MetaRe = make(map[string]*regexp.Regexp, len(r.Meta))
+		for k, pattern := range r.Meta {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("rule %d: meta %q: %s", i, k, err)
+			}
+			r.MetaRe[k] = re
+		}
+		r.MetricsCmp = make(map[string]config.MetricCondition, len(r.Metrics))
+		for k, cmp := range r.Metrics {
+			c, err := config.ParseMetricCondition(cmp)
+			if err != nil {
+				return fmt.Errorf("rule %d: metrics %q: %s", i, k, err)
+			}
+			r.MetricsCmp[k] = c
+		}
+	}
+	return nil
+}
+
+// compilePIIConfig validates the names of the PII detectors and compiles the regular
+// expressions of the custom ones. If it fails it returns the first error.
+func compilePIIConfig(cfg *config.PIIObfuscationConfig) error {
+	for _, name := range cfg.Detectors {
+		if _, ok := obfuscate.BuiltinPIIDetector(name); !ok {
+			return fmt.Errorf("unknown detector %q, valid detectors are: %s", name, strings.Join(obfuscate.BuiltinPIIDetectorNames(), ", "))
+		}
+	}
+	for i, p := range cfg.CustomPatterns {
+		if p.Name == "" {
+			return fmt.Errorf(`custom pattern %d: all custom patterns must have a "name"`, i)
+		}
+		if p.Pattern == "" {
+			return fmt.Errorf(`custom pattern %q: all custom patterns must have a "pattern"`, p.Name)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("custom pattern %q: %s", p.Name, err)
+		}
+		p.Re = re
+	}
+	return nil
+}
+
+// validateOTLPExporterConfig validates the protocol and the endpoint of the OTLP exporter,
+// if it is enabled.
+func validateOTLPExporterConfig(cfg *config.OTLPExporterConfig) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	switch cfg.Protocol {
+	case "http":
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return fmt.Errorf("endpoint: %s", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf(`endpoint %q: the URL must start with "http://" or "https://"`, cfg.Endpoint)
+		}
+	case "grpc":
+		if _, _, err := net.SplitHostPort(cfg.Endpoint); err != nil {
+			return fmt.Errorf("endpoint %q: the address must be of the form host:port", cfg.Endpoint)
+		}
+	default:
+		return fmt.Errorf(`unknown protocol %q, valid protocols are: http, grpc`, cfg.Protocol)
+	}
+	return nil
+}
+
 // getDuration returns the duration of the provided value in seconds
 func getDuration(seconds int) time.Duration {
 	return time.Duration(seconds) * time.Second
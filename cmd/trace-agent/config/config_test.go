@@ -56,6 +56,72 @@ func TestParseRepaceRules(t *testing.T) {
 	}
 }
 
+func TestCompileSpanFilterRules(t *testing.T) {
+	assert := assert.New(t)
+	rules := []*config.SpanFilterRule{
+		{Name: "^redis", Meta: map[string]string{"cache.op": "get"}},
+		{Resource: "GET /health", Metrics: map[string]string{"http.status_code": ">= 200", "cached": "1"}},
+	}
+	assert.NoError(compileSpanFilterRules(rules))
+	assert.Equal("^redis", rules[0].NameRe.String())
+	assert.Nil(rules[0].ServiceRe)
+	assert.Equal("get", rules[0].MetaRe["cache.op"].String())
+	assert.Equal("GET /health", rules[1].ResourceRe.String())
+	assert.Equal(map[string]config.MetricCondition{
+		"http.status_code": {Op: ">=", Value: 200},
+		"cached":           {Op: "==", Value: 1},
+	}, rules[1].MetricsCmp)
+
+	for _, rule := range []*config.SpanFilterRule{
+		{},
+		{Service: "("},
+		{Meta: map[string]string{"k": "["}},
+		{Metrics: map[string]string{"k": "> high"}},
+		{Metrics: map[string]string{"k": "=< 1"}},
+	} {
+		assert.Error(compileSpanFilterRules([]*config.SpanFilterRule{rule}), rule)
+	}
+}
+
+func TestCompilePIIConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.PIIObfuscationConfig{
+		Detectors:      []string{"email", "iban"},
+		CustomPatterns: []*config.PIIPattern{{Name: "employee_id", Pattern: `EMP-\d{6}`}},
+	}
+	assert.NoError(compilePIIConfig(&cfg))
+	assert.Equal(`EMP-\d{6}`, cfg.CustomPatterns[0].Re.String())
+
+	for _, cfg := range []config.PIIObfuscationConfig{
+		{Detectors: []string{"email", "unknown"}},
+		{CustomPatterns: []*config.PIIPattern{{Pattern: "x"}}},
+		{CustomPatterns: []*config.PIIPattern{{Name: "x"}}},
+		{CustomPatterns: []*config.PIIPattern{{Name: "x", Pattern: "("}}},
+	} {
+		assert.Error(compilePIIConfig(&cfg), cfg)
+	}
+}
+
+func TestValidateOTLPExporterConfig(t *testing.T) {
+	assert := assert.New(t)
+	for _, cfg := range []*config.OTLPExporterConfig{
+		{Protocol: "unknown"},
+		{Endpoint: "http://localhost:4318/v1/traces", Protocol: "http"},
+		{Endpoint: "https://otel.example.com/v1/traces", Protocol: "http"},
+		{Endpoint: "localhost:4317", Protocol: "grpc"},
+	} {
+		assert.NoError(validateOTLPExporterConfig(cfg), cfg)
+	}
+	for _, cfg := range []*config.OTLPExporterConfig{
+		{Endpoint: "localhost:4318", Protocol: "http"},
+		{Endpoint: "http://local host", Protocol: "http"},
+		{Endpoint: "localhost", Protocol: "grpc"},
+		{Endpoint: "localhost:4317", Protocol: "thrift"},
+	} {
+		assert.Error(validateOTLPExporterConfig(cfg), cfg)
+	}
+}
+
 func TestSplitTag(t *testing.T) {
 	for _, tt := range []struct {
 		tag string
@@ -487,8 +553,17 @@ func TestFullYamlConfig(t *testing.T) {
 	assert.True(o.RemoveStackTraces)
 	assert.True(o.Redis.Enabled)
 	assert.True(o.Memcached.Enabled)
+	assert.True(o.GraphQL.Enabled)
+	assert.True(o.GraphQL.RemoveAliases)
 	assert.True(o.CreditCards.Enabled)
 	assert.True(o.CreditCards.Luhn)
+	assert.True(o.PII.Enabled)
+	assert.Equal([]string{"email", "jwt"}, o.PII.Detectors)
+	assert.Equal([]string{"*"}, o.PII.Tags)
+	assert.Equal([]string{"http.route"}, o.PII.KeepTags)
+	assert.Len(o.PII.CustomPatterns, 1)
+	assert.Equal("employee_id", o.PII.CustomPatterns[0].Name)
+	assert.Equal(`EMP-\d{6}`, o.PII.CustomPatterns[0].Re.String())
 }
 
 func TestUndocumentedYamlConfig(t *testing.T) {
@@ -788,6 +863,108 @@ func TestLoadEnv(t *testing.T) {
 		})
 	}
 
+	env = "DD_APM_EXTRA_AGGREGATORS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "peer.service,db.instance")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		err = os.Setenv("DD_APM_EXTRA_AGGREGATORS_MAX_VALUES", "20")
+		assert.NoError(err)
+		defer os.Unsetenv("DD_APM_EXTRA_AGGREGATORS_MAX_VALUES")
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal([]string{"peer.service", "db.instance"}, cfg.ExtraAggregators)
+		assert.Equal(20, cfg.ExtraAggregatorsMaxValues)
+	})
+
+	for _, env := range []string{"DD_APM_ZIPKIN_RECEIVER_ENABLED", "DD_APM_JAEGER_RECEIVER_ENABLED"} {
+		t.Run(env, func(t *testing.T) {
+			defer cleanConfig()()
+			assert := assert.New(t)
+			assert.NoError(os.Setenv(env, "true"))
+			defer os.Unsetenv(env)
+			cfg, err := LoadConfigFile("./testdata/full.yaml")
+			assert.NoError(err)
+			assert.Equal(env == "DD_APM_ZIPKIN_RECEIVER_ENABLED", cfg.ZipkinReceiverEnabled)
+			assert.Equal(env == "DD_APM_JAEGER_RECEIVER_ENABLED", cfg.JaegerReceiverEnabled)
+		})
+	}
+
+	env = "DD_APM_DISK_RETRY_PATH"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "/var/lib/datadog-agent/apm-retry")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		err = os.Setenv("DD_APM_DISK_RETRY_MAX_SIZE_IN_BYTES", "1024")
+		assert.NoError(err)
+		defer os.Unsetenv("DD_APM_DISK_RETRY_MAX_SIZE_IN_BYTES")
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal("/var/lib/datadog-agent/apm-retry", cfg.DiskRetryPath)
+		assert.EqualValues(1024, cfg.DiskRetryMaxSize)
+	})
+
+	env = "DD_APM_CAPTURE_MAX_SIZE_IN_BYTES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "2048")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.EqualValues(2048, cfg.CaptureMaxSize)
+	})
+
+	env = "DD_APM_OTLP_EXPORTER_ENDPOINT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		for k, v := range map[string]string{
+			env:                               "otel-collector:4317",
+			"DD_APM_OTLP_EXPORTER_PROTOCOL":   "GRPC",
+			"DD_APM_OTLP_EXPORTER_HEADERS":    `{"x-tenant":"apm","authorization":"Bearer token"}`,
+			"DD_APM_OTLP_EXPORTER_INSECURE":   "true",
+			"DD_APM_OTLP_EXPORTER_QUEUE_SIZE": "20",
+		} {
+			assert.NoError(os.Setenv(k, v))
+			defer os.Unsetenv(k)
+		}
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.OTLPExporter.Enabled())
+		assert.Equal(&config.OTLPExporterConfig{
+			Endpoint:  "otel-collector:4317",
+			Protocol:  "grpc",
+			Headers:   map[string]string{"x-tenant": "apm", "authorization": "Bearer token"},
+			Insecure:  true,
+			QueueSize: 20,
+		}, cfg.OTLPExporter)
+	})
+
+	env = "DD_APM_LATENCY_SAMPLER_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		for k, v := range map[string]string{
+			env:                                 "true",
+			"DD_APM_LATENCY_SAMPLER_PERCENTILE": "0.95",
+			"DD_APM_LATENCY_SAMPLER_TPS":        "2.5",
+		} {
+			assert.NoError(os.Setenv(k, v))
+			defer os.Unsetenv(k)
+		}
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.LatencySamplerEnabled)
+		assert.Equal(0.95, cfg.LatencySamplerPercentile)
+		assert.Equal(2.5, cfg.LatencySamplerTPS)
+	})
+
 	env = "DD_APM_ANALYZED_SPANS"
 	t.Run(env, func(t *testing.T) {
 		defer cleanConfig()()
@@ -827,6 +1004,22 @@ func TestLoadEnv(t *testing.T) {
 		assert.Contains(cfg.ReplaceTags, rule2)
 	})
 
+	env = "DD_APM_FILTER_SPANS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, `[{"name":"^redis","meta":{"cache.op":"get"}},{"service":"web","metrics":{"cached":"== 1"}}]`)
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Len(cfg.FilterSpans, 2)
+		assert.Equal("^redis", cfg.FilterSpans[0].NameRe.String())
+		assert.Equal("get", cfg.FilterSpans[0].MetaRe["cache.op"].String())
+		assert.Equal("web", cfg.FilterSpans[1].ServiceRe.String())
+		assert.Equal(config.MetricCondition{Op: "==", Value: 1}, cfg.FilterSpans[1].MetricsCmp["cached"])
+	})
+
 	env = "DD_APM_FILTER_TAGS_REQUIRE"
 	t.Run(env, func(t *testing.T) {
 		defer cleanConfig()()
@@ -1037,6 +1230,19 @@ func TestLoadEnv(t *testing.T) {
 		assert.False(coreconfig.Datadog.GetBool("apm_config.obfuscation.credit_cards.luhn"))
 	})
 
+	env = "DD_APM_OBFUSCATION_PII_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "false")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		c, err := LoadConfigFile("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.False(coreconfig.Datadog.GetBool("apm_config.obfuscation.pii.enabled"))
+		assert.False(c.Obfuscation.PII.Enabled)
+	})
+
 	env = "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS"
 	t.Run(env, func(t *testing.T) {
 		defer cleanConfig()()
@@ -5,7 +5,10 @@
 
 package flags
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 var (
 	// ConfigPath specifies the path to the configuration file.
@@ -20,6 +23,16 @@ var (
 	// Info will display information about a running agent.
 	Info bool
 
+	// Capture specifies the duration of a capture of the traces and stats received
+	// by a running agent. When zero, no capture is started.
+	Capture time.Duration
+
+	// Replay specifies the path to a capture file to replay to a running agent.
+	Replay string
+
+	// ReplaySpeed specifies the speed of the replay relative to the capture.
+	ReplaySpeed float64
+
 	// CPUProfile specifies the path to output CPU profiling information to.
 	// When empty, CPU profiling is disabled.
 	CPUProfile string
@@ -44,6 +57,11 @@ func init() {
 	flag.BoolVar(&Version, "version", false, "Show version information and exit")
 	flag.BoolVar(&Info, "info", false, "Show info about running trace agent process and exit")
 
+	// traffic capture
+	flag.DurationVar(&Capture, "capture", 0, "Capture the traces and stats received by the running trace agent for the given `duration` and exit")
+	flag.StringVar(&Replay, "replay", "", "Replay the capture `file` to the running trace agent and exit")
+	flag.Float64Var(&ReplaySpeed, "replay-speed", 1, "Speed of the replay relative to the capture, 0 replays as fast as possible")
+
 	// profiling
 	flag.StringVar(&CPUProfile, "cpuprofile", "", "Write cpu profile to file")
 	flag.StringVar(&MemProfile, "memprofile", "", "Write memory profile to `file`")
@@ -85,6 +85,20 @@ func Run(ctx context.Context) {
 		return
 	}
 
+	if flags.Capture != 0 {
+		if err := runCapture(os.Stdout, cfg, flags.Capture); err != nil {
+			osutil.Exitf("Failed to start capture: %s", err)
+		}
+		return
+	}
+
+	if flags.Replay != "" {
+		if err := runReplay(ctx, os.Stdout, cfg, flags.Replay, flags.ReplaySpeed); err != nil {
+			osutil.Exitf("Failed to replay capture: %s", err)
+		}
+		return
+	}
+
 	if err := coreconfig.SetupLogger(
 		coreconfig.LoggerName("TRACE"),
 		coreconfig.Datadog.GetString("log_level"),
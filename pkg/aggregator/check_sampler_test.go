@@ -287,6 +287,49 @@ func TestCheckHistogramBucketSampling(t *testing.T) {
 	testWithTagsStore(t, testCheckHistogramBucketSampling)
 }
 
+func testCheckDistributionSampling(t *testing.T, store *tags.Store) {
+	checkSampler := newCheckSampler(1, true, 1*time.Second, store)
+
+	mSample1 := metrics.MetricSample{
+		Name:       "my.distribution",
+		Value:      1,
+		Mtype:      metrics.DistributionType,
+		Tags:       []string{"foo", "bar"},
+		SampleRate: 1,
+		Timestamp:  12345.0,
+	}
+	mSample2 := mSample1
+	mSample2.Value = 10
+	mSample3 := mSample1
+	mSample3.Value = 5
+
+	checkSampler.addSample(&mSample1)
+	checkSampler.addSample(&mSample2)
+	checkSampler.addSample(&mSample3)
+
+	checkSampler.commit(12346.0)
+	series, sketches := checkSampler.flush()
+
+	// distributions are only aggregated in sketches
+	assert.Len(t, series, 0)
+	require.Len(t, sketches, 1)
+
+	expSketch := &quantile.Sketch{}
+	expSketch.Insert(quantile.Default(), 1, 10, 5)
+
+	metrics.AssertSketchSeriesEqual(t, &metrics.SketchSeries{
+		Name: "my.distribution",
+		Tags: tagset.CompositeTagsFromSlice([]string{"foo", "bar"}),
+		Points: []metrics.SketchPoint{
+			{Ts: 12345, Sketch: expSketch},
+		},
+		ContextKey: generateContextKey(&mSample1),
+	}, sketches[0])
+}
+func TestCheckDistributionSampling(t *testing.T) {
+	testWithTagsStore(t, testCheckDistributionSampling)
+}
+
 func testCheckHistogramBucketDontFlushFirstValue(t *testing.T, store *tags.Store) {
 	checkSampler := newCheckSampler(1, true, 1*time.Second, store)
 
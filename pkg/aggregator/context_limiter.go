@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// overflowTag replaces the metric tags of the samples whose context is over
+// the limit, collapsing them into a single context.
+const overflowTag = "overflow:true"
+
+type contextLimiterKey struct {
+	name   string
+	origin ckey.TagsKey
+}
+
+// contextLimiter enforces a maximum number of contexts per metric name, and
+// optionally per origin, in a contextResolver. The origin of a context is
+// identified by the tags added by the tagger.
+//
+// It is not thread safe, it's meant to be used by a single contextResolver.
+type contextLimiter struct {
+	limit     int
+	perOrigin bool
+
+	countsByKey map[contextLimiterKey]int
+	// overflowsByName counts the samples sent to the overflow contexts since
+	// the last call to flushOverflows.
+	overflowsByName map[string]uint64
+}
+
+// newContextLimiter returns a contextLimiter, or nil if limit is not positive.
+func newContextLimiter(limit int, perOrigin bool) *contextLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &contextLimiter{
+		limit:           limit,
+		perOrigin:       perOrigin,
+		countsByKey:     make(map[contextLimiterKey]int),
+		overflowsByName: make(map[string]uint64),
+	}
+}
+
+// newContextLimiterFromConfig returns the contextLimiter for a time sampler,
+// or nil if the limiter is disabled.
+func newContextLimiterFromConfig() *contextLimiter {
+	return newContextLimiter(
+		config.Datadog.GetInt("dogstatsd_context_limiter.metric_limit"),
+		config.Datadog.GetBool("dogstatsd_context_limiter.per_origin"),
+	)
+}
+
+func (l *contextLimiter) key(name string, origin ckey.TagsKey) contextLimiterKey {
+	if !l.perOrigin {
+		origin = 0
+	}
+	return contextLimiterKey{name: name, origin: origin}
+}
+
+// track returns true and accounts for a new context if the metric is below
+// its limit. Otherwise, it returns false and counts the sample as an overflow.
+func (l *contextLimiter) track(name string, origin ckey.TagsKey) bool {
+	key := l.key(name, origin)
+	if l.countsByKey[key] >= l.limit {
+		l.overflowsByName[name]++
+		return false
+	}
+	l.countsByKey[key]++
+	return true
+}
+
+// remove releases a context previously accounted for by track.
+func (l *contextLimiter) remove(name string, origin ckey.TagsKey) {
+	key := l.key(name, origin)
+	if count := l.countsByKey[key]; count > 1 {
+		l.countsByKey[key] = count - 1
+	} else {
+		delete(l.countsByKey, key)
+	}
+}
+
+// flushOverflows returns the samples sent to the overflow contexts by metric
+// name since the last call, and resets them.
+func (l *contextLimiter) flushOverflows() map[string]uint64 {
+	overflows := l.overflowsByName
+	l.overflowsByName = make(map[string]uint64)
+	return overflows
+}
@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+// +build test
+
+package aggregator
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+)
+
+func TestContextLimiterDisabled(t *testing.T) {
+	assert.Nil(t, newContextLimiter(0, false))
+	assert.Nil(t, newContextLimiter(-1, true))
+}
+
+func TestContextLimiterPerOrigin(t *testing.T) {
+	for _, perOrigin := range []bool{false, true} {
+		l := newContextLimiter(1, perOrigin)
+
+		assert.True(t, l.track("foo", ckey.TagsKey(1)))
+		assert.False(t, l.track("foo", ckey.TagsKey(1)))
+		assert.Equal(t, perOrigin, l.track("foo", ckey.TagsKey(2)))
+		assert.True(t, l.track("bar", ckey.TagsKey(1)))
+
+		l.remove("foo", ckey.TagsKey(1))
+		assert.True(t, l.track("foo", ckey.TagsKey(1)))
+
+		expected := map[string]uint64{"foo": 1}
+		if !perOrigin {
+			expected["foo"] = 2
+		}
+		assert.Equal(t, expected, l.flushOverflows())
+	}
+}
+
+func TestContextsOverLimitTelemetry(t *testing.T) {
+	// this test IS USING globals (tagsetTlm)
+	tlm := tagsetTlm
+	tlm.reset()
+	defer tlm.reset()
+
+	overflows := map[string]uint64{}
+	for i := 0; i < contextsOverLimitTop+2; i++ {
+		overflows[string(rune('a'+i))] = uint64(i + 1)
+	}
+	tlm.updateContextsOverLimitTelemetry(overflows)
+	tlm.updateContextsOverLimitTelemetry(map[string]uint64{"a": 100})
+	tlm.updateContextsOverLimitTelemetry(nil)
+
+	top := aggregatorExpvars.Get("MetricTags").(expvar.Func).Value().(map[string]map[string]uint64)["ContextsOverLimit"]
+	assert.Len(t, top, contextsOverLimitTop)
+	assert.Equal(t, uint64(101), top["a"])
+	assert.Equal(t, uint64(contextsOverLimitTop+2), top[string(rune('a'+contextsOverLimitTop+1))])
+	assert.NotContains(t, top, "b")
+	assert.NotContains(t, top, "c")
+}
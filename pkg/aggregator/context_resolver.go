@@ -21,6 +21,10 @@ type Context struct {
 	mtype      metrics.MetricType
 	taggerTags *tags.Entry
 	metricTags *tags.Entry
+	// originKey is the key of the tagger tags, used by the contextLimiter
+	originKey ckey.TagsKey
+	// overflow is true for the contexts collecting the samples over the limit
+	overflow bool
 }
 
 // Tags returns tags for the context.
@@ -41,6 +45,9 @@ type contextResolver struct {
 	keyGenerator  *ckey.KeyGenerator
 	taggerBuffer  *tagset.HashingTagsAccumulator
 	metricBuffer  *tagset.HashingTagsAccumulator
+	// limiter is optional, if set new contexts over the limit are collapsed into
+	// an overflow context.
+	limiter *contextLimiter
 }
 
 // generateContextKey generates the contextKey associated with the context of the metricSample
@@ -48,7 +55,7 @@ func (cr *contextResolver) generateContextKey(metricSampleContext metrics.Metric
 	return cr.keyGenerator.GenerateWithTags2(metricSampleContext.GetName(), metricSampleContext.GetHost(), cr.taggerBuffer, cr.metricBuffer)
 }
 
-func newContextResolver(cache *tags.Store) *contextResolver {
+func newContextResolver(cache *tags.Store, limiter *contextLimiter) *contextResolver {
 	return &contextResolver{
 		contextsByKey: make(map[ckey.ContextKey]*Context),
 		countsByMtype: make([]uint64, metrics.NumMetricTypes),
@@ -56,6 +63,7 @@ func newContextResolver(cache *tags.Store) *contextResolver {
 		keyGenerator:  ckey.NewKeyGenerator(),
 		taggerBuffer:  tagset.NewHashingTagsAccumulator(),
 		metricBuffer:  tagset.NewHashingTagsAccumulator(),
+		limiter:       limiter,
 	}
 }
 
@@ -65,15 +73,29 @@ func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSample
 	contextKey, taggerKey, metricKey := cr.generateContextKey(metricSampleContext) // the generator will remove duplicates (and doesn't mind the order)
 
 	if _, ok := cr.contextsByKey[contextKey]; !ok {
-		mtype := metricSampleContext.GetMetricType()
-		cr.contextsByKey[contextKey] = &Context{
-			Name:       metricSampleContext.GetName(),
-			taggerTags: cr.tagsCache.Insert(taggerKey, cr.taggerBuffer),
-			metricTags: cr.tagsCache.Insert(metricKey, cr.metricBuffer),
-			Host:       metricSampleContext.GetHost(),
-			mtype:      mtype,
+		overflow := false
+		if cr.limiter != nil && !cr.limiter.track(metricSampleContext.GetName(), taggerKey) {
+			// collapse the sample into the overflow context of the metric, which
+			// keeps the origin tags but none of the metric tags
+			overflow = true
+			cr.metricBuffer.Reset()
+			cr.metricBuffer.Append(overflowTag)
+			contextKey, taggerKey, metricKey = cr.generateContextKey(metricSampleContext)
+		}
+
+		if _, ok := cr.contextsByKey[contextKey]; !ok {
+			mtype := metricSampleContext.GetMetricType()
+			cr.contextsByKey[contextKey] = &Context{
+				Name:       metricSampleContext.GetName(),
+				taggerTags: cr.tagsCache.Insert(taggerKey, cr.taggerBuffer),
+				metricTags: cr.tagsCache.Insert(metricKey, cr.metricBuffer),
+				Host:       metricSampleContext.GetHost(),
+				mtype:      mtype,
+				originKey:  taggerKey,
+				overflow:   overflow,
+			}
+			cr.countsByMtype[mtype]++
 		}
-		cr.countsByMtype[mtype]++
 	}
 
 	cr.taggerBuffer.Reset()
@@ -98,6 +120,9 @@ func (cr *contextResolver) removeKeys(expiredContextKeys []ckey.ContextKey) {
 
 		if context != nil {
 			cr.countsByMtype[context.mtype]--
+			if cr.limiter != nil && !context.overflow {
+				cr.limiter.remove(context.Name, context.originKey)
+			}
 			context.release()
 		}
 	}
@@ -115,9 +140,9 @@ type timestampContextResolver struct {
 	lastSeenByKey map[ckey.ContextKey]float64
 }
 
-func newTimestampContextResolver(cache *tags.Store) *timestampContextResolver {
+func newTimestampContextResolver(cache *tags.Store, limiter *contextLimiter) *timestampContextResolver {
 	return &timestampContextResolver{
-		resolver:      newContextResolver(cache),
+		resolver:      newContextResolver(cache, limiter),
 		lastSeenByKey: make(map[ckey.ContextKey]float64),
 	}
 }
@@ -152,6 +177,15 @@ func (cr *timestampContextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	return cr.resolver.get(key)
 }
 
+// flushOverflows returns the samples sent to overflow contexts by metric name
+// since the last call, nil if no limit is enforced.
+func (cr *timestampContextResolver) flushOverflows() map[string]uint64 {
+	if cr.resolver.limiter == nil {
+		return nil
+	}
+	return cr.resolver.limiter.flushOverflows()
+}
+
 // expireContexts cleans up the contexts that haven't been tracked since the given timestamp
 // and returns the associated contextKeys.
 // keep can be used to retain contexts longer than their natural expiration time based on some condition.
@@ -186,7 +220,7 @@ type countBasedContextResolver struct {
 
 func newCountBasedContextResolver(expireCountInterval int, cache *tags.Store) *countBasedContextResolver {
 	return &countBasedContextResolver{
-		resolver:            newContextResolver(cache),
+		resolver:            newContextResolver(cache, nil),
 		expireCountByKey:    make(map[ckey.ContextKey]int64),
 		expireCount:         0,
 		expireCountInterval: int64(expireCountInterval),
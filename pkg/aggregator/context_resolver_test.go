@@ -78,7 +78,7 @@ func testTrackContext(t *testing.T, store *tags.Store) {
 		SampleRate: 1,
 	}
 
-	contextResolver := newContextResolver(store)
+	contextResolver := newContextResolver(store, nil)
 
 	// Track the 2 contexts
 	contextKey1 := contextResolver.trackContext(&mSample1)
@@ -122,7 +122,7 @@ func testExpireContexts(t *testing.T, store *tags.Store) {
 		Tags:       []string{"foo", "bar", "baz"},
 		SampleRate: 1,
 	}
-	contextResolver := newTimestampContextResolver(store)
+	contextResolver := newTimestampContextResolver(store, nil)
 
 	// Track the 2 contexts
 	contextKey1 := contextResolver.trackContext(&mSample1, 4)
@@ -166,7 +166,7 @@ func testExpireContextsWithKeep(t *testing.T, store *tags.Store) {
 		Tags:       []string{"foo", "bar", "baz"},
 		SampleRate: 1,
 	}
-	contextResolver := newTimestampContextResolver(store)
+	contextResolver := newTimestampContextResolver(store, nil)
 
 	// Track the 2 contexts
 	contextKey1 := contextResolver.trackContext(&mSample1, 4)
@@ -244,7 +244,7 @@ func TestCountBasedExpireContexts(t *testing.T) {
 }
 
 func testTagDeduplication(t *testing.T, store *tags.Store) {
-	resolver := newContextResolver(store)
+	resolver := newContextResolver(store, nil)
 
 	ckey := resolver.trackContext(&metrics.MetricSample{
 		Name: "foo",
@@ -257,3 +257,51 @@ func testTagDeduplication(t *testing.T, store *tags.Store) {
 func TestTagDeduplication(t *testing.T) {
 	testWithTagsStore(t, testTagDeduplication)
 }
+
+func testContextLimiter(t *testing.T, store *tags.Store) {
+	contextResolver := newTimestampContextResolver(store, newContextLimiter(2, false))
+
+	sample := func(name string, tags ...string) *metrics.MetricSample {
+		return &metrics.MetricSample{
+			Name:       name,
+			Value:      1,
+			Mtype:      metrics.GaugeType,
+			Tags:       tags,
+			SampleRate: 1,
+		}
+	}
+
+	contextKey1 := contextResolver.trackContext(sample("my.metric.name", "foo:1"), 1)
+	contextKey2 := contextResolver.trackContext(sample("my.metric.name", "foo:2"), 1)
+	// the limit is per metric name
+	otherKey := contextResolver.trackContext(sample("my.other.metric", "foo:3"), 1)
+
+	// over the limit, both samples end up in the same context
+	contextKey3 := contextResolver.trackContext(sample("my.metric.name", "foo:3"), 2)
+	contextKey4 := contextResolver.trackContext(sample("my.metric.name", "foo:4"), 2)
+	assert.Equal(t, contextKey3, contextKey4)
+	assert.NotEqual(t, contextKey1, contextKey3)
+	assert.NotEqual(t, contextKey2, contextKey3)
+
+	overflowContext, ok := contextResolver.get(contextKey3)
+	require.True(t, ok)
+	assertContext(t, overflowContext, "my.metric.name", []string{overflowTag}, "")
+	assert.Equal(t, 4, contextResolver.length())
+
+	// existing contexts are still tracked
+	assert.Equal(t, contextKey1, contextResolver.trackContext(sample("my.metric.name", "foo:1"), 2))
+
+	assert.Equal(t, map[string]uint64{"my.metric.name": 2}, contextResolver.flushOverflows())
+	assert.Empty(t, contextResolver.flushOverflows())
+
+	// expiring a context makes room for a new one
+	assert.ElementsMatch(t, []ckey.ContextKey{contextKey2, otherKey}, contextResolver.expireContexts(2, nil))
+	contextKey5 := contextResolver.trackContext(sample("my.metric.name", "foo:5"), 3)
+	context5, ok := contextResolver.get(contextKey5)
+	require.True(t, ok)
+	assertContext(t, context5, "my.metric.name", []string{"foo:5"}, "")
+	assert.Empty(t, contextResolver.flushOverflows())
+}
+func TestContextLimiter(t *testing.T) {
+	testWithTagsStore(t, testContextLimiter)
+}
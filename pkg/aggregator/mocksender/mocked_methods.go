@@ -91,6 +91,11 @@ func (m *MockSender) SetCheckService(service string) {
 	m.Called(service)
 }
 
+//SetHistogramsAsDistributions enables the setting of histograms as distributions mock call.
+func (m *MockSender) SetHistogramsAsDistributions(all bool, metricNames []string) {
+	m.Called(all, metricNames)
+}
+
 //FinalizeCheckServiceTag enables the sending of check service tag mock call.
 func (m *MockSender) FinalizeCheckServiceTag() {
 	m.Called()
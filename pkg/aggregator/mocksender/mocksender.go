@@ -80,6 +80,7 @@ func (m *MockSender) SetupAcceptAll() {
 	m.On("DisableDefaultHostname", mock.AnythingOfType("bool")).Return()
 	m.On("SetCheckCustomTags", mock.AnythingOfType("[]string")).Return()
 	m.On("SetCheckService", mock.AnythingOfType("string")).Return()
+	m.On("SetHistogramsAsDistributions", mock.AnythingOfType("bool"), mock.AnythingOfType("[]string")).Return()
 	m.On("FinalizeCheckServiceTag").Return()
 	m.On("Commit").Return()
 }
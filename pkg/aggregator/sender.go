@@ -36,6 +36,7 @@ type Sender interface {
 	DisableDefaultHostname(disable bool)
 	SetCheckCustomTags(tags []string)
 	SetCheckService(service string)
+	SetHistogramsAsDistributions(all bool, metricNames []string)
 	FinalizeCheckServiceTag()
 	OrchestratorMetadata(msgs []serializer.ProcessMessageBody, clusterID string, nodeType int)
 	OrchestratorManifest(msgs []serializer.ProcessMessageBody, clusterID string)
@@ -67,6 +68,9 @@ type checkSender struct {
 	eventPlatformOut        chan<- senderEventPlatformEvent
 	checkTags               []string
 	service                 string
+	// histograms sent as distributions, either all of them or by metric name
+	allHistogramsAsDistributions bool
+	histogramsAsDistributions    map[string]struct{}
 }
 
 type senderMetricSample struct {
@@ -200,6 +204,17 @@ func (s *checkSender) SetCheckService(service string) {
 	s.service = service
 }
 
+// SetHistogramsAsDistributions makes the check histograms aggregated as distributions,
+// in sketches, instead of being aggregated into fixed aggregates and percentiles.
+// If all is false, only the histograms of the given metric names are affected.
+func (s *checkSender) SetHistogramsAsDistributions(all bool, metricNames []string) {
+	s.allHistogramsAsDistributions = all
+	s.histogramsAsDistributions = make(map[string]struct{}, len(metricNames))
+	for _, name := range metricNames {
+		s.histogramsAsDistributions[name] = struct{}{}
+	}
+}
+
 // FinalizeCheckServiceTag appends the service as a tag for metrics, events, and service checks
 func (s *checkSender) FinalizeCheckServiceTag() {
 	if s.service != "" {
@@ -294,9 +309,22 @@ func (s *checkSender) Counter(metric string, value float64, hostname string, tag
 	s.sendMetricSample(metric, value, hostname, tags, metrics.CounterType, false)
 }
 
+// histogramAsDistribution returns true if the histogram should be aggregated as a distribution
+func (s *checkSender) histogramAsDistribution(metric string) bool {
+	if s.allHistogramsAsDistributions {
+		return true
+	}
+	_, found := s.histogramsAsDistributions[metric]
+	return found
+}
+
 // Histogram should be used to track the statistical distribution of a set of values during a check run
 // Should be called multiple times on the same (metric, hostname, tags) so that a distribution can be computed
 func (s *checkSender) Histogram(metric string, value float64, hostname string, tags []string) {
+	if s.histogramAsDistribution(metric) {
+		s.sendMetricSample(metric, value, hostname, tags, metrics.DistributionType, false)
+		return
+	}
 	s.sendMetricSample(metric, value, hostname, tags, metrics.HistogramType, false)
 }
 
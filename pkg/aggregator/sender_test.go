@@ -205,6 +205,37 @@ func TestGetSenderServiceTagMetrics(t *testing.T) {
 	assert.Equal(t, append(checkTags, "service:service2"), sms.metricSample.Tags)
 }
 
+func TestGetSenderHistogramsAsDistributions(t *testing.T) {
+	// this test not using anything global
+	// -
+
+	s := initSender(checkID1, "")
+
+	s.sender.Histogram("my.histogram", 1.0, "", nil)
+	sms := <-s.senderMetricSampleChan
+	assert.Equal(t, metrics.HistogramType, sms.metricSample.Mtype)
+
+	// only the listed metrics
+	s.sender.SetHistogramsAsDistributions(false, []string{"my.histogram"})
+	s.sender.Histogram("my.histogram", 1.0, "", nil)
+	sms = <-s.senderMetricSampleChan
+	assert.Equal(t, metrics.DistributionType, sms.metricSample.Mtype)
+	s.sender.Histogram("my.other.histogram", 1.0, "", nil)
+	sms = <-s.senderMetricSampleChan
+	assert.Equal(t, metrics.HistogramType, sms.metricSample.Mtype)
+
+	// all histograms
+	s.sender.SetHistogramsAsDistributions(true, nil)
+	s.sender.Histogram("my.other.histogram", 1.0, "", nil)
+	sms = <-s.senderMetricSampleChan
+	assert.Equal(t, metrics.DistributionType, sms.metricSample.Mtype)
+
+	// historates are not affected
+	s.sender.Historate("my.histogram", 1.0, "", nil)
+	sms = <-s.senderMetricSampleChan
+	assert.Equal(t, metrics.HistorateType, sms.metricSample.Mtype)
+}
+
 func TestGetSenderServiceTagServiceCheck(t *testing.T) {
 	// this test not using anything global
 	// -
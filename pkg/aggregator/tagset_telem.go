@@ -7,6 +7,8 @@ package aggregator
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"go.uber.org/atomic"
 
@@ -38,8 +40,21 @@ type tagsetTelemetry struct {
 	// tlmHugeSketches is an array containing counters with the same values as
 	// hugeSketchesCount.
 	tlmHugeSketches []telemetry.Counter
+
+	// contextsOverLimit contains the total count of samples sent to overflow
+	// contexts by the context limiter, by metric name.
+	contextsOverLimitMu sync.Mutex
+	contextsOverLimit   map[string]uint64
+
+	// tlmContextsOverLimit has the same values as contextsOverLimit, summed
+	// across metrics.
+	tlmContextsOverLimit telemetry.Counter
 }
 
+// contextsOverLimitTop is the number of metrics reported in the expvars, the
+// ones with the most samples over the limit.
+const contextsOverLimitTop = 10
+
 func newTagsetTelemetry(thresholds []uint64) *tagsetTelemetry {
 	size := len(thresholds)
 	t := &tagsetTelemetry{
@@ -49,6 +64,9 @@ func newTagsetTelemetry(thresholds []uint64) *tagsetTelemetry {
 		tlmHugeSeries:     make([]telemetry.Counter, size, size),
 		hugeSketchesCount: make([]*atomic.Uint64, size, size),
 		tlmHugeSketches:   make([]telemetry.Counter, size, size),
+		contextsOverLimit: make(map[string]uint64),
+		tlmContextsOverLimit: telemetry.NewCounter("aggregator", "contexts_over_limit_samples", nil,
+			"Count of samples aggregated in an overflow context by the context limiter"),
 	}
 
 	for i, thresh := range t.sizeThresholds {
@@ -84,10 +102,55 @@ func (t *tagsetTelemetry) updateHugeSerieTelemetry(serie *metrics.Serie) {
 	t.updateTelemetry(tagsetSize, t.hugeSeriesCount, t.tlmHugeSeries)
 }
 
+// updateContextsOverLimitTelemetry adds the samples sent to overflow contexts,
+// by metric name, since the last flush of a time sampler.
+func (t *tagsetTelemetry) updateContextsOverLimitTelemetry(overflows map[string]uint64) {
+	if len(overflows) == 0 {
+		return
+	}
+
+	t.contextsOverLimitMu.Lock()
+	defer t.contextsOverLimitMu.Unlock()
+
+	for name, count := range overflows {
+		t.contextsOverLimit[name] += count
+		t.tlmContextsOverLimit.Add(float64(count))
+	}
+}
+
+// topContextsOverLimit returns the metrics with the most samples sent to
+// overflow contexts.
+func (t *tagsetTelemetry) topContextsOverLimit(n int) map[string]uint64 {
+	t.contextsOverLimitMu.Lock()
+	defer t.contextsOverLimitMu.Unlock()
+
+	names := make([]string, 0, len(t.contextsOverLimit))
+	for name := range t.contextsOverLimit {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if t.contextsOverLimit[names[i]] != t.contextsOverLimit[names[j]] {
+			return t.contextsOverLimit[names[i]] > t.contextsOverLimit[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+
+	top := make(map[string]uint64, len(names))
+	for _, name := range names {
+		top[name] = t.contextsOverLimit[name]
+	}
+	return top
+}
+
 func (t *tagsetTelemetry) exp() interface{} {
 	rv := map[string]map[string]uint64{
 		"Series":   {},
 		"Sketches": {},
+		// Only the top offenders are reported
+		"ContextsOverLimit": t.topContextsOverLimit(contextsOverLimitTop),
 	}
 
 	for i, thresh := range t.sizeThresholds {
@@ -14,4 +14,7 @@ func (t *tagsetTelemetry) reset() {
 		t.hugeSeriesCount[i].Store(0)
 		t.hugeSketchesCount[i].Store(0)
 	}
+	t.contextsOverLimitMu.Lock()
+	t.contextsOverLimit = make(map[string]uint64)
+	t.contextsOverLimitMu.Unlock()
 }
@@ -47,7 +47,7 @@ func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store) *TimeSa
 
 	s := &TimeSampler{
 		interval:                    interval,
-		contextResolver:             newTimestampContextResolver(cache),
+		contextResolver:             newTimestampContextResolver(cache, newContextLimiterFromConfig()),
 		metricsByTimestamp:          map[int64]metrics.ContextMetrics{},
 		counterLastSampledByContext: map[ckey.ContextKey]float64{},
 		sketchMap:                   make(sketchMap),
@@ -222,6 +222,8 @@ func (s *TimeSampler) flush(timestamp float64, series metrics.SerieSink, sketche
 	aggregatorDogstatsdContexts.Set(int64(totalContexts))
 	tlmDogstatsdContexts.Set(float64(totalContexts))
 
+	tagsetTlm.updateContextsOverLimitTelemetry(s.contextResolver.flushOverflows())
+
 	byMtype := s.contextResolver.countsByMtype()
 	for i, count := range byMtype {
 		mtype := metrics.MetricType(i).String()
@@ -102,12 +102,14 @@ type Config struct {
 
 // CommonInstanceConfig holds the reserved fields for the yaml instance data
 type CommonInstanceConfig struct {
-	MinCollectionInterval int      `yaml:"min_collection_interval"`
-	EmptyDefaultHostname  bool     `yaml:"empty_default_hostname"`
-	Tags                  []string `yaml:"tags"`
-	Service               string   `yaml:"service"`
-	Name                  string   `yaml:"name"`
-	Namespace             string   `yaml:"namespace"`
+	MinCollectionInterval            int      `yaml:"min_collection_interval"`
+	EmptyDefaultHostname             bool     `yaml:"empty_default_hostname"`
+	Tags                             []string `yaml:"tags"`
+	Service                          string   `yaml:"service"`
+	Name                             string   `yaml:"name"`
+	Namespace                        string   `yaml:"namespace"`
+	HistogramsAsDistributions        bool     `yaml:"histograms_as_distributions"`
+	HistogramsAsDistributionsMetrics []string `yaml:"histograms_as_distributions_metrics"`
 }
 
 // CommonGlobalConfig holds the reserved fields for the yaml init_config data
@@ -134,6 +134,16 @@ func (c *CheckBase) CommonConfigure(initConfig, instanceConfig integration.Data,
 			s.SetCheckService(commonOptions.Service)
 		}
 
+		// Aggregate histograms as distributions if specified
+		if commonOptions.HistogramsAsDistributions || len(commonOptions.HistogramsAsDistributionsMetrics) > 0 {
+			s, err := c.GetSender()
+			if err != nil {
+				log.Errorf("failed to retrieve a sender for check %s: %s", string(c.ID()), err)
+				return err
+			}
+			s.SetHistogramsAsDistributions(commonOptions.HistogramsAsDistributions, commonOptions.HistogramsAsDistributionsMetrics)
+		}
+
 		c.source = source
 		return nil
 	}
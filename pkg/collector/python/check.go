@@ -259,6 +259,16 @@ func (c *PythonCheck) Configure(data integration.Data, initConfig integration.Da
 		}
 	}
 
+	// Aggregate histograms as distributions if specified
+	if commonOptions.HistogramsAsDistributions || len(commonOptions.HistogramsAsDistributionsMetrics) > 0 {
+		s, err := aggregator.GetSender(c.id)
+		if err != nil {
+			log.Errorf("failed to retrieve a sender for check %s: %s", string(c.id), err)
+		} else {
+			s.SetHistogramsAsDistributions(commonOptions.HistogramsAsDistributions, commonOptions.HistogramsAsDistributionsMetrics)
+		}
+	}
+
 	cInitConfig := TrackedCString(string(initConfig))
 	cInstance := TrackedCString(string(data))
 	cCheckID := TrackedCString(string(c.id))
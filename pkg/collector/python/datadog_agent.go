@@ -238,6 +238,8 @@ type sqlConfig struct {
 	KeepSQLAlias bool `json:"keep_sql_alias"`
 	// DollarQuotedFunc specifies whether or not to remove $func$ strings in postgres.
 	DollarQuotedFunc bool `json:"dollar_quoted_func"`
+	// Signature specifies whether the obfuscator should compute and return the signature of the query as SQL metadata.
+	Signature bool `json:"signature"`
 	// ReturnJSONMetadata specifies whether the stub will return metadata as JSON.
 	ReturnJSONMetadata bool `json:"return_json_metadata"`
 }
@@ -265,6 +267,7 @@ func ObfuscateSQL(rawQuery, opts *C.char, errResult **C.char) *C.char {
 		ReplaceDigits:    sqlOpts.ReplaceDigits,
 		KeepSQLAlias:     sqlOpts.KeepSQLAlias,
 		DollarQuotedFunc: sqlOpts.DollarQuotedFunc,
+		Signature:        sqlOpts.Signature,
 	})
 	if err != nil {
 		// memory will be freed by caller
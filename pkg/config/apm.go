@@ -37,6 +37,12 @@ func setupAPM(config Config) {
 	config.SetKnown("apm_config.obfuscation.remove_stack_traces")
 	config.SetKnown("apm_config.obfuscation.redis.enabled")
 	config.SetKnown("apm_config.obfuscation.memcached.enabled")
+	config.SetKnown("apm_config.obfuscation.graphql.enabled")
+	config.SetKnown("apm_config.obfuscation.graphql.remove_aliases")
+	config.SetKnown("apm_config.obfuscation.pii.detectors")
+	config.SetKnown("apm_config.obfuscation.pii.custom_patterns")
+	config.SetKnown("apm_config.obfuscation.pii.tags")
+	config.SetKnown("apm_config.obfuscation.pii.keep_tags")
 	config.SetKnown("apm_config.filter_tags.require")
 	config.SetKnown("apm_config.filter_tags.reject")
 	config.SetKnown("apm_config.extra_sample_rate")
@@ -76,7 +82,19 @@ func setupAPM(config Config) {
 	config.BindEnv("apm_config.max_traces_per_second", "DD_APM_MAX_TPS", "DD_MAX_TPS")
 	config.BindEnv("apm_config.errors_per_second", "DD_APM_ERROR_TPS")
 	config.BindEnv("apm_config.disable_rare_sampler", "DD_APM_DISABLE_RARE_SAMPLER")
+	config.BindEnv("apm_config.latency_sampler.enabled", "DD_APM_LATENCY_SAMPLER_ENABLED")
+	config.BindEnv("apm_config.latency_sampler.percentile", "DD_APM_LATENCY_SAMPLER_PERCENTILE")
+	config.BindEnv("apm_config.latency_sampler.tps", "DD_APM_LATENCY_SAMPLER_TPS")
+	config.BindEnv("apm_config.capture_path", "DD_APM_CAPTURE_PATH")
+	config.BindEnv("apm_config.capture_max_size_in_bytes", "DD_APM_CAPTURE_MAX_SIZE_IN_BYTES")
+	config.BindEnv("apm_config.disk_retry.path", "DD_APM_DISK_RETRY_PATH")
+	config.BindEnv("apm_config.disk_retry.max_size_in_bytes", "DD_APM_DISK_RETRY_MAX_SIZE_IN_BYTES")
 	config.BindEnv("apm_config.max_remote_traces_per_second", "DD_APM_MAX_REMOTE_TPS")
+	config.BindEnv("apm_config.otlp_exporter.endpoint", "DD_APM_OTLP_EXPORTER_ENDPOINT")
+	config.BindEnv("apm_config.otlp_exporter.protocol", "DD_APM_OTLP_EXPORTER_PROTOCOL")
+	config.BindEnv("apm_config.otlp_exporter.headers", "DD_APM_OTLP_EXPORTER_HEADERS")
+	config.BindEnv("apm_config.otlp_exporter.insecure", "DD_APM_OTLP_EXPORTER_INSECURE")
+	config.BindEnv("apm_config.otlp_exporter.queue_size", "DD_APM_OTLP_EXPORTER_QUEUE_SIZE")
 
 	config.BindEnv("apm_config.max_memory", "DD_APM_MAX_MEMORY")
 	config.BindEnv("apm_config.max_cpu_percent", "DD_APM_MAX_CPU_PERCENT")
@@ -89,8 +107,11 @@ func setupAPM(config Config) {
 	config.BindEnv("apm_config.profiling_additional_endpoints", "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.additional_endpoints", "DD_APM_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.replace_tags", "DD_APM_REPLACE_TAGS")
+	config.BindEnv("apm_config.filter_spans", "DD_APM_FILTER_SPANS")
 	config.BindEnv("apm_config.analyzed_spans", "DD_APM_ANALYZED_SPANS")
 	config.BindEnv("apm_config.ignore_resources", "DD_APM_IGNORE_RESOURCES", "DD_IGNORE_RESOURCE")
+	config.BindEnv("apm_config.extra_aggregators", "DD_APM_EXTRA_AGGREGATORS")
+	config.BindEnv("apm_config.extra_aggregators_max_values", "DD_APM_EXTRA_AGGREGATORS_MAX_VALUES")
 	config.BindEnv("apm_config.receiver_socket", "DD_APM_RECEIVER_SOCKET")
 	config.BindEnv("apm_config.windows_pipe_name", "DD_APM_WINDOWS_PIPE_NAME")
 	config.BindEnv("apm_config.sync_flushing", "DD_APM_SYNC_FLUSHING")
@@ -102,8 +123,11 @@ func setupAPM(config Config) {
 	config.BindEnvAndSetDefault("apm_config.telemetry.enabled", true, "DD_APM_TELEMETRY_ENABLED")
 	config.BindEnv("apm_config.telemetry.dd_url", "DD_APM_TELEMETRY_DD_URL")
 	config.BindEnv("apm_config.telemetry.additional_endpoints", "DD_APM_TELEMETRY_ADDITIONAL_ENDPOINTS")
+	config.BindEnvAndSetDefault("apm_config.zipkin_receiver.enabled", false, "DD_APM_ZIPKIN_RECEIVER_ENABLED")
+	config.BindEnvAndSetDefault("apm_config.jaeger_receiver.enabled", false, "DD_APM_JAEGER_RECEIVER_ENABLED")
 	config.BindEnv("apm_config.obfuscation.credit_cards.enabled", "DD_APM_OBFUSCATION_CREDIT_CARDS_ENABLED")
 	config.BindEnv("apm_config.obfuscation.credit_cards.luhn", "DD_APM_OBFUSCATION_CREDIT_CARDS_LUHN")
+	config.BindEnv("apm_config.obfuscation.pii.enabled", "DD_APM_OBFUSCATION_PII_ENABLED")
 
 	config.SetEnvKeyTransformer("apm_config.ignore_resources", func(in string) interface{} {
 		r, err := splitCSVString(in, ',')
@@ -114,6 +138,15 @@ func setupAPM(config Config) {
 		return r
 	})
 
+	config.SetEnvKeyTransformer("apm_config.extra_aggregators", func(in string) interface{} {
+		r, err := splitCSVString(in, ',')
+		if err != nil {
+			log.Warnf(`"apm_config.extra_aggregators" can not be parsed: %v`, err)
+			return []string{}
+		}
+		return r
+	})
+
 	config.SetEnvKeyTransformer("apm_config.filter_tags.require", parseKVList("apm_config.filter_tags.require"))
 
 	config.SetEnvKeyTransformer("apm_config.filter_tags.reject", parseKVList("apm_config.filter_tags.reject"))
@@ -126,6 +159,22 @@ func setupAPM(config Config) {
 		return out
 	})
 
+	config.SetEnvKeyTransformer("apm_config.filter_spans", func(in string) interface{} {
+		var out []map[string]interface{}
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.filter_spans" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
+	config.SetEnvKeyTransformer("apm_config.otlp_exporter.headers", func(in string) interface{} {
+		var out map[string]string
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.otlp_exporter.headers" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
 	config.SetEnvKeyTransformer("apm_config.analyzed_spans", func(in string) interface{} {
 		out, err := parseAnalyzedSpans(in)
 		if err != nil {
@@ -178,10 +178,23 @@ type MappingProfile struct {
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	Match     string            `mapstructure:"match" json:"match"`
-	MatchType string            `mapstructure:"match_type" json:"match_type"`
-	Name      string            `mapstructure:"name" json:"name"`
-	Tags      map[string]string `mapstructure:"tags" json:"tags"`
+	Match        string            `mapstructure:"match" json:"match"`
+	MatchType    string            `mapstructure:"match_type" json:"match_type"`
+	MatchTags    map[string]string `mapstructure:"match_tags" json:"match_tags"`
+	Name         string            `mapstructure:"name" json:"name"`
+	Tags         map[string]string `mapstructure:"tags" json:"tags"`
+	Drop         bool              `mapstructure:"drop" json:"drop"`
+	RenameTags   map[string]string `mapstructure:"rename_tags" json:"rename_tags"`
+	RemoveTags   []string          `mapstructure:"remove_tags" json:"remove_tags"`
+	MaxTagValues map[string]int    `mapstructure:"max_tag_values" json:"max_tag_values"`
+}
+
+// ForwarderRoute represent the data the forwarder sends to one of its domains
+type ForwarderRoute struct {
+	Domain         string   `mapstructure:"domain" json:"domain"`
+	Endpoints      []string `mapstructure:"endpoints" json:"endpoints"`
+	MetricPrefixes []string `mapstructure:"metric_prefixes" json:"metric_prefixes"`
+	MetricTags     []string `mapstructure:"metric_tags" json:"metric_tags"`
 }
 
 // Endpoint represent a datadog endpoint
@@ -455,7 +468,25 @@ func InitConfig(config Config) {
 
 	// Forwarder
 	config.BindEnvAndSetDefault("additional_endpoints", map[string][]string{})
+	config.BindEnv("forwarder_routes")
+	config.SetEnvKeyTransformer("forwarder_routes", func(in string) interface{} {
+		var routes []ForwarderRoute
+		if err := json.Unmarshal([]byte(in), &routes); err != nil {
+			log.Errorf(`"forwarder_routes" can not be parsed: %v`, err)
+		}
+		return routes
+	})
 	config.BindEnvAndSetDefault("forwarder_timeout", 20)
+	config.BindEnvAndSetDefault("prometheus_remote_write.url", "")
+	config.BindEnvAndSetDefault("prometheus_remote_write.headers", map[string]string{})
+	config.SetEnvKeyTransformer("prometheus_remote_write.headers", func(in string) interface{} {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(in), &headers); err != nil {
+			log.Errorf(`"prometheus_remote_write.headers" can not be parsed: %v`, err)
+		}
+		return headers
+	})
+	config.BindEnvAndSetDefault("prometheus_remote_write.max_series_per_payload", 1000)
 	config.BindEnv("forwarder_retry_queue_max_size")                                                     // Deprecated in favor of `forwarder_retry_queue_payloads_max_size`
 	config.BindEnv("forwarder_retry_queue_payloads_max_size")                                            // Default value is defined inside `NewOptions` in pkg/forwarder/forwarder.go
 	config.BindEnvAndSetDefault("forwarder_connection_reset_interval", 0)                                // in seconds, 0 means disabled
@@ -476,6 +507,28 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("forwarder_storage_max_size_in_bytes", 0)                // 0 means disabled. This is a BETA feature.
 	config.BindEnvAndSetDefault("forwarder_storage_max_disk_ratio", 0.80)                // Do not store transactions on disk when the disk usage exceeds 80% of the disk capacity. Use 80% as some applications do not behave well when the disk space is very small.
 	config.BindEnvAndSetDefault("forwarder_retry_queue_capacity_time_interval_sec", 900) // 15 mins
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_keys", []string{})
+
+	// Forwarder retry queue shedding
+	config.BindEnvAndSetDefault("forwarder_retry_queue_shed_order", []string{})
+	config.BindEnv("forwarder_retry_queue_max_size_by_endpoint_kind")
+	config.BindEnv("forwarder_storage_max_size_in_bytes_by_endpoint_kind")
+	for _, key := range []string{"forwarder_retry_queue_max_size_by_endpoint_kind", "forwarder_storage_max_size_in_bytes_by_endpoint_kind"} {
+		key := key
+		config.SetEnvKeyTransformer(key, func(in string) interface{} {
+			var budgets map[string]int64
+			if err := json.Unmarshal([]byte(in), &budgets); err != nil {
+				log.Errorf(`"%s" can not be parsed: %v`, key, err)
+			}
+			return budgets
+		})
+	}
+
+	// Forwarder offline mode
+	config.BindEnvAndSetDefault("forwarder_record_path", "")                               // "" means disabled
+	config.BindEnvAndSetDefault("forwarder_record_bundle_max_size_in_bytes", 10*1024*1024) // 10MB
+	config.BindEnvAndSetDefault("forwarder_record_bundle_max_duration", 1*time.Hour)       // Also completes the bundles of the quiet hosts
+	config.BindEnvAndSetDefault("forwarder_record_max_size_in_bytes", 1024*1024*1024)      // 1GB
 
 	// Forwarder channels buffer size
 	config.BindEnvAndSetDefault("forwarder_high_prio_buffer_size", 100)
@@ -501,7 +554,9 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("dogstatsd_queue_size", 1024)
 
 	config.BindEnvAndSetDefault("dogstatsd_non_local_traffic", false)
-	config.BindEnvAndSetDefault("dogstatsd_socket", "") // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_socket", "")          // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_pushgateway_port", 0) // Notice: 0 means HTTP listener disabled
+	config.BindEnvAndSetDefault("dogstatsd_pushgateway_max_body_size", 10*1024*1024)
 	config.BindEnvAndSetDefault("dogstatsd_pipeline_autoadjust", false)
 	config.BindEnvAndSetDefault("dogstatsd_pipeline_count", 1)
 	config.BindEnvAndSetDefault("dogstatsd_stats_port", 5000)
@@ -514,6 +569,10 @@ func InitConfig(config Config) {
 	// is 10s), otherwise we won't be able to sample unseen counter as
 	// contexts will be deleted (see 'dogstatsd_expiry_seconds').
 	config.BindEnvAndSetDefault("dogstatsd_context_expiry_seconds", 300)
+	// Maximum number of contexts per metric name in each dogstatsd time sampler,
+	// the samples over the limit are aggregated in a single context. 0 disables it.
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.metric_limit", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.per_origin", false)
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection", false) // Only supported for socket traffic
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection_client", false)
 	config.BindEnvAndSetDefault("dogstatsd_so_rcvbuf", 0)
@@ -1541,6 +1600,7 @@ func bindEnvAndSetLogsConfigKeys(config Config, prefix string) {
 	config.BindEnv(prefix + "dd_url")
 	config.BindEnv(prefix + "additional_endpoints")
 	config.BindEnvAndSetDefault(prefix+"use_compression", true)
+	config.BindEnvAndSetDefault(prefix+"compression_kind", "gzip")
 	config.BindEnvAndSetDefault(prefix+"compression_level", 6) // Default level for the gzip/deflate algorithm
 	config.BindEnvAndSetDefault(prefix+"batch_wait", DefaultBatchWait)
 	config.BindEnvAndSetDefault(prefix+"connection_reset_interval", 0) // in seconds, 0 means disabled
@@ -1790,6 +1850,26 @@ func getDogstatsdMappingProfilesConfig(config Config) ([]MappingProfile, error)
 	return mappings, nil
 }
 
+// GetForwarderRoutes returns the routes selecting the data sent by the forwarder to its domains
+func GetForwarderRoutes() ([]ForwarderRoute, error) {
+	return getForwarderRoutesConfig(Datadog)
+}
+
+func getForwarderRoutesConfig(config Config) ([]ForwarderRoute, error) {
+	var routes []ForwarderRoute
+	if config.IsSet("forwarder_routes") {
+		if err := config.UnmarshalKey("forwarder_routes", &routes); err != nil {
+			return nil, fmt.Errorf("could not parse forwarder_routes: %v", err)
+		}
+	}
+	for _, route := range routes {
+		if route.Domain == "" {
+			return nil, fmt.Errorf("could not parse forwarder_routes: a route has no domain")
+		}
+	}
+	return routes, nil
+}
+
 // IsCLCRunner returns whether the Agent is in cluster check runner mode
 func IsCLCRunner() bool {
 	if !Datadog.GetBool("clc_runner_enabled") {
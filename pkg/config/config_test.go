@@ -1137,6 +1137,40 @@ func TestDogstatsdMappingProfilesEnv(t *testing.T) {
 	assert.Equal(t, mappings, expected)
 }
 
+func TestForwarderRoutes(t *testing.T) {
+	datadogYaml := `
+forwarder_routes:
+  - domain: "https://app.datadoghq.eu"
+    endpoints: ["series", "sketches"]
+    metric_prefixes: ["billing."]
+    metric_tags: ["team:billing"]
+  - domain: "https://app.datadoghq.com"
+`
+	routes, err := getForwarderRoutesConfig(setupConfFromYAML(datadogYaml))
+	assert.NoError(t, err)
+	assert.Equal(t, []ForwarderRoute{
+		{
+			Domain:         "https://app.datadoghq.eu",
+			Endpoints:      []string{"series", "sketches"},
+			MetricPrefixes: []string{"billing."},
+			MetricTags:     []string{"team:billing"},
+		},
+		{Domain: "https://app.datadoghq.com"},
+	}, routes)
+
+	routes, err = getForwarderRoutesConfig(setupConfFromYAML(`forwarder_routes: [{endpoints: ["series"]}]`))
+	assert.Error(t, err)
+	assert.Empty(t, routes)
+
+	env := "DD_FORWARDER_ROUTES"
+	err = os.Setenv(env, `[{"domain":"https://app.datadoghq.eu","endpoints":["events"]}]`)
+	assert.Nil(t, err)
+	defer os.Unsetenv(env)
+	routes, err = GetForwarderRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, []ForwarderRoute{{Domain: "https://app.datadoghq.eu", Endpoints: []string{"events"}}}, routes)
+}
+
 func TestGetValidHostAliasesWithConfig(t *testing.T) {
 	config := setupConfFromYAML(`host_aliases: ["foo", "-bar"]`)
 	assert.EqualValues(t, getValidHostAliasesWithConfig(config), []string{"foo"})
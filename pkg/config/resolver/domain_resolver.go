@@ -36,19 +36,25 @@ type DomainResolver interface {
 	GetAlternateDomains() []string
 	// SetBaseDomain sets the base domain to a new value
 	SetBaseDomain(domain string)
+	// GetRoutingRule returns the RoutingRule selecting the data sent by this `DomainResolver`, nil if
+	// all the data is sent
+	GetRoutingRule() *RoutingRule
+	// SetRoutingRule sets the RoutingRule selecting the data sent by this `DomainResolver`
+	SetRoutingRule(rule *RoutingRule)
 }
 
 // SingleDomainResolver will always return the same host
 type SingleDomainResolver struct {
 	domain  string
 	apiKeys []string
+	rule    *RoutingRule
 }
 
 // NewSingleDomainResolver creates a SingleDomainResolver with its destination domain & API keys
 func NewSingleDomainResolver(domain string, apiKeys []string) *SingleDomainResolver {
 	return &SingleDomainResolver{
-		domain,
-		apiKeys,
+		domain:  domain,
+		apiKeys: apiKeys,
 	}
 }
 
@@ -86,6 +92,16 @@ func (r *SingleDomainResolver) GetAlternateDomains() []string {
 	return []string{}
 }
 
+// GetRoutingRule returns the RoutingRule of a SingleDomainResolver
+func (r *SingleDomainResolver) GetRoutingRule() *RoutingRule {
+	return r.rule
+}
+
+// SetRoutingRule sets the RoutingRule of a SingleDomainResolver
+func (r *SingleDomainResolver) SetRoutingRule(rule *RoutingRule) {
+	r.rule = rule
+}
+
 type destination struct {
 	domain string
 	dType  DestinationType
@@ -97,15 +113,16 @@ type MultiDomainResolver struct {
 	apiKeys             []string
 	overrides           map[string]destination
 	alternateDomainList []string
+	rule                *RoutingRule
 }
 
 // NewMultiDomainResolver initializes a MultiDomainResolver with its API keys and base destination
 func NewMultiDomainResolver(baseDomain string, apiKeys []string) *MultiDomainResolver {
 	return &MultiDomainResolver{
-		baseDomain,
-		apiKeys,
-		make(map[string]destination),
-		[]string{},
+		baseDomain:          baseDomain,
+		apiKeys:             apiKeys,
+		overrides:           make(map[string]destination),
+		alternateDomainList: []string{},
 	}
 }
 
@@ -137,6 +154,16 @@ func (r *MultiDomainResolver) GetAlternateDomains() []string {
 	return r.alternateDomainList
 }
 
+// GetRoutingRule returns the RoutingRule of a MultiDomainResolver
+func (r *MultiDomainResolver) GetRoutingRule() *RoutingRule {
+	return r.rule
+}
+
+// SetRoutingRule sets the RoutingRule of a MultiDomainResolver
+func (r *MultiDomainResolver) SetRoutingRule(rule *RoutingRule) {
+	r.rule = rule
+}
+
 // RegisterAlternateDestination adds an alternate destination to a MultiDomainResolver.
 // The resolver will match transaction.Endpoint.Name against forwarderName to check if the request shall
 // be diverted.
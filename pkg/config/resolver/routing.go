@@ -0,0 +1,169 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// EndpointKind is the kind of data sent to a `transaction.Endpoint`, used to route it
+type EndpointKind string
+
+const (
+	// SeriesKind is the kind of the series endpoints
+	SeriesKind EndpointKind = "series"
+	// SketchesKind is the kind of the sketches endpoints
+	SketchesKind EndpointKind = "sketches"
+	// EventsKind is the kind of the events endpoints
+	EventsKind EndpointKind = "events"
+	// ServiceChecksKind is the kind of the service checks endpoints
+	ServiceChecksKind EndpointKind = "service_checks"
+	// MetadataKind is the kind of the metadata endpoints
+	MetadataKind EndpointKind = "metadata"
+	// ProcessKind is the kind of the process and container endpoints, including the container
+	// lifecycle events
+	ProcessKind EndpointKind = "process"
+	// OrchestratorKind is the kind of the orchestrator endpoints
+	OrchestratorKind EndpointKind = "orchestrator"
+)
+
+// endpointKinds maps the names of the routable endpoints to their kind. The v1 intake endpoint is
+// used for events and some metadata: the forwarder sets the kind of the payloads submitted as
+// metadata, like the host, agent checks and processes metadata, itself.
+var endpointKinds = map[string]EndpointKind{
+	endpoints.V1SeriesEndpoint.Name:             SeriesKind,
+	endpoints.SeriesEndpoint.Name:               SeriesKind,
+	endpoints.V1SketchSeriesEndpoint.Name:       SketchesKind,
+	endpoints.SketchSeriesEndpoint.Name:         SketchesKind,
+	endpoints.V1IntakeEndpoint.Name:             EventsKind,
+	endpoints.EventsEndpoint.Name:               EventsKind,
+	endpoints.V1CheckRunsEndpoint.Name:          ServiceChecksKind,
+	endpoints.ServiceChecksEndpoint.Name:        ServiceChecksKind,
+	endpoints.V1MetadataEndpoint.Name:           MetadataKind,
+	endpoints.HostMetadataEndpoint.Name:         MetadataKind,
+	endpoints.ProcessesEndpoint.Name:            ProcessKind,
+	endpoints.ProcessDiscoveryEndpoint.Name:     ProcessKind,
+	endpoints.ProcessLifecycleEndpoint.Name:     ProcessKind,
+	endpoints.RtProcessesEndpoint.Name:          ProcessKind,
+	endpoints.ContainerEndpoint.Name:            ProcessKind,
+	endpoints.RtContainerEndpoint.Name:          ProcessKind,
+	endpoints.ConnectionsEndpoint.Name:          ProcessKind,
+	endpoints.ContainerLifecycleEndpoint.Name:   ProcessKind,
+	endpoints.LegacyOrchestratorEndpoint.Name:   OrchestratorKind,
+	endpoints.OrchestratorEndpoint.Name:         OrchestratorKind,
+	endpoints.OrchestratorManifestEndpoint.Name: OrchestratorKind,
+}
+
+// EndpointKindOf returns the kind of a `transaction.Endpoint`, or an empty kind if it is not routable
+func EndpointKindOf(endpoint transaction.Endpoint) EndpointKind {
+	return EndpointKindByName(endpoint.Name)
+}
+
+// TransactionKindOf returns the kind of the data sent by a transaction: the kind set by the
+// forwarder on the `transaction.HTTPTransaction`, the kind of its endpoint otherwise
+func TransactionKindOf(t transaction.Transaction) EndpointKind {
+	if httpTransaction, ok := t.(*transaction.HTTPTransaction); ok && httpTransaction.Kind != "" {
+		return EndpointKind(httpTransaction.Kind)
+	}
+	return EndpointKindByName(t.GetEndpointName())
+}
+
+// EndpointKindByName returns the kind of the endpoint with the given name, or an empty kind if it
+// is not routable
+func EndpointKindByName(name string) EndpointKind {
+	return endpointKinds[name]
+}
+
+// ParseEndpointKind returns the endpoint kind with the given name, case insensitive
+func ParseEndpointKind(name string) (EndpointKind, error) {
+	kind := EndpointKind(strings.ToLower(name))
+	switch kind {
+	case SeriesKind, SketchesKind, EventsKind, ServiceChecksKind, MetadataKind, ProcessKind, OrchestratorKind:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown endpoint kind %q", name)
+	}
+}
+
+// RoutingRule selects the data sent to the domains of a `DomainResolver`
+type RoutingRule struct {
+	kinds          map[EndpointKind]bool
+	metricPrefixes []string
+	metricTags     map[string]bool
+	// metricTagKeys holds the `key:` prefixes of the tags given without value
+	metricTagKeys []string
+}
+
+// NewRoutingRule creates a RoutingRule accepting the given endpoint kinds, all the routable kinds if
+// empty. If metric prefixes or tags are given, only the series and sketches whose name starts with
+// one of the prefixes or which have one of the tags are accepted. A tag without value matches all the
+// tags with this key.
+func NewRoutingRule(kinds []string, metricPrefixes []string, metricTags []string) (*RoutingRule, error) {
+	r := &RoutingRule{
+		metricPrefixes: metricPrefixes,
+		metricTags:     make(map[string]bool, len(metricTags)),
+	}
+	for _, t := range metricTags {
+		r.metricTags[t] = true
+		if !strings.Contains(t, ":") {
+			r.metricTagKeys = append(r.metricTagKeys, t+":")
+		}
+	}
+	if len(kinds) == 0 {
+		return r, nil
+	}
+	r.kinds = make(map[EndpointKind]bool, len(kinds))
+	for _, k := range kinds {
+		kind, err := ParseEndpointKind(k)
+		if err != nil {
+			return nil, err
+		}
+		r.kinds[kind] = true
+	}
+	return r, nil
+}
+
+// AcceptsKind returns whether the data of the given endpoint kind is sent to the domain. Endpoints
+// which are not routable are always accepted. A nil RoutingRule accepts everything.
+func (r *RoutingRule) AcceptsKind(kind EndpointKind) bool {
+	if r == nil || r.kinds == nil || kind == "" {
+		return true
+	}
+	return r.kinds[kind]
+}
+
+// FiltersMetrics returns whether only a subset of the series and sketches is sent to the domain
+func (r *RoutingRule) FiltersMetrics() bool {
+	return r != nil && (len(r.metricPrefixes) > 0 || len(r.metricTags) > 0)
+}
+
+// AcceptsMetric returns whether a serie or sketch with the given name and tags is sent to the domain
+func (r *RoutingRule) AcceptsMetric(name string, tags tagset.CompositeTags) bool {
+	if !r.FiltersMetrics() {
+		return true
+	}
+	for _, prefix := range r.metricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return tags.Find(func(tag string) bool {
+		if r.metricTags[tag] {
+			return true
+		}
+		for _, key := range r.metricTagKeys {
+			if strings.HasPrefix(tag, key) {
+				return true
+			}
+		}
+		return false
+	})
+}
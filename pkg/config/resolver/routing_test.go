@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+func TestRoutingRule(t *testing.T) {
+	var none *RoutingRule
+	assert.True(t, none.AcceptsKind(SeriesKind))
+	assert.False(t, none.FiltersMetrics())
+	assert.True(t, none.AcceptsMetric("foo", tagset.CompositeTags{}))
+
+	_, err := NewRoutingRule([]string{"series", "logs"}, nil, nil)
+	assert.Error(t, err)
+
+	r, err := NewRoutingRule([]string{"Series", "events"}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, r.AcceptsKind(EndpointKindOf(endpoints.SeriesEndpoint)))
+	assert.True(t, r.AcceptsKind(EndpointKindOf(endpoints.V1IntakeEndpoint)))
+	assert.False(t, r.AcceptsKind(EndpointKindOf(endpoints.SketchSeriesEndpoint)))
+	assert.False(t, r.AcceptsKind(EndpointKindOf(endpoints.OrchestratorManifestEndpoint)))
+	assert.False(t, r.AcceptsKind(EndpointKindOf(endpoints.ContainerLifecycleEndpoint)))
+	assert.False(t, r.AcceptsKind(EndpointKindOf(endpoints.LegacyOrchestratorEndpoint)))
+	assert.False(t, r.FiltersMetrics())
+
+	r, err = NewRoutingRule(nil, []string{"billing."}, []string{"team:billing", "cost_center"})
+	require.NoError(t, err)
+	assert.True(t, r.AcceptsKind(ProcessKind))
+	assert.True(t, r.FiltersMetrics())
+	for _, tt := range []struct {
+		name     string
+		tags     []string
+		accepted bool
+	}{
+		{"billing.invoices", nil, true},
+		{"system.cpu", []string{"team:billing"}, true},
+		{"system.cpu", []string{"env:prod", "cost_center:42"}, true},
+		{"system.cpu", []string{"cost_center"}, true},
+		{"system.cpu", []string{"team:billing-eu", "cost_center_id:42"}, false},
+		{"system.billing.cpu", nil, false},
+	} {
+		assert.Equal(t, tt.accepted, r.AcceptsMetric(tt.name, tagset.CompositeTagsFromSlice(tt.tags)), "%s %v", tt.name, tt.tags)
+	}
+}
+
+func TestTransactionKindOf(t *testing.T) {
+	tr := transaction.NewHTTPTransaction()
+	tr.Endpoint = endpoints.V1IntakeEndpoint
+	assert.Equal(t, EventsKind, TransactionKindOf(tr))
+	tr.Kind = string(MetadataKind)
+	assert.Equal(t, MetadataKind, TransactionKindOf(tr))
+}
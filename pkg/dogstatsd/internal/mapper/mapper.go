@@ -42,13 +42,21 @@ type MetricMapping struct {
 	name  string
 	tags  map[string]string
 	regex *regexp.Regexp
+	drop  bool
+	rules *tagRules
 }
 
 // MapResult represent the outcome of the mapping
 type MapResult struct {
-	Name    string
-	Tags    []string
+	Name string
+	Tags []string
+	// Drop is true if the metric should be dropped
+	Drop    bool
 	matched bool
+	// rules holds the tag rules of the mapping, if any
+	rules *tagRules
+	// next is the next candidate, when this result only applies to some tags
+	next *MapResult
 }
 
 // NewMetricMapper creates, validates, prepares a new MetricMapper
@@ -74,7 +82,12 @@ func NewMetricMapper(configProfiles []config.MappingProfile, cacheSize int) (*Me
 			if matchType != matchTypeWildcard && matchType != matchTypeRegex {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match type, must be `wildcard` or `regex`", profile.Name, i)
 			}
-			if currentMapping.Name == "" {
+			rules, err := newTagRules(currentMapping.MatchTags, currentMapping.RenameTags, currentMapping.RemoveTags, currentMapping.MaxTagValues)
+			if err != nil {
+				return nil, fmt.Errorf("profile: %s, mapping num %d: %v", profile.Name, i, err)
+			}
+			// the name can only be omitted by mappings not renaming the metric
+			if currentMapping.Name == "" && !currentMapping.Drop && !rules.rewritesTags() {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: name is required", profile.Name, i)
 			}
 			if currentMapping.Match == "" {
@@ -84,7 +97,13 @@ func NewMetricMapper(configProfiles []config.MappingProfile, cacheSize int) (*Me
 			if err != nil {
 				return nil, err
 			}
-			profile.Mappings = append(profile.Mappings, &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex})
+			profile.Mappings = append(profile.Mappings, &MetricMapping{
+				name:  currentMapping.Name,
+				tags:  currentMapping.Tags,
+				regex: regex,
+				drop:  currentMapping.Drop,
+				rules: rules,
+			})
 		}
 		profiles = append(profiles, profile)
 	}
@@ -114,44 +133,75 @@ func buildRegex(matchRe string, matchType string) (*regexp.Regexp, error) {
 }
 
 // Map returns a MapResult
-func (m *MetricMapper) Map(metricName string) *MapResult {
+// The tags of the metric are only used by mappings matching on tags, the
+// results are cached by metric name.
+func (m *MetricMapper) Map(metricName string, tags []string) *MapResult {
 	for _, profile := range m.Profiles {
 		if !strings.HasPrefix(metricName, profile.Prefix) && profile.Prefix != "*" {
 			continue
 		}
 		result, cached := m.cache.get(metricName)
-		if cached {
-			if result.matched {
+		if !cached {
+			result = profile.mapName(metricName)
+			m.cache.add(metricName, result)
+		}
+		for ; result != nil && result.matched; result = result.next {
+			if result.rules.matches(tags) {
 				return result
 			}
-			return nil
 		}
-		for _, mapping := range profile.Mappings {
-			matches := mapping.regex.FindStringSubmatchIndex(metricName)
-			if len(matches) == 0 {
-				continue
-			}
+		return nil
+	}
+	return nil
+}
+
+// mapName returns the results of the mappings matching the metric name,
+// chained in order, up to the first one which doesn't depend on tags.
+func (p *MappingProfile) mapName(metricName string) *MapResult {
+	var first, last *MapResult
+	for _, mapping := range p.Mappings {
+		matches := mapping.regex.FindStringSubmatchIndex(metricName)
+		if len(matches) == 0 {
+			continue
+		}
 
-			name := string(mapping.regex.ExpandString(
+		name := metricName
+		if mapping.name != "" {
+			name = string(mapping.regex.ExpandString(
 				[]byte{},
 				mapping.name,
 				metricName,
 				matches,
 			))
+		}
 
-			tags := make([]string, 0, len(mapping.tags))
-			for tagKey, tagValueExpr := range mapping.tags {
-				tagValue := string(mapping.regex.ExpandString([]byte{}, tagValueExpr, metricName, matches))
-				tags = append(tags, tagKey+":"+tagValue)
-			}
+		tags := make([]string, 0, len(mapping.tags))
+		for tagKey, tagValueExpr := range mapping.tags {
+			tagValue := string(mapping.regex.ExpandString([]byte{}, tagValueExpr, metricName, matches))
+			tags = append(tags, tagKey+":"+tagValue)
+		}
 
-			mapResult := &MapResult{Name: name, matched: true, Tags: tags}
-			m.cache.add(metricName, mapResult)
-			return mapResult
+		mapResult := &MapResult{Name: name, matched: true, Tags: tags, Drop: mapping.drop, rules: mapping.rules}
+		if first == nil {
+			first = mapResult
+		} else {
+			last.next = mapResult
+		}
+		last = mapResult
+
+		if !mapping.rules.matchesOnTags() {
+			return first
 		}
-		mapResult := &MapResult{matched: false}
-		m.cache.add(metricName, mapResult)
-		return nil
 	}
-	return nil
+	if first == nil {
+		return &MapResult{matched: false}
+	}
+	return first
+}
+
+// RewriteTags applies the tag rewriting rules of the mapping to the metric
+// tags. The tags added by the mapping itself are not part of them.
+// The tags slice is modified in place.
+func (r *MapResult) RewriteTags(tags []string) []string {
+	return r.rules.rewrite(r.Name, tags)
 }
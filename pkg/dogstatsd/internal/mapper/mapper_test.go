@@ -335,7 +335,7 @@ dogstatsd_mapper_profiles:
 
 			var actualResults []MapResult
 			for _, packet := range scenario.packets {
-				mapResult := mapper.Map(packet)
+				mapResult := mapper.Map(packet, nil)
 				if mapResult != nil {
 					actualResults = append(actualResults, *mapResult)
 				}
@@ -351,6 +351,64 @@ dogstatsd_mapper_profiles:
 	}
 }
 
+func TestMappingsWithTagRules(t *testing.T) {
+	mapper, err := getMapper(`
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.http.*"
+        match_tags:
+          status: "5.."
+          env: "prod"
+        name: "test.http.errors"
+        tags:
+          handler: "$1"
+      - match: "test.http.*"
+        match_tags:
+          env: "dev"
+        drop: true
+      - match: "test.http.*"
+        rename_tags:
+          instance: host_name
+        remove_tags:
+          - session
+        max_tag_values:
+          user: 2
+      - match: "test.never.*"
+        name: "test.never"
+`)
+	require.NoError(t, err)
+
+	result := mapper.Map("test.http.login", []string{"env:prod", "status:503"})
+	require.NotNil(t, result)
+	assert.Equal(t, "test.http.errors", result.Name)
+	assert.Equal(t, []string{"handler:login"}, result.Tags)
+	assert.False(t, result.Drop)
+
+	// same name, served from the cache, other tags
+	result = mapper.Map("test.http.login", []string{"env:dev", "status:503"})
+	require.NotNil(t, result)
+	assert.True(t, result.Drop)
+
+	result = mapper.Map("test.http.login", []string{"env:prod", "status:200"})
+	require.NotNil(t, result)
+	assert.False(t, result.Drop)
+	assert.Equal(t, "test.http.login", result.Name)
+	assert.Equal(t, []string{"env:prod", "host_name:i-1", "user:a", "flag"},
+		result.RewriteTags([]string{"env:prod", "instance:i-1", "session:abc", "user:a", "flag"}))
+	assert.Equal(t, []string{"user:b", "user:overflow", "user:a"},
+		result.RewriteTags([]string{"user:b", "user:c", "user:a"}))
+
+	// tags are limited per metric
+	result = mapper.Map("test.http.logout", nil)
+	require.NotNil(t, result)
+	assert.Equal(t, []string{"user:c"}, result.RewriteTags([]string{"user:c"}))
+
+	assert.Nil(t, mapper.Map("test.other", []string{"env:dev"}))
+	assert.Equal(t, 3, mapper.cache.cache.Len())
+}
+
 func TestMappingErrors(t *testing.T) {
 	scenarios := []struct {
 		name          string
@@ -501,6 +559,46 @@ dogstatsd_mapper_profiles:
 			},
 			expectedError: "missing prefix for profile",
 		},
+		{
+			name: "Invalid match_tags regex",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        match_tags:
+          env: "prod("
+        drop: true
+`,
+			expectedError: "invalid match_tags value",
+		},
+		{
+			name: "Invalid max_tag_values",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        max_tag_values:
+          user: 0
+`,
+			expectedError: "invalid max_tag_values for tag `user`",
+		},
+		{
+			name: "Missing name with only tags matchers",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        match_tags:
+          env: "prod"
+`,
+			expectedError: "name is required",
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// overflowTagValue replaces the values of a tag once its maximum number of
+// distinct values has been reached for a metric.
+const overflowTagValue = "overflow"
+
+// tagRules holds the rules of a mapping applying to the tags of the metric.
+type tagRules struct {
+	// matchTags must all match the metric tags for the mapping to apply
+	matchTags map[string]*regexp.Regexp
+
+	renameTags map[string]string
+	removeTags map[string]struct{}
+	limiter    *tagValuesLimiter
+}
+
+// newTagRules validates and prepares the tag rules of a mapping, returns nil
+// if the mapping has none.
+func newTagRules(matchTags map[string]string, renameTags map[string]string, removeTags []string, maxTagValues map[string]int) (*tagRules, error) {
+	if len(matchTags) == 0 && len(renameTags) == 0 && len(removeTags) == 0 && len(maxTagValues) == 0 {
+		return nil, nil
+	}
+
+	rules := &tagRules{
+		renameTags: renameTags,
+	}
+
+	if len(matchTags) > 0 {
+		rules.matchTags = make(map[string]*regexp.Regexp, len(matchTags))
+		for key, valueRe := range matchTags {
+			regex, err := regexp.Compile("^(?:" + valueRe + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid match_tags value `%s` for tag `%s`. cannot compile regex: %v", valueRe, key, err)
+			}
+			rules.matchTags[key] = regex
+		}
+	}
+
+	for from, to := range renameTags {
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("invalid rename_tags entry `%s: %s`, tag keys can't be empty", from, to)
+		}
+	}
+
+	if len(removeTags) > 0 {
+		rules.removeTags = make(map[string]struct{}, len(removeTags))
+		for _, key := range removeTags {
+			rules.removeTags[key] = struct{}{}
+		}
+	}
+
+	if len(maxTagValues) > 0 {
+		for key, max := range maxTagValues {
+			if max <= 0 {
+				return nil, fmt.Errorf("invalid max_tag_values for tag `%s`: %d, must be positive", key, max)
+			}
+		}
+		rules.limiter = newTagValuesLimiter(maxTagValues)
+	}
+
+	return rules, nil
+}
+
+// splitTag returns the key and the value of a tag. Tags without value are
+// considered as a key with an empty value.
+func splitTag(tag string) (string, string) {
+	if idx := strings.IndexByte(tag, ':'); idx >= 0 {
+		return tag[:idx], tag[idx+1:]
+	}
+	return tag, ""
+}
+
+// matchesOnTags returns true if the mapping only applies to some tags.
+func (r *tagRules) matchesOnTags() bool {
+	return r != nil && len(r.matchTags) > 0
+}
+
+// rewritesTags returns true if the mapping modifies the metric tags.
+func (r *tagRules) rewritesTags() bool {
+	return r != nil && (len(r.renameTags) > 0 || len(r.removeTags) > 0 || r.limiter != nil)
+}
+
+// matches returns true if all the tags matchers are satisfied by tags.
+func (r *tagRules) matches(tags []string) bool {
+	if r == nil {
+		return true
+	}
+	for key, regex := range r.matchTags {
+		matched := false
+		for _, tag := range tags {
+			tagKey, tagValue := splitTag(tag)
+			if tagKey == key && regex.MatchString(tagValue) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// rewrite removes, renames and caps the cardinality of the given tags, in
+// this order. The tags slice is modified in place.
+func (r *tagRules) rewrite(metricName string, tags []string) []string {
+	if !r.rewritesTags() {
+		return tags
+	}
+
+	n := 0
+	for _, tag := range tags {
+		key, value := splitTag(tag)
+		if _, found := r.removeTags[key]; found {
+			continue
+		}
+
+		rewritten := false
+		if newKey, found := r.renameTags[key]; found {
+			key = newKey
+			rewritten = true
+		}
+		if r.limiter != nil && !r.limiter.allow(metricName, key, value) {
+			value = overflowTagValue
+			rewritten = true
+		}
+
+		if rewritten {
+			if value == "" && !strings.ContainsRune(tag, ':') {
+				tag = key
+			} else {
+				tag = key + ":" + value
+			}
+		}
+		tags[n] = tag
+		n++
+	}
+	return tags[:n]
+}
+
+// tagValuesLimiter keeps track of the distinct values seen for some tag keys
+// of each metric, up to a maximum.
+type tagValuesLimiter struct {
+	sync.Mutex
+	max map[string]int
+	// metric name -> tag key -> tag values
+	seen map[string]map[string]map[string]struct{}
+}
+
+func newTagValuesLimiter(max map[string]int) *tagValuesLimiter {
+	return &tagValuesLimiter{
+		max:  max,
+		seen: make(map[string]map[string]map[string]struct{}),
+	}
+}
+
+// allow returns false if the value is a new one for this metric and tag key
+// and the maximum number of distinct values has already been reached.
+func (l *tagValuesLimiter) allow(metricName, key, value string) bool {
+	max, limited := l.max[key]
+	if !limited {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	keys, found := l.seen[metricName]
+	if !found {
+		keys = make(map[string]map[string]struct{})
+		l.seen[metricName] = keys
+	}
+	values, found := keys[key]
+	if !found {
+		values = make(map[string]struct{})
+		keys[key] = values
+	}
+
+	if _, found := values[value]; found {
+		return true
+	}
+	if len(values) >= max {
+		return false
+	}
+	values[value] = struct{}{}
+	return true
+}
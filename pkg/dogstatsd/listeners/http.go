@@ -0,0 +1,264 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"expvar"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/replay"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	httpExpvars        = expvar.NewMap("dogstatsd-http")
+	httpRequestErrors  = expvar.Int{}
+	httpRequests       = expvar.Int{}
+	httpBytes          = expvar.Int{}
+	httpDroppedSamples = expvar.Int{}
+)
+
+func init() {
+	httpExpvars.Set("RequestErrors", &httpRequestErrors)
+	httpExpvars.Set("Requests", &httpRequests)
+	httpExpvars.Set("Bytes", &httpBytes)
+	httpExpvars.Set("DroppedSamples", &httpDroppedSamples)
+}
+
+const (
+	// pushPathPrefix is the prefix of the Pushgateway push API
+	pushPathPrefix = "/metrics/"
+	// base64Suffix marks a grouping label whose value is base64 encoded
+	base64Suffix = "@base64"
+	// containerIDHeader is the header used by clients to report their container,
+	// the same one is used by the trace-agent.
+	containerIDHeader = "Datadog-Container-ID"
+)
+
+// HTTPListener implements the StatsdListener interface for OpenMetrics and
+// Prometheus text payloads pushed over HTTP, using the Pushgateway API paths:
+//
+//	PUT|POST /metrics/job/<job>{/<label>/<value>}
+//
+// The samples are converted to DogStatsD messages so they go through the
+// same parsing, enrichment and origin detection as any other packet. The
+// grouping labels of the path are added as tags.
+// Origin detection relies on the container ID sent in the Datadog-Container-ID
+// header, and is only honored when dogstatsd_origin_detection_client is enabled.
+type HTTPListener struct {
+	listener        net.Listener
+	server          *http.Server
+	packetsBuffer   *packets.Buffer
+	packetAssembler *packets.Assembler
+	maxBodySize     int64
+	maxMessageSize  int
+	trafficCapture  *replay.TrafficCapture // Currently ignored
+	cumulative      *cumulativeValues
+}
+
+// NewHTTPListener returns an idle HTTP Statsd listener
+func NewHTTPListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager, capture *replay.TrafficCapture) (*HTTPListener, error) {
+	var url string
+
+	if config.Datadog.GetBool("dogstatsd_non_local_traffic") == true {
+		// Listen to all network interfaces
+		url = fmt.Sprintf(":%d", config.Datadog.GetInt("dogstatsd_pushgateway_port"))
+	} else {
+		url = net.JoinHostPort(config.GetBindHost(), config.Datadog.GetString("dogstatsd_pushgateway_port"))
+	}
+
+	ln, err := net.Listen("tcp", url)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+
+	packetsBufferSize := config.Datadog.GetInt("dogstatsd_packet_buffer_size")
+	flushTimeout := config.Datadog.GetDuration("dogstatsd_packet_buffer_flush_timeout")
+
+	packetsBuffer := packets.NewBuffer(uint(packetsBufferSize), flushTimeout, packetOut)
+	packetAssembler := packets.NewAssembler(flushTimeout, packetsBuffer, sharedPacketPoolManager, packets.HTTP)
+
+	listener := &HTTPListener{
+		listener:        ln,
+		packetsBuffer:   packetsBuffer,
+		packetAssembler: packetAssembler,
+		maxBodySize:     config.Datadog.GetInt64("dogstatsd_pushgateway_max_body_size"),
+		maxMessageSize:  config.Datadog.GetInt("dogstatsd_buffer_size"),
+		trafficCapture:  capture,
+		cumulative:      newCumulativeValues(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pushPathPrefix, listener.handlePush)
+	mux.HandleFunc("/-/healthy", handleHealth)
+	mux.HandleFunc("/-/ready", handleHealth)
+	listener.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	log.Debugf("dogstatsd-http: %s successfully initialized", ln.Addr())
+	return listener, nil
+}
+
+// Listen runs the intake loop. Should be called in its own goroutine
+func (l *HTTPListener) Listen() {
+	log.Infof("dogstatsd-http: starting to listen on %s", l.listener.Addr())
+	if err := l.server.Serve(l.listener); err != nil && err != http.ErrServerClosed {
+		log.Errorf("dogstatsd-http: error serving requests: %v", err)
+	}
+}
+
+// Stop closes the HTTP server and stops listening
+func (l *HTTPListener) Stop() {
+	l.server.Close()
+	l.packetAssembler.Close()
+	l.packetsBuffer.Close()
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (l *HTTPListener) handlePush(w http.ResponseWriter, r *http.Request) {
+	var t1, t2 time.Time
+	t1 = time.Now()
+	httpRequests.Add(1)
+
+	status, err := l.push(r)
+	if err != nil {
+		log.Debugf("dogstatsd-http: rejecting push from %s: %v", r.RemoteAddr, err)
+		httpRequestErrors.Add(1)
+		tlmHTTPRequests.Inc("error")
+		http.Error(w, err.Error(), status)
+	} else {
+		tlmHTTPRequests.Inc("ok")
+		w.WriteHeader(status)
+	}
+
+	t2 = time.Now()
+	tlmListener.Observe(float64(t2.Sub(t1).Nanoseconds()), "http")
+}
+
+// push ingests the request and returns the status code to answer with.
+func (l *HTTPListener) push(r *http.Request) (int, error) {
+	groupingTags, err := parseGroupingPath(r.URL.Path)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+	case http.MethodDelete:
+		// groups are not persisted by the agent, there is nothing to delete
+		return http.StatusAccepted, nil
+	default:
+		return http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method)
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid content type: %v", err)
+		}
+		if mediaType != "text/plain" && mediaType != "application/openmetrics-text" {
+			return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, only text formats are supported", mediaType)
+		}
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid gzip payload: %v", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(body, l.maxBodySize+1))
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("could not read payload: %v", err)
+	}
+	if int64(len(payload)) > l.maxBodySize {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("payload exceeds %d bytes", l.maxBodySize)
+	}
+	httpBytes.Add(int64(len(payload)))
+	tlmHTTPBytes.Add(float64(len(payload)))
+
+	containerID := r.Header.Get(containerIDHeader)
+	if strings.ContainsAny(containerID, "|,\n\r") {
+		containerID = ""
+	}
+
+	messages, err := convertExposition(payload, groupingTags, containerID, l.cumulative)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	for _, message := range messages {
+		// the assembler would truncate it
+		if len(message) > l.maxMessageSize {
+			httpDroppedSamples.Add(1)
+			continue
+		}
+		l.packetAssembler.AddMessage(message)
+	}
+
+	return http.StatusOK, nil
+}
+
+// parseGroupingPath reads the grouping labels of a Pushgateway push path and
+// returns them as tags. The job label is mandatory.
+func parseGroupingPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, pushPathPrefix) {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	parts := strings.Split(strings.Trim(path[len(pushPathPrefix):], "/"), "/")
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("invalid grouping labels in path %q", path)
+	}
+
+	tags := make([]string, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		key, value := parts[i], parts[i+1]
+		if strings.HasSuffix(key, base64Suffix) {
+			key = strings.TrimSuffix(key, base64Suffix)
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value for label %q: %v", key, err)
+			}
+			value = string(decoded)
+		}
+		if key == "" || strings.ContainsRune(key, ':') {
+			return nil, fmt.Errorf("invalid label name %q in path %q", key, path)
+		}
+		if i == 0 && key != "job" {
+			return nil, fmt.Errorf("path %q should start with the job label", path)
+		}
+		if i == 0 && value == "" {
+			return nil, fmt.Errorf("job label should not be empty")
+		}
+		// empty label values are equivalent to the label being absent
+		if value == "" {
+			continue
+		}
+		tags = append(tags, key+":"+value)
+	}
+	return tags, nil
+}
@@ -0,0 +1,188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+)
+
+var (
+	packetPoolHTTP        = packets.NewPool(config.Datadog.GetInt("dogstatsd_buffer_size"))
+	packetPoolManagerHTTP = packets.NewPoolManager(packetPoolHTTP)
+)
+
+func newTestHTTPListener(t *testing.T, packetChannel chan packets.Packets) (*HTTPListener, string) {
+	port, err := getAvailableTCPPort()
+	require.NoError(t, err)
+	mockConfig := config.Mock(t)
+	mockConfig.Set("dogstatsd_pushgateway_port", port)
+	mockConfig.Set("dogstatsd_non_local_traffic", false)
+
+	s, err := NewHTTPListener(packetChannel, packetPoolManagerHTTP, nil)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	go s.Listen()
+
+	return s, fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+func TestHTTPReceive(t *testing.T) {
+	packetChannel := make(chan packets.Packets)
+	s, url := newTestHTTPListener(t, packetChannel)
+	defer s.Stop()
+
+	req, err := http.NewRequest(http.MethodPut, url+"/metrics/job/batch/instance@base64/aS0x",
+		bytes.NewBufferString("# TYPE processed counter\nprocessed_total{queue=\"a\"} 12\n"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	req.Header.Set("Datadog-Container-ID", "abcdef")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		assert.Equal(t, "processed_total:12|c|#job:batch,instance:i-1,queue:a|c:abcdef", string(pkts[0].Contents))
+		assert.Equal(t, packets.HTTP, pkts[0].Source)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestHTTPReceiveCounterIncrease(t *testing.T) {
+	packetChannel := make(chan packets.Packets)
+	s, url := newTestHTTPListener(t, packetChannel)
+	defer s.Stop()
+
+	for _, tc := range []struct {
+		value    string
+		expected string
+	}{
+		{"12", "processed_total:12|c|#job:batch"},
+		{"12", "processed_total:0|c|#job:batch"},
+		{"20", "processed_total:8|c|#job:batch"},
+	} {
+		resp, err := http.Post(url+"/metrics/job/batch", "text/plain", bytes.NewBufferString("# TYPE processed counter\nprocessed_total "+tc.value+"\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		select {
+		case pkts := <-packetChannel:
+			require.Len(t, pkts, 1)
+			assert.Equal(t, tc.expected, string(pkts[0].Contents))
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Timeout on receive channel")
+		}
+	}
+}
+
+func TestHTTPReceiveGzip(t *testing.T) {
+	packetChannel := make(chan packets.Packets)
+	s, url := newTestHTTPListener(t, packetChannel)
+	defer s.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("queue_size 3\n"))
+	gz.Close()
+
+	req, err := http.NewRequest(http.MethodPost, url+"/metrics/job/batch", &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		assert.Equal(t, "queue_size:3|g|#job:batch", string(pkts[0].Contents))
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestHTTPRejectedRequests(t *testing.T) {
+	s, url := newTestHTTPListener(t, make(chan packets.Packets))
+	defer s.Stop()
+
+	for _, tc := range []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodPut, "/metrics/instance/i-1", "", "m 1", http.StatusBadRequest},
+		{http.MethodPut, "/metrics/job", "", "m 1", http.StatusBadRequest},
+		{http.MethodPut, "/metrics/job/batch", "", "m{ 1", http.StatusBadRequest},
+		{http.MethodPut, "/metrics/job/batch", "application/vnd.google.protobuf", "", http.StatusUnsupportedMediaType},
+		{http.MethodGet, "/metrics/job/batch", "", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/metrics/job/batch", "", "", http.StatusAccepted},
+	} {
+		req, err := http.NewRequest(tc.method, url+tc.path, bytes.NewBufferString(tc.body))
+		require.NoError(t, err)
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tc.status, resp.StatusCode, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestParseGroupingPath(t *testing.T) {
+	tags, err := parseGroupingPath("/metrics/job/batch/instance/i-1/empty@base64/=")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job:batch", "instance:i-1"}, tags)
+
+	tags, err = parseGroupingPath("/metrics/job@base64/L3Zhci90bXA/path@base64/L3Zhci90bXA=")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job:/var/tmp", "path:/var/tmp"}, tags)
+
+	for _, path := range []string{"/metrics/", "/metrics/job/", "/metrics/job/a/b", "/metrics/job/a/b:c/d", "/metrics/job@base64/!!"} {
+		_, err = parseGroupingPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+// getAvailableTCPPort requests a random port number and makes sure it is available
+func getAvailableTCPPort() (int, error) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return -1, fmt.Errorf("can't find an available tcp port: %s", err)
+	}
+	defer ln.Close()
+
+	_, portString, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		return -1, fmt.Errorf("can't find an available tcp port: %s", err)
+	}
+	portInt, err := strconv.Atoi(portString)
+	if err != nil {
+		return -1, fmt.Errorf("can't convert tcp port: %s", err)
+	}
+
+	return portInt, nil
+}
@@ -0,0 +1,345 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenMetrics/Prometheus metric family types, as found in `# TYPE` lines.
+const (
+	familyCounter        = "counter"
+	familyGauge          = "gauge"
+	familyHistogram      = "histogram"
+	familyGaugeHistogram = "gaugehistogram"
+	familySummary        = "summary"
+)
+
+// familySuffixes are the sample name suffixes that can be appended to a
+// metric family name. They are tried in order when a sample name doesn't
+// match a declared family.
+var familySuffixes = []string{"_total", "_bucket", "_count", "_sum", "_gcount", "_gsum", "_created", "_info"}
+
+// statsd symbols used to build the converted messages
+const (
+	statsdCount = "c"
+	statsdGauge = "g"
+)
+
+// label renamed for histogram buckets, consistent with the openmetrics check
+const (
+	bucketLabel      = "le"
+	bucketUpperBound = "upper_bound"
+)
+
+// expositionSample is a single sample line read from an exposition payload.
+type expositionSample struct {
+	name   string
+	labels [][2]string
+	value  float64
+}
+
+// cumulativeValues remembers the last value pushed for every cumulative
+// sample, so that only their increase is submitted as a count.
+// Samples are identified by their name, grouping labels, labels and
+// container, as they are in the converted message.
+type cumulativeValues struct {
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+func newCumulativeValues() *cumulativeValues {
+	return &cumulativeValues{last: make(map[string]float64)}
+}
+
+// increase records the value of a cumulative sample and returns its increase
+// since the previous push. The whole value is returned when the sample is
+// pushed for the first time, or when it decreased as the counter was reset.
+func (c *cumulativeValues) increase(key string, value float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last, found := c.last[key]
+	c.last[key] = value
+	if !found || value < last {
+		return value
+	}
+	return value - last
+}
+
+// convertExposition parses an OpenMetrics or Prometheus text exposition
+// payload and converts every sample into a DogStatsD metric message.
+//
+// Cumulative samples are submitted as the increase of their value since the
+// previous push, as recorded in cumulative. Their whole value is submitted
+// if cumulative is nil.
+//
+// groupingTags are added to every message (pushed labels with the same name
+// are dropped, as the Pushgateway does) and a non-empty containerID is set in
+// the container field so that the sample goes through the regular origin
+// detection.
+//
+// Nothing is returned if the payload is invalid, so that a push is either
+// fully ingested or fully rejected.
+func convertExposition(payload []byte, groupingTags []string, containerID string, cumulative *cumulativeValues) ([][]byte, error) {
+	families := make(map[string]string)
+	groupingKeys := make(map[string]struct{}, len(groupingTags))
+	for _, tag := range groupingTags {
+		groupingKeys[tag[:strings.IndexByte(tag, ':')]] = struct{}{}
+	}
+
+	type typedSample struct {
+		sample     expositionSample
+		statsdType string
+	}
+	var samples []typedSample
+	for lineno, line := range bytes.Split(payload, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '#' {
+			// only the TYPE metadata matters, HELP, UNIT and EOF are ignored
+			fields := strings.Fields(string(line[1:]))
+			if len(fields) >= 3 && fields[0] == "TYPE" {
+				families[fields[1]] = strings.ToLower(fields[2])
+			}
+			continue
+		}
+
+		sample, err := parseExpositionSample(string(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno+1, err)
+		}
+
+		// statsd can't carry these values
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			continue
+		}
+
+		familyType, suffix := resolveFamily(sample.name, families)
+		statsdType, ok := statsdTypeFor(familyType, suffix)
+		if !ok {
+			continue
+		}
+
+		samples = append(samples, typedSample{sample, statsdType})
+	}
+
+	// the cumulative values are only recorded once the whole payload is valid
+	messages := make([][]byte, 0, len(samples))
+	for _, s := range samples {
+		name := sanitizeStatsdName(s.sample.name)
+		fields := buildStatsdFields(s.sample, s.statsdType, groupingTags, groupingKeys, containerID)
+		value := s.sample.value
+		if s.statsdType == statsdCount && cumulative != nil {
+			value = cumulative.increase(name+fields, value)
+		}
+		messages = append(messages, buildStatsdMessage(name, value, fields))
+	}
+
+	return messages, nil
+}
+
+// resolveFamily returns the type of the family the sample belongs to, along
+// with the suffix distinguishing the sample inside its family.
+func resolveFamily(name string, families map[string]string) (string, string) {
+	if familyType, found := families[name]; found {
+		return familyType, ""
+	}
+	for _, suffix := range familySuffixes {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if familyType, found := families[strings.TrimSuffix(name, suffix)]; found {
+			return familyType, suffix
+		}
+	}
+	return "", ""
+}
+
+// statsdTypeFor maps a sample to the DogStatsD type it is submitted as.
+// Cumulative values (counters, histogram buckets, summary sums and counts)
+// are submitted as counts of their increase, everything else as gauges. The second return
+// value is false for samples which must be skipped.
+func statsdTypeFor(familyType, suffix string) (string, bool) {
+	if suffix == "_created" {
+		return "", false
+	}
+
+	switch familyType {
+	case familyCounter:
+		return statsdCount, true
+	case familyHistogram:
+		return statsdCount, true
+	case familySummary:
+		if suffix == "_sum" || suffix == "_count" {
+			return statsdCount, true
+		}
+		return statsdGauge, true
+	case familyGaugeHistogram:
+		return statsdGauge, true
+	}
+
+	// gauge, info, stateset, unknown and untyped
+	return statsdGauge, true
+}
+
+func buildStatsdMessage(name string, value float64, fields string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteString(fields)
+
+	return b.Bytes()
+}
+
+// buildStatsdFields returns the fields of the message following the value:
+// its type, tags and container.
+func buildStatsdFields(sample expositionSample, statsdType string, groupingTags []string, groupingKeys map[string]struct{}, containerID string) string {
+	var b strings.Builder
+
+	b.WriteByte('|')
+	b.WriteString(statsdType)
+
+	sep := "|#"
+	writeTag := func(key, value string) {
+		b.WriteString(sep)
+		sep = ","
+		b.WriteString(sanitizeStatsdTag(key))
+		b.WriteByte(':')
+		b.WriteString(sanitizeStatsdTag(value))
+	}
+	for _, tag := range groupingTags {
+		idx := strings.IndexByte(tag, ':')
+		writeTag(tag[:idx], tag[idx+1:])
+	}
+	for _, label := range sample.labels {
+		if _, found := groupingKeys[label[0]]; found {
+			continue
+		}
+		key := label[0]
+		if key == bucketLabel {
+			key = bucketUpperBound
+		}
+		writeTag(key, label[1])
+	}
+
+	if containerID != "" {
+		b.WriteString("|c:")
+		b.WriteString(containerID)
+	}
+
+	return b.String()
+}
+
+// parseExpositionSample parses a `name{label="value",...} value [timestamp] [# exemplar]` line.
+// The timestamp and exemplar, if any, are ignored.
+func parseExpositionSample(line string) (expositionSample, error) {
+	var sample expositionSample
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	sample.name = line[:end]
+	line = line[end:]
+
+	if line[0] == '{' {
+		var err error
+		sample.labels, line, err = parseExpositionLabels(line[1:])
+		if err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value for %q", sample.name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value for %q: %v", sample.name, err)
+	}
+	sample.value = value
+
+	return sample, nil
+}
+
+// parseExpositionLabels parses the labels of a sample, starting right after
+// the opening brace. It returns the labels and the remaining of the line
+// after the closing brace.
+func parseExpositionLabels(line string) ([][2]string, string, error) {
+	var labels [][2]string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if line[0] == '}' {
+			return labels, line[1:], nil
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid label in %q", line)
+		}
+		key := strings.TrimSpace(line[:eq])
+		line = strings.TrimLeft(line[eq+1:], " \t")
+		if len(line) == 0 || line[0] != '"' {
+			return nil, "", fmt.Errorf("label value of %q should be quoted", key)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] != '\\' || i+1 == len(line) {
+				value.WriteByte(line[i])
+				continue
+			}
+			i++
+			switch line[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(line[i])
+			}
+		}
+		if i == len(line) {
+			return nil, "", fmt.Errorf("unterminated value for label %q", key)
+		}
+		line = strings.TrimLeft(line[i+1:], " \t")
+		if len(line) > 0 && line[0] == ',' {
+			line = line[1:]
+		}
+
+		// empty label values are equivalent to the label being absent
+		if value.Len() > 0 {
+			labels = append(labels, [2]string{key, value.String()})
+		}
+	}
+}
+
+// sanitizeStatsdName replaces the colons allowed in Prometheus metric names,
+// as they separate the name from the value in the statsd format.
+func sanitizeStatsdName(name string) string {
+	return strings.ReplaceAll(name, ":", "_")
+}
+
+var tagReplacer = strings.NewReplacer("|", "_", ",", "_", "\n", "_", "\r", "_")
+
+// sanitizeStatsdTag replaces the characters delimiting statsd fields and tags.
+func sanitizeStatsdTag(tag string) string {
+	return tagReplacer.Replace(tag)
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func convertToStrings(t *testing.T, payload string, groupingTags []string, containerID string) []string {
+	messages, err := convertExposition([]byte(payload), groupingTags, containerID, nil)
+	require.NoError(t, err)
+
+	res := make([]string, 0, len(messages))
+	for _, m := range messages {
+		res = append(res, string(m))
+	}
+	return res
+}
+
+func TestConvertPrometheusText(t *testing.T) {
+	payload := `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+# TYPE temperature gauge
+temperature{room="a, b|c"} -3.5
+# A comment
+untyped_metric 12
+nan_metric NaN
+
+# TYPE job_duration_seconds histogram
+job_duration_seconds_bucket{le="0.5"} 4
+job_duration_seconds_bucket{le="+Inf"} 5
+job_duration_seconds_sum 3.2
+job_duration_seconds_count 5
+
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds_sum 17
+rpc_duration_seconds_count 2693
+`
+	assert.Equal(t, []string{
+		"http_requests_total:1027|c|#job:batch,method:post,code:200",
+		"http_requests_total:3|c|#job:batch,method:post,code:400",
+		"temperature:-3.5|g|#job:batch,room:a_ b_c",
+		"untyped_metric:12|g|#job:batch",
+		"job_duration_seconds_bucket:4|c|#job:batch,upper_bound:0.5",
+		"job_duration_seconds_bucket:5|c|#job:batch,upper_bound:+Inf",
+		"job_duration_seconds_sum:3.2|c|#job:batch",
+		"job_duration_seconds_count:5|c|#job:batch",
+		"rpc_duration_seconds:0.05|g|#job:batch,quantile:0.5",
+		"rpc_duration_seconds_sum:17|c|#job:batch",
+		"rpc_duration_seconds_count:2693|c|#job:batch",
+	}, convertToStrings(t, payload, []string{"job:batch"}, ""))
+}
+
+func TestConvertOpenMetricsText(t *testing.T) {
+	payload := `# TYPE acme_http_router_request_seconds summary
+# UNIT acme_http_router_request_seconds seconds
+acme_http_router_request_seconds_sum{path="/api/v1",method="GET"} 9036.32
+acme_http_router_request_seconds_count{path="/api/v1",method="GET"} 807283.0
+acme_http_router_request_seconds_created{path="/api/v1",method="GET"} 1605281325.0
+# TYPE foo counter
+foo_total 17.0 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67
+foo_created 1520872607.123
+# TYPE build info
+build_info{version="1.0",empty=""} 1
+# EOF
+`
+	assert.Equal(t, []string{
+		"acme_http_router_request_seconds_sum:9036.32|c|#path:/api/v1,method:GET",
+		"acme_http_router_request_seconds_count:807283|c|#path:/api/v1,method:GET",
+		"foo_total:17|c",
+		"build_info:1|g|#version:1.0",
+	}, convertToStrings(t, payload, nil, ""))
+}
+
+func TestConvertGroupingLabelsAndContainer(t *testing.T) {
+	payload := `some:metric{job="overridden",instance="i-1",escaped="a\"b\\c\nd"} 1`
+	assert.Equal(t, []string{
+		"some_metric:1|g|#job:batch,instance:i-1,escaped:a\"b\\c_d|c:abcdef",
+	}, convertToStrings(t, payload, []string{"job:batch"}, "abcdef"))
+}
+
+func TestConvertInvalidPayload(t *testing.T) {
+	for _, payload := range []string{
+		"metric_without_value",
+		`metric{label="value" 1`,
+		`metric{label=value} 1`,
+		`metric{label="value} 1`,
+		"metric abc",
+		"valid 1\ninvalid",
+	} {
+		messages, err := convertExposition([]byte(payload), nil, "", nil)
+		assert.Error(t, err, payload)
+		assert.Nil(t, messages, payload)
+	}
+}
+
+func TestConvertCumulativeIncrease(t *testing.T) {
+	cumulative := newCumulativeValues()
+	convert := func(payload string, groupingTags []string) []string {
+		messages, err := convertExposition([]byte(payload), groupingTags, "", cumulative)
+		require.NoError(t, err)
+		res := make([]string, 0, len(messages))
+		for _, m := range messages {
+			res = append(res, string(m))
+		}
+		return res
+	}
+
+	payload := "# TYPE requests counter\nrequests_total 10\n# TYPE temperature gauge\ntemperature 20\n"
+	assert.Equal(t, []string{"requests_total:10|c", "temperature:20|g"}, convert(payload, nil))
+
+	payload = "# TYPE requests counter\nrequests_total 15\n# TYPE temperature gauge\ntemperature 20\n"
+	assert.Equal(t, []string{"requests_total:5|c", "temperature:20|g"}, convert(payload, nil))
+
+	// another grouping key is another sample
+	assert.Equal(t, []string{"requests_total:15|c|#job:batch", "temperature:20|g|#job:batch"}, convert(payload, []string{"job:batch"}))
+
+	// the counter was reset
+	payload = "# TYPE requests counter\nrequests_total 4\n"
+	assert.Equal(t, []string{"requests_total:4|c"}, convert(payload, nil))
+
+	// an invalid payload doesn't record anything
+	_, err := convertExposition([]byte("# TYPE requests counter\nrequests_total 100\ninvalid\n"), nil, "", cumulative)
+	require.Error(t, err)
+
+	payload = "# TYPE job_duration_seconds histogram\njob_duration_seconds_bucket{le=\"1\"} 4\njob_duration_seconds_count 4\njob_duration_seconds_sum 2.5\n" +
+		"requests_total 6\n"
+	assert.Equal(t, []string{
+		"job_duration_seconds_bucket:4|c|#upper_bound:1",
+		"job_duration_seconds_count:4|c",
+		"job_duration_seconds_sum:2.5|c",
+		"requests_total:6|g",
+	}, convert(payload, nil))
+
+	payload = "# TYPE job_duration_seconds histogram\njob_duration_seconds_bucket{le=\"1\"} 7\njob_duration_seconds_count 7\njob_duration_seconds_sum 4\n" +
+		"# TYPE requests counter\nrequests_total 6\n"
+	assert.Equal(t, []string{
+		"job_duration_seconds_bucket:3|c|#upper_bound:1",
+		"job_duration_seconds_count:3|c",
+		"job_duration_seconds_sum:1.5|c",
+		"requests_total:2|c",
+	}, convert(payload, nil))
+}
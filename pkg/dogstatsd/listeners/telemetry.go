@@ -24,6 +24,12 @@ var (
 	tlmUDSPacketsBytes = telemetry.NewCounter("dogstatsd", "uds_packets_bytes",
 		nil, "Dogstatsd UDS packets bytes")
 
+	// HTTP
+	tlmHTTPRequests = telemetry.NewCounter("dogstatsd", "http_requests",
+		[]string{"state"}, "Dogstatsd HTTP push requests count")
+	tlmHTTPBytes = telemetry.NewCounter("dogstatsd", "http_bytes",
+		nil, "Dogstatsd HTTP push payloads bytes count")
+
 	tlmListener            = telemetry.NewHistogramNoOp()
 	defaultListenerBuckets = []float64{300, 500, 1000, 1500, 2000, 2500, 3000, 10000, 20000, 50000}
 )
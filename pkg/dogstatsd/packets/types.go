@@ -15,6 +15,8 @@ const (
 	UDS
 	// NamedPipe Windows named pipe listner
 	NamedPipe
+	// HTTP OpenMetrics push listener
+	HTTP
 )
 
 // Packet represents a statsd packet ready to process,
@@ -46,11 +46,14 @@ var (
 	dogstatsdMetricPackets            = expvar.Int{}
 	dogstatsdPacketsLastSec           = expvar.Int{}
 	dogstatsdUnterminatedMetricErrors = expvar.Int{}
+	dogstatsdMetricMapperDrops        = expvar.Int{}
 
 	tlmProcessed = telemetry.NewCounter("dogstatsd", "processed",
 		[]string{"message_type", "state", "origin"}, "Count of service checks/events/metrics processed by dogstatsd")
 	tlmProcessedOk    = tlmProcessed.WithValues("metrics", "ok", "")
 	tlmProcessedError = tlmProcessed.WithValues("metrics", "error", "")
+	// metrics dropped by the mapper
+	tlmProcessedDropped = tlmProcessed.WithValues("metrics", "dropped", "")
 
 	// while we try to add the origin tag in the tlmProcessed metric, we want to
 	// avoid having it growing indefinitely, hence this safeguard to limit the
@@ -71,6 +74,7 @@ func init() {
 	dogstatsdExpvars.Set("MetricParseErrors", &dogstatsdMetricParseErrors)
 	dogstatsdExpvars.Set("MetricPackets", &dogstatsdMetricPackets)
 	dogstatsdExpvars.Set("UnterminatedMetricErrors", &dogstatsdUnterminatedMetricErrors)
+	dogstatsdExpvars.Set("MetricMapperDrops", &dogstatsdMetricMapperDrops)
 }
 
 // used in debug mode to add the origin on the processed metric as a tag
@@ -118,7 +122,7 @@ func initLatencyTelemetry() {
 
 // Server represent a Dogstatsd server
 type Server struct {
-	// listeners are the instantiated socket listener (UDS or UDP or both)
+	// listeners are the instantiated socket listener (UDS, UDP, named pipe or HTTP)
 	listeners []listeners.StatsdListener
 
 	// demultiplexer will receive the metrics processed by the DogStatsD server,
@@ -281,6 +285,15 @@ func NewServer(demultiplexer aggregator.Demultiplexer, serverless bool) (*Server
 		}
 	}
 
+	if config.Datadog.GetInt("dogstatsd_pushgateway_port") > 0 {
+		httpListener, err := listeners.NewHTTPListener(packetsChannel, sharedPacketPoolManager, capture)
+		if err != nil {
+			log.Errorf(err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, httpListener)
+		}
+	}
+
 	pipeName := config.Datadog.GetString("dogstatsd_pipe_name")
 	if len(pipeName) > 0 {
 		namedPipeListener, err := listeners.NewNamedPipeListener(pipeName, packetsChannel, sharedPacketPoolManager, capture)
@@ -679,11 +692,20 @@ func (s *Server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 	}
 
 	if s.mapper != nil {
-		mapResult := s.mapper.Map(sample.name)
+		mapResult := s.mapper.Map(sample.name, sample.tags)
 		if mapResult != nil {
+			if mapResult.Drop {
+				log.Tracef("Dogstatsd mapper: metric %q dropped", sample.name)
+				if len(sample.values) > 0 {
+					s.sharedFloat64List.put(sample.values)
+				}
+				dogstatsdMetricMapperDrops.Add(1)
+				tlmProcessedDropped.Inc()
+				return metricSamples, nil
+			}
 			log.Tracef("Dogstatsd mapper: metric mapped from %q to %q with tags %v", sample.name, mapResult.Name, mapResult.Tags)
 			sample.name = mapResult.Name
-			sample.tags = append(sample.tags, mapResult.Tags...)
+			sample.tags = append(mapResult.RewriteTags(sample.tags), mapResult.Tags...)
 		}
 	}
 
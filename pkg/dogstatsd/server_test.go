@@ -872,6 +872,39 @@ dogstatsd_mapper_profiles:
 			},
 			expectedCacheSize: 1000,
 		},
+		{
+			name: "Tag rules",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.cache.*"
+        match_tags:
+          env: "dev|staging"
+        drop: true
+      - match: "test.cache.*"
+        name: "test.cache"
+        tags:
+          op: "$1"
+        rename_tags:
+          hostname: instance
+        remove_tags:
+          - request_id
+        max_tag_values:
+          user: 1
+`,
+			packets: []string{
+				"test.cache.get:1|c|#env:dev",
+				"test.cache.get:1|c|#env:prod,hostname:a,request_id:42,user:bob",
+				"test.cache.set:1|c|#env:prod,user:alice",
+			},
+			expectedSamples: []MetricSample{
+				{Name: "test.cache", Tags: []string{"op:get", "env:prod", "instance:a", "user:bob"}, Mtype: metrics.CounterType, Value: 1.0},
+				{Name: "test.cache", Tags: []string{"op:set", "env:prod", "user:overflow"}, Mtype: metrics.CounterType, Value: 1.0},
+			},
+			expectedCacheSize: 1000,
+		},
 		{
 			name: "Cache size",
 			config: `
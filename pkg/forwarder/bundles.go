@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/internal/retry"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	utilhttp "github.com/DataDog/datadog-agent/pkg/util/http"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// bundleRecorderDomain is the domain of the transactions recorded in offline mode. The
+	// transactions are recorded once, whatever the domains configured, and sent to the domains
+	// of the Agent uploading the bundles.
+	bundleRecorderDomain = "offline"
+	// bundleRecorderAPIKey is the API key of the transactions recorded in offline mode. It is
+	// replaced by a placeholder in the bundles, then by the API keys of the Agent uploading them.
+	bundleRecorderAPIKey = "recorded-api-key"
+)
+
+// newBundleRecorder returns the recorder of the forwarder in offline mode, nil if the offline mode
+// is disabled
+func newBundleRecorder(agentName string) (*retry.BundleRecorder, resolver.DomainResolver, error) {
+	recordPath := config.Datadog.GetString("forwarder_record_path")
+	if recordPath == "" {
+		return nil, nil, nil
+	}
+	// As for the storage on disk, only the core Agent can record its transactions.
+	if agentName == "" {
+		log.Infof("The offline mode of the forwarder is unavailable for this process.")
+		return nil, nil, nil
+	}
+	encryption, err := getStorageEncryption()
+	if err != nil {
+		return nil, nil, err
+	}
+	dr := resolver.NewSingleDomainResolver(bundleRecorderDomain, []string{bundleRecorderAPIKey})
+	recorder, err := retry.NewBundleRecorder(
+		recordPath,
+		config.Datadog.GetInt64("forwarder_record_bundle_max_size_in_bytes"),
+		config.Datadog.GetDuration("forwarder_record_bundle_max_duration"),
+		config.Datadog.GetInt64("forwarder_record_max_size_in_bytes"),
+		encryption,
+		dr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return recorder, dr, nil
+}
+
+// getStorageEncryption returns the encryption of the files stored on the disk, nil if not configured
+func getStorageEncryption() (*retry.StorageEncryption, error) {
+	// The keys are usually `ENC[]` handles resolved by the secrets backend.
+	keys := config.Datadog.GetStringSlice("forwarder_storage_encryption_keys")
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return retry.NewStorageEncryption(keys)
+}
+
+// recordHTTPTransactions writes the transactions to the bundles in offline mode
+func (f *DefaultForwarder) recordHTTPTransactions(transactions []*transaction.HTTPTransaction) error {
+	recorded := make([]transaction.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		recorded = append(recorded, t)
+	}
+	if err := f.recorder.Record(recorded); err != nil {
+		return fmt.Errorf("cannot record the transactions: %v", err)
+	}
+	return nil
+}
+
+// ListRecordedBundles returns the paths of the bundles recorded in a folder by a forwarder in
+// offline mode, oldest first
+func ListRecordedBundles(folderPath string) ([]string, error) {
+	return retry.ListBundles(folderPath)
+}
+
+// BundleUploader sends the transactions recorded by a forwarder in offline mode to the domains of
+// the Agent, with their original creation time.
+type BundleUploader struct {
+	domainResolvers map[string]resolver.DomainResolver
+	encryption      *retry.StorageEncryption
+	client          *http.Client
+}
+
+// NewBundleUploader returns a new BundleUploader
+func NewBundleUploader(domainResolvers map[string]resolver.DomainResolver, timeout time.Duration) (*BundleUploader, error) {
+	encryption, err := getStorageEncryption()
+	if err != nil {
+		return nil, err
+	}
+	u := &BundleUploader{
+		domainResolvers: make(map[string]resolver.DomainResolver, len(domainResolvers)),
+		encryption:      encryption,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: utilhttp.CreateHTTPTransport(),
+		},
+	}
+	for domain, dr := range domainResolvers {
+		domain, _ := config.AddAgentVersionToDomain(domain, "app")
+		dr.SetBaseDomain(domain)
+		if len(dr.GetAPIKeys()) == 0 {
+			log.Errorf("No API keys for domain '%s', dropping domain ", domain)
+			continue
+		}
+		u.domainResolvers[domain] = dr
+	}
+	if len(u.domainResolvers) == 0 {
+		return nil, fmt.Errorf("no domain to upload the bundles to")
+	}
+	return u, nil
+}
+
+// UploadBundle sends the transactions of a bundle to each domain with each of its API keys, and
+// removes the bundle once sent. If a transaction cannot be sent, or if an API key is rejected, the
+// bundle is kept with the progress of its upload, so that uploading it again only sends the
+// transactions which were not sent yet. Returns the number of transactions sent, and the number of
+// transactions rejected by the intake, which are dropped.
+func (u *BundleUploader) UploadBundle(ctx context.Context, bundlePath string) (int, int, error) {
+	content, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	progress, err := retry.LoadBundleProgress(bundlePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot read the upload progress of the bundle %s: %v", bundlePath, err)
+	}
+
+	sent, dropped := 0, 0
+	for domain, dr := range u.domainResolvers {
+		for _, apiKey := range dr.GetAPIKeys() {
+			serializer := retry.NewHTTPTransactionsSerializer(apiKeyResolver{DomainResolver: dr, apiKey: apiKey})
+			transactions, errorCount, err := retry.DeserializeBundle(content, serializer, u.encryption)
+			if err != nil {
+				return sent, dropped, fmt.Errorf("cannot read the bundle %s: %v", bundlePath, err)
+			}
+			if errorCount > 0 {
+				log.Errorf("Cannot read %d transactions of the bundle %s", errorCount, bundlePath)
+			}
+			for i := progress.SentCount(domain, apiKey); i < len(transactions); i++ {
+				t := transactions[i]
+				httpTransaction := t.(*transaction.HTTPTransaction)
+				if isRoutedTo(domain, dr, resolver.TransactionKindOf(httpTransaction), httpTransaction.Payload) {
+					// The transactions rejected by the intake are dropped without error by Process.
+					var statusCode int
+					var completionErr error
+					httpTransaction.CompletionHandler = func(_ *transaction.HTTPTransaction, code int, _ []byte, err error) {
+						statusCode, completionErr = code, err
+					}
+					if err := t.Process(ctx, u.client); err != nil {
+						return sent, dropped, saveUploadProgress(progress, bundlePath, err)
+					}
+					// A canceled transaction is not reported as an error.
+					if err := ctx.Err(); err != nil {
+						return sent, dropped, saveUploadProgress(progress, bundlePath, err)
+					}
+					switch {
+					case statusCode == http.StatusForbidden:
+						return sent, dropped, saveUploadProgress(progress, bundlePath, fmt.Errorf("an API key of %s is invalid", domain))
+					case completionErr != nil:
+						return sent, dropped, saveUploadProgress(progress, bundlePath, completionErr)
+					case statusCode == http.StatusBadRequest || statusCode == http.StatusRequestEntityTooLarge:
+						dropped++
+					default:
+						sent++
+					}
+				}
+				progress.SetSentCount(domain, apiKey, i+1)
+			}
+		}
+	}
+	if dropped > 0 {
+		log.Warnf("%d transactions of the bundle %s were rejected by the intake and dropped", dropped, bundlePath)
+	}
+	if err := os.Remove(bundlePath); err != nil {
+		return sent, dropped, err
+	}
+	return sent, dropped, progress.Remove()
+}
+
+// saveUploadProgress records the progress of the upload of a bundle which failed with uploadErr,
+// and returns uploadErr
+func saveUploadProgress(progress *retry.BundleProgress, bundlePath string, uploadErr error) error {
+	if err := progress.Save(); err != nil {
+		log.Errorf("Cannot record the upload progress of the bundle %s, its next upload sends all its transactions again: %v", bundlePath, err)
+	}
+	return uploadErr
+}
+
+// apiKeyResolver restores a single API key in the recorded transactions
+type apiKeyResolver struct {
+	resolver.DomainResolver
+	apiKey string
+}
+
+// GetAPIKeys returns the API key to restore
+func (r apiKeyResolver) GetAPIKeys() []string {
+	return []string{r.apiKey}
+}
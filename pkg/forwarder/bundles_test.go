@@ -0,0 +1,265 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+func TestOfflineModeRecordAndUpload(t *testing.T) {
+	mockConfig := config.Mock(t)
+	recordPath := t.TempDir()
+	mockConfig.Set("forwarder_record_path", recordPath)
+
+	// Record the transactions on the air-gapped host
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	options.EnabledFeatures = SetFeature(options.EnabledFeatures, CoreFeatures)
+	f := NewDefaultForwarder(options)
+	require.NotNil(t, f.recorder)
+	assert.Empty(t, f.domainForwarders)
+	require.NoError(t, f.Start())
+
+	series := []byte("series payload")
+	checks := []byte("service checks payload")
+	hostMetadata := []byte("host metadata payload")
+	assert.NoError(t, f.SubmitSeries(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&series}), http.Header{"Content-Encoding": {"deflate"}}))
+	assert.NoError(t, f.SubmitV1CheckRuns(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&checks}), http.Header{}))
+	// The host metadata holds the API key and is never recorded.
+	assert.NoError(t, f.SubmitHostMetadata(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&hostMetadata}), http.Header{}))
+	f.Stop()
+
+	bundles, err := ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	content, err := ioutil.ReadFile(bundles[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "api-key-1")
+	assert.NotContains(t, string(content), bundleRecorderAPIKey)
+
+	// Upload them from a connected host
+	var m sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		m.Lock()
+		requests = append(requests, r.URL.String()+" "+r.Header.Get("DD-Api-Key")+" "+r.Header.Get("Content-Encoding")+" "+string(body))
+		m.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	uploader, err := NewBundleUploader(resolver.NewSingleDomainResolvers(map[string][]string{ts.URL: {"key-a", "key-b"}}), 5*time.Second)
+	require.NoError(t, err)
+	sent, dropped, err := uploader.UploadBundle(context.Background(), bundles[0])
+	require.NoError(t, err)
+	assert.Equal(t, 4, sent)
+	assert.Zero(t, dropped)
+
+	sort.Strings(requests)
+	assert.Equal(t, []string{
+		"/api/v1/check_run?api_key=key-a key-a  service checks payload",
+		"/api/v1/check_run?api_key=key-b key-b  service checks payload",
+		"/api/v2/series key-a deflate series payload",
+		"/api/v2/series key-b deflate series payload",
+	}, requests)
+
+	bundles, err = ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	assert.Empty(t, bundles, "the bundle is removed once uploaded")
+}
+
+func TestOfflineModeUploadFailure(t *testing.T) {
+	mockConfig := config.Mock(t)
+	recordPath := t.TempDir()
+	mockConfig.Set("forwarder_record_path", recordPath)
+
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	options.EnabledFeatures = SetFeature(options.EnabledFeatures, CoreFeatures)
+	f := NewDefaultForwarder(options)
+	require.NoError(t, f.Start())
+	series := []byte("series payload")
+	assert.NoError(t, f.SubmitSeries(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&series}), http.Header{}))
+	f.Stop()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	bundles, err := ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	uploader, err := NewBundleUploader(resolver.NewSingleDomainResolvers(map[string][]string{ts.URL: {"key-a"}}), 5*time.Second)
+	require.NoError(t, err)
+	_, _, err = uploader.UploadBundle(context.Background(), bundles[0])
+	assert.Error(t, err)
+
+	bundles, err = ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	assert.Len(t, bundles, 1, "the bundle is kept to be uploaded again")
+}
+
+func TestOfflineModeUploadRejected(t *testing.T) {
+	mockConfig := config.Mock(t)
+	// The transactions rejected by the intake are counted as dropped by the forwarder.
+	droppedCount := transaction.TransactionsDropped.Value()
+	defer transaction.TransactionsDropped.Set(droppedCount)
+	recordPath := t.TempDir()
+	mockConfig.Set("forwarder_record_path", recordPath)
+
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	options.EnabledFeatures = SetFeature(options.EnabledFeatures, CoreFeatures)
+	f := NewDefaultForwarder(options)
+	require.NoError(t, f.Start())
+	series := []byte("series payload")
+	checks := []byte("service checks payload")
+	assert.NoError(t, f.SubmitSeries(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&series}), http.Header{}))
+	assert.NoError(t, f.SubmitV1CheckRuns(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&checks}), http.Header{}))
+	f.Stop()
+
+	var m sync.Mutex
+	statusCode := http.StatusForbidden
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		// The series are too large for the intake, the service checks are accepted.
+		if r.URL.Path == "/api/v2/series" && statusCode != http.StatusForbidden {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(statusCode)
+	}))
+	defer ts.Close()
+
+	bundles, err := ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	uploader, err := NewBundleUploader(resolver.NewSingleDomainResolvers(map[string][]string{ts.URL: {"revoked-key"}}), 5*time.Second)
+	require.NoError(t, err)
+
+	// An invalid API key keeps the bundle
+	sent, dropped, err := uploader.UploadBundle(context.Background(), bundles[0])
+	assert.Error(t, err)
+	assert.Zero(t, sent)
+	assert.Zero(t, dropped)
+	bundles, err = ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1, "the bundle is kept to be uploaded again")
+
+	// The transactions rejected by the intake are dropped, and not reported as sent
+	m.Lock()
+	statusCode = http.StatusAccepted
+	m.Unlock()
+	sent, dropped, err = uploader.UploadBundle(context.Background(), bundles[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 1, dropped)
+	bundles, err = ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	assert.Empty(t, bundles)
+}
+
+func TestOfflineModeUploadResume(t *testing.T) {
+	mockConfig := config.Mock(t)
+	recordPath := t.TempDir()
+	mockConfig.Set("forwarder_record_path", recordPath)
+
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	options.EnabledFeatures = SetFeature(options.EnabledFeatures, CoreFeatures)
+	f := NewDefaultForwarder(options)
+	require.NoError(t, f.Start())
+	series := []byte("series payload")
+	checks := []byte("service checks payload")
+	assert.NoError(t, f.SubmitSeries(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&series}), http.Header{}))
+	assert.NoError(t, f.SubmitV1CheckRuns(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&checks}), http.Header{}))
+	f.Stop()
+
+	var m sync.Mutex
+	requests := make(map[string][]string)
+	newServer := func(name string, accept func(count int) bool) *httptest.Server {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			m.Lock()
+			defer m.Unlock()
+			if !accept(len(requests[name])) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			requests[name] = append(requests[name], r.Header.Get("DD-Api-Key")+" "+string(body))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	// The second domain accepts the first transaction, then fails until it is available again.
+	available := false
+	first := newServer("first", func(int) bool { return true })
+	second := newServer("second", func(count int) bool { return count == 0 || available })
+
+	bundles, err := ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	uploader, err := NewBundleUploader(resolver.NewSingleDomainResolvers(map[string][]string{
+		first.URL:  {"key-a", "key-b"},
+		second.URL: {"key-c"},
+	}), 5*time.Second)
+	require.NoError(t, err)
+
+	_, _, err = uploader.UploadBundle(context.Background(), bundles[0])
+	assert.Error(t, err)
+	bundles, err = ListRecordedBundles(recordPath)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1, "the bundle is kept to be uploaded again")
+
+	m.Lock()
+	available = true
+	m.Unlock()
+	_, _, err = uploader.UploadBundle(context.Background(), bundles[0])
+	require.NoError(t, err)
+
+	for name := range requests {
+		sort.Strings(requests[name])
+	}
+	assert.Equal(t, map[string][]string{
+		"first": {
+			"key-a series payload",
+			"key-a service checks payload",
+			"key-b series payload",
+			"key-b service checks payload",
+		},
+		"second": {
+			"key-c series payload",
+			"key-c service checks payload",
+		},
+	}, requests, "each transaction is sent once to each domain with each API key")
+
+	entries, err := ioutil.ReadDir(recordPath)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the bundle and its upload progress are removed once uploaded")
+}
+
+func TestOfflineModeCoreOnly(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.Set("forwarder_record_path", t.TempDir())
+
+	f := NewDefaultForwarder(NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains)))
+	assert.Nil(t, f.recorder)
+	assert.NotEmpty(t, f.domainForwarders)
+}
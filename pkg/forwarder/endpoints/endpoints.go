@@ -56,4 +56,8 @@ var (
 	OrchestratorManifestEndpoint = transaction.Endpoint{Route: "/api/v2/orchmanif", Name: "orchmanifest"}
 	// ContainerLifecycleEndpoint is an event platform endpoint used to send container lifecycle events
 	ContainerLifecycleEndpoint = transaction.Endpoint{Route: "/api/v2/contlcycle", Name: "contlcycle"}
+
+	// PrometheusRemoteWriteEndpoint is used to send metrics to a Prometheus remote-write endpoint, whose
+	// configured URL already contains the request path
+	PrometheusRemoteWriteEndpoint = transaction.Endpoint{Route: "", Name: "prometheus_remote_write"}
 )
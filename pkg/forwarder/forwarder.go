@@ -65,6 +65,7 @@ type Forwarder interface {
 	SubmitHostMetadata(payload transaction.BytesPayloads, extra http.Header) error
 	SubmitAgentChecksMetadata(payload transaction.BytesPayloads, extra http.Header) error
 	SubmitMetadata(payload transaction.BytesPayloads, extra http.Header) error
+	SubmitProcessesMetadata(payload transaction.BytesPayloads, extra http.Header) error
 	SubmitProcessChecks(payload transaction.BytesPayloads, extra http.Header) (chan Response, error)
 	SubmitProcessDiscoveryChecks(payload transaction.BytesPayloads, extra http.Header) (chan Response, error)
 	SubmitProcessEventChecks(payload transaction.BytesPayloads, extra http.Header) (chan Response, error)
@@ -80,6 +81,26 @@ type Forwarder interface {
 // Compile-time check to ensure that DefaultForwarder implements the Forwarder interface
 var _ Forwarder = &DefaultForwarder{}
 
+// MetricRouter is implemented by the forwarders sending only a subset of the series and sketches to
+// some of their domains. The serializer sends dedicated payloads, restricted with
+// `transaction.BytesPayload.SetDomains`, to those domains.
+type MetricRouter interface {
+	MetricRoutingRules() map[string]*resolver.RoutingRule
+}
+
+// Compile-time check to ensure that DefaultForwarder implements the MetricRouter interface
+var _ MetricRouter = &DefaultForwarder{}
+
+// PrometheusRemoteWriter is implemented by the forwarders which can duplicate the series and
+// sketches to a Prometheus remote-write endpoint.
+type PrometheusRemoteWriter interface {
+	PrometheusRemoteWriteEnabled() bool
+	SubmitPrometheusRemoteWrite(payload transaction.BytesPayloads, extra http.Header) error
+}
+
+// Compile-time check to ensure that DefaultForwarder implements the PrometheusRemoteWriter interface
+var _ PrometheusRemoteWriter = &DefaultForwarder{}
+
 // Features is a bitmask to enable specific forwarder features
 type Features uint8
 
@@ -104,6 +125,10 @@ type Options struct {
 	DomainResolvers                map[string]resolver.DomainResolver
 	ConnectionResetInterval        time.Duration
 	CompletionHandler              transaction.HTTPCompletionHandler
+	// PrometheusRemoteWriteURL is the URL of the Prometheus remote-write endpoint the series and
+	// sketches are duplicated to, empty if disabled
+	PrometheusRemoteWriteURL     string
+	PrometheusRemoteWriteHeaders map[string]string
 }
 
 // SetFeature sets forwarder features in a feature set
@@ -133,7 +158,38 @@ func NewOptions(keysPerDomain map[string][]string) *Options {
 			vectorMetricsURL,
 		)
 	}
-	return NewOptionsWithResolvers(resolvers)
+	setRoutingRules(resolvers)
+	options := NewOptionsWithResolvers(resolvers)
+	if url := config.Datadog.GetString("prometheus_remote_write.url"); url != "" {
+		log.Debugf("Configuring forwarder to send metrics to the Prometheus remote-write endpoint: %s", url)
+		options.PrometheusRemoteWriteURL = url
+		options.PrometheusRemoteWriteHeaders = config.Datadog.GetStringMapString("prometheus_remote_write.headers")
+	}
+	return options
+}
+
+// setRoutingRules sets the routing rules configured in `forwarder_routes` on the domain resolvers
+func setRoutingRules(domainResolvers map[string]resolver.DomainResolver) {
+	routes, err := config.GetForwarderRoutes()
+	if err != nil {
+		log.Errorf("Misconfiguration of the forwarder routes, sending all the data to all the endpoints: %v", err)
+		return
+	}
+	for _, route := range routes {
+		dr, ok := domainResolvers[route.Domain]
+		if !ok {
+			log.Warnf("Ignoring the forwarder route of %q: it is not one of the configured endpoints", route.Domain)
+			continue
+		}
+		rule, err := resolver.NewRoutingRule(route.Endpoints, route.MetricPrefixes, route.MetricTags)
+		if err != nil {
+			log.Errorf("Ignoring the forwarder route of %q: %v", route.Domain, err)
+			continue
+		}
+		log.Debugf("Configuring forwarder to route data to %s: endpoints %v, metric prefixes %v, metric tags %v",
+			route.Domain, route.Endpoints, route.MetricPrefixes, route.MetricTags)
+		dr.SetRoutingRule(rule)
+	}
 }
 
 // NewOptionsWithResolvers creates new Options with default values
@@ -197,15 +253,22 @@ type DefaultForwarder struct {
 
 	domainForwarders map[string]*domainForwarder
 	domainResolvers  map[string]resolver.DomainResolver
-	healthChecker    *forwarderHealth
-	internalState    *atomic.Uint32
-	m                sync.Mutex // To control Start/Stop races
+	// prometheusRemoteWriteURL is the domain of the Prometheus remote-write domainForwarder, which is
+	// not part of domainResolvers as it does not receive the Datadog payloads
+	prometheusRemoteWriteURL     string
+	prometheusRemoteWriteHeaders map[string]string
+	healthChecker                *forwarderHealth
+	internalState                *atomic.Uint32
+	m                            sync.Mutex // To control Start/Stop races
 
 	completionHandler transaction.HTTPCompletionHandler
 
 	agentName                       string
 	queueDurationCapacity           *retry.QueueDurationCapacity
 	retryQueueDurationCapacityMutex sync.Mutex
+
+	// recorder writes the transactions to bundles instead of sending them, in offline mode
+	recorder *retry.BundleRecorder
 }
 
 // NewDefaultForwarder returns a new DefaultForwarder.
@@ -224,9 +287,29 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 		completionHandler: options.CompletionHandler,
 		agentName:         agentName,
 	}
+
+	recorder, recorderResolver, err := newBundleRecorder(agentName)
+	if err != nil {
+		// Never send the transactions of an air-gapped host.
+		log.Errorf("Cannot record the transactions to %s, dropping them: %v", config.Datadog.GetString("forwarder_record_path"), err)
+		f.healthChecker.disableAPIKeyChecking = true
+		return f
+	}
+	if recorder != nil {
+		// In offline mode, the transactions are recorded once instead of being sent to each domain.
+		f.recorder = recorder
+		f.domainResolvers[bundleRecorderDomain] = recorderResolver
+		f.healthChecker.disableAPIKeyChecking = true
+		if options.PrometheusRemoteWriteURL != "" {
+			log.Warnf("The Prometheus remote-write payloads are not recorded in offline mode")
+		}
+		return f
+	}
+
 	var optionalRemovalPolicy *retry.FileRemovalPolicy
 	storageMaxSize := config.Datadog.GetInt64("forwarder_storage_max_size_in_bytes")
 	var diskUsageLimit *retry.DiskUsageLimit
+	var storageEncryption *retry.StorageEncryption
 
 	// Disk Persistence is a core-only feature for now.
 	if storageMaxSize == 0 {
@@ -254,10 +337,21 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 		diskRatio := config.Datadog.GetFloat64("forwarder_storage_max_disk_ratio")
 		diskUsageLimit = retry.NewDiskUsageLimit(storagePath, filesystem.NewDisk(), storageMaxSize, diskRatio)
 
+		storageEncryption, err = getStorageEncryption()
+		if err != nil {
+			// Never store the transactions in plain text when the encryption is requested.
+			log.Errorf("Retry queue storage on disk disabled. Cannot use the storage encryption keys: %v", err)
+			diskUsageLimit = nil
+		}
 	} else {
 		log.Infof("Retry queue storage on disk is disabled because the feature is unavailable for this process.")
 	}
 
+	sheddingPolicy, err := getSheddingPolicy()
+	if err != nil {
+		log.Errorf("Ignoring the retry queue shedding configuration: %v", err)
+	}
+
 	flushToDiskMemRatio := config.Datadog.GetFloat64("forwarder_flush_to_disk_mem_ratio")
 	domainForwarderSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: true}
 	transactionContainerSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: false}
@@ -282,6 +376,8 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 				flushToDiskMemRatio,
 				domainFolderPath,
 				diskUsageLimit,
+				storageEncryption,
+				sheddingPolicy,
 				transactionContainerSort,
 				resolver)
 			f.domainResolvers[domain] = resolver
@@ -299,6 +395,27 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 		}
 	}
 
+	if options.PrometheusRemoteWriteURL != "" {
+		// The remote-write transactions are not stored on disk, as the headers may hold credentials.
+		transactionContainer := retry.BuildTransactionRetryQueue(
+			options.RetryQueuePayloadsTotalMaxSize,
+			flushToDiskMemRatio,
+			"",
+			nil,
+			nil,
+			nil,
+			transactionContainerSort,
+			resolver.NewSingleDomainResolver(options.PrometheusRemoteWriteURL, nil))
+		f.prometheusRemoteWriteURL = options.PrometheusRemoteWriteURL
+		f.prometheusRemoteWriteHeaders = options.PrometheusRemoteWriteHeaders
+		f.domainForwarders[f.prometheusRemoteWriteURL] = newDomainForwarder(
+			f.prometheusRemoteWriteURL,
+			transactionContainer,
+			options.NumberOfWorkers,
+			options.ConnectionResetInterval,
+			domainForwarderSort)
+	}
+
 	timeInterval := config.Datadog.GetInt("forwarder_retry_queue_capacity_time_interval_sec")
 	if f.agentName != "" {
 		f.queueDurationCapacity = retry.NewQueueDurationCapacity(
@@ -329,6 +446,24 @@ func getAgentName(options *Options) string {
 	return ""
 }
 
+// getSheddingPolicy returns the policy used by the retry queues to shed the transactions by endpoint
+// kind, nil if not configured
+func getSheddingPolicy() (*retry.SheddingPolicy, error) {
+	shedOrder := config.Datadog.GetStringSlice("forwarder_retry_queue_shed_order")
+	var memBudgets map[string]int
+	if err := config.Datadog.UnmarshalKey("forwarder_retry_queue_max_size_by_endpoint_kind", &memBudgets); err != nil {
+		return nil, err
+	}
+	var diskBudgets map[string]int64
+	if err := config.Datadog.UnmarshalKey("forwarder_storage_max_size_in_bytes_by_endpoint_kind", &diskBudgets); err != nil {
+		return nil, err
+	}
+	if len(shedOrder) == 0 && len(memBudgets) == 0 && len(diskBudgets) == 0 {
+		return nil, nil
+	}
+	return retry.NewSheddingPolicy(shedOrder, memBudgets, diskBudgets)
+}
+
 // Start initialize and runs the forwarder.
 func (f *DefaultForwarder) Start() error {
 	// Lock so we can't stop a Forwarder while is starting
@@ -349,8 +484,15 @@ func (f *DefaultForwarder) Start() error {
 		endpointLogs = append(endpointLogs, fmt.Sprintf("\"%s\" (%v api key(s))",
 			domain, len(dr.GetAPIKeys())))
 	}
-	log.Infof("Forwarder started, sending to %v endpoint(s) with %v worker(s) each: %s",
-		len(endpointLogs), f.NumberOfWorkers, strings.Join(endpointLogs, " ; "))
+	if f.PrometheusRemoteWriteEnabled() {
+		endpointLogs = append(endpointLogs, fmt.Sprintf("\"%s\" (Prometheus remote-write)", f.prometheusRemoteWriteURL))
+	}
+	if f.recorder != nil {
+		log.Infof("Forwarder started in offline mode, recording the transactions to %s", config.Datadog.GetString("forwarder_record_path"))
+	} else {
+		log.Infof("Forwarder started, sending to %v endpoint(s) with %v worker(s) each: %s",
+			len(endpointLogs), f.NumberOfWorkers, strings.Join(endpointLogs, " ; "))
+	}
 
 	f.healthChecker.Start()
 	f.internalState.Store(Started)
@@ -400,6 +542,12 @@ func (f *DefaultForwarder) Stop() {
 		}
 	}
 
+	if f.recorder != nil {
+		if err := f.recorder.Close(); err != nil {
+			log.Errorf("Cannot complete the current bundle: %v", err)
+		}
+	}
+
 	f.healthChecker.Stop()
 
 	f.healthChecker = nil
@@ -416,20 +564,54 @@ func (f *DefaultForwarder) State() uint32 {
 	return f.internalState.Load()
 }
 
+// MetricRoutingRules returns the routing rules of the domains only receiving a subset of the series
+// and sketches, by domain. Implements MetricRouter.
+func (f *DefaultForwarder) MetricRoutingRules() map[string]*resolver.RoutingRule {
+	rules := make(map[string]*resolver.RoutingRule)
+	for domain, dr := range f.domainResolvers {
+		if rule := dr.GetRoutingRule(); rule.FiltersMetrics() {
+			rules[domain] = rule
+		}
+	}
+	return rules
+}
+
+// isRoutedTo returns whether a payload of the given kind is sent to a domain
+func isRoutedTo(domain string, dr resolver.DomainResolver, kind resolver.EndpointKind, payload *transaction.BytesPayload) bool {
+	rule := dr.GetRoutingRule()
+	if !rule.AcceptsKind(kind) {
+		return false
+	}
+	if domains := payload.GetDomains(); domains != nil {
+		for _, d := range domains {
+			if d == domain {
+				return true
+			}
+		}
+		return false
+	}
+	// the serializer sends dedicated payloads to the domains filtering metrics
+	return !rule.FiltersMetrics() || (kind != resolver.SeriesKind && kind != resolver.SketchesKind)
+}
+
 func (f *DefaultForwarder) createHTTPTransactions(endpoint transaction.Endpoint, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header) []*transaction.HTTPTransaction {
-	return f.createAdvancedHTTPTransactions(endpoint, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, true)
+	return f.createAdvancedHTTPTransactions(endpoint, resolver.EndpointKindOf(endpoint), payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, true)
 }
 
-func (f *DefaultForwarder) createAdvancedHTTPTransactions(endpoint transaction.Endpoint, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header, priority transaction.Priority, storableOnDisk bool) []*transaction.HTTPTransaction {
+func (f *DefaultForwarder) createAdvancedHTTPTransactions(endpoint transaction.Endpoint, kind resolver.EndpointKind, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header, priority transaction.Priority, storableOnDisk bool) []*transaction.HTTPTransaction {
 	transactions := make([]*transaction.HTTPTransaction, 0, len(payloads)*len(f.domainForwarders))
 	allowArbitraryTags := config.Datadog.GetBool("allow_arbitrary_tags")
 
 	for _, payload := range payloads {
 		for domain, dr := range f.domainResolvers {
+			if !isRoutedTo(domain, dr, kind, payload) {
+				continue
+			}
 			for _, apiKey := range dr.GetAPIKeys() {
 				t := transaction.NewHTTPTransaction()
 				t.Domain, _ = dr.Resolve(endpoint)
 				t.Endpoint = endpoint
+				t.Kind = string(kind)
 				if apiKeyInQueryString {
 					t.Endpoint.Route = fmt.Sprintf("%s?api_key=%s", endpoint.Route, apiKey)
 				}
@@ -466,6 +648,9 @@ func (f *DefaultForwarder) sendHTTPTransactions(transactions []*transaction.HTTP
 	if f.internalState.Load() == Stopped {
 		return fmt.Errorf("the forwarder is not started")
 	}
+	if f.recorder != nil {
+		return f.recordHTTPTransactions(transactions)
+	}
 	if config.Datadog.GetBool("telemetry.enabled") {
 		f.retryQueueDurationCapacityMutex.Lock()
 		defer f.retryQueueDurationCapacityMutex.Unlock()
@@ -514,13 +699,52 @@ func (f *DefaultForwarder) SubmitSketchSeries(payload transaction.BytesPayloads,
 	return f.sendHTTPTransactions(transactions)
 }
 
+// PrometheusRemoteWriteEnabled returns whether a Prometheus remote-write endpoint is configured
+func (f *DefaultForwarder) PrometheusRemoteWriteEnabled() bool {
+	return f.prometheusRemoteWriteURL != ""
+}
+
+// SubmitPrometheusRemoteWrite will send Prometheus remote-write payloads to the remote-write endpoint
+func (f *DefaultForwarder) SubmitPrometheusRemoteWrite(payloads transaction.BytesPayloads, extra http.Header) error {
+	if !f.PrometheusRemoteWriteEnabled() {
+		return fmt.Errorf("no Prometheus remote-write endpoint is configured")
+	}
+	domain, endpoint := f.prometheusRemoteWriteURL, endpoints.PrometheusRemoteWriteEndpoint
+	transactions := make([]*transaction.HTTPTransaction, 0, len(payloads))
+	for _, payload := range payloads {
+		t := transaction.NewHTTPTransaction()
+		t.Domain = domain
+		t.Endpoint = endpoint
+		t.Payload = payload
+		t.Priority = transaction.TransactionPriorityNormal
+		t.StorableOnDisk = false
+		t.Headers.Set(useragentHTTPHeaderKey, fmt.Sprintf("datadog-agent/%s", version.AgentVersion))
+		for key, value := range f.prometheusRemoteWriteHeaders {
+			t.Headers.Set(key, value)
+		}
+		for key := range extra {
+			t.Headers.Set(key, extra.Get(key))
+		}
+		if f.completionHandler != nil {
+			t.CompletionHandler = f.completionHandler
+		}
+
+		tlmTxInputCount.Inc(domain, endpoint.Name)
+		tlmTxInputBytes.Add(float64(t.GetPayloadSize()), domain, endpoint.Name)
+		transactionsInputCountByEndpoint.Add(endpoint.Name, 1)
+		transactionsInputBytesByEndpoint.Add(endpoint.Name, int64(t.GetPayloadSize()))
+		transactions = append(transactions, t)
+	}
+	return f.sendHTTPTransactions(transactions)
+}
+
 // SubmitHostMetadata will send a host_metadata tag type payload to Datadog backend.
 func (f *DefaultForwarder) SubmitHostMetadata(payload transaction.BytesPayloads, extra http.Header) error {
 	return f.submitV1IntakeWithTransactionsFactory(payload, extra,
 		func(endpoint transaction.Endpoint, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header) []*transaction.HTTPTransaction {
 			// Host metadata contains the API KEY and should not be stored on disk.
 			storableOnDisk := false
-			return f.createAdvancedHTTPTransactions(endpoint, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityHigh, storableOnDisk)
+			return f.createAdvancedHTTPTransactions(endpoint, resolver.MetadataKind, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityHigh, storableOnDisk)
 		})
 }
 
@@ -530,7 +754,15 @@ func (f *DefaultForwarder) SubmitAgentChecksMetadata(payload transaction.BytesPa
 		func(endpoint transaction.Endpoint, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header) []*transaction.HTTPTransaction {
 			// Agentchecks metadata contains the API KEY and should not be stored on disk.
 			storableOnDisk := false
-			return f.createAdvancedHTTPTransactions(endpoint, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, storableOnDisk)
+			return f.createAdvancedHTTPTransactions(endpoint, resolver.MetadataKind, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, storableOnDisk)
+		})
+}
+
+// SubmitProcessesMetadata will send a legacy processes metadata payload to Datadog backend.
+func (f *DefaultForwarder) SubmitProcessesMetadata(payload transaction.BytesPayloads, extra http.Header) error {
+	return f.submitV1IntakeWithTransactionsFactory(payload, extra,
+		func(endpoint transaction.Endpoint, payloads transaction.BytesPayloads, apiKeyInQueryString bool, extra http.Header) []*transaction.HTTPTransaction {
+			return f.createAdvancedHTTPTransactions(endpoint, resolver.MetadataKind, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, true)
 		})
 }
 
@@ -273,6 +273,67 @@ func TestCreateHTTPTransactionsWithOverrides(t *testing.T) {
 	assert.Equal(t, transactions[0].Domain, "vector.tld")
 }
 
+func TestCreateHTTPTransactionsWithRoutingRules(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.Set("forwarder_routes", []map[string]interface{}{
+		{"domain": "datadog.bar", "endpoints": []string{"series", "events"}, "metric_prefixes": []string{"billing."}},
+		{"domain": "datadog.unknown", "endpoints": []string{"series"}},
+	})
+	defer mockConfig.Set("forwarder_routes", nil)
+
+	resolvers := resolver.NewSingleDomainResolvers(keysWithMultipleDomains)
+	setRoutingRules(resolvers)
+	assert.Nil(t, resolvers[testDomain].GetRoutingRule())
+	require.NotNil(t, resolvers["datadog.bar"].GetRoutingRule())
+	forwarder := NewDefaultForwarder(NewOptionsWithResolvers(resolvers))
+	assert.Equal(t, map[string]*resolver.RoutingRule{"datadog.bar": resolvers["datadog.bar"].GetRoutingRule()}, forwarder.MetricRoutingRules())
+
+	countByDomain := func(transactions []*transaction.HTTPTransaction) map[string]int {
+		count := make(map[string]int)
+		for _, t := range transactions {
+			count[t.Domain]++
+		}
+		return count
+	}
+	p := []byte("A payload")
+	payloads := transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&p})
+	headers := make(http.Header)
+
+	transactions := forwarder.createHTTPTransactions(endpoints.V1IntakeEndpoint, payloads, true, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2, "datadog.bar": 1}, countByDomain(transactions))
+
+	transactions = forwarder.createHTTPTransactions(endpoints.V1CheckRunsEndpoint, payloads, true, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2}, countByDomain(transactions))
+
+	transactions = forwarder.createAdvancedHTTPTransactions(endpoints.V1IntakeEndpoint, resolver.MetadataKind, payloads, true, headers, transaction.TransactionPriorityHigh, false)
+	assert.Equal(t, map[string]int{testVersionDomain: 2}, countByDomain(transactions))
+	for _, tr := range transactions {
+		assert.Equal(t, resolver.MetadataKind, resolver.TransactionKindOf(tr), "the retry queue sheds the transactions by kind")
+	}
+
+	transactions = forwarder.createHTTPTransactions(endpoints.ContainerLifecycleEndpoint, payloads, false, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2}, countByDomain(transactions))
+
+	transactions = forwarder.createHTTPTransactions(endpoints.LegacyOrchestratorEndpoint, payloads, false, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2}, countByDomain(transactions))
+
+	// endpoints which are not routable are sent everywhere
+	transactions = forwarder.createHTTPTransactions(transaction.Endpoint{Route: "/api/foo", Name: "foo"}, payloads, false, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2, "datadog.bar": 1}, countByDomain(transactions))
+
+	// the domains filtering metrics only receive the series payloads dedicated to them
+	transactions = forwarder.createHTTPTransactions(endpoints.SeriesEndpoint, payloads, false, headers)
+	assert.Equal(t, map[string]int{testVersionDomain: 2}, countByDomain(transactions))
+
+	routed := transaction.NewBytesPayloadWithoutMetaData(p)
+	routed.SetDomains([]string{"datadog.bar"})
+	transactions = forwarder.createHTTPTransactions(endpoints.SeriesEndpoint, transaction.BytesPayloads{routed}, false, headers)
+	assert.Equal(t, map[string]int{"datadog.bar": 1}, countByDomain(transactions))
+
+	transactions = forwarder.createHTTPTransactions(endpoints.SketchSeriesEndpoint, transaction.BytesPayloads{routed}, false, headers)
+	assert.Empty(t, transactions)
+}
+
 func TestArbitraryTagsHTTPHeader(t *testing.T) {
 	mockConfig := config.Mock(t)
 	mockConfig.Set("allow_arbitrary_tags", true)
@@ -707,3 +768,66 @@ func TestCustomCompletionHandler(t *testing.T) {
 
 	assert.True(t, handlerCalled)
 }
+
+func TestSubmitPrometheusRemoteWrite(t *testing.T) {
+	f := NewDefaultForwarder(NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(validKeysPerDomain)))
+	assert.False(t, f.PrometheusRemoteWriteEnabled())
+	data := []byte("remote write payload")
+	payload := transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&data})
+	assert.Error(t, f.SubmitPrometheusRemoteWrite(payload, http.Header{}))
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(validKeysPerDomain))
+	options.PrometheusRemoteWriteURL = ts.URL + "/api/v1/push"
+	options.PrometheusRemoteWriteHeaders = map[string]string{"X-Scope-OrgID": "agents"}
+	f = NewDefaultForwarder(options)
+	assert.True(t, f.PrometheusRemoteWriteEnabled())
+	assert.Len(t, f.domainForwarders, 2)
+	assert.NotContains(t, f.domainResolvers, options.PrometheusRemoteWriteURL)
+
+	require.NoError(t, f.Start())
+	defer f.Stop()
+
+	headers := http.Header{}
+	headers.Set("Content-Encoding", "snappy")
+	require.NoError(t, f.SubmitPrometheusRemoteWrite(payload, headers))
+
+	select {
+	case r := <-requests:
+		assert.Equal(t, "/api/v1/push", r.URL.Path)
+		assert.Equal(t, "agents", r.Header.Get("X-Scope-OrgID"))
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Empty(t, r.Header.Get("DD-Api-Key"))
+		assert.Equal(t, data, <-bodies)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the remote-write payload was not sent")
+	}
+}
+
+func TestGetSheddingPolicy(t *testing.T) {
+	mockConfig := config.Mock(t)
+
+	policy, err := getSheddingPolicy()
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	mockConfig.Set("forwarder_retry_queue_shed_order", []string{"process", "orchestrator"})
+	mockConfig.Set("forwarder_retry_queue_max_size_by_endpoint_kind", map[string]interface{}{"process": 1000})
+	mockConfig.Set("forwarder_storage_max_size_in_bytes_by_endpoint_kind", map[string]interface{}{"series": 5000})
+	policy, err = getSheddingPolicy()
+	assert.NoError(t, err)
+	assert.NotNil(t, policy)
+
+	mockConfig.Set("forwarder_retry_queue_shed_order", []string{"logs"})
+	_, err = getSheddingPolicy()
+	assert.Error(t, err)
+}
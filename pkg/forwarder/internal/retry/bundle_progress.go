@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// bundleProgressExtension is the extension of the file recording the upload progress of a bundle,
+// next to the bundle
+const bundleProgressExtension = ".progress"
+
+// BundleProgress records how many transactions of a bundle were sent to each domain with each API
+// key, so that the upload of a bundle which failed resumes where it stopped instead of sending the
+// accepted transactions again. The domains and the API keys are hashed so that the API keys are
+// never written to the disk.
+type BundleProgress struct {
+	path string
+	sent map[string]int
+}
+
+// LoadBundleProgress returns the upload progress of a bundle, empty if the bundle was never
+// partially uploaded
+func LoadBundleProgress(bundlePath string) (*BundleProgress, error) {
+	p := &BundleProgress{
+		path: bundleProgressPath(bundlePath),
+		sent: make(map[string]int),
+	}
+	content, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &p.sent); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SentCount returns the number of transactions, in the order of the bundle, already sent to the
+// domain with the API key
+func (p *BundleProgress) SentCount(domain, apiKey string) int {
+	return p.sent[bundleProgressKey(domain, apiKey)]
+}
+
+// SetSentCount sets the number of transactions, in the order of the bundle, sent to the domain
+// with the API key
+func (p *BundleProgress) SetSentCount(domain, apiKey string, count int) {
+	p.sent[bundleProgressKey(domain, apiKey)] = count
+}
+
+// Save writes the upload progress next to the bundle
+func (p *BundleProgress) Save() error {
+	content, err := json.Marshal(p.sent)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash never leaves a truncated progress file.
+	tmpPath := p.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p.path)
+}
+
+// Remove removes the upload progress of the bundle, once the bundle is removed
+func (p *BundleProgress) Remove() error {
+	return removeBundleProgress(p.path)
+}
+
+func bundleProgressPath(bundlePath string) string {
+	return bundlePath + bundleProgressExtension
+}
+
+func removeBundleProgress(progressPath string) error {
+	if err := os.Remove(progressPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func bundleProgressKey(domain, apiKey string) string {
+	hash := sha256.Sum256([]byte(domain + "\n" + apiKey))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleProgress(t *testing.T) {
+	a := assert.New(t)
+	bundlePath := filepath.Join(t.TempDir(), "test"+bundleExtension)
+
+	p, err := LoadBundleProgress(bundlePath)
+	require.NoError(t, err)
+	a.Equal(0, p.SentCount("https://app.datadoghq.com", "key-a"))
+
+	p.SetSentCount("https://app.datadoghq.com", "key-a", 3)
+	p.SetSentCount("https://app.datadoghq.eu", "key-a", 1)
+	require.NoError(t, p.Save())
+
+	content, err := ioutil.ReadFile(bundlePath + bundleProgressExtension)
+	require.NoError(t, err)
+	a.NotContains(string(content), "key-a", "the progress must not hold the API keys")
+
+	p, err = LoadBundleProgress(bundlePath)
+	require.NoError(t, err)
+	a.Equal(3, p.SentCount("https://app.datadoghq.com", "key-a"))
+	a.Equal(1, p.SentCount("https://app.datadoghq.eu", "key-a"))
+	a.Equal(0, p.SentCount("https://app.datadoghq.com", "key-b"))
+
+	require.NoError(t, p.Remove())
+	_, err = os.Stat(bundlePath + bundleProgressExtension)
+	a.True(os.IsNotExist(err))
+	a.NoError(p.Remove(), "removing a missing progress is not an error")
+}
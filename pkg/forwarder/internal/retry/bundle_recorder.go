@@ -0,0 +1,332 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	bundleExtension          = ".bundle"
+	recordingBundleExtension = ".recording"
+	// bundleFileFormat sorts the bundles by creation time, even when created in the same second
+	bundleFileFormat = "2006_01_02__15_04_05.000000000_"
+	// bundleFileMagic starts the bundle files
+	bundleFileMagic = "DDBUNDLE1"
+)
+
+// BundleRecorder records the transactions into bundle files instead of sending them, for the hosts
+// which cannot reach the intake. The bundles are uploaded later by a connected Agent.
+//
+// A bundle is made of chunks, each one being the length-prefixed serialization of the transactions
+// recorded at once by a HTTPTransactionsSerializer: the API keys are replaced by placeholders and
+// the payloads are stored as they are sent, compressed. A bundle truncated by a crash of the Agent
+// can be read up to its last complete chunk.
+//
+// The bundle being written has the `.recording` extension. It is renamed with the `.bundle`
+// extension when it is full, too old, or when the recorder is closed. A bundle which gets too old
+// is completed even if nothing else is recorded, so that the bundles of a quiet host can be
+// uploaded.
+type BundleRecorder struct {
+	m                 sync.Mutex
+	serializer        *HTTPTransactionsSerializer
+	encryption        *StorageEncryption
+	folderPath        string
+	maxBundleSize     int64
+	maxBundleDuration time.Duration
+	maxSizeInBytes    int64
+
+	current          *os.File
+	currentSize      int64
+	currentCreatedAt time.Time
+	// currentExpiry completes the current bundle once it is maxBundleDuration old
+	currentExpiry *time.Timer
+	// bundles are the complete bundles, oldest first
+	bundles     []string
+	bundlesSize int64
+	telemetry   bundleRecorderTelemetry
+}
+
+// NewBundleRecorder creates a new instance of BundleRecorder writing the bundles in folderPath.
+// A bundle is completed when it reaches maxBundleSize bytes or when it was created more than
+// maxBundleDuration ago. The oldest bundles are removed when the bundles use more than
+// maxSizeInBytes bytes.
+func NewBundleRecorder(
+	folderPath string,
+	maxBundleSize int64,
+	maxBundleDuration time.Duration,
+	maxSizeInBytes int64,
+	optionalEncryption *StorageEncryption,
+	resolver resolver.DomainResolver) (*BundleRecorder, error) {
+
+	if maxBundleSize <= 0 || maxSizeInBytes <= 0 {
+		return nil, errors.New("the maximum sizes of the bundles must be positive")
+	}
+	if maxBundleDuration <= 0 {
+		return nil, errors.New("the maximum duration of the bundles must be positive")
+	}
+	if err := os.MkdirAll(folderPath, 0700); err != nil {
+		return nil, err
+	}
+
+	r := &BundleRecorder{
+		serializer:        NewHTTPTransactionsSerializer(resolver),
+		encryption:        optionalEncryption,
+		folderPath:        folderPath,
+		maxBundleSize:     maxBundleSize,
+		maxBundleDuration: maxBundleDuration,
+		maxSizeInBytes:    maxSizeInBytes,
+	}
+	if err := r.completeExistingBundles(); err != nil {
+		return nil, err
+	}
+	var err error
+	if r.bundles, r.bundlesSize, err = listBundles(folderPath); err != nil {
+		return nil, err
+	}
+	r.telemetry.setBundlesCount(len(r.bundles))
+	return r, nil
+}
+
+// Record appends the transactions to the current bundle. The transactions which cannot be stored on
+// the disk, like the ones holding an API key in their payload, are not recorded.
+func (r *BundleRecorder) Record(transactions []transaction.Transaction) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, t := range transactions {
+		if err := t.SerializeTo(r.serializer); err != nil {
+			log.Errorf("Cannot record the transaction to %s: %v", t.GetTarget(), err)
+		}
+	}
+	recordedCount := len(r.serializer.collection.Values)
+	r.telemetry.addNotRecordedTransactionsCount(len(transactions) - recordedCount)
+	if recordedCount == 0 {
+		return nil
+	}
+
+	data, err := r.serializer.GetBytesAndReset()
+	if err != nil {
+		return err
+	}
+	if r.encryption != nil {
+		if data, err = r.encryption.encrypt(data); err != nil {
+			return err
+		}
+	}
+	chunk := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	chunk = append(chunk[:binary.PutUvarint(chunk, uint64(len(data)))], data...)
+
+	if r.current != nil && (r.currentSize+int64(len(chunk)) > r.maxBundleSize || time.Since(r.currentCreatedAt) >= r.maxBundleDuration) {
+		if err := r.completeBundle(); err != nil {
+			return err
+		}
+	}
+	if err := r.makeRoomFor(int64(len(chunk))); err != nil {
+		r.telemetry.addDroppedTransactionsCount(recordedCount)
+		return err
+	}
+	if r.current == nil {
+		if err := r.createBundle(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.current.Write(chunk); err != nil {
+		return err
+	}
+	r.currentSize += int64(len(chunk))
+	r.telemetry.addRecordedTransactionsCount(recordedCount)
+	return nil
+}
+
+// Close completes the current bundle. The next transactions are recorded in a new bundle.
+func (r *BundleRecorder) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.current == nil {
+		return nil
+	}
+	return r.completeBundle()
+}
+
+func (r *BundleRecorder) createBundle() error {
+	filename := time.Now().UTC().Format(bundleFileFormat)
+	file, err := ioutil.TempFile(r.folderPath, filename+"*"+recordingBundleExtension)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(bundleFileMagic); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	r.current = file
+	r.currentSize = int64(len(bundleFileMagic))
+	r.currentCreatedAt = time.Now()
+	r.currentExpiry = time.AfterFunc(r.maxBundleDuration, func() { r.completeExpiredBundle(file) })
+	return nil
+}
+
+// completeExpiredBundle completes the bundle if it is still the current one
+func (r *BundleRecorder) completeExpiredBundle(file *os.File) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.current != file {
+		return
+	}
+	if err := r.completeBundle(); err != nil {
+		log.Errorf("Cannot complete the bundle %s: %v", file.Name(), err)
+	}
+}
+
+// completeBundle closes the current bundle and gives it the `.bundle` extension
+func (r *BundleRecorder) completeBundle() error {
+	file := r.current
+	r.current = nil
+	r.currentExpiry.Stop()
+	if err := file.Close(); err != nil {
+		return err
+	}
+	bundlePath := strings.TrimSuffix(file.Name(), recordingBundleExtension) + bundleExtension
+	if err := os.Rename(file.Name(), bundlePath); err != nil {
+		return err
+	}
+	r.bundles = append(r.bundles, bundlePath)
+	r.bundlesSize += r.currentSize
+	r.currentSize = 0
+	r.telemetry.setBundlesCount(len(r.bundles))
+	return nil
+}
+
+// makeRoomFor removes the oldest bundles until a chunk of chunkSize bytes can be recorded
+func (r *BundleRecorder) makeRoomFor(chunkSize int64) error {
+	for len(r.bundles) > 0 && r.bundlesSize+r.currentSize+chunkSize > r.maxSizeInBytes {
+		bundlePath := r.bundles[0]
+		// The bundle may have been removed by an upload.
+		size, err := fileSize(bundlePath)
+		if err == nil {
+			log.Warnf("The bundles reached the maximum size of %d bytes, removing the oldest bundle %s", r.maxSizeInBytes, bundlePath)
+			err = os.Remove(bundlePath)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := removeBundleProgress(bundleProgressPath(bundlePath)); err != nil {
+			return err
+		}
+		r.bundles = r.bundles[1:]
+		r.bundlesSize -= size
+		if len(r.bundles) == 0 {
+			r.bundlesSize = 0
+		}
+		r.telemetry.addBundlesRemovedCount()
+		r.telemetry.setBundlesCount(len(r.bundles))
+	}
+	if r.bundlesSize+r.currentSize+chunkSize > r.maxSizeInBytes {
+		return fmt.Errorf("cannot record %d bytes as the maximum size of the bundles is %d bytes", chunkSize, r.maxSizeInBytes)
+	}
+	return nil
+}
+
+// completeExistingBundles completes the bundles which were being written when the Agent stopped
+func (r *BundleRecorder) completeExistingBundles() error {
+	entries, err := ioutil.ReadDir(r.folderPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || filepath.Ext(entry.Name()) != recordingBundleExtension {
+			continue
+		}
+		recordingPath := filepath.Join(r.folderPath, entry.Name())
+		bundlePath := strings.TrimSuffix(recordingPath, recordingBundleExtension) + bundleExtension
+		if err := os.Rename(recordingPath, bundlePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBundles returns the paths of the complete bundles of a folder, oldest first
+func ListBundles(folderPath string) ([]string, error) {
+	bundles, _, err := listBundles(folderPath)
+	return bundles, err
+}
+
+func listBundles(folderPath string) ([]string, int64, error) {
+	entries, err := ioutil.ReadDir(folderPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	var bundles []string
+	var size int64
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == bundleExtension {
+			bundles = append(bundles, filepath.Join(folderPath, entry.Name()))
+			size += entry.Size()
+		}
+	}
+	// The names of the bundles start with their creation time.
+	sort.Strings(bundles)
+	return bundles, size, nil
+}
+
+// DeserializeBundle deserializes the transactions of a bundle. The API keys and the domains of the
+// transactions are restored with the resolver of the serializer. Returns the transactions and the
+// number of transactions which cannot be deserialized.
+func DeserializeBundle(data []byte, serializer *HTTPTransactionsSerializer, optionalEncryption *StorageEncryption) ([]transaction.Transaction, int, error) {
+	if !bytes.HasPrefix(data, []byte(bundleFileMagic)) {
+		return nil, 0, errors.New("not a bundle file")
+	}
+	data = data[len(bundleFileMagic):]
+
+	var transactions []transaction.Transaction
+	errorCount := 0
+	for len(data) > 0 {
+		chunkSize, n := binary.Uvarint(data)
+		if n <= 0 || chunkSize > uint64(len(data)-n) {
+			log.Warnf("The bundle is truncated, ignoring its last %d bytes", len(data))
+			break
+		}
+		chunk := data[n : n+int(chunkSize)]
+		data = data[n+int(chunkSize):]
+
+		chunk, err := optionalEncryption.decrypt(chunk)
+		if err != nil {
+			return nil, 0, err
+		}
+		chunkTransactions, chunkErrorCount, err := serializer.Deserialize(chunk)
+		if err != nil {
+			return nil, 0, err
+		}
+		transactions = append(transactions, chunkTransactions...)
+		errorCount += chunkErrorCount
+	}
+	return transactions, errorCount, nil
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
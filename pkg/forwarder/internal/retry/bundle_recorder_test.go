@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+const recordedAPIKey = "recorded_api_key"
+
+func newTestBundleRecorder(t *testing.T, path string, maxBundleSize int64, maxSizeInBytes int64, encryption *StorageEncryption) *BundleRecorder {
+	r, err := NewBundleRecorder(path, maxBundleSize, time.Hour, maxSizeInBytes, encryption, resolver.NewSingleDomainResolver(domainName, []string{recordedAPIKey}))
+	require.NoError(t, err)
+	return r
+}
+
+func createRecordedTransactions(endpoints ...string) []transaction.Transaction {
+	transactions := createHTTPTransactionCollectionTests(endpoints...)
+	for _, t := range transactions {
+		httpTransaction := t.(*transaction.HTTPTransaction)
+		httpTransaction.Endpoint.Route = "/api/v1/" + httpTransaction.Endpoint.Name + "?api_key=" + recordedAPIKey
+		httpTransaction.Headers.Set("DD-Api-Key", recordedAPIKey)
+		httpTransaction.CreatedAt = time.Unix(1600000000, 0)
+	}
+	return transactions
+}
+
+func readTestBundle(t *testing.T, bundlePath string, apiKey string, encryption *StorageEncryption) []transaction.Transaction {
+	content, err := ioutil.ReadFile(bundlePath)
+	require.NoError(t, err)
+	serializer := NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver("https://uploader", []string{apiKey}))
+	transactions, errorCount, err := DeserializeBundle(content, serializer, encryption)
+	require.NoError(t, err)
+	require.Zero(t, errorCount)
+	return transactions
+}
+
+func TestBundleRecorder(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	r := newTestBundleRecorder(t, path, 1000, 10000, nil)
+
+	a.NoError(r.Record(createRecordedTransactions("endpoint1", "endpoint2")))
+	a.NoError(r.Record(createRecordedTransactions("endpoint3")))
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	a.Empty(bundles, "the bundle being written is not listed")
+
+	a.NoError(r.Close())
+	bundles, err = ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 1)
+
+	content, err := ioutil.ReadFile(bundles[0])
+	a.NoError(err)
+	a.False(bytes.Contains(content, []byte(recordedAPIKey)), "the bundle must not hold the API key")
+
+	transactions := readTestBundle(t, bundles[0], "uploader_api_key", nil)
+	a.Equal([]string{"endpoint1", "endpoint2", "endpoint3"}, getEndpointsFromTransactions(transactions))
+	for _, tr := range transactions {
+		httpTransaction := tr.(*transaction.HTTPTransaction)
+		a.Equal("https://uploader", httpTransaction.Domain)
+		a.Equal("uploader_api_key", httpTransaction.Headers.Get("DD-Api-Key"))
+		a.Contains(httpTransaction.Endpoint.Route, "?api_key=uploader_api_key")
+		a.Equal(int64(1600000000), httpTransaction.CreatedAt.Unix())
+	}
+
+	// The next transactions are recorded in a new bundle.
+	a.NoError(r.Record(createRecordedTransactions("endpoint4")))
+	a.NoError(r.Close())
+	bundles, err = ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 2)
+	a.Equal([]string{"endpoint4"}, getEndpointsFromTransactions(readTestBundle(t, bundles[1], "uploader_api_key", nil)))
+}
+
+func TestBundleRecorderNotStorableTransactions(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	r := newTestBundleRecorder(t, path, 1000, 10000, nil)
+
+	transactions := createRecordedTransactions("endpoint1", "endpoint2")
+	transactions[0].(*transaction.HTTPTransaction).StorableOnDisk = false
+	notRecorded := notRecordedTransactionsCountTelemetry.expvar.Value()
+	a.NoError(r.Record(transactions))
+	a.NoError(r.Close())
+	a.Equal(notRecorded+1, notRecordedTransactionsCountTelemetry.expvar.Value())
+
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 1)
+	a.Equal([]string{"endpoint2"}, getEndpointsFromTransactions(readTestBundle(t, bundles[0], "uploader_api_key", nil)))
+}
+
+func TestBundleRecorderMaxSize(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+
+	// Measure the size of a chunk
+	r := newTestBundleRecorder(t, t.TempDir(), 1000, 10000, nil)
+	a.NoError(r.Record(createRecordedTransactions("endpointX")))
+	chunkSize := r.currentSize - int64(len(bundleFileMagic))
+
+	// Two chunks per bundle, two bundles at most
+	bundleSize := int64(len(bundleFileMagic)) + 2*chunkSize
+	r = newTestBundleRecorder(t, path, bundleSize, 2*bundleSize, nil)
+	bundlesRemoved := bundlesRemovedCountTelemetry.expvar.Value()
+	for _, endpoint := range []string{"endpoint0", "endpoint1", "endpoint2", "endpoint3", "endpoint4"} {
+		a.NoError(r.Record(createRecordedTransactions(endpoint)))
+	}
+	a.NoError(r.Close())
+	a.Equal(bundlesRemoved+1, bundlesRemovedCountTelemetry.expvar.Value())
+
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 2)
+	a.Equal([]string{"endpoint2", "endpoint3"}, getEndpointsFromTransactions(readTestBundle(t, bundles[0], "key", nil)))
+	a.Equal([]string{"endpoint4"}, getEndpointsFromTransactions(readTestBundle(t, bundles[1], "key", nil)))
+
+	// A chunk larger than the maximum size is dropped.
+	r = newTestBundleRecorder(t, t.TempDir(), bundleSize, chunkSize-1, nil)
+	a.Error(r.Record(createRecordedTransactions("endpointX")))
+}
+
+func TestBundleRecorderIncompleteBundle(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	r := newTestBundleRecorder(t, path, 1000, 10000, nil)
+	a.NoError(r.Record(createRecordedTransactions("endpoint1")))
+	a.NoError(r.Record(createRecordedTransactions("endpoint2")))
+
+	// Simulate a crash of the Agent while writing a chunk.
+	recordingPath := r.current.Name()
+	a.NoError(r.current.Truncate(r.currentSize - 2))
+	a.NoError(r.current.Close())
+
+	r = newTestBundleRecorder(t, path, 1000, 10000, nil)
+	_, err := os.Stat(recordingPath)
+	a.True(os.IsNotExist(err))
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 1)
+	a.Equal(filepath.Dir(recordingPath), filepath.Dir(bundles[0]))
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(readTestBundle(t, bundles[0], "key", nil)))
+	a.Equal(1, len(r.bundles))
+}
+
+func TestBundleRecorderEncryption(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	encryption, err := NewStorageEncryption([]string{newTestStorageKey(1, 32)})
+	a.NoError(err)
+	r := newTestBundleRecorder(t, path, 1000, 10000, encryption)
+	a.NoError(r.Record(createRecordedTransactions("endpoint1")))
+	a.NoError(r.Close())
+
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 1)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(readTestBundle(t, bundles[0], "key", encryption)))
+
+	content, err := ioutil.ReadFile(bundles[0])
+	a.NoError(err)
+	_, _, err = DeserializeBundle(content, NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, []string{"key"})), nil)
+	a.Error(err)
+}
+
+func TestBundleRecorderQuietHost(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	r, err := NewBundleRecorder(path, 1000, 50*time.Millisecond, 10000, nil, resolver.NewSingleDomainResolver(domainName, []string{recordedAPIKey}))
+	require.NoError(t, err)
+	a.NoError(r.Record(createRecordedTransactions("endpoint1")))
+
+	// The bundle is completed once too old, even if nothing else is recorded.
+	a.Eventually(func() bool {
+		bundles, err := ListBundles(path)
+		return err == nil && len(bundles) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	bundles, err := ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 1)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(readTestBundle(t, bundles[0], "key", nil)))
+
+	// The next transactions are recorded in a new bundle.
+	a.NoError(r.Record(createRecordedTransactions("endpoint2")))
+	a.NoError(r.Close())
+	bundles, err = ListBundles(path)
+	a.NoError(err)
+	require.Len(t, bundles, 2)
+	a.Equal([]string{"endpoint2"}, getEndpointsFromTransactions(readTestBundle(t, bundles[1], "key", nil)))
+}
+
+func TestNewBundleRecorderInvalidLimits(t *testing.T) {
+	dr := resolver.NewSingleDomainResolver(domainName, []string{recordedAPIKey})
+	_, err := NewBundleRecorder(t.TempDir(), 0, time.Hour, 10000, nil, dr)
+	assert.Error(t, err)
+	_, err = NewBundleRecorder(t.TempDir(), 1000, time.Hour, 0, nil, dr)
+	assert.Error(t, err)
+	_, err = NewBundleRecorder(t.TempDir(), 1000, 0, 10000, nil, dr)
+	assert.Error(t, err)
+}
@@ -88,6 +88,7 @@ func (s *HTTPTransactionsSerializer) Add(transaction *transaction.HTTPTransactio
 		Retryable:  transaction.Retryable,
 		Priority:   priority,
 		PointCount: pointCount,
+		Kind:       transaction.Kind,
 	}
 	s.collection.Values = append(s.collection.Values, &transactionProto)
 	return nil
@@ -135,6 +136,7 @@ func (s *HTTPTransactionsSerializer) Deserialize(bytes []byte) ([]transaction.Tr
 		tr := transaction.HTTPTransaction{
 			Domain:         domain,
 			Endpoint:       endpoint,
+			Kind:           tr.Kind,
 			Headers:        proto,
 			Payload:        transaction.NewBytesPayload(tr.Payload, int(tr.GetPointCount())),
 			ErrorCount:     int(tr.ErrorCount),
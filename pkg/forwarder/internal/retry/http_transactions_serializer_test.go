@@ -104,7 +104,7 @@ func TestHTTPTransactionSerializerMissingAPIKey(t *testing.T) {
 func TestHTTPTransactionFieldsCount(t *testing.T) {
 	tr := transaction.HTTPTransaction{}
 	transactionType := reflect.TypeOf(tr)
-	assert.Equalf(t, 11, transactionType.NumField(),
+	assert.Equalf(t, 12, transactionType.NumField(),
 		"A field was added or remove from HTTPTransaction. "+
 			"You probably need to update the implementation of "+
 			"HTTPTransactionsSerializer and then adjust this unit test.")
@@ -119,6 +119,7 @@ func createHTTPTransactionWithHeaderTests(header http.Header, domain string) *tr
 	tr := transaction.NewHTTPTransaction()
 	tr.Domain = domain
 	tr.Endpoint = transaction.Endpoint{Route: "route" + apiKey1, Name: "name"}
+	tr.Kind = string(resolver.MetadataKind)
 	tr.Headers = header
 	tr.Payload = transaction.NewBytesPayload(payload, 10)
 	tr.ErrorCount = 1
@@ -131,6 +132,7 @@ func createHTTPTransactionWithHeaderTests(header http.Header, domain string) *tr
 func assertTransactionEqual(a *assert.Assertions, tr1 *transaction.HTTPTransaction, tr2 *transaction.HTTPTransaction) {
 	a.Equal(tr1.Domain, tr2.Domain)
 	a.Equal(tr1.Endpoint, tr2.Endpoint)
+	a.Equal(tr1.Kind, tr2.Kind)
 	a.EqualValues(tr1.Headers, tr2.Headers)
 	a.Equal(tr1.Retryable, tr2.Retryable)
 	a.Equal(tr1.Priority, tr2.Priority)
@@ -7,13 +7,16 @@ package retry
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/DataDog/datadog-agent/pkg/util"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -26,15 +29,22 @@ type onDiskRetryQueue struct {
 	serializer         *HTTPTransactionsSerializer
 	storagePath        string
 	diskUsageLimit     *DiskUsageLimit
+	encryption         *StorageEncryption
+	sheddingPolicy     *SheddingPolicy
 	filenames          []string
 	currentSizeInBytes int64
-	telemetry          onDiskRetryQueueTelemetry
+	// fileKinds holds the endpoint kind of the files only storing the transactions of one kind
+	fileKinds         map[string]resolver.EndpointKind
+	sizeInBytesByKind map[resolver.EndpointKind]int64
+	telemetry         onDiskRetryQueueTelemetry
 }
 
 func newOnDiskRetryQueue(
 	serializer *HTTPTransactionsSerializer,
 	storagePath string,
 	diskUsageLimit *DiskUsageLimit,
+	optionalEncryption *StorageEncryption,
+	optionalSheddingPolicy *SheddingPolicy,
 	telemetry onDiskRetryQueueTelemetry) (*onDiskRetryQueue, error) {
 
 	if err := os.MkdirAll(storagePath, 0700); err != nil {
@@ -42,10 +52,14 @@ func newOnDiskRetryQueue(
 	}
 
 	storage := &onDiskRetryQueue{
-		serializer:     serializer,
-		storagePath:    storagePath,
-		diskUsageLimit: diskUsageLimit,
-		telemetry:      telemetry,
+		serializer:        serializer,
+		storagePath:       storagePath,
+		diskUsageLimit:    diskUsageLimit,
+		encryption:        optionalEncryption,
+		sheddingPolicy:    optionalSheddingPolicy,
+		fileKinds:         make(map[string]resolver.EndpointKind),
+		sizeInBytesByKind: make(map[resolver.EndpointKind]int64),
+		telemetry:         telemetry,
 	}
 
 	if err := storage.reloadExistingRetryFiles(); err != nil {
@@ -60,9 +74,33 @@ func newOnDiskRetryQueue(
 }
 
 // Serialize serializes transactions to the file system.
+// When a `SheddingPolicy` is set, the transactions of each endpoint kind are stored in their own
+// file so that they can be dropped independently.
 func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) error {
 	s.telemetry.addSerializeCount()
 
+	if s.sheddingPolicy == nil {
+		return s.serializeFile("", transactions)
+	}
+	var kinds []resolver.EndpointKind
+	transactionsByKind := make(map[resolver.EndpointKind][]transaction.Transaction)
+	for _, t := range transactions {
+		kind := endpointKindOf(t)
+		if _, found := transactionsByKind[kind]; !found {
+			kinds = append(kinds, kind)
+		}
+		transactionsByKind[kind] = append(transactionsByKind[kind], t)
+	}
+	for _, kind := range kinds {
+		if err := s.serializeFile(kind, transactionsByKind[kind]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serializeFile serializes transactions of the given endpoint kind, if not empty, to a new file.
+func (s *onDiskRetryQueue) serializeFile(kind resolver.EndpointKind, transactions []transaction.Transaction) error {
 	// Reset the serializer in case some transactions were serialized
 	// but `GetBytesAndReset` was not called because of an error.
 	_, _ = s.serializer.GetBytesAndReset()
@@ -77,13 +115,21 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 	if err != nil {
 		return err
 	}
+	if s.encryption != nil {
+		if bytes, err = s.encryption.encrypt(bytes); err != nil {
+			return err
+		}
+	}
 	bufferSize := int64(len(bytes))
 
-	if err := s.makeRoomFor(bufferSize); err != nil {
+	if err := s.makeRoomFor(bufferSize, kind); err != nil {
 		return err
 	}
 
 	filename := time.Now().UTC().Format(retryFileFormat)
+	if kind != "" {
+		filename += string(kind) + "_"
+	}
 	file, err := ioutil.TempFile(s.storagePath, filename+"*"+retryTransactionsExtension)
 	if err != nil {
 		return err
@@ -97,6 +143,10 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 
 	s.currentSizeInBytes += bufferSize
 	s.filenames = append(s.filenames, file.Name())
+	if kind != "" {
+		s.fileKinds[file.Name()] = kind
+		s.sizeInBytesByKind[kind] += bufferSize
+	}
 	s.telemetry.setFileSize(bufferSize)
 	s.telemetry.setCurrentSizeInBytes(s.GetDiskSpaceUsed())
 	s.telemetry.setFilesCount(s.getFilesCount())
@@ -111,7 +161,7 @@ func (s *onDiskRetryQueue) Deserialize() ([]transaction.Transaction, error) {
 	s.telemetry.addDeserializeCount()
 	index := len(s.filenames) - 1
 	path := s.filenames[index]
-	bytes, err := ioutil.ReadFile(path)
+	bytes, err := s.readFile(path)
 
 	// Remove the file even in case of a read failure.
 	if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
@@ -143,41 +193,99 @@ func (s *onDiskRetryQueue) GetDiskSpaceUsed() int64 {
 	return s.currentSizeInBytes
 }
 
-func (s *onDiskRetryQueue) makeRoomFor(bufferSize int64) error {
+func (s *onDiskRetryQueue) makeRoomFor(bufferSize int64, kind resolver.EndpointKind) error {
 	maxSizeInBytes := s.diskUsageLimit.getMaxSizeInBytes()
 	if bufferSize > maxSizeInBytes {
 		return fmt.Errorf("The payload is too big. Current:%v Maximum:%v", bufferSize, maxSizeInBytes)
 	}
 
+	if budget, found := s.sheddingPolicy.diskBudget(kind); found {
+		if bufferSize > budget {
+			return fmt.Errorf("The payload is too big for the disk budget of the %s endpoints. Current:%v Maximum:%v", kind, bufferSize, budget)
+		}
+		for s.sizeInBytesByKind[kind]+bufferSize > budget {
+			index := s.oldestFileOfKind(kind)
+			if index < 0 {
+				break
+			}
+			log.Errorf("Maximum disk space for the %s retry transactions is reached. Removing %s", kind, s.filenames[index])
+			if err := s.dropFileAt(index); err != nil {
+				return err
+			}
+		}
+	}
+
 	maxStorageInBytes, err := s.diskUsageLimit.computeAvailableSpace(s.currentSizeInBytes)
 	if err != nil {
 		return err
 	}
 	for len(s.filenames) > 0 && s.currentSizeInBytes+bufferSize > maxStorageInBytes {
-		index := 0
-		filename := s.filenames[index]
-		log.Errorf("Maximum disk space for retry transactions is reached. Removing %s", filename)
-
-		bytes, err := ioutil.ReadFile(filename)
-		if err != nil {
-			log.Errorf("Cannot read the file %v: %v", filename, err)
-		} else if transactions, _, errDeserialize := s.serializer.Deserialize(bytes); errDeserialize == nil {
-			for _, tr := range transactions {
-				s.telemetry.addPointDroppedCount(tr.GetPointCount())
-			}
-		} else {
-			log.Errorf("Cannot deserialize the content of file %v: %v", filename, errDeserialize)
+		index := s.nextFileToShed()
+		log.Errorf("Maximum disk space for retry transactions is reached. Removing %s", s.filenames[index])
+		if err := s.dropFileAt(index); err != nil {
+			return err
 		}
+	}
 
-		if err := s.removeFileAt(index); err != nil {
-			return err
+	return nil
+}
+
+// nextFileToShed returns the index of the oldest file of the lowest shed rank
+func (s *onDiskRetryQueue) nextFileToShed() int {
+	next := 0
+	for i := 1; i < len(s.filenames); i++ {
+		if s.sheddingPolicy.rank(s.fileKinds[s.filenames[i]]) < s.sheddingPolicy.rank(s.fileKinds[s.filenames[next]]) {
+			next = i
+		}
+	}
+	return next
+}
+
+// oldestFileOfKind returns the index of the oldest file of an endpoint kind, -1 if there is none
+func (s *onDiskRetryQueue) oldestFileOfKind(kind resolver.EndpointKind) int {
+	for i, filename := range s.filenames {
+		if s.fileKinds[filename] == kind {
+			return i
 		}
-		s.telemetry.addFilesRemovedCount()
 	}
+	return -1
+}
 
+// dropFileAt removes a file whose transactions will not be sent
+func (s *onDiskRetryQueue) dropFileAt(index int) error {
+	filename := s.filenames[index]
+	bytes, err := s.readFile(filename)
+	if err != nil {
+		log.Errorf("Cannot read the file %v: %v", filename, err)
+	} else if transactions, _, errDeserialize := s.serializer.Deserialize(bytes); errDeserialize == nil {
+		for _, tr := range transactions {
+			s.telemetry.addPointDroppedCount(tr.GetPointCount())
+			s.telemetry.addEvictedTransaction(tr.GetEndpointName())
+		}
+	} else {
+		log.Errorf("Cannot deserialize the content of file %v: %v", filename, errDeserialize)
+	}
+
+	if err := s.removeFileAt(index); err != nil {
+		return err
+	}
+	s.telemetry.addFilesRemovedCount()
 	return nil
 }
 
+// readFile reads and decrypts a retry file
+func (s *onDiskRetryQueue) readFile(filename string) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if bytes, err = s.encryption.decrypt(bytes); err != nil {
+		s.telemetry.addUndecryptableFilesCount()
+		return nil, err
+	}
+	return bytes, nil
+}
+
 func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	filename := s.filenames[index]
 
@@ -185,6 +293,9 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	// fail on the next call.
 	s.filenames = append(s.filenames[:index], s.filenames[index+1:]...)
 
+	kind, hasKind := s.fileKinds[filename]
+	delete(s.fileKinds, filename)
+
 	size, err := util.GetFileSize(filename)
 	if err != nil {
 		return err
@@ -195,6 +306,9 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	}
 
 	s.currentSizeInBytes -= size
+	if hasKind {
+		s.sizeInBytesByKind[kind] -= size
+	}
 	return nil
 }
 
@@ -211,6 +325,22 @@ func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
 	var filenames []string
 	for _, file := range files {
 		fullPath := path.Join(s.storagePath, file.Name())
+		// The files encrypted with a key which is no longer configured are discarded
+		// as they cannot be replayed.
+		if err := s.checkFileKey(fullPath); err != nil {
+			log.Warnf("Removing the retry file %v: %v", fullPath, err)
+			if err := os.Remove(fullPath); err != nil {
+				log.Errorf("Cannot remove the retry file %v: %v", fullPath, err)
+				continue
+			}
+			s.currentSizeInBytes -= file.Size()
+			s.telemetry.addUndecryptableFilesCount()
+			continue
+		}
+		if kind := retryFileKind(file.Name()); kind != "" {
+			s.fileKinds[fullPath] = kind
+			s.sizeInBytesByKind[kind] += file.Size()
+		}
 		filenames = append(filenames, fullPath)
 	}
 	s.telemetry.setReloadedRetryFilesCount(len(filenames))
@@ -218,6 +348,41 @@ func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
 	return nil
 }
 
+// retryFileKind returns the endpoint kind of the transactions stored in a file, empty if the file
+// stores transactions of several kinds.
+func retryFileKind(filename string) resolver.EndpointKind {
+	if len(filename) <= len(retryFileFormat) {
+		return ""
+	}
+	// The file name is made of the creation time, the endpoint kind if any and a random suffix.
+	name := filename[len(retryFileFormat):]
+	i := strings.LastIndexByte(name, '_')
+	if i < 0 {
+		return ""
+	}
+	kind, err := resolver.ParseEndpointKind(name[:i])
+	if err != nil {
+		return ""
+	}
+	return kind
+}
+
+// checkFileKey returns an error if the retry file is encrypted with a key which is not configured
+func (s *onDiskRetryQueue) checkFileKey(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, len(encryptedFileMagic)+storageKeyIDSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	return s.encryption.checkKey(header[:n])
+}
+
 func (s *onDiskRetryQueue) getExistingRetryFiles() ([]os.FileInfo, int64, error) {
 	entries, err := ioutil.ReadDir(s.storagePath)
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
 )
@@ -130,7 +131,142 @@ func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes i
 			Total:     10000,
 		}}
 	diskUsageLimit := NewDiskUsageLimit("", disk, maxSizeInBytes, 1)
-	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), path, diskUsageLimit, telemetry)
+	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), path, diskUsageLimit, nil, nil, telemetry)
 	a.NoError(err)
 	return storage
 }
+
+func TestOnDiskRetryQueueEncryption(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	oldKey, newKey := newTestStorageKey(1, 32), newTestStorageKey(2, 32)
+	undecryptable := undecryptableFilesCountTelemetry.expvar.Value()
+
+	plainQueue := newTestOnDiskRetryQueue(a, path, 1000)
+	a.NoError(plainQueue.Serialize(createHTTPTransactionCollectionTests("plain")))
+	oldQueue := newTestEncryptedOnDiskRetryQueue(a, path, oldKey)
+	a.NoError(oldQueue.Serialize(createHTTPTransactionCollectionTests("old_key")))
+	a.Equal(2, oldQueue.getFilesCount())
+
+	// After a rotation, the files written with the old key and the plain files are replayed
+	rotatedQueue := newTestEncryptedOnDiskRetryQueue(a, path, newKey, oldKey)
+	a.Equal(2, rotatedQueue.getFilesCount())
+	a.NoError(rotatedQueue.Serialize(createHTTPTransactionCollectionTests("new_key")))
+	var replayed []string
+	for rotatedQueue.getFilesCount() > 0 {
+		transactions, err := rotatedQueue.Deserialize()
+		a.NoError(err)
+		replayed = append(replayed, getEndpointsFromTransactions(transactions)...)
+	}
+	a.ElementsMatch([]string{"new_key", "old_key", "plain"}, replayed)
+
+	// Once the old key is removed, the files written with it are discarded at startup
+	a.NoError(oldQueue.Serialize(createHTTPTransactionCollectionTests("old_key")))
+	a.NoError(rotatedQueue.Serialize(createHTTPTransactionCollectionTests("new_key")))
+	newQueue := newTestEncryptedOnDiskRetryQueue(a, path, newKey)
+	a.Equal(1, newQueue.getFilesCount())
+	a.Equal(undecryptable+1, undecryptableFilesCountTelemetry.expvar.Value())
+	transactions, err := newQueue.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"new_key"}, getEndpointsFromTransactions(transactions))
+	a.Equal(int64(0), newQueue.GetDiskSpaceUsed())
+
+	// Without encryption, the encrypted files are discarded too
+	a.NoError(newQueue.Serialize(createHTTPTransactionCollectionTests("new_key")))
+	a.Equal(0, newTestOnDiskRetryQueue(a, path, 1000).getFilesCount())
+	a.Equal(undecryptable+2, undecryptableFilesCountTelemetry.expvar.Value())
+}
+
+func newTestEncryptedOnDiskRetryQueue(a *assert.Assertions, path string, keys ...string) *onDiskRetryQueue {
+	encryption, err := NewStorageEncryption(keys)
+	a.NoError(err)
+	disk := diskUsageRetrieverMock{
+		diskUsage: &filesystem.DiskUsage{
+			Available: 10000,
+			Total:     10000,
+		}}
+	diskUsageLimit := NewDiskUsageLimit("", disk, 1000, 1)
+	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), path, diskUsageLimit, encryption, nil, newOnDiskRetryQueueTelemetry("domain"))
+	a.NoError(err)
+	return storage
+}
+
+func TestOnDiskRetryQueueSheddingPolicy(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	policy, err := NewSheddingPolicy([]string{"process"}, nil, map[string]int64{"series": 1})
+	a.NoError(err)
+	q := newTestOnDiskRetryQueue(a, path, 1000)
+	q.sheddingPolicy = policy
+	newTransaction := func(endpoint transaction.Endpoint) transaction.Transaction {
+		tr := createTransactionWithEndpoint(endpoint, 10)
+		tr.Domain = domainName
+		return tr
+	}
+
+	// The transactions of each kind are stored in their own file
+	err = q.Serialize([]transaction.Transaction{
+		newTransaction(endpoints.ProcessesEndpoint),
+		newTransaction(endpoints.ServiceChecksEndpoint),
+		newTransaction(endpoints.RtProcessesEndpoint),
+	})
+	a.NoError(err)
+	a.Equal(2, q.getFilesCount())
+	a.Equal(resolver.ProcessKind, q.fileKinds[q.filenames[0]])
+	a.Equal(resolver.ServiceChecksKind, q.fileKinds[q.filenames[1]])
+	a.Equal(q.GetDiskSpaceUsed(), q.sizeInBytesByKind[resolver.ProcessKind]+q.sizeInBytesByKind[resolver.ServiceChecksKind])
+
+	// The kinds are reloaded from the file names
+	reloaded := newTestOnDiskRetryQueue(a, path, 1000)
+	a.Equal(q.fileKinds, reloaded.fileKinds)
+	a.Equal(q.sizeInBytesByKind, reloaded.sizeInBytesByKind)
+
+	// The files over the disk budget of their kind are not stored
+	a.Error(q.Serialize([]transaction.Transaction{newTransaction(endpoints.SeriesEndpoint)}))
+
+	// The process files are removed first when the disk is full
+	evicted := getEvictedCount(&fileStorageEvictedByEndpointExpvar, endpoints.RtProcessesEndpoint.Name)
+	q.diskUsageLimit = NewDiskUsageLimit("", q.diskUsageLimit.disk, q.GetDiskSpaceUsed()+1, 1)
+	err = q.Serialize([]transaction.Transaction{newTransaction(endpoints.V1CheckRunsEndpoint)})
+	a.NoError(err)
+	a.Equal(2, q.getFilesCount())
+	a.Equal(int64(0), q.sizeInBytesByKind[resolver.ProcessKind])
+	a.Equal(evicted+1, getEvictedCount(&fileStorageEvictedByEndpointExpvar, endpoints.RtProcessesEndpoint.Name))
+	var remaining []string
+	for q.getFilesCount() > 0 {
+		transactions, err := q.Deserialize()
+		a.NoError(err)
+		remaining = append(remaining, getEndpointsFromTransactions(transactions)...)
+	}
+	a.Equal([]string{endpoints.V1CheckRunsEndpoint.Name, endpoints.ServiceChecksEndpoint.Name}, remaining)
+}
+
+func TestOnDiskRetryQueueTransactionKind(t *testing.T) {
+	a := assert.New(t)
+	path := t.TempDir()
+	policy, err := NewSheddingPolicy([]string{"events"}, nil, nil)
+	a.NoError(err)
+	q := newTestOnDiskRetryQueue(a, path, 1000)
+	q.sheddingPolicy = policy
+
+	// The host metadata sent to the intake endpoint is stored as metadata, not as events
+	tr := createTransactionWithEndpoint(endpoints.V1IntakeEndpoint, 10)
+	tr.Domain = domainName
+	tr.Kind = string(resolver.MetadataKind)
+	a.NoError(q.Serialize([]transaction.Transaction{tr}))
+	a.Equal(1, q.getFilesCount())
+	a.Equal(resolver.MetadataKind, q.fileKinds[q.filenames[0]])
+
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Len(transactions, 1)
+	a.Equal(resolver.MetadataKind, endpointKindOf(transactions[0]))
+}
+
+func TestRetryFileKind(t *testing.T) {
+	assert.Equal(t, resolver.EndpointKind(""), retryFileKind("2022_10_17__10_00_00_123456.retry"))
+	assert.Equal(t, resolver.SeriesKind, retryFileKind("2022_10_17__10_00_00_series_123456.retry"))
+	assert.Equal(t, resolver.ServiceChecksKind, retryFileKind("2022_10_17__10_00_00_service_checks_123456.retry"))
+	assert.Equal(t, resolver.EndpointKind(""), retryFileKind("2022_10_17__10_00_00_logs_123456.retry"))
+	assert.Equal(t, resolver.EndpointKind(""), retryFileKind("file.retry"))
+}
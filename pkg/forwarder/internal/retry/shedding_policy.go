@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+// SheddingPolicy defines which transactions are dropped first when the retry queue is full, and the
+// maximum size of the transactions of each endpoint kind in memory and on the disk.
+//
+// The transactions of the kinds listed first in the shed order are dropped before the other ones.
+// The kinds which are not listed, and the endpoints which have no kind, are dropped last. The
+// transactions of the same rank are dropped according to the `TransactionPrioritySorter` of the
+// retry queue.
+type SheddingPolicy struct {
+	ranks       map[resolver.EndpointKind]int
+	memBudgets  map[resolver.EndpointKind]int
+	diskBudgets map[resolver.EndpointKind]int64
+}
+
+// NewSheddingPolicy creates a new instance of SheddingPolicy from the names of the endpoint kinds
+// in shed order, and the maximum sizes in bytes of the transactions by endpoint kind name.
+func NewSheddingPolicy(shedOrder []string, memBudgets map[string]int, diskBudgets map[string]int64) (*SheddingPolicy, error) {
+	p := &SheddingPolicy{
+		ranks:       make(map[resolver.EndpointKind]int, len(shedOrder)),
+		memBudgets:  make(map[resolver.EndpointKind]int, len(memBudgets)),
+		diskBudgets: make(map[resolver.EndpointKind]int64, len(diskBudgets)),
+	}
+	for _, name := range shedOrder {
+		kind, err := resolver.ParseEndpointKind(name)
+		if err != nil {
+			return nil, err
+		}
+		// The ranks skip the duplicates so that the kinds which are not listed keep the last rank.
+		if _, found := p.ranks[kind]; !found {
+			p.ranks[kind] = len(p.ranks)
+		}
+	}
+	for name, budget := range memBudgets {
+		kind, err := resolver.ParseEndpointKind(name)
+		if err != nil {
+			return nil, err
+		}
+		if budget < 0 {
+			return nil, fmt.Errorf("the memory budget of the %s endpoints is negative", kind)
+		}
+		p.memBudgets[kind] = budget
+	}
+	for name, budget := range diskBudgets {
+		kind, err := resolver.ParseEndpointKind(name)
+		if err != nil {
+			return nil, err
+		}
+		if budget < 0 {
+			return nil, fmt.Errorf("the disk budget of the %s endpoints is negative", kind)
+		}
+		p.diskBudgets[kind] = budget
+	}
+	return p, nil
+}
+
+// rank returns the shed rank of an endpoint kind: the lower ranks are dropped first
+func (p *SheddingPolicy) rank(kind resolver.EndpointKind) int {
+	if p == nil {
+		return 0
+	}
+	if rank, found := p.ranks[kind]; found {
+		return rank
+	}
+	return len(p.ranks)
+}
+
+// sort sorts the transactions already sorted by a `TransactionPrioritySorter` by shed rank
+func (p *SheddingPolicy) sort(transactions []transaction.Transaction) {
+	if p == nil || len(p.ranks) == 0 {
+		return
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return p.rank(endpointKindOf(transactions[i])) < p.rank(endpointKindOf(transactions[j]))
+	})
+}
+
+// memBudget returns the maximum size in memory of the transactions of an endpoint kind, if any
+func (p *SheddingPolicy) memBudget(kind resolver.EndpointKind) (int, bool) {
+	if p == nil || kind == "" {
+		return 0, false
+	}
+	budget, found := p.memBudgets[kind]
+	return budget, found
+}
+
+// diskBudget returns the maximum size on the disk of the transactions of an endpoint kind, if any
+func (p *SheddingPolicy) diskBudget(kind resolver.EndpointKind) (int64, bool) {
+	if p == nil || kind == "" {
+		return 0, false
+	}
+	budget, found := p.diskBudgets[kind]
+	return budget, found
+}
+
+func endpointKindOf(t transaction.Transaction) resolver.EndpointKind {
+	return resolver.TransactionKindOf(t)
+}
@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+func TestNewSheddingPolicy(t *testing.T) {
+	_, err := NewSheddingPolicy([]string{"process", "logs"}, nil, nil)
+	assert.Error(t, err)
+	_, err = NewSheddingPolicy(nil, map[string]int{"logs": 10}, nil)
+	assert.Error(t, err)
+	_, err = NewSheddingPolicy(nil, map[string]int{"process": -1}, nil)
+	assert.Error(t, err)
+	_, err = NewSheddingPolicy(nil, nil, map[string]int64{"process": -1})
+	assert.Error(t, err)
+
+	p, err := NewSheddingPolicy([]string{"Process", "orchestrator", "process"}, map[string]int{"process": 10}, map[string]int64{"sketches": 20})
+	require.NoError(t, err)
+	assert.Equal(t, 0, p.rank(resolver.ProcessKind))
+	assert.Equal(t, 1, p.rank(resolver.OrchestratorKind))
+	assert.Equal(t, 2, p.rank(resolver.SeriesKind))
+	assert.Equal(t, 2, p.rank(""))
+
+	withDuplicates, err := NewSheddingPolicy([]string{"process", "process", "orchestrator"}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, withDuplicates.rank(resolver.ProcessKind))
+	assert.Equal(t, 1, withDuplicates.rank(resolver.OrchestratorKind))
+	assert.Equal(t, 2, withDuplicates.rank(resolver.SeriesKind))
+
+	budget, found := p.memBudget(resolver.ProcessKind)
+	assert.True(t, found)
+	assert.Equal(t, 10, budget)
+	_, found = p.memBudget(resolver.SeriesKind)
+	assert.False(t, found)
+	diskBudget, found := p.diskBudget(resolver.SketchesKind)
+	assert.True(t, found)
+	assert.Equal(t, int64(20), diskBudget)
+
+	var none *SheddingPolicy
+	assert.Equal(t, 0, none.rank(resolver.ProcessKind))
+	_, found = none.memBudget(resolver.ProcessKind)
+	assert.False(t, found)
+}
+
+func TestSheddingPolicyTransactionKind(t *testing.T) {
+	p, err := NewSheddingPolicy([]string{"events"}, nil, nil)
+	require.NoError(t, err)
+
+	// The host metadata and the events are both sent to the intake endpoint.
+	event := createTransactionWithEndpoint(endpoints.V1IntakeEndpoint, 1)
+	hostMetadata := createTransactionWithEndpoint(endpoints.V1IntakeEndpoint, 1)
+	hostMetadata.Kind = string(resolver.MetadataKind)
+
+	assert.Equal(t, resolver.EventsKind, endpointKindOf(event))
+	assert.Equal(t, resolver.MetadataKind, endpointKindOf(hostMetadata))
+	assert.Equal(t, 0, p.rank(endpointKindOf(event)))
+	assert.Equal(t, 1, p.rank(endpointKindOf(hostMetadata)))
+}
+
+func TestSheddingPolicySort(t *testing.T) {
+	p, err := NewSheddingPolicy([]string{"process", "orchestrator"}, nil, nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	var transactions []transaction.Transaction
+	for i, endpoint := range []transaction.Endpoint{
+		endpoints.SeriesEndpoint,
+		endpoints.OrchestratorEndpoint,
+		endpoints.ProcessesEndpoint,
+		endpoints.V1CheckRunsEndpoint,
+		endpoints.RtProcessesEndpoint,
+	} {
+		tr := createTransactionWithEndpoint(endpoint, 1)
+		tr.CreatedAt = now.Add(time.Duration(i) * time.Second)
+		transactions = append(transactions, tr)
+	}
+	createDropPrioritySorter().Sort(transactions)
+	p.sort(transactions)
+
+	var names []string
+	for _, tr := range transactions {
+		names = append(names, tr.GetEndpointName())
+	}
+	assert.Equal(t, []string{
+		endpoints.ProcessesEndpoint.Name,
+		endpoints.RtProcessesEndpoint.Name,
+		endpoints.OrchestratorEndpoint.Name,
+		endpoints.SeriesEndpoint.Name,
+		endpoints.V1CheckRunsEndpoint.Name,
+	}, names)
+}
@@ -0,0 +1,196 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedFileMagic starts the encrypted retry files. A plain retry file cannot start with it as
+// it is a protobuf message starting with the varint `Version` field.
+const encryptedFileMagic = "DDENC1"
+
+const (
+	storageKeyIDSize = 8
+	dataKeySize      = 32
+	gcmNonceSize     = 12
+	gcmTagSize       = 16
+	wrappedKeySize   = gcmNonceSize + dataKeySize + gcmTagSize
+	// encryptedFileHeaderSize is the size of the header of an encrypted retry file, made of the
+	// magic, the ID of the key encryption key and the wrapped data key.
+	encryptedFileHeaderSize = len(encryptedFileMagic) + storageKeyIDSize + wrappedKeySize
+)
+
+var errFileEncrypted = errors.New("the retry file is encrypted but no storage encryption key is configured")
+
+// StorageEncryption encrypts the retry files with AES-GCM envelope encryption: the content of
+// each file is encrypted with a random data key, itself encrypted with the current key encryption
+// key and stored in the header of the file.
+//
+// The previous key encryption keys are only used to decrypt the files written before a key
+// rotation. The files written with a key which is no longer configured cannot be decrypted and
+// are discarded.
+type StorageEncryption struct {
+	current *storageKey
+	keys    map[string]*storageKey
+}
+
+type storageKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// NewStorageEncryption creates a new instance of StorageEncryption from base64-encoded AES keys
+// (16, 24 or 32 bytes). The first key is used to encrypt the new files, all of them to decrypt the
+// existing ones.
+func NewStorageEncryption(keys []string) (*StorageEncryption, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no storage encryption key")
+	}
+	e := &StorageEncryption{keys: make(map[string]*storageKey, len(keys))}
+	for i, k := range keys {
+		// Never log the content of the key.
+		k = strings.TrimSpace(k)
+		if strings.HasPrefix(k, "ENC[") {
+			return nil, fmt.Errorf("the storage encryption key #%d was not resolved by the secrets backend", i)
+		}
+		raw, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("the storage encryption key #%d is not base64-encoded", i)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("the storage encryption key #%d is not a valid AES key: %v", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(raw)
+		key := &storageKey{id: sum[:storageKeyIDSize], aead: aead}
+		if i == 0 {
+			e.current = key
+		}
+		e.keys[string(key.id)] = key
+	}
+	return e, nil
+}
+
+// encrypt encrypts the content of a retry file with a new data key
+func (e *StorageEncryption) encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, encryptedFileHeaderSize+gcmNonceSize+len(plaintext)+gcmTagSize)
+	out = append(out, encryptedFileMagic...)
+	out = append(out, e.current.id...)
+	if out, err = seal(e.current.aead, out, dataKey, out); err != nil {
+		return nil, err
+	}
+	// The header is authenticated with the content.
+	return seal(aead, out, plaintext, out[:encryptedFileHeaderSize])
+}
+
+// decrypt decrypts the content of a retry file. The content of the plain retry files, written
+// before the encryption was enabled, is returned as is.
+func (e *StorageEncryption) decrypt(data []byte) ([]byte, error) {
+	if !isEncryptedRetryFile(data) {
+		return data, nil
+	}
+	key, err := e.keyOf(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < encryptedFileHeaderSize+gcmNonceSize {
+		return nil, errors.New("the encrypted retry file is truncated")
+	}
+
+	keyIDEnd := len(encryptedFileMagic) + storageKeyIDSize
+	dataKey, err := open(key.aead, data[keyIDEnd:encryptedFileHeaderSize], data[:keyIDEnd])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the data key of the retry file: %v", err)
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, data[encryptedFileHeaderSize:], data[:encryptedFileHeaderSize])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the retry file: %v", err)
+	}
+	return plaintext, nil
+}
+
+// checkKey returns an error if a retry file starting with `header` cannot be decrypted, because it
+// is encrypted with a key which is not configured.
+func (e *StorageEncryption) checkKey(header []byte) error {
+	if !isEncryptedRetryFile(header) {
+		return nil
+	}
+	_, err := e.keyOf(header)
+	return err
+}
+
+func (e *StorageEncryption) keyOf(data []byte) (*storageKey, error) {
+	if e == nil {
+		return nil, errFileEncrypted
+	}
+	keyIDEnd := len(encryptedFileMagic) + storageKeyIDSize
+	if len(data) < keyIDEnd {
+		return nil, errors.New("the encrypted retry file is truncated")
+	}
+	keyID := data[len(encryptedFileMagic):keyIDEnd]
+	key, found := e.keys[string(keyID)]
+	if !found {
+		return nil, fmt.Errorf("the retry file is encrypted with the unknown key %s", hex.EncodeToString(keyID))
+	}
+	return key, nil
+}
+
+func isEncryptedRetryFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedFileMagic))
+}
+
+// seal appends the random nonce and the encrypted plaintext to dst
+func seal(aead cipher.AEAD, dst []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts data made of a nonce and a ciphertext
+func open(aead cipher.AEAD, data []byte, additionalData []byte) ([]byte, error) {
+	if len(data) < gcmNonceSize {
+		return nil, errors.New("missing nonce")
+	}
+	return aead.Open(nil, data[:gcmNonceSize], data[gcmNonceSize:], additionalData)
+}
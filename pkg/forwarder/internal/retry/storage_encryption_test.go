@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorageKey(b byte, size int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, size))
+}
+
+func TestNewStorageEncryption(t *testing.T) {
+	for _, keys := range [][]string{
+		nil,
+		{"ENC[retry_key]"},
+		{"not base64!"},
+		{newTestStorageKey(1, 20)},
+		{newTestStorageKey(1, 32), "ENC[retry_key]"},
+	} {
+		_, err := NewStorageEncryption(keys)
+		assert.Error(t, err, "%v", keys)
+	}
+
+	for _, size := range []int{16, 24, 32} {
+		_, err := NewStorageEncryption([]string{" " + newTestStorageKey(1, size) + "\n"})
+		assert.NoError(t, err)
+	}
+}
+
+func TestStorageEncryption(t *testing.T) {
+	a := assert.New(t)
+	oldKey, newKey := newTestStorageKey(1, 32), newTestStorageKey(2, 16)
+	plaintext := []byte("transactions with the api_key")
+
+	old, err := NewStorageEncryption([]string{oldKey})
+	require.NoError(t, err)
+	encrypted, err := old.encrypt(plaintext)
+	a.NoError(err)
+	a.True(isEncryptedRetryFile(encrypted))
+	a.NotContains(string(encrypted), "api_key")
+
+	// Encrypting twice gives different files
+	other, err := old.encrypt(plaintext)
+	a.NoError(err)
+	a.NotEqual(encrypted, other)
+
+	decrypted, err := old.decrypt(encrypted)
+	a.NoError(err)
+	a.Equal(plaintext, decrypted)
+
+	// After a rotation, the files written with the old key can still be decrypted
+	rotated, err := NewStorageEncryption([]string{newKey, oldKey})
+	require.NoError(t, err)
+	a.NoError(rotated.checkKey(encrypted[:encryptedFileHeaderSize]))
+	decrypted, err = rotated.decrypt(encrypted)
+	a.NoError(err)
+	a.Equal(plaintext, decrypted)
+
+	// Once the old key is removed, they cannot
+	current, err := NewStorageEncryption([]string{newKey})
+	require.NoError(t, err)
+	a.Error(current.checkKey(encrypted[:encryptedFileHeaderSize]))
+	_, err = current.decrypt(encrypted)
+	a.Error(err)
+
+	// Nor without encryption
+	var disabled *StorageEncryption
+	a.Equal(errFileEncrypted, disabled.checkKey(encrypted))
+	_, err = disabled.decrypt(encrypted)
+	a.Equal(errFileEncrypted, err)
+
+	// The plain files are returned as is
+	a.NoError(current.checkKey(plaintext))
+	decrypted, err = current.decrypt(plaintext)
+	a.NoError(err)
+	a.Equal(plaintext, decrypted)
+
+	// Tampered and truncated files are rejected
+	for _, i := range []int{len(encryptedFileMagic) + storageKeyIDSize, encryptedFileHeaderSize - 1, len(encrypted) - 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 1
+		_, err = old.decrypt(tampered)
+		a.Error(err, "byte %d", i)
+	}
+	_, err = old.decrypt(encrypted[:encryptedFileHeaderSize])
+	a.Error(err)
+}
@@ -77,6 +77,20 @@ var (
 	fileStoragePointDroppedCountTelemetry   *counterExpvar
 	deserializeErrorsCountTelemetry         *counterExpvar
 	deserializeTransactionsCountTelemetry   *counterExpvar
+	undecryptableFilesCountTelemetry        *counterExpvar
+
+	// The transactions evicted from the retry queue, by endpoint
+	transactionContainerEvictedByEndpointExpvar = expvar.Map{}
+	fileStorageEvictedByEndpointExpvar          = expvar.Map{}
+	evictedTransactionsCountTelemetry           = telemetry.NewCounter("transaction_container", "evicted_transactions_count",
+		[]string{"domain", "endpoint", "storage"}, "The number of transactions evicted from the retry queue, by endpoint")
+
+	bundleRecorderExpvar                      = expvar.Map{}
+	recordedTransactionsCountTelemetry        *counterExpvar
+	notRecordedTransactionsCountTelemetry     *counterExpvar
+	droppedRecordedTransactionsCountTelemetry *counterExpvar
+	bundlesCountTelemetry                     *gaugeExpvar
+	bundlesRemovedCountTelemetry              *counterExpvar
 )
 
 func init() {
@@ -139,6 +153,8 @@ func init() {
 		"The number of points dropped",
 		&transactionContainerExpvar)
 
+	transactionContainerExpvar.Set("EvictedByEndpoint", &transactionContainerEvictedByEndpointExpvar)
+
 	transaction.ForwarderExpvars.Set("FileStorage", &fileStorageExpvar)
 	serializeCountTelemetry = newCounterExpvar(
 		"file_storage",
@@ -202,6 +218,45 @@ func init() {
 		domainTag,
 		"The number of transactions read from the disk",
 		&fileStorageExpvar)
+	undecryptableFilesCountTelemetry = newCounterExpvar(
+		"file_storage",
+		"undecryptable_files_count",
+		domainTag,
+		"The number of files discarded because they cannot be decrypted",
+		&fileStorageExpvar)
+	fileStorageExpvar.Set("EvictedByEndpoint", &fileStorageEvictedByEndpointExpvar)
+
+	transaction.ForwarderExpvars.Set("BundleRecorder", &bundleRecorderExpvar)
+	recordedTransactionsCountTelemetry = newCounterExpvar(
+		"bundle_recorder",
+		"recorded_transactions_count",
+		nil,
+		"The number of transactions recorded in the bundles",
+		&bundleRecorderExpvar)
+	notRecordedTransactionsCountTelemetry = newCounterExpvar(
+		"bundle_recorder",
+		"not_recorded_transactions_count",
+		nil,
+		"The number of transactions not recorded because they cannot be stored on the disk",
+		&bundleRecorderExpvar)
+	droppedRecordedTransactionsCountTelemetry = newCounterExpvar(
+		"bundle_recorder",
+		"dropped_transactions_count",
+		nil,
+		"The number of transactions dropped because the bundles are full",
+		&bundleRecorderExpvar)
+	bundlesCountTelemetry = newGaugeExpvar(
+		"bundle_recorder",
+		"bundles_count",
+		nil,
+		"The number of complete bundles",
+		&bundleRecorderExpvar)
+	bundlesRemovedCountTelemetry = newCounterExpvar(
+		"bundle_recorder",
+		"bundles_removed_count",
+		nil,
+		"The number of bundles removed because the maximum size of the bundles was reached",
+		&bundleRecorderExpvar)
 }
 
 // FileRemovalPolicyTelemetry handles the telemetry for FileRemovalPolicy.
@@ -254,6 +309,11 @@ func (t TransactionRetryQueueTelemetry) addPointDroppedCount(count int) {
 	transactionContainerPointDroppedCountTelemetry.add(float64(count), t.domainName)
 }
 
+func (t TransactionRetryQueueTelemetry) addEvictedTransaction(endpoint string) {
+	evictedTransactionsCountTelemetry.Inc(t.domainName, endpoint, "memory")
+	transactionContainerEvictedByEndpointExpvar.Add(endpoint, 1)
+}
+
 type onDiskRetryQueueTelemetry struct {
 	domainName string
 }
@@ -304,6 +364,37 @@ func (t onDiskRetryQueueTelemetry) addDeserializeTransactionsCount(count int) {
 	deserializeTransactionsCountTelemetry.add(float64(count), t.domainName)
 }
 
+func (t onDiskRetryQueueTelemetry) addEvictedTransaction(endpoint string) {
+	evictedTransactionsCountTelemetry.Inc(t.domainName, endpoint, "disk")
+	fileStorageEvictedByEndpointExpvar.Add(endpoint, 1)
+}
+
+func (t onDiskRetryQueueTelemetry) addUndecryptableFilesCount() {
+	undecryptableFilesCountTelemetry.add(1, t.domainName)
+}
+
+type bundleRecorderTelemetry struct{}
+
+func (bundleRecorderTelemetry) addRecordedTransactionsCount(count int) {
+	recordedTransactionsCountTelemetry.add(float64(count))
+}
+
+func (bundleRecorderTelemetry) addNotRecordedTransactionsCount(count int) {
+	notRecordedTransactionsCountTelemetry.add(float64(count))
+}
+
+func (bundleRecorderTelemetry) addDroppedTransactionsCount(count int) {
+	droppedRecordedTransactionsCountTelemetry.add(float64(count))
+}
+
+func (bundleRecorderTelemetry) setBundlesCount(count int) {
+	bundlesCountTelemetry.set(float64(count))
+}
+
+func (bundleRecorderTelemetry) addBundlesRemovedCount() {
+	bundlesRemovedCountTelemetry.add(1)
+}
+
 func toCamelCase(s string) string {
 	parts := strings.Split(s, "_")
 	var camelCase string
@@ -37,6 +37,8 @@ type TransactionRetryQueue struct {
 	flushToStorageRatio   float64
 	dropPrioritySorter    TransactionPrioritySorter
 	optionalSerializer    DiskTransactionSerializer
+	sheddingPolicy        *SheddingPolicy
+	memSizeByKind         map[resolver.EndpointKind]int
 	telemetry             TransactionRetryQueueTelemetry
 	mutex                 sync.RWMutex
 }
@@ -47,6 +49,8 @@ func BuildTransactionRetryQueue(
 	flushToStorageRatio float64,
 	optionalDomainFolderPath string,
 	optionalDiskUsageLimit *DiskUsageLimit,
+	optionalEncryption *StorageEncryption,
+	optionalSheddingPolicy *SheddingPolicy,
 	dropPrioritySorter TransactionPrioritySorter,
 	resolver resolver.DomainResolver) *TransactionRetryQueue {
 	var storage DiskTransactionSerializer
@@ -54,7 +58,7 @@ func BuildTransactionRetryQueue(
 
 	if optionalDomainFolderPath != "" && optionalDiskUsageLimit != nil {
 		serializer := NewHTTPTransactionsSerializer(resolver)
-		storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, optionalDiskUsageLimit, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
+		storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, optionalDiskUsageLimit, optionalEncryption, optionalSheddingPolicy, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
 
 		// If the storage on disk cannot be used, log the error and continue.
 		// Returning `nil, err` would mean not using `TransactionRetryQueue` and so not using `forwarder_retry_queue_payloads_max_size` config.
@@ -66,6 +70,7 @@ func BuildTransactionRetryQueue(
 	return NewTransactionRetryQueue(
 		dropPrioritySorter,
 		storage,
+		optionalSheddingPolicy,
 		maxMemSizeInBytes,
 		flushToStorageRatio,
 		NewTransactionRetryQueueTelemetry(resolver.GetBaseDomain()))
@@ -75,6 +80,7 @@ func BuildTransactionRetryQueue(
 func NewTransactionRetryQueue(
 	dropPrioritySorter TransactionPrioritySorter,
 	optionalTransactionSerializer DiskTransactionSerializer,
+	optionalSheddingPolicy *SheddingPolicy,
 	maxMemSizeInBytes int,
 	flushToStorageRatio float64,
 	telemetry TransactionRetryQueueTelemetry) *TransactionRetryQueue {
@@ -83,6 +89,8 @@ func NewTransactionRetryQueue(
 		flushToStorageRatio: flushToStorageRatio,
 		dropPrioritySorter:  dropPrioritySorter,
 		optionalSerializer:  optionalTransactionSerializer,
+		sheddingPolicy:      optionalSheddingPolicy,
+		memSizeByKind:       make(map[resolver.EndpointKind]int),
 		telemetry:           telemetry,
 	}
 }
@@ -97,20 +105,51 @@ func NewTransactionRetryQueue(
 // The first 3 transactions are flushed to the disk as 10 + 20 + 30 >= 60
 // If disk serialization failed or is not enabled, remove old transactions such as
 // `currentMemSizeInBytes` <= `maxMemSizeInBytes`
+// When a `SheddingPolicy` is set, the transactions are flushed or dropped in its shed order and
+// the same applies to the transactions of each endpoint kind having a memory budget.
 func (tc *TransactionRetryQueue) Add(t transaction.Transaction) (int, error) {
 	tc.mutex.Lock()
 	defer tc.mutex.Unlock()
 
-	var diskErr error
 	payloadSize := t.GetPayloadSize()
+	kind := endpointKindOf(t)
+	inMemTransactionDroppedCount := 0
+	var diskErr error
+
+	if budget, found := tc.sheddingPolicy.memBudget(kind); found {
+		droppedCount, err := tc.makeRoomFor(payloadSize, budget, func() int { return tc.memSizeByKind[kind] }, kind)
+		inMemTransactionDroppedCount += droppedCount
+		diskErr = err
+	}
+	droppedCount, err := tc.makeRoomFor(payloadSize, tc.maxMemSizeInBytes, func() int { return tc.currentMemSizeInBytes }, "")
+	inMemTransactionDroppedCount += droppedCount
+	if diskErr == nil {
+		diskErr = err
+	}
+
+	tc.transactions = append(tc.transactions, t)
+	tc.currentMemSizeInBytes += payloadSize
+	tc.memSizeByKind[kind] += payloadSize
+	tc.telemetry.setCurrentMemSizeInBytes(tc.currentMemSizeInBytes)
+	tc.telemetry.setTransactionsCount(len(tc.transactions))
+
+	return inMemTransactionDroppedCount, diskErr
+}
+
+// makeRoomFor flushes transactions to disk, or drops them if disk serialization failed or is not
+// enabled, until `currentSize() + payloadSize <= maxSize`. Only the transactions of the given
+// endpoint kind are extracted if it is not empty.
+func (tc *TransactionRetryQueue) makeRoomFor(payloadSize int, maxSize int, currentSize func() int, kind resolver.EndpointKind) (int, error) {
+	var diskErr error
 	if tc.optionalSerializer != nil {
-		payloadsGroupToFlush := tc.extractTransactionsForDisk(payloadSize)
+		payloadsGroupToFlush := tc.extractTransactionsForDisk(payloadSize, maxSize, currentSize, kind)
 		for _, payloads := range payloadsGroupToFlush {
 			if err := tc.optionalSerializer.Serialize(payloads); err != nil {
 				diskErr = multierror.Append(diskErr, err)
 				// Assuming all payloads failed during serialization
 				for _, payload := range payloads {
 					tc.telemetry.addTransactionsDroppedCount(payload.GetPointCount())
+					tc.telemetry.addEvictedTransaction(payload.GetEndpointName())
 				}
 			}
 		}
@@ -120,23 +159,18 @@ func (tc *TransactionRetryQueue) Add(t transaction.Transaction) (int, error) {
 		}
 	}
 
-	// If disk serialization failed or is not enabled, make sure `currentMemSizeInBytes` <= `maxMemSizeInBytes`
-	payloadSizeInBytesToDrop := (tc.currentMemSizeInBytes + payloadSize) - tc.maxMemSizeInBytes
+	// If disk serialization failed or is not enabled, make sure `currentSize()` <= `maxSize`
+	payloadSizeInBytesToDrop := (currentSize() + payloadSize) - maxSize
 	inMemTransactionDroppedCount := 0
 	if payloadSizeInBytesToDrop > 0 {
-		transactions := tc.extractTransactionsFromMemory(payloadSizeInBytesToDrop)
+		transactions := tc.extractTransactionsFromMemory(payloadSizeInBytesToDrop, kind)
 		for _, tr := range transactions {
 			tc.telemetry.addPointDroppedCount(tr.GetPointCount())
+			tc.telemetry.addEvictedTransaction(tr.GetEndpointName())
 		}
 		inMemTransactionDroppedCount = len(transactions)
 		tc.telemetry.addTransactionsDroppedCount(inMemTransactionDroppedCount)
 	}
-
-	tc.transactions = append(tc.transactions, t)
-	tc.currentMemSizeInBytes += payloadSize
-	tc.telemetry.setCurrentMemSizeInBytes(tc.currentMemSizeInBytes)
-	tc.telemetry.setTransactionsCount(len(tc.transactions))
-
 	return inMemTransactionDroppedCount, diskErr
 }
 
@@ -161,6 +195,7 @@ func (tc *TransactionRetryQueue) ExtractTransactions() ([]transaction.Transactio
 		}
 	}
 	tc.currentMemSizeInBytes = 0
+	tc.memSizeByKind = make(map[resolver.EndpointKind]int)
 	tc.telemetry.setCurrentMemSizeInBytes(tc.currentMemSizeInBytes)
 	tc.telemetry.setTransactionsCount(len(tc.transactions))
 	return transactions, nil
@@ -200,15 +235,15 @@ func (tc *TransactionRetryQueue) GetDiskSpaceUsed() int64 {
 	return 0
 }
 
-func (tc *TransactionRetryQueue) extractTransactionsForDisk(payloadSize int) [][]transaction.Transaction {
-	sizeInBytesToFlush := int(float64(tc.maxMemSizeInBytes) * tc.flushToStorageRatio)
+func (tc *TransactionRetryQueue) extractTransactionsForDisk(payloadSize int, maxSize int, currentSize func() int, kind resolver.EndpointKind) [][]transaction.Transaction {
+	sizeInBytesToFlush := int(float64(maxSize) * tc.flushToStorageRatio)
 	var payloadsGroupToFlush [][]transaction.Transaction
-	for tc.currentMemSizeInBytes+payloadSize > tc.maxMemSizeInBytes && len(tc.transactions) > 0 {
+	for currentSize()+payloadSize > maxSize && len(tc.transactions) > 0 {
 		// Flush the N first transactions whose payload size sum is greater than `sizeInBytesToFlush`
-		transactions := tc.extractTransactionsFromMemory(sizeInBytesToFlush)
+		transactions := tc.extractTransactionsFromMemory(sizeInBytesToFlush, kind)
 
 		if len(transactions) == 0 {
-			// Happens when `sizeInBytesToFlush == 0`
+			// Happens when `sizeInBytesToFlush == 0` or when there is no transaction of this kind
 			// Avoid infinite loop
 			break
 		}
@@ -218,19 +253,33 @@ func (tc *TransactionRetryQueue) extractTransactionsForDisk(payloadSize int) [][
 	return payloadsGroupToFlush
 }
 
-func (tc *TransactionRetryQueue) extractTransactionsFromMemory(payloadSizeInBytesToExtract int) []transaction.Transaction {
-	i := 0
+// extractTransactionsFromMemory extracts the first transactions to drop whose payload size sum is
+// greater than `payloadSizeInBytesToExtract`, only among the transactions of the given endpoint
+// kind if it is not empty.
+func (tc *TransactionRetryQueue) extractTransactionsFromMemory(payloadSizeInBytesToExtract int, kind resolver.EndpointKind) []transaction.Transaction {
 	sizeInBytesExtracted := 0
 	var transactionsExtracted []transaction.Transaction
 
 	tc.dropPrioritySorter.Sort(tc.transactions)
-	for ; i < len(tc.transactions) && sizeInBytesExtracted < payloadSizeInBytesToExtract; i++ {
-		transaction := tc.transactions[i]
-		sizeInBytesExtracted += transaction.GetPayloadSize()
+	tc.sheddingPolicy.sort(tc.transactions)
+	remaining := tc.transactions[:0]
+	for _, transaction := range tc.transactions {
+		transactionKind := endpointKindOf(transaction)
+		if sizeInBytesExtracted >= payloadSizeInBytesToExtract || (kind != "" && transactionKind != kind) {
+			remaining = append(remaining, transaction)
+			continue
+		}
+		size := transaction.GetPayloadSize()
+		sizeInBytesExtracted += size
+		tc.memSizeByKind[transactionKind] -= size
 		transactionsExtracted = append(transactionsExtracted, transaction)
 	}
 
-	tc.transactions = tc.transactions[i:]
+	// Release the extracted transactions, and their payloads, left after the remaining ones.
+	for i := len(remaining); i < len(tc.transactions); i++ {
+		tc.transactions[i] = nil
+	}
+	tc.transactions = remaining
 	tc.currentMemSizeInBytes -= sizeInBytesExtracted
 	return transactionsExtracted
 }
@@ -6,11 +6,13 @@
 package retry
 
 import (
+	"expvar"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
 )
@@ -20,7 +22,7 @@ func TestTransactionRetryQueueAdd(t *testing.T) {
 	pointDropped := transactionContainerPointDroppedCountTelemetry.expvar.Value()
 	q := newOnDiskRetryQueueTest(t, a)
 
-	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, 100, 0.6, NewTransactionRetryQueueTelemetry("domain"))
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, nil, 100, 0.6, NewTransactionRetryQueueTelemetry("domain"))
 
 	// When adding the last element `15`, the buffer becomes full and the first 3
 	// transactions are flushed to the disk as 10 + 20 + 30 >= 100 * 0.6
@@ -48,7 +50,7 @@ func TestTransactionRetryQueueSeveralFlushToDisk(t *testing.T) {
 	a := assert.New(t)
 	q := newOnDiskRetryQueueTest(t, a)
 
-	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, 50, 0.1, NewTransactionRetryQueueTelemetry("domain"))
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, nil, 50, 0.1, NewTransactionRetryQueueTelemetry("domain"))
 
 	// Flush to disk when adding `40`
 	for _, payloadSize := range []int{9, 10, 11, 40} {
@@ -68,7 +70,7 @@ func TestTransactionRetryQueueSeveralFlushToDisk(t *testing.T) {
 func TestTransactionRetryQueueNoTransactionStorage(t *testing.T) {
 	a := assert.New(t)
 	pointDropped := transactionContainerPointDroppedCountTelemetry.expvar.Value()
-	container := NewTransactionRetryQueue(createDropPrioritySorter(), nil, 50, 0.1, NewTransactionRetryQueueTelemetry("domain"))
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), nil, nil, 50, 0.1, NewTransactionRetryQueueTelemetry("domain"))
 
 	for _, payloadSize := range []int{9, 10, 11} {
 		dropCount, err := container.Add(createTransactionWithPayloadSize(payloadSize))
@@ -93,7 +95,7 @@ func TestTransactionRetryQueueZeroMaxMemSizeInBytes(t *testing.T) {
 
 	maxMemSizeInBytes := 0
 	pointDropped := transactionContainerPointDroppedCountTelemetry.expvar.Value()
-	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, maxMemSizeInBytes, 0.1, NewTransactionRetryQueueTelemetry("domain"))
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), q, nil, maxMemSizeInBytes, 0.1, NewTransactionRetryQueueTelemetry("domain"))
 
 	inMemTrDropped, err := container.Add(createTransactionWithPayloadSize(10))
 	a.NoError(err)
@@ -107,6 +109,87 @@ func TestTransactionRetryQueueZeroMaxMemSizeInBytes(t *testing.T) {
 	a.Equal(pointDropped+1, transactionContainerPointDroppedCountTelemetry.expvar.Value())
 }
 
+func TestTransactionRetryQueueShedOrder(t *testing.T) {
+	a := assert.New(t)
+	policy, err := NewSheddingPolicy([]string{"process", "orchestrator"}, nil, nil)
+	a.NoError(err)
+	evicted := getEvictedCount(&transactionContainerEvictedByEndpointExpvar, endpoints.ProcessesEndpoint.Name)
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), nil, policy, 50, 0.1, NewTransactionRetryQueueTelemetry("domain"))
+
+	for _, endpoint := range []transaction.Endpoint{endpoints.SeriesEndpoint, endpoints.ProcessesEndpoint, endpoints.OrchestratorEndpoint, endpoints.ProcessesEndpoint} {
+		_, err := container.Add(createTransactionWithEndpoint(endpoint, 10))
+		a.NoError(err)
+	}
+
+	// The process transactions are dropped first, then the orchestrator ones
+	dropCount, err := container.Add(createTransactionWithEndpoint(endpoints.SeriesEndpoint, 25))
+	a.NoError(err)
+	a.Equal(2, dropCount)
+	dropCount, err = container.Add(createTransactionWithEndpoint(endpoints.ServiceChecksEndpoint, 10))
+	a.NoError(err)
+	a.Equal(1, dropCount)
+	a.Equal(45, container.getCurrentMemSizeInBytes())
+	a.Equal(evicted+2, getEvictedCount(&transactionContainerEvictedByEndpointExpvar, endpoints.ProcessesEndpoint.Name))
+	// The dropped transactions are not kept in the backing array of the queue
+	for _, tr := range container.transactions[len(container.transactions):cap(container.transactions)] {
+		a.Nil(tr)
+	}
+
+	transactions, err := container.ExtractTransactions()
+	a.NoError(err)
+	a.ElementsMatch([]string{endpoints.SeriesEndpoint.Name, endpoints.SeriesEndpoint.Name, endpoints.ServiceChecksEndpoint.Name}, getEndpointsFromTransactions(transactions))
+}
+
+func TestTransactionRetryQueueMemBudget(t *testing.T) {
+	a := assert.New(t)
+	q := newOnDiskRetryQueueTest(t, a)
+	policy, err := NewSheddingPolicy(nil, map[string]int{"process": 20}, nil)
+	a.NoError(err)
+	container := NewTransactionRetryQueue(createDropPrioritySorter(), nil, policy, 100, 0.5, NewTransactionRetryQueueTelemetry("domain"))
+
+	// The process transactions cannot use more than 20 bytes, even if the queue is not full
+	for _, endpoint := range []transaction.Endpoint{endpoints.ProcessesEndpoint, endpoints.SeriesEndpoint, endpoints.ProcessesEndpoint} {
+		dropCount, err := container.Add(createTransactionWithEndpoint(endpoint, 10))
+		a.NoError(err)
+		a.Equal(0, dropCount)
+	}
+	dropCount, err := container.Add(createTransactionWithEndpoint(endpoints.ProcessesEndpoint, 10))
+	a.NoError(err)
+	a.Equal(1, dropCount)
+	a.Equal(30, container.getCurrentMemSizeInBytes())
+	a.Equal(20, container.memSizeByKind[resolver.ProcessKind])
+
+	// With a storage on disk, the transactions over budget are flushed to disk
+	container = NewTransactionRetryQueue(createDropPrioritySorter(), q, policy, 100, 0.5, NewTransactionRetryQueueTelemetry("domain"))
+	for _, endpoint := range []transaction.Endpoint{endpoints.ProcessesEndpoint, endpoints.SeriesEndpoint, endpoints.ProcessesEndpoint, endpoints.ProcessesEndpoint} {
+		dropCount, err := container.Add(createTransactionWithEndpoint(endpoint, 10))
+		a.NoError(err)
+		a.Equal(0, dropCount)
+	}
+	a.Equal(30, container.getCurrentMemSizeInBytes())
+	a.Equal(1, q.getFilesCount())
+	transactions, err := container.ExtractTransactions()
+	a.NoError(err)
+	a.Len(transactions, 3)
+	a.Empty(container.memSizeByKind)
+	transactions, err = container.ExtractTransactions()
+	a.NoError(err)
+	a.Equal([]string{endpoints.ProcessesEndpoint.Name}, getEndpointsFromTransactions(transactions))
+}
+
+func getEvictedCount(evictedByEndpoint *expvar.Map, endpoint string) int64 {
+	if count, ok := evictedByEndpoint.Get(endpoint).(*expvar.Int); ok {
+		return count.Value()
+	}
+	return 0
+}
+
+func createTransactionWithEndpoint(endpoint transaction.Endpoint, payloadSize int) *transaction.HTTPTransaction {
+	tr := createTransactionWithPayloadSize(payloadSize)
+	tr.Endpoint = endpoint
+	return tr
+}
+
 func createTransactionWithPayloadSize(payloadSize int) *transaction.HTTPTransaction {
 	tr := transaction.NewHTTPTransaction()
 	payload := make([]byte, payloadSize)
@@ -142,7 +225,7 @@ func newOnDiskRetryQueueTest(t *testing.T, a *assert.Assertions) *onDiskRetryQue
 			Total:     10000,
 		}}
 	diskUsageLimit := NewDiskUsageLimit("", disk, 1000, 1)
-	q, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver("", nil)), path, diskUsageLimit, newOnDiskRetryQueueTelemetry("domain"))
+	q, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver("", nil)), path, diskUsageLimit, nil, nil, newOnDiskRetryQueueTelemetry("domain"))
 	a.NoError(err)
 	return q
 }
@@ -60,6 +60,11 @@ func (f NoopForwarder) SubmitMetadata(payload transaction.BytesPayloads, extra h
 	return nil
 }
 
+// SubmitProcessesMetadata does nothing.
+func (f NoopForwarder) SubmitProcessesMetadata(payload transaction.BytesPayloads, extra http.Header) error {
+	return nil
+}
+
 // SubmitProcessChecks does nothing.
 func (f NoopForwarder) SubmitProcessChecks(payload transaction.BytesPayloads, extra http.Header) (chan Response, error) {
 	return nil, nil
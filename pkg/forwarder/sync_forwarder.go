@@ -76,7 +76,12 @@ func (f *SyncForwarder) SubmitSeries(payload transaction.BytesPayloads, extra ht
 
 // SubmitV1Intake will send payloads to the universal `/intake/` endpoint used by Agent v.5
 func (f *SyncForwarder) SubmitV1Intake(payload transaction.BytesPayloads, extra http.Header) error {
-	transactions := f.defaultForwarder.createHTTPTransactions(endpoints.V1IntakeEndpoint, payload, true, extra)
+	return f.submitV1Intake(resolver.EndpointKindOf(endpoints.V1IntakeEndpoint), payload, extra)
+}
+
+// submitV1Intake sends payloads of the given kind to the `/intake/` endpoint
+func (f *SyncForwarder) submitV1Intake(kind resolver.EndpointKind, payload transaction.BytesPayloads, extra http.Header) error {
+	transactions := f.defaultForwarder.createAdvancedHTTPTransactions(endpoints.V1IntakeEndpoint, kind, payload, true, extra, transaction.TransactionPriorityNormal, true)
 	// the intake endpoint requires the Content-Type header to be set
 	for _, t := range transactions {
 		t.Headers.Set("Content-Type", "application/json")
@@ -99,17 +104,22 @@ func (f *SyncForwarder) SubmitSketchSeries(payload transaction.BytesPayloads, ex
 
 // SubmitHostMetadata will send a host_metadata tag type payload to Datadog backend.
 func (f *SyncForwarder) SubmitHostMetadata(payload transaction.BytesPayloads, extra http.Header) error {
-	return f.SubmitV1Intake(payload, extra)
+	return f.submitV1Intake(resolver.MetadataKind, payload, extra)
 }
 
 // SubmitMetadata will send a metadata type payload to Datadog backend.
 func (f *SyncForwarder) SubmitMetadata(payload transaction.BytesPayloads, extra http.Header) error {
-	return f.SubmitV1Intake(payload, extra)
+	return f.submitV1Intake(resolver.MetadataKind, payload, extra)
+}
+
+// SubmitProcessesMetadata will send a legacy processes metadata payload to Datadog backend.
+func (f *SyncForwarder) SubmitProcessesMetadata(payload transaction.BytesPayloads, extra http.Header) error {
+	return f.submitV1Intake(resolver.MetadataKind, payload, extra)
 }
 
 // SubmitAgentChecksMetadata will send a agentchecks_metadata tag type payload to Datadog backend.
 func (f *SyncForwarder) SubmitAgentChecksMetadata(payload transaction.BytesPayloads, extra http.Header) error {
-	return f.SubmitV1Intake(payload, extra)
+	return f.submitV1Intake(resolver.MetadataKind, payload, extra)
 }
 
 // SubmitProcessChecks sends process checks
@@ -133,6 +133,11 @@ func (tf *MockedForwarder) SubmitMetadata(payload transaction.BytesPayloads, ext
 	return tf.Called(payload, extra).Error(0)
 }
 
+// SubmitProcessesMetadata updates the internal mock struct
+func (tf *MockedForwarder) SubmitProcessesMetadata(payload transaction.BytesPayloads, extra http.Header) error {
+	return tf.Called(payload, extra).Error(0)
+}
+
 // SubmitProcessChecks mock
 func (tf *MockedForwarder) SubmitProcessChecks(payload transaction.BytesPayloads, extra http.Header) (chan Response, error) {
 	return nil, tf.Called(payload, extra).Error(0)
@@ -10,6 +10,8 @@ package transaction
 type BytesPayload struct {
 	content    []byte
 	pointCount int
+	// domains holds the only domains the payload is sent to, nil if it is not restricted
+	domains []string
 }
 
 // NewBytesPayload creates a new instance of BytesPayload.
@@ -40,6 +42,16 @@ func (p *BytesPayload) GetPointCount() int {
 	return p.pointCount
 }
 
+// GetDomains returns the only domains this payload is sent to, nil if it is not restricted
+func (p *BytesPayload) GetDomains() []string {
+	return p.domains
+}
+
+// SetDomains restricts the domains this payload is sent to
+func (p *BytesPayload) SetDomains(domains []string) {
+	p.domains = domains
+}
+
 // BytesPayloads is a collection of BytesPayload
 type BytesPayloads []*BytesPayload
 
@@ -170,6 +170,10 @@ type HTTPTransaction struct {
 	Domain string
 	// Endpoint is the API Endpoint used by the HTTPTransaction.
 	Endpoint Endpoint
+	// Kind is the kind of data sent by the HTTPTransaction, used to route and shed it. It is set
+	// by the forwarder, as some endpoints receive several kinds of data, and is empty if the
+	// endpoint is not routable.
+	Kind string
 	// Headers are the HTTP headers used by the HTTPTransaction.
 	Headers http.Header
 	// Payload is the content delivered to the backend.
@@ -199,6 +199,7 @@ func BuildHTTPEndpointsWithConfig(logsConfig *LogsConfigKeys, endpointPrefix str
 	main := Endpoint{
 		APIKey:                  logsConfig.getLogsAPIKey(),
 		UseCompression:          logsConfig.useCompression(),
+		CompressionKind:         logsConfig.compressionKind(),
 		CompressionLevel:        logsConfig.compressionLevel(),
 		ConnectionResetInterval: logsConfig.connectionResetInterval(),
 		BackoffBase:             logsConfig.senderBackoffBase(),
@@ -264,8 +265,18 @@ func BuildHTTPEndpointsWithConfig(logsConfig *LogsConfigKeys, endpointPrefix str
 	for i := 0; i < len(additionals); i++ {
 		additionals[i].UseSSL = main.UseSSL
 		additionals[i].APIKey = coreConfig.SanitizeAPIKey(additionals[i].APIKey)
-		additionals[i].UseCompression = main.UseCompression
-		additionals[i].CompressionLevel = main.CompressionLevel
+		if additionals[i].CompressionKind == "" {
+			additionals[i].UseCompression = main.UseCompression
+			additionals[i].CompressionKind = main.CompressionKind
+			additionals[i].CompressionLevel = main.CompressionLevel
+		} else {
+			// the endpoint has its own compression settings
+			additionals[i].UseCompression = true
+			additionals[i].CompressionKind = validCompressionKind(logsConfig.getConfigKey("additional_endpoints"), additionals[i].CompressionKind)
+			if additionals[i].CompressionLevel == 0 {
+				additionals[i].CompressionLevel = main.CompressionLevel
+			}
+		}
 		additionals[i].BackoffBase = main.BackoffBase
 		additionals[i].BackoffMax = main.BackoffMax
 		additionals[i].BackoffFactor = main.BackoffFactor
@@ -110,6 +110,24 @@ func (l *LogsConfigKeys) compressionLevel() int {
 	return l.getConfig().GetInt(l.getConfigKey("compression_level"))
 }
 
+func (l *LogsConfigKeys) compressionKind() string {
+	key := l.getConfigKey("compression_kind")
+	return validCompressionKind(key, l.getConfig().GetString(key))
+}
+
+// validCompressionKind returns the given compression kind, or the gzip one if
+// it's invalid or not set.
+func validCompressionKind(key string, kind string) string {
+	switch kind {
+	case GzipCompressionKind, ZstdCompressionKind:
+		return kind
+	case "":
+		return GzipCompressionKind
+	}
+	log.Warnf("Invalid %s: %v should be one of %s, %s, fallback on %s", key, kind, GzipCompressionKind, ZstdCompressionKind, GzipCompressionKind)
+	return GzipCompressionKind
+}
+
 func (l *LogsConfigKeys) useCompression() bool {
 	return l.getConfig().GetBool(l.getConfigKey("use_compression"))
 }
@@ -160,6 +160,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsEnvVar() {
 		Port:             443,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    3,
 		BackoffBase:      1.0,
@@ -174,6 +175,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsEnvVar() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    3,
 		BackoffBase:      1.0,
@@ -188,6 +190,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsEnvVar() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    3,
 		BackoffBase:      1.0,
@@ -204,6 +207,46 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsEnvVar() {
 	suite.Equal(expectedEndpoints, endpoints)
 }
 
+func (suite *ConfigTestSuite) TestMultipleHttpEndpointsCompression() {
+	suite.config.Set("api_key", "123")
+	suite.config.Set("logs_config.use_compression", true)
+	suite.config.Set("logs_config.compression_level", 6)
+	suite.config.Set("logs_config.additional_endpoints", []map[string]interface{}{
+		{"api_key": "456", "host": "additional.endpoint.1", "use_compression": false},
+		{"api_key": "789", "host": "additional.endpoint.2", "compression_kind": "zstd", "compression_level": 3},
+		{"api_key": "abc", "host": "additional.endpoint.3", "compression_kind": "zstd"},
+		{"api_key": "def", "host": "additional.endpoint.4", "compression_kind": "lz4"},
+	})
+
+	endpoints, err := BuildHTTPEndpoints("test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Len(endpoints.Endpoints, 5)
+
+	type compression struct {
+		use   bool
+		kind  string
+		level int
+	}
+	var got []compression
+	for _, endpoint := range endpoints.Endpoints {
+		got = append(got, compression{endpoint.UseCompression, endpoint.CompressionKind, endpoint.CompressionLevel})
+	}
+	suite.Equal([]compression{
+		{true, GzipCompressionKind, 6},
+		// endpoints without a compression kind use the main endpoint settings
+		{true, GzipCompressionKind, 6},
+		{true, ZstdCompressionKind, 3},
+		{true, ZstdCompressionKind, 6},
+		{true, GzipCompressionKind, 6},
+	}, got)
+
+	suite.config.Set("logs_config.compression_kind", "zstd")
+	suite.config.Set("logs_config.additional_endpoints", nil)
+	endpoints, err = BuildHTTPEndpoints("test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Equal(ZstdCompressionKind, endpoints.Main.CompressionKind)
+}
+
 func (suite *ConfigTestSuite) TestMultipleTCPEndpointsEnvVar() {
 	suite.config.Set("api_key", "123")
 	suite.config.Set("logs_config.logs_dd_url", "agent-http-intake.logs.datadoghq.com:443")
@@ -271,6 +314,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig() {
 		Port:             443,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -284,6 +328,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -297,6 +342,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -343,6 +389,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig2() {
 		Port:             443,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -359,6 +406,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig2() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -372,6 +420,7 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig2() {
 		Port:             1234,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -446,6 +495,7 @@ func (suite *ConfigTestSuite) TestEndpointsSetLogsDDUrl() {
 		Port:             443,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -492,6 +542,7 @@ func (suite *ConfigTestSuite) TestEndpointsSetDDSite() {
 		Port:             0,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -528,6 +579,7 @@ func (suite *ConfigTestSuite) TestBuildServerlessEndpoints() {
 		Port:             0,
 		UseSSL:           true,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -563,6 +615,7 @@ func getTestEndpoint(host string, port int, ssl bool) Endpoint {
 		Port:             port,
 		UseSSL:           ssl,
 		UseCompression:   true,
+		CompressionKind:  "gzip",
 		CompressionLevel: 6,
 		BackoffFactor:    coreConfig.DefaultLogsSenderBackoffFactor,
 		BackoffBase:      coreConfig.DefaultLogsSenderBackoffBase,
@@ -674,6 +727,7 @@ func (suite *ConfigTestSuite) TestEndpointsSetNonDefaultCustomConfigs() {
 		Port:                    0,
 		UseSSL:                  true,
 		UseCompression:          false,
+		CompressionKind:         "gzip",
 		CompressionLevel:        10,
 		BackoffFactor:           4,
 		BackoffBase:             2,
@@ -23,6 +23,7 @@ const (
 	JournaldType      = "journald"
 	WindowsEventType  = "windows_event"
 	StringChannelType = "string_channel"
+	OTLPType          = "otlp"
 
 	// UTF16BE for UTF-16 Big endian encoding
 	UTF16BE string = "utf-16-be"
@@ -30,6 +31,9 @@ const (
 	UTF16LE string = "utf-16-le"
 	// SHIFTJIS for Shift JIS (Japanese) encoding
 	SHIFTJIS string = "shift-jis"
+
+	// SyslogFormat for syslog messages, following RFC 5424 or RFC 3164
+	SyslogFormat string = "syslog"
 )
 
 // LogsConfig represents a log source config, which can be for instance
@@ -37,8 +41,10 @@ const (
 type LogsConfig struct {
 	Type string
 
-	Port        int    // Network
+	Port        int    // Network, OTLP over gRPC
+	HTTPPort    int    `mapstructure:"http_port" json:"http_port"`       // OTLP over HTTP
 	IdleTimeout string `mapstructure:"idle_timeout" json:"idle_timeout"` // Network
+	Format      string `mapstructure:"format" json:"format"`             // Network
 	Path        string // File, Journald
 
 	Encoding     string   `mapstructure:"encoding" json:"encoding"`             // File
@@ -106,9 +112,14 @@ func (c *LogsConfig) Dump(multiline bool) string {
 	case TCPType:
 		fmt.Fprintf(&b, ws("Port: %d,"), c.Port)
 		fmt.Fprintf(&b, ws("IdleTimeout: %#v,"), c.IdleTimeout)
+		fmt.Fprintf(&b, ws("Format: %#v,"), c.Format)
 	case UDPType:
 		fmt.Fprintf(&b, ws("Port: %d,"), c.Port)
 		fmt.Fprintf(&b, ws("IdleTimeout: %#v,"), c.IdleTimeout)
+		fmt.Fprintf(&b, ws("Format: %#v,"), c.Format)
+	case OTLPType:
+		fmt.Fprintf(&b, ws("Port: %d,"), c.Port)
+		fmt.Fprintf(&b, ws("HTTPPort: %d,"), c.HTTPPort)
 	case FileType:
 		fmt.Fprintf(&b, ws("Path: %#v,"), c.Path)
 		fmt.Fprintf(&b, ws("Encoding: %#v,"), c.Encoding)
@@ -212,6 +223,16 @@ func (c *LogsConfig) Validate() error {
 		return fmt.Errorf("tcp source must have a port")
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
+	case c.Type == OTLPType && c.Port == 0 && c.HTTPPort == 0:
+		return fmt.Errorf("otlp source must have a port or an http_port")
+	}
+	if c.Format != "" {
+		if c.Type != TCPType && c.Type != UDPType {
+			return fmt.Errorf("format is only supported by tcp and udp sources")
+		}
+		if c.Format != SyslogFormat {
+			return fmt.Errorf("invalid format %q, the only supported format is %q", c.Format, SyslogFormat)
+		}
 	}
 	err := ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
@@ -8,6 +8,7 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Processing rule types
@@ -16,8 +17,30 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+	JSONFields     = "json_fields"
+	Sample         = "sample"
+	RateLimit      = "rate_limit"
 )
 
+// JSON field actions of the json_fields processing rule
+const (
+	// JSONFieldDrop removes the field
+	JSONFieldDrop = "drop"
+	// JSONFieldRename moves the field to the target path
+	JSONFieldRename = "rename"
+	// JSONFieldMask replaces the field value with the placeholder
+	JSONFieldMask = "mask"
+	// JSONFieldStatus sets the status of the log to the field value
+	JSONFieldStatus = "status"
+	// JSONFieldTag removes the field and adds its value as a tag, using the
+	// target as tag key, or the field name if not set
+	JSONFieldTag = "tag"
+)
+
+// defaultJSONFieldPlaceholder is used to mask JSON fields when the rule doesn't
+// define a placeholder
+const defaultJSONFieldPlaceholder = "[masked]"
+
 // ProcessingRule defines an exclusion or a masking rule to
 // be applied on log lines
 type ProcessingRule struct {
@@ -25,16 +48,37 @@ type ProcessingRule struct {
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
 	Pattern            string
+	// Fields are the field rules of a json_fields rule
+	Fields []*JSONFieldRule `mapstructure:"fields" json:"fields"`
+	// SampleRate is the ratio of log lines kept by a sample rule
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate"`
+	// MaxPerSecond is the number of log lines kept per second by a rate_limit rule
+	MaxPerSecond int `mapstructure:"max_per_second" json:"max_per_second"`
 	// TODO: should be moved out
 	Regex       *regexp.Regexp
 	Placeholder []byte
+	RateLimiter *RateLimiter
+}
+
+// JSONFieldRule defines the action applied on a field of JSON log lines, the
+// field is identified by its path, with a dot separating the nested keys.
+type JSONFieldRule struct {
+	Path               string
+	Action             string
+	Target             string
+	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
+	// TODO: should be moved out
+	Keys        []string
+	TargetKeys  []string
+	Placeholder string
 }
 
 // ValidateProcessingRules validates the rules and raises an error if one is misconfigured.
 // Each processing rule must have:
 // - a valid name
 // - a valid type
-// - a valid pattern that compiles
+// - a valid pattern that compiles, or valid fields for json_fields rules
+// sample and rate_limit rules only apply to the lines matching their pattern, if any.
 func ValidateProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
 		if rule.Name == "" {
@@ -44,6 +88,25 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		switch rule.Type {
 		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
 			break
+		case JSONFields:
+			if err := validateJSONFieldRules(rule); err != nil {
+				return err
+			}
+			continue
+		case Sample:
+			if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+				return fmt.Errorf("invalid sample_rate %v for processing rule: %s, must be in ]0, 1]", rule.SampleRate, rule.Name)
+			}
+			if rule.Pattern == "" {
+				continue
+			}
+		case RateLimit:
+			if rule.MaxPerSecond <= 0 {
+				return fmt.Errorf("invalid max_per_second %d for processing rule: %s, must be positive", rule.MaxPerSecond, rule.Name)
+			}
+			if rule.Pattern == "" {
+				continue
+			}
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
 		default:
@@ -61,15 +124,87 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 	return nil
 }
 
+// validateJSONFieldRules checks that a json_fields rule has fields, and that
+// each of them has a path and a supported action.
+func validateJSONFieldRules(rule *ProcessingRule) error {
+	if len(rule.Fields) == 0 {
+		return fmt.Errorf("no fields provided for processing rule: %s", rule.Name)
+	}
+	for _, field := range rule.Fields {
+		if !validJSONFieldPath(field.Path) {
+			return fmt.Errorf("invalid field path `%s` for processing rule: %s", field.Path, rule.Name)
+		}
+		switch field.Action {
+		case JSONFieldDrop, JSONFieldMask, JSONFieldStatus:
+		case JSONFieldRename:
+			if !validJSONFieldPath(field.Target) {
+				return fmt.Errorf("invalid target `%s` to rename field `%s` for processing rule: %s", field.Target, field.Path, rule.Name)
+			}
+		case JSONFieldTag:
+			if strings.ContainsAny(field.Target, ":,") {
+				return fmt.Errorf("invalid tag key `%s` for field `%s` for processing rule: %s", field.Target, field.Path, rule.Name)
+			}
+		case "":
+			return fmt.Errorf("action must be set for field `%s` of processing rule: %s", field.Path, rule.Name)
+		default:
+			return fmt.Errorf("action %s is not supported for field `%s` of processing rule: %s", field.Action, field.Path, rule.Name)
+		}
+	}
+	return nil
+}
+
+// validJSONFieldPath returns false if the path is empty or has an empty key.
+func validJSONFieldPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// compileJSONFieldRules splits the field paths and prepares the placeholders.
+func compileJSONFieldRules(rule *ProcessingRule) {
+	for _, field := range rule.Fields {
+		field.Keys = strings.Split(field.Path, ".")
+		switch field.Action {
+		case JSONFieldRename:
+			field.TargetKeys = strings.Split(field.Target, ".")
+		case JSONFieldMask:
+			field.Placeholder = field.ReplacePlaceholder
+			if field.Placeholder == "" {
+				field.Placeholder = defaultJSONFieldPlaceholder
+			}
+		case JSONFieldTag:
+			if field.Target == "" {
+				field.Target = field.Keys[len(field.Keys)-1]
+			}
+		}
+	}
+}
+
 // CompileProcessingRules compiles all processing rule regular expressions.
 func CompileProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
+		switch rule.Type {
+		case JSONFields:
+			compileJSONFieldRules(rule)
+			continue
+		case RateLimit:
+			rule.RateLimiter = NewRateLimiter(rule.MaxPerSecond)
+		}
+		if (rule.Type == Sample || rule.Type == RateLimit) && rule.Pattern == "" {
+			continue
+		}
 		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return err
 		}
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch:
+		case ExcludeAtMatch, IncludeAtMatch, Sample, RateLimit:
 			rule.Regex = re
 		case MaskSequences:
 			rule.Regex = re
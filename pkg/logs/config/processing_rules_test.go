@@ -34,3 +34,63 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Regex)
 	}
 }
+
+func TestValidateJSONFieldsRules(t *testing.T) {
+	valid := []*ProcessingRule{{
+		Type: JSONFields,
+		Name: "json",
+		Fields: []*JSONFieldRule{
+			{Path: "password", Action: JSONFieldDrop},
+			{Path: "usr.email", Action: JSONFieldMask},
+			{Path: "msg", Action: JSONFieldRename, Target: "message"},
+			{Path: "level", Action: JSONFieldStatus},
+			{Path: "ctx.trace_id", Action: JSONFieldTag},
+		},
+	}}
+	assert.NoError(t, ValidateProcessingRules(valid))
+	assert.NoError(t, CompileProcessingRules(valid))
+	assert.Equal(t, []string{"usr", "email"}, valid[0].Fields[1].Keys)
+	assert.Equal(t, defaultJSONFieldPlaceholder, valid[0].Fields[1].Placeholder)
+	assert.Equal(t, []string{"message"}, valid[0].Fields[2].TargetKeys)
+	assert.Equal(t, "trace_id", valid[0].Fields[4].Target)
+
+	invalidFields := []*JSONFieldRule{
+		{Path: "", Action: JSONFieldDrop},
+		{Path: "usr..email", Action: JSONFieldDrop},
+		{Path: "msg", Action: JSONFieldRename},
+		{Path: "msg", Action: JSONFieldTag, Target: "a:b"},
+		{Path: "msg"},
+		{Path: "msg", Action: "hash"},
+	}
+	for _, field := range invalidFields {
+		rules := []*ProcessingRule{{Type: JSONFields, Name: "json", Fields: []*JSONFieldRule{field}}}
+		assert.Error(t, ValidateProcessingRules(rules))
+	}
+	assert.Error(t, ValidateProcessingRules([]*ProcessingRule{{Type: JSONFields, Name: "json"}}))
+}
+
+func TestValidateSampleAndRateLimitRules(t *testing.T) {
+	valid := []*ProcessingRule{
+		{Type: Sample, Name: "sample", SampleRate: 0.1},
+		{Type: Sample, Name: "sample_debug", SampleRate: 1, Pattern: "DEBUG"},
+		{Type: RateLimit, Name: "rate_limit", MaxPerSecond: 10},
+		{Type: RateLimit, Name: "rate_limit_debug", MaxPerSecond: 10, Pattern: "DEBUG"},
+	}
+	assert.NoError(t, ValidateProcessingRules(valid))
+	assert.NoError(t, CompileProcessingRules(valid))
+	assert.Nil(t, valid[0].Regex)
+	assert.NotNil(t, valid[1].Regex)
+	assert.NotNil(t, valid[2].RateLimiter)
+	assert.NotNil(t, valid[3].Regex)
+
+	invalid := []*ProcessingRule{
+		{Type: Sample, Name: "sample"},
+		{Type: Sample, Name: "sample", SampleRate: 1.5},
+		{Type: Sample, Name: "sample", SampleRate: 0.5, Pattern: "(?=abf)"},
+		{Type: RateLimit, Name: "rate_limit"},
+		{Type: RateLimit, Name: "rate_limit", MaxPerSecond: -1},
+	}
+	for _, rule := range invalid {
+		assert.Error(t, ValidateProcessingRules([]*ProcessingRule{rule}))
+	}
+}
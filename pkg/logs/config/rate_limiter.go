@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"sync"
+
+	"github.com/benbjohnson/clock"
+)
+
+// RateLimiter counts the log lines of a rate_limit rule in one second windows,
+// separately for each key. It is shared by all the pipelines.
+type RateLimiter struct {
+	mu           sync.Mutex
+	maxPerSecond int
+	clock        clock.Clock
+	windows      map[string]*rateLimitWindow
+}
+
+type rateLimitWindow struct {
+	start   int64
+	count   int
+	dropped int64
+	// pending is the number of lines dropped in the previous windows, not reported yet
+	pending int64
+}
+
+// NewRateLimiter returns a RateLimiter allowing maxPerSecond lines per second.
+func NewRateLimiter(maxPerSecond int) *RateLimiter {
+	return NewRateLimiterWithClock(maxPerSecond, clock.New())
+}
+
+// NewRateLimiterWithClock returns a RateLimiter allowing maxPerSecond lines per
+// second of the given clock.
+func NewRateLimiterWithClock(maxPerSecond int, clock clock.Clock) *RateLimiter {
+	return &RateLimiter{
+		maxPerSecond: maxPerSecond,
+		clock:        clock,
+		windows:      make(map[string]*rateLimitWindow),
+	}
+}
+
+// Allow returns true if a line for the given key is within the limit.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	second := l.clock.Now().Unix()
+	window, found := l.windows[key]
+	if !found {
+		window = &rateLimitWindow{start: second}
+		l.windows[key] = window
+	}
+
+	if window.start != second {
+		window.pending += window.dropped
+		window.start = second
+		window.count = 0
+		window.dropped = 0
+	}
+
+	if window.count >= l.maxPerSecond {
+		window.dropped++
+		return false
+	}
+	window.count++
+	return true
+}
+
+// Dropped returns the number of lines of the given key dropped in the windows
+// that ended since the last call, so that they are reported once. It also
+// returns true when the current window of the key ended, the key being
+// forgotten until its next line.
+func (l *RateLimiter) Dropped(key string) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	window, found := l.windows[key]
+	if !found {
+		return 0, true
+	}
+	if window.start != l.clock.Now().Unix() {
+		delete(l.windows, key)
+		return window.pending + window.dropped, true
+	}
+	dropped := window.pending
+	window.pending = 0
+	return dropped, false
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clk := clock.NewMock()
+	limiter := NewRateLimiterWithClock(2, clk)
+
+	assertDropped := func(key string, expectedDropped int64, expectedEnded bool) {
+		t.Helper()
+		dropped, ended := limiter.Dropped(key)
+		assert.Equal(t, expectedDropped, dropped)
+		assert.Equal(t, expectedEnded, ended)
+	}
+
+	assert.True(t, limiter.Allow("foo"))
+	clk.Add(100 * time.Millisecond)
+	assert.True(t, limiter.Allow("foo"))
+	assert.False(t, limiter.Allow("foo"))
+	assert.False(t, limiter.Allow("foo"))
+	// keys are limited separately
+	assert.True(t, limiter.Allow("bar"))
+
+	// the dropped lines are reported once the window ended
+	assertDropped("foo", 0, false)
+	clk.Add(time.Second)
+	assertDropped("foo", 2, true)
+	assertDropped("foo", 0, true)
+	assertDropped("bar", 0, true)
+
+	// the lines dropped in a window are kept when the next one starts
+	assert.True(t, limiter.Allow("foo"))
+	assert.True(t, limiter.Allow("foo"))
+	assert.False(t, limiter.Allow("foo"))
+	clk.Add(time.Second)
+	assert.True(t, limiter.Allow("foo"))
+	assertDropped("foo", 1, false)
+	assertDropped("foo", 0, false)
+	clk.Add(10 * time.Second)
+	assertDropped("foo", 0, true)
+}
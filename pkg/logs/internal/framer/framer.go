@@ -38,6 +38,11 @@ const (
 	// headers are included in the log frame.  The size in those headers is not
 	// consulted.  The result does not include the trailing newlines.
 	DockerStream
+
+	// Syslog messages, either octet-counted (`MSG-LEN SP SYSLOG-MSG`) or
+	// newline-terminated, as described in RFC 6587.  The framing is detected
+	// for each message.
+	SyslogStream
 )
 
 // Framer gets chunks of bytes (via Process(..)) and uses an
@@ -95,6 +100,8 @@ func NewFramer(
 		matcher = &oneByteNewLineMatcher{contentLenLimit}
 	case DockerStream:
 		matcher = &dockerStreamMatcher{contentLenLimit}
+	case SyslogStream:
+		matcher = &syslogMatcher{contentLenLimit: contentLenLimit}
 	default:
 		panic(fmt.Sprintf("unknown framing %d", framing))
 	}
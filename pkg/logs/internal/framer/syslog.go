@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package framer
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// maxSyslogMsgLenDigits is the maximum number of digits of the length of an
+// octet-counted frame, longer numbers are not considered as a frame length.
+const maxSyslogMsgLenDigits = 9
+
+// syslogMatcher matches syslog messages framed either with octet counting or
+// with trailing newlines, as described in RFC 6587.  The framing is detected
+// for each frame: a frame starting with a length followed by a space is
+// octet-counted (`MSG-LEN SP SYSLOG-MSG`), anything else ends with a newline.
+type syslogMatcher struct {
+	// contentLenLimit is the maximum content length that will be returned.
+	// Octet-counted frames longer than this value are truncated, the
+	// remainder of the frame is dropped.
+	contentLenLimit int
+
+	// discard is the number of bytes remaining from a truncated frame.
+	discard int
+}
+
+// FindFrame implements EndLineMatcher#FindFrame.
+func (s *syslogMatcher) FindFrame(buf []byte, seen int) ([]byte, int) {
+	if s.discard > 0 {
+		n := s.discard
+		if n > len(buf) {
+			n = len(buf)
+		}
+		s.discard -= n
+		return buf[:0], n
+	}
+
+	digits := 0
+	for digits < len(buf) && digits <= maxSyslogMsgLenDigits && buf[digits] >= '0' && buf[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits <= maxSyslogMsgLenDigits && buf[0] != '0' {
+		if digits == len(buf) {
+			// wait for the rest of the frame header
+			return nil, 0
+		}
+		if buf[digits] == ' ' {
+			return s.findOctetCountedFrame(buf, digits)
+		}
+	}
+
+	nl := bytes.IndexByte(buf[seen:], '\n')
+	if nl == -1 {
+		return nil, 0
+	}
+
+	// limit the returned line to contentLenLimit bytes
+	eol := nl + seen
+	if eol > s.contentLenLimit {
+		return buf[:s.contentLenLimit], s.contentLenLimit
+	}
+	return buf[:eol], eol + 1
+}
+
+// findOctetCountedFrame returns the content of a frame whose header, the
+// length followed by a space, is digits+1 bytes long.
+func (s *syslogMatcher) findOctetCountedFrame(buf []byte, digits int) ([]byte, int) {
+	msgLen, _ := strconv.Atoi(string(buf[:digits]))
+	start := digits + 1
+
+	// the whole frame, header included, must fit in contentLenLimit, otherwise
+	// the framer would break it before it is complete
+	if maxLen := s.contentLenLimit - start; msgLen > maxLen {
+		if len(buf) < s.contentLenLimit {
+			return nil, 0
+		}
+		s.discard = msgLen - maxLen
+		return buf[start:s.contentLenLimit], s.contentLenLimit
+	}
+
+	if len(buf) < start+msgLen {
+		return nil, 0
+	}
+	return buf[start : start+msgLen], start + msgLen
+}
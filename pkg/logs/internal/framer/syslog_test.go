@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package framer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func syslogFramerOutput(limit int) (*Framer, *[]string, *[]int) {
+	gotContent := []string{}
+	gotLens := []int{}
+	outputFn := func(content []byte, rawDataLen int) {
+		gotContent = append(gotContent, string(content))
+		gotLens = append(gotLens, rawDataLen)
+	}
+	return NewFramer(outputFn, SyslogStream, limit), &gotContent, &gotLens
+}
+
+func TestSyslogOctetCounting(t *testing.T) {
+	input := []byte("11 <13>1 - - x12 <13>1 - - \ny<13>1 - - z\n")
+
+	for _, size := range []int{len(input), 1, 5} {
+		fr, gotContent, gotLens := syslogFramerOutput(256000)
+		for i := 0; i < len(input); i += size {
+			end := i + size
+			if end > len(input) {
+				end = len(input)
+			}
+			fr.Process(input[i:end])
+		}
+		assert.Equal(t, []string{"<13>1 - - x", "<13>1 - - \ny", "<13>1 - - z"}, *gotContent, "chunk size %d", size)
+		assert.Equal(t, []int{14, 15, 12}, *gotLens, "chunk size %d", size)
+	}
+}
+
+func TestSyslogNonTransparentFraming(t *testing.T) {
+	fr, gotContent, gotLens := syslogFramerOutput(256000)
+	fr.Process([]byte("<13>Oct 11 22:14:15 host app: one\n<13>Oct 11 22:14:15 host app: two\n2021-10-11 not a length\n"))
+	assert.Equal(t, []string{
+		"<13>Oct 11 22:14:15 host app: one",
+		"<13>Oct 11 22:14:15 host app: two",
+		"2021-10-11 not a length",
+	}, *gotContent)
+	assert.Equal(t, []int{34, 34, 24}, *gotLens)
+}
+
+func TestSyslogOctetCountingTruncatesLongFrames(t *testing.T) {
+	fr, gotContent, gotLens := syslogFramerOutput(10)
+	// the frame is 20 bytes long, only 7 fit in the limit with the 3 bytes header
+	fr.Process([]byte("20 " + strings.Repeat("a", 20) + "4 abcd"))
+	assert.Equal(t, []string{"aaaaaaa", "", "abcd"}, *gotContent)
+	assert.Equal(t, []int{10, 13, 6}, *gotLens)
+}
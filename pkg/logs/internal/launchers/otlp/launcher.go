@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/launchers"
+	tailer "github.com/DataDog/datadog-agent/pkg/logs/internal/tailers/otlp"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/startstop"
+)
+
+// Launcher starts an OTLP receiver for each otlp source.
+type Launcher struct {
+	pipelineProvider pipeline.Provider
+	sources          chan *sources.LogSource
+	tailers          []*tailer.Tailer
+	stop             chan struct{}
+}
+
+// NewLauncher returns an initialized Launcher
+func NewLauncher() *Launcher {
+	return &Launcher{
+		stop: make(chan struct{}),
+	}
+}
+
+// Start starts the launcher.
+func (l *Launcher) Start(sourceProvider launchers.SourceProvider, pipelineProvider pipeline.Provider, registry auditor.Registry) {
+	l.pipelineProvider = pipelineProvider
+	l.sources = sourceProvider.GetAddedForType(config.OTLPType)
+	go l.run()
+}
+
+// run starts new OTLP receivers.
+func (l *Launcher) run() {
+	for {
+		select {
+		case source := <-l.sources:
+			l.startTailer(source)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// startTailer starts the OTLP receiver of a source.
+func (l *Launcher) startTailer(source *sources.LogSource) {
+	log.Infof("Starting OTLP logs receiver with gRPC port: %d, HTTP port: %d", source.Config.Port, source.Config.HTTPPort)
+	t := tailer.NewTailer(source, l.pipelineProvider.NextPipelineChan())
+	if err := t.Start(); err != nil {
+		log.Errorf("Can't start OTLP logs receiver: %v", err)
+		source.Status.Error(err)
+		return
+	}
+	source.Status.Success()
+	l.tailers = append(l.tailers, t)
+}
+
+// Stop stops all the OTLP receivers
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+	stopper := startstop.NewParallelStopper()
+	for _, t := range l.tailers {
+		stopper.Add(t)
+	}
+	stopper.Stop()
+}
@@ -58,6 +58,11 @@ var (
 		nil, "Histogram of http sender latency in ms", []float64{10, 25, 50, 75, 100, 250, 500, 1000, 10000})
 	// DestinationExpVars a map of sender utilization metrics for each http destination
 	DestinationExpVars = expvar.Map{}
+	// ProcessingRulesDropped is the total number of logs dropped per source and sample or rate_limit processing rule
+	ProcessingRulesDropped = expvar.Map{}
+	// TlmProcessingRulesDropped is the total number of logs dropped per source and sample or rate_limit processing rule
+	TlmProcessingRulesDropped = telemetry.NewCounter("logs", "processing_rules_dropped",
+		[]string{"rule_type", "rule_name", "source"}, "Total number of logs dropped per source and sample or rate_limit processing rule")
 	// TODO: Add LogsCollected for the total number of collected logs.
 
 )
@@ -73,4 +78,5 @@ func init() {
 	LogsExpvars.Set("EncodedBytesSent", &EncodedBytesSent)
 	LogsExpvars.Set("SenderLatency", &SenderLatency)
 	LogsExpvars.Set("HttpDestinationStats", &DestinationExpVars)
+	LogsExpvars.Set("ProcessingRulesDropped", &ProcessingRulesDropped)
 }
@@ -0,0 +1,357 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package syslog implements a parser for syslog messages, following RFC 5424
+// or RFC 3164.
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// nilValue is used by RFC 5424 for the header fields without value
+const nilValue = "-"
+
+// rfc3164TimestampLen is the length of a `Mmm dd hh:mm:ss` timestamp
+const rfc3164TimestampLen = len(time.Stamp)
+
+// utf8BOM may start the MSG part of an RFC 5424 message
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// severityStatusMapping maps the syslog severities to the message statuses.
+var severityStatusMapping = [8]string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// New creates a new parser that parses syslog messages.
+//
+// The messages are parsed following RFC 5424 when the version of the protocol
+// follows the priority, and following RFC 3164 otherwise. For example:
+//
+//	<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event log entry
+//	<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8
+//
+// The content of the parsed message is a JSON object, in which the MSG part
+// is the "message" attribute and the header fields are bundled in a "syslog"
+// attribute. The status is set from the severity and the timestamp from the
+// header, when it has one.
+func New() parsers.Parser {
+	return &syslogFormat{now: time.Now}
+}
+
+type syslogFormat struct {
+	// now returns the current time, used to guess the year of the RFC 3164
+	// timestamps
+	now func() time.Time
+}
+
+// Parse implements Parser#Parse
+func (p *syslogFormat) Parse(msg []byte) (parsers.Message, error) {
+	msg = bytes.TrimRight(msg, "\r\n")
+
+	header, content, err := p.parse(msg)
+	if err != nil {
+		return parsers.Message{Content: msg, Status: message.StatusInfo}, err
+	}
+
+	encoded, err := json.Marshal(syslogPayload{Message: string(content), Syslog: header.attributes})
+	if err != nil {
+		return parsers.Message{Content: msg, Status: message.StatusInfo}, err
+	}
+
+	var timestamp string
+	if !header.timestamp.IsZero() {
+		timestamp = header.timestamp.UTC().Format(config.DateFormat)
+	}
+	return parsers.Message{
+		Content:   encoded,
+		Status:    severityStatusMapping[header.attributes.Severity],
+		Timestamp: timestamp,
+	}, nil
+}
+
+// SupportsPartialLine implements Parser#SupportsPartialLine
+func (p *syslogFormat) SupportsPartialLine() bool {
+	return false
+}
+
+// syslogPayload is the content of a parsed message
+type syslogPayload struct {
+	Message string           `json:"message"`
+	Syslog  syslogAttributes `json:"syslog"`
+}
+
+// syslogAttributes are the header fields of a message
+type syslogAttributes struct {
+	Facility       int                          `json:"facility"`
+	Severity       int                          `json:"severity"`
+	Version        int                          `json:"version,omitempty"`
+	Hostname       string                       `json:"hostname,omitempty"`
+	AppName        string                       `json:"appname,omitempty"`
+	ProcID         string                       `json:"procid,omitempty"`
+	MsgID          string                       `json:"msgid,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+}
+
+type syslogHeader struct {
+	attributes syslogAttributes
+	timestamp  time.Time
+}
+
+// parse returns the header and the MSG part of a message.
+func (p *syslogFormat) parse(msg []byte) (syslogHeader, []byte, error) {
+	var header syslogHeader
+
+	priority, rest, err := parsePriority(msg)
+	if err != nil {
+		return header, nil, err
+	}
+	header.attributes.Facility = priority / 8
+	header.attributes.Severity = priority % 8
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		header.attributes.Version = 1
+		rest, err = parseRFC5424(rest[2:], &header)
+	} else {
+		rest = p.parseRFC3164(rest, &header)
+	}
+	return header, rest, err
+}
+
+// parsePriority parses the `<PRI>` part starting a message.
+func parsePriority(msg []byte) (int, []byte, error) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, nil, errors.New("cannot parse the syslog message: missing priority")
+	}
+	end := bytes.IndexByte(msg[:min(len(msg), 5)], '>')
+	if end < 2 {
+		return 0, nil, errors.New("cannot parse the syslog message: invalid priority")
+	}
+	priority, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || priority > 191 {
+		return 0, nil, errors.New("cannot parse the syslog message: invalid priority")
+	}
+	return priority, msg[end+1:], nil
+}
+
+// parseRFC5424 parses the header fields following the version of an RFC 5424
+// message: `TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]`.
+func parseRFC5424(msg []byte, header *syslogHeader) ([]byte, error) {
+	var fields [5]string
+	for i := range fields {
+		var field []byte
+		field, msg = nextField(msg)
+		if field == nil {
+			return nil, errors.New("cannot parse the syslog message: truncated header")
+		}
+		if string(field) != nilValue {
+			fields[i] = string(field)
+		}
+	}
+
+	if fields[0] != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return nil, errors.New("cannot parse the syslog message: invalid timestamp")
+		}
+		header.timestamp = timestamp
+	}
+	header.attributes.Hostname = fields[1]
+	header.attributes.AppName = fields[2]
+	header.attributes.ProcID = fields[3]
+	header.attributes.MsgID = fields[4]
+
+	if len(msg) == 0 {
+		return nil, errors.New("cannot parse the syslog message: missing structured data")
+	}
+	if msg[0] == '-' {
+		msg = msg[1:]
+	} else {
+		structuredData, rest, err := parseStructuredData(msg)
+		if err != nil {
+			return nil, err
+		}
+		header.attributes.StructuredData = structuredData
+		msg = rest
+	}
+
+	if len(msg) > 0 {
+		if msg[0] != ' ' {
+			return nil, errors.New("cannot parse the syslog message: invalid structured data")
+		}
+		msg = msg[1:]
+	}
+	return bytes.TrimPrefix(msg, utf8BOM), nil
+}
+
+// parseStructuredData parses the `[SD-ID SD-PARAM*]+` structured data of an
+// RFC 5424 message, and returns the remaining of the message.
+func parseStructuredData(msg []byte) (map[string]map[string]string, []byte, error) {
+	invalid := errors.New("cannot parse the syslog message: invalid structured data")
+	structuredData := make(map[string]map[string]string)
+
+	for len(msg) > 0 && msg[0] == '[' {
+		end := bytes.IndexAny(msg, " ]")
+		if end <= 1 {
+			return nil, nil, invalid
+		}
+		params := make(map[string]string)
+		structuredData[string(msg[1:end])] = params
+		msg = msg[end:]
+
+		for {
+			if len(msg) == 0 {
+				return nil, nil, invalid
+			}
+			if msg[0] == ']' {
+				msg = msg[1:]
+				break
+			}
+			if msg[0] != ' ' {
+				return nil, nil, invalid
+			}
+			msg = msg[1:]
+
+			eq := bytes.IndexByte(msg, '=')
+			if eq <= 0 || eq+1 >= len(msg) || msg[eq+1] != '"' {
+				return nil, nil, invalid
+			}
+			name := string(msg[:eq])
+			msg = msg[eq+2:]
+
+			var value []byte
+			i := 0
+			for ; i < len(msg) && msg[i] != '"'; i++ {
+				// only '"', '\' and ']' are escaped, a backslash followed by
+				// another character is kept as is
+				if msg[i] == '\\' && i+1 < len(msg) && (msg[i+1] == '"' || msg[i+1] == '\\' || msg[i+1] == ']') {
+					i++
+				}
+				value = append(value, msg[i])
+			}
+			if i == len(msg) {
+				return nil, nil, invalid
+			}
+			params[name] = string(value)
+			msg = msg[i+1:]
+		}
+	}
+	return structuredData, msg, nil
+}
+
+// parseRFC3164 parses the header following the priority of an RFC 3164
+// message: `TIMESTAMP HOSTNAME TAG[PID]: MSG`. As the format is loosely
+// followed, every part of the header is optional, the hostname is considered
+// missing when the first field looks like a tag, and a message with an
+// unexpected header is kept whole.
+func (p *syslogFormat) parseRFC3164(msg []byte, header *syslogHeader) []byte {
+	if timestamp, rest, ok := p.parseRFC3164Timestamp(msg); ok {
+		header.timestamp = timestamp
+		msg = rest
+	} else {
+		return msg
+	}
+
+	field, rest := nextField(msg)
+	if field == nil {
+		return msg
+	}
+	if appName, procID, ok := parseTag(field); ok {
+		header.attributes.AppName = appName
+		header.attributes.ProcID = procID
+		return rest
+	}
+	header.attributes.Hostname = string(field)
+	msg = rest
+
+	if field, rest = nextField(msg); field != nil {
+		if appName, procID, ok := parseTag(field); ok {
+			header.attributes.AppName = appName
+			header.attributes.ProcID = procID
+			return rest
+		}
+	}
+	return msg
+}
+
+// parseRFC3164Timestamp parses the `Mmm dd hh:mm:ss` timestamp of an RFC 3164
+// message, or the RFC 3339 timestamp used instead by some senders. The former
+// has no year nor timezone, the local time of the agent and the year which
+// makes it closest to the current time are used.
+func (p *syslogFormat) parseRFC3164Timestamp(msg []byte) (time.Time, []byte, bool) {
+	if len(msg) > rfc3164TimestampLen && msg[rfc3164TimestampLen] == ' ' {
+		if timestamp, err := time.ParseInLocation(time.Stamp, string(msg[:rfc3164TimestampLen]), time.Local); err == nil {
+			now := p.now()
+			timestamp = timestamp.AddDate(now.Year(), 0, 0)
+			// a message sent by the end of December received in January
+			if timestamp.After(now.AddDate(0, 0, 1)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+			return timestamp, msg[rfc3164TimestampLen+1:], true
+		}
+	}
+
+	field, rest := nextField(msg)
+	if field != nil {
+		if timestamp, err := time.Parse(time.RFC3339Nano, string(field)); err == nil {
+			return timestamp, rest, true
+		}
+	}
+	return time.Time{}, msg, false
+}
+
+// parseTag parses a `TAG[PID]:` or `TAG:` field.
+func parseTag(field []byte) (string, string, bool) {
+	if len(field) < 2 || field[len(field)-1] != ':' {
+		return "", "", false
+	}
+	field = field[:len(field)-1]
+
+	if field[len(field)-1] == ']' {
+		if start := bytes.IndexByte(field, '['); start > 0 {
+			return string(field[:start]), string(field[start+1 : len(field)-1]), true
+		}
+		return "", "", false
+	}
+	if bytes.IndexAny(field, "[]") >= 0 {
+		return "", "", false
+	}
+	return string(field), "", true
+}
+
+// nextField returns the field at the beginning of msg, terminated by a space
+// or the end of msg, and the remaining of msg after the space. It returns a
+// nil field if msg is empty or starts with a space.
+func nextField(msg []byte) ([]byte, []byte) {
+	if len(msg) == 0 || msg[0] == ' ' {
+		return nil, msg
+	}
+	if end := bytes.IndexByte(msg, ' '); end >= 0 {
+		return msg[:end], msg[end+1:]
+	}
+	return msg, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
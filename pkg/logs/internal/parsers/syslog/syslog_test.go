@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func newTestParser(now time.Time) *syslogFormat {
+	return &syslogFormat{now: func() time.Time { return now }}
+}
+
+func TestSyslogParserRFC5424(t *testing.T) {
+	msg, err := New().Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication\]"][examplePriority@32473 class="high"] ` + "\xEF\xBB\xBF" + `An application event log entry`))
+	require.NoError(t, err)
+	assert.Equal(t, message.StatusNotice, msg.Status)
+	assert.Equal(t, "2003-10-11T22:14:15.003000000Z", msg.Timestamp)
+	assert.JSONEq(t, `{
+		"message": "An application event log entry",
+		"syslog": {
+			"facility": 20,
+			"severity": 5,
+			"version": 1,
+			"hostname": "mymachine.example.com",
+			"appname": "evntslog",
+			"procid": "1234",
+			"msgid": "ID47",
+			"structured_data": {
+				"exampleSDID@32473": {"iut": "3", "eventSource": "App\"lication]"},
+				"examplePriority@32473": {"class": "high"}
+			}
+		}
+	}`, string(msg.Content))
+}
+
+func TestSyslogParserRFC5424NilValues(t *testing.T) {
+	msg, err := New().Parse([]byte("<11>1 - - - - - -\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, message.StatusError, msg.Status)
+	assert.Equal(t, "", msg.Timestamp)
+	assert.JSONEq(t, `{"message": "", "syslog": {"facility": 1, "severity": 3, "version": 1}}`, string(msg.Content))
+
+	msg, err = New().Parse([]byte("<14>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts."))
+	require.NoError(t, err)
+	assert.Equal(t, message.StatusInfo, msg.Status)
+	assert.Equal(t, "2003-08-24T12:14:15.000003000Z", msg.Timestamp)
+	assert.JSONEq(t, `{
+		"message": "%% It's time to make the do-nuts.",
+		"syslog": {"facility": 1, "severity": 6, "version": 1, "hostname": "192.0.2.1", "appname": "myproc", "procid": "8710"}
+	}`, string(msg.Content))
+}
+
+func TestSyslogParserRFC3164(t *testing.T) {
+	now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name      string
+		input     string
+		status    string
+		timestamp time.Time
+		content   string
+	}{
+		{
+			name:      "full header",
+			input:     "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8",
+			status:    message.StatusCritical,
+			timestamp: time.Date(2021, time.October, 11, 22, 14, 15, 0, time.Local),
+			content:   `{"message":"'su root' failed for lonvick on /dev/pts/8","syslog":{"facility":4,"severity":2,"hostname":"mymachine","appname":"su","procid":"123"}}`,
+		},
+		{
+			name:      "no hostname",
+			input:     "<13>Feb  5 17:32:18 sshd: Accepted publickey",
+			status:    message.StatusNotice,
+			timestamp: time.Date(2022, time.February, 5, 17, 32, 18, 0, time.Local),
+			content:   `{"message":"Accepted publickey","syslog":{"facility":1,"severity":5,"appname":"sshd"}}`,
+		},
+		{
+			name:      "no tag",
+			input:     "<15>Mar  1 11:00:00 host something happened",
+			status:    message.StatusDebug,
+			timestamp: time.Date(2022, time.March, 1, 11, 0, 0, 0, time.Local),
+			content:   `{"message":"something happened","syslog":{"facility":1,"severity":7,"hostname":"host"}}`,
+		},
+		{
+			name:      "RFC 3339 timestamp",
+			input:     "<12>2022-02-28T10:00:00.5+01:00 host app[1]: hello",
+			status:    message.StatusWarning,
+			timestamp: time.Date(2022, time.February, 28, 9, 0, 0, 500000000, time.UTC),
+			content:   `{"message":"hello","syslog":{"facility":1,"severity":4,"hostname":"host","appname":"app","procid":"1"}}`,
+		},
+		{
+			name:    "no timestamp",
+			input:   "<8>just a message",
+			status:  message.StatusEmergency,
+			content: `{"message":"just a message","syslog":{"facility":1,"severity":0}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := newTestParser(now).Parse([]byte(test.input))
+			require.NoError(t, err)
+			assert.Equal(t, test.status, msg.Status)
+			if test.timestamp.IsZero() {
+				assert.Equal(t, "", msg.Timestamp)
+			} else {
+				assert.Equal(t, test.timestamp.UTC().Format("2006-01-02T15:04:05.000000000Z"), msg.Timestamp)
+			}
+			assert.JSONEq(t, test.content, string(msg.Content))
+		})
+	}
+}
+
+func TestSyslogParserShouldFailWithInvalidInput(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"no priority",
+		"<>1 - - - - - -",
+		"<192>1 - - - - - -",
+		"<abc>message",
+		"<13>1 - - -",
+		"<13>1 yesterday - - - - -",
+		"<13>1 - - - - - [unterminated",
+		"<13>1 - - - - - [id key=unquoted]",
+		"<13>1 - - - - -message",
+	} {
+		msg, err := New().Parse([]byte(input))
+		assert.Error(t, err, input)
+		assert.Equal(t, input, string(msg.Content))
+		assert.Equal(t, message.StatusInfo, msg.Status)
+		assert.Equal(t, "", msg.Timestamp)
+	}
+}
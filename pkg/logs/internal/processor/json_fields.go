@@ -0,0 +1,144 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// statusAliases maps the usual log level names to the message statuses.
+var statusAliases = map[string]string{
+	"emerg":       message.StatusEmergency,
+	"emergency":   message.StatusEmergency,
+	"panic":       message.StatusEmergency,
+	"alert":       message.StatusAlert,
+	"crit":        message.StatusCritical,
+	"critical":    message.StatusCritical,
+	"fatal":       message.StatusCritical,
+	"err":         message.StatusError,
+	"error":       message.StatusError,
+	"warn":        message.StatusWarning,
+	"warning":     message.StatusWarning,
+	"notice":      message.StatusNotice,
+	"info":        message.StatusInfo,
+	"information": message.StatusInfo,
+	"debug":       message.StatusDebug,
+	"trace":       message.StatusDebug,
+}
+
+// applyJSONFieldRules applies the field rules of a json_fields rule on content
+// and returns the new content. The content is returned as is if it's not a JSON
+// object. Otherwise it is encoded again, with its keys sorted.
+func applyJSONFieldRules(msg *message.Message, content []byte, rule *config.ProcessingRule) []byte {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return content
+	}
+
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	// keep the numbers as they were written
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil || decoder.More() {
+		return content
+	}
+
+	modified := false
+	for _, field := range rule.Fields {
+		parent, key, found := lookupJSONField(object, field.Keys)
+		if !found {
+			continue
+		}
+		value := parent[key]
+
+		switch field.Action {
+		case config.JSONFieldDrop:
+			delete(parent, key)
+		case config.JSONFieldRename:
+			delete(parent, key)
+			setJSONField(object, field.TargetKeys, value)
+		case config.JSONFieldMask:
+			parent[key] = field.Placeholder
+		case config.JSONFieldStatus:
+			if level, ok := value.(string); ok {
+				if status, ok := statusAliases[strings.ToLower(level)]; ok {
+					msg.SetStatus(status)
+				}
+			}
+			// the field is kept, only the status is updated
+			continue
+		case config.JSONFieldTag:
+			delete(parent, key)
+			if tagValue, ok := jsonFieldTagValue(value); ok {
+				msg.Origin.AddTags(field.Target + ":" + tagValue)
+			}
+		}
+		modified = true
+	}
+
+	if !modified {
+		return content
+	}
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(object); err != nil {
+		return content
+	}
+	return bytes.TrimSuffix(encoded.Bytes(), []byte("\n"))
+}
+
+// lookupJSONField returns the object holding the field at the given path, and
+// the key of the field in this object.
+func lookupJSONField(object map[string]interface{}, keys []string) (map[string]interface{}, string, bool) {
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			return nil, "", false
+		}
+		object = child
+	}
+	key := keys[len(keys)-1]
+	_, found := object[key]
+	return object, key, found
+}
+
+// setJSONField sets the value of the field at the given path, creating the
+// intermediate objects as needed. Existing values on the path which are not
+// objects are replaced.
+func setJSONField(object map[string]interface{}, keys []string, value interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			object[key] = child
+		}
+		object = child
+	}
+	object[keys[len(keys)-1]] = value
+}
+
+// jsonFieldTagValue returns the value of a field as a tag value. Objects,
+// arrays and null values can't be used as tag values.
+func jsonFieldTagValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return strings.ReplaceAll(v, ",", "_"), v != ""
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newJSONFieldsSource(t *testing.T, fields ...*config.JSONFieldRule) sources.LogSource {
+	rules := []*config.ProcessingRule{{Type: config.JSONFields, Name: "test", Fields: fields}}
+	require.NoError(t, config.ValidateProcessingRules(rules))
+	require.NoError(t, config.CompileProcessingRules(rules))
+	return sources.LogSource{Config: &config.LogsConfig{ProcessingRules: rules}}
+}
+
+func TestJSONFields(t *testing.T) {
+	p := &Processor{}
+
+	source := newJSONFieldsSource(t,
+		&config.JSONFieldRule{Path: "password", Action: config.JSONFieldDrop},
+		&config.JSONFieldRule{Path: "usr.email", Action: config.JSONFieldMask},
+		&config.JSONFieldRule{Path: "usr.token", Action: config.JSONFieldMask, ReplacePlaceholder: "<token>"},
+		&config.JSONFieldRule{Path: "msg", Action: config.JSONFieldRename, Target: "message"},
+		&config.JSONFieldRule{Path: "lvl", Action: config.JSONFieldStatus},
+		&config.JSONFieldRule{Path: "trace_id", Action: config.JSONFieldTag},
+		&config.JSONFieldRule{Path: "ctx.code", Action: config.JSONFieldTag, Target: "status_code"},
+	)
+
+	msg := newMessage([]byte(`{"msg":"a <b>","lvl":"WARNING","password":"secret","trace_id":"123","ctx":{"code":404,"id":1.50},"usr":{"email":"foo@bar.com","token":"abc"}}`), &source, "")
+	shouldProcess, redactedMessage := p.applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	assert.Equal(t, `{"ctx":{"id":1.50},"lvl":"WARNING","message":"a <b>","usr":{"email":"[masked]","token":"<token>"}}`, string(redactedMessage))
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+	assert.Equal(t, []string{"trace_id:123", "status_code:404"}, msg.Origin.Tags())
+
+	// missing fields are ignored
+	msg = newMessage([]byte(`{"lvl":"unknown","usr":"foo"}`), &source, message.StatusError)
+	shouldProcess, redactedMessage = p.applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	assert.Equal(t, `{"lvl":"unknown","usr":"foo"}`, string(redactedMessage))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Empty(t, msg.Origin.Tags())
+
+	// other lines are left untouched
+	for _, content := range []string{`password=secret`, `{"password":"secret"`, `["password"]`, `{"password":"secret"} {}`} {
+		shouldProcess, redactedMessage = p.applyRedactingRules(newMessage([]byte(content), &source, ""))
+		assert.True(t, shouldProcess)
+		assert.Equal(t, content, string(redactedMessage))
+	}
+}
+
+func TestJSONFieldsRenameNested(t *testing.T) {
+	p := &Processor{}
+
+	source := newJSONFieldsSource(t,
+		&config.JSONFieldRule{Path: "user_id", Action: config.JSONFieldRename, Target: "usr.id"},
+	)
+
+	_, redactedMessage := p.applyRedactingRules(newMessage([]byte(`{"user_id":42,"usr":{"name":"foo"}}`), &source, ""))
+	assert.Equal(t, `{"usr":{"id":42,"name":"foo"}}`, string(redactedMessage))
+
+	_, redactedMessage = p.applyRedactingRules(newMessage([]byte(`{"user_id":42}`), &source, ""))
+	assert.Equal(t, `{"usr":{"id":42}}`, string(redactedMessage))
+}
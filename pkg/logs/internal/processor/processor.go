@@ -7,7 +7,10 @@ package processor
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
@@ -17,6 +20,9 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 )
 
+// rateLimitReportPeriod is the period of the reports of the lines dropped by the rate_limit rules
+const rateLimitReportPeriod = time.Second
+
 // A Processor updates messages from an inputChan and pushes
 // in an outputChan.
 type Processor struct {
@@ -27,6 +33,20 @@ type Processor struct {
 	done                      chan struct{}
 	diagnosticMessageReceiver diagnostic.MessageReceiver
 	mu                        sync.Mutex
+
+	// rateLimited are the sources with lines dropped by a rate_limit rule, not reported yet
+	rateLimited   map[rateLimitKey]*rateLimitSummary
+	rateLimitedMu sync.Mutex
+}
+
+type rateLimitKey struct {
+	rule   *config.ProcessingRule
+	source string
+}
+
+type rateLimitSummary struct {
+	origin  *message.Origin
+	dropped int64
 }
 
 // New returns an initialized Processor.
@@ -38,6 +58,7 @@ func New(inputChan, outputChan chan *message.Message, processingRules []*config.
 		encoder:                   encoder,
 		done:                      make(chan struct{}),
 		diagnosticMessageReceiver: diagnosticMessageReceiver,
+		rateLimited:               make(map[rateLimitKey]*rateLimitSummary),
 	}
 }
 
@@ -76,11 +97,21 @@ func (p *Processor) run() {
 	defer func() {
 		p.done <- struct{}{}
 	}()
-	for msg := range p.inputChan {
-		p.processMessage(msg)
-		p.mu.Lock() // block here if we're trying to flush synchronously
-		//nolint:staticcheck
-		p.mu.Unlock()
+	ticker := time.NewTicker(rateLimitReportPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-p.inputChan:
+			if !ok {
+				return
+			}
+			p.processMessage(msg)
+			p.mu.Lock() // block here if we're trying to flush synchronously
+			//nolint:staticcheck
+			p.mu.Unlock()
+		case <-ticker.C:
+			p.sendRateLimitSummaries()
+		}
 	}
 }
 
@@ -121,7 +152,88 @@ func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
 			}
 		case config.MaskSequences:
 			content = rule.Regex.ReplaceAll(content, rule.Placeholder)
+		case config.JSONFields:
+			content = applyJSONFieldRules(msg, content, rule)
+		case config.Sample:
+			if rule.Regex != nil && !rule.Regex.Match(content) {
+				continue
+			}
+			if rand.Float64() >= rule.SampleRate {
+				dropByRule(msg, rule)
+				return false, nil
+			}
+		case config.RateLimit:
+			if rule.Regex != nil && !rule.Regex.Match(content) {
+				continue
+			}
+			if !rule.RateLimiter.Allow(msg.Origin.LogSource.Name) {
+				p.trackRateLimited(msg, rule)
+				dropByRule(msg, rule)
+				return false, nil
+			}
 		}
 	}
 	return true, content
 }
+
+// dropByRule counts a message dropped by a sample or rate_limit rule.
+func dropByRule(msg *message.Message, rule *config.ProcessingRule) {
+	// rules of different sources may have the same name
+	source := msg.Origin.LogSource.Name
+	metrics.ProcessingRulesDropped.Add(source+"/"+rule.Name, 1)
+	metrics.TlmProcessingRulesDropped.Inc(rule.Type, rule.Name, source)
+}
+
+// trackRateLimited remembers the origin of a message dropped by a rate_limit
+// rule, to report the dropped messages of its source once its window ends.
+func (p *Processor) trackRateLimited(msg *message.Message, rule *config.ProcessingRule) {
+	p.rateLimitedMu.Lock()
+	defer p.rateLimitedMu.Unlock()
+	key := rateLimitKey{rule: rule, source: msg.Origin.LogSource.Name}
+	if _, found := p.rateLimited[key]; !found {
+		p.rateLimited[key] = &rateLimitSummary{origin: msg.Origin}
+	}
+}
+
+// sendRateLimitSummaries sends a message for each source reporting the number
+// of its messages dropped by a rate_limit rule in the windows that ended. It
+// does not block the pipeline: the summaries which cannot be sent are sent
+// at the next report.
+func (p *Processor) sendRateLimitSummaries() {
+	p.rateLimitedMu.Lock()
+	defer p.rateLimitedMu.Unlock()
+	for key, summary := range p.rateLimited {
+		dropped, ended := key.rule.RateLimiter.Dropped(key.source)
+		summary.dropped += dropped
+		if summary.dropped > 0 && p.trySendRateLimitSummary(summary, key.rule) {
+			summary.dropped = 0
+		}
+		if ended && summary.dropped == 0 {
+			delete(p.rateLimited, key)
+		}
+	}
+}
+
+func (p *Processor) trySendRateLimitSummary(summary *rateLimitSummary, rule *config.ProcessingRule) bool {
+	// the summary has no offset to commit
+	origin := *summary.origin
+	origin.Identifier = ""
+	origin.Offset = ""
+
+	content := []byte(fmt.Sprintf("rate_limit processing rule %s dropped %d log lines", rule.Name, summary.dropped))
+	msg := message.NewMessage(content, &origin, message.StatusWarning, time.Now().UnixNano())
+
+	encoded, err := p.encoder.Encode(msg, content)
+	if err != nil {
+		log.Error("unable to encode rate limit summary ", err)
+		// the summary would fail again
+		return true
+	}
+	msg.Content = encoded
+	select {
+	case p.outputChan <- msg:
+		return true
+	default:
+		return false
+	}
+}
@@ -8,10 +8,14 @@ package processor
 import (
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
 )
@@ -158,3 +162,81 @@ func newSource(ruleType, replacePlaceholder, pattern string) sources.LogSource {
 func newMessage(content []byte, source *sources.LogSource, status string) *message.Message {
 	return message.NewMessageWithSource(content, status, source, 0)
 }
+
+func TestSample(t *testing.T) {
+	p := &Processor{}
+
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{
+		{Type: config.Sample, Name: "sample_debug", SampleRate: 0.5, Regex: regexp.MustCompile("DEBUG")},
+	}}}
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("DEBUG hello"), &source, ""))
+		if shouldProcess {
+			kept++
+		}
+	}
+	assert.InDelta(t, 500, kept, 150)
+
+	// other lines are not sampled
+	for i := 0; i < 100; i++ {
+		shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("INFO hello"), &source, ""))
+		assert.True(t, shouldProcess)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	outputChan := make(chan *message.Message, 1)
+	p := New(nil, outputChan, nil, RawEncoder, nil)
+	clk := clock.NewMock()
+
+	// sources with a rule of the same name are limited and reported separately
+	newRateLimitedSource := func(name string) *sources.LogSource {
+		rule := &config.ProcessingRule{Type: config.RateLimit, Name: "rate_limit_api", MaxPerSecond: 2}
+		rule.RateLimiter = config.NewRateLimiterWithClock(rule.MaxPerSecond, clk)
+		return sources.NewLogSource(name, &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}})
+	}
+	api, web := newRateLimitedSource("api"), newRateLimitedSource("web")
+	defer metrics.ProcessingRulesDropped.Init()
+
+	send := func(source *sources.LogSource, count int) int {
+		kept := 0
+		for i := 0; i < count; i++ {
+			msg := newMessage([]byte("hello"), source, "")
+			msg.Origin.Identifier = "file:/var/log/" + source.Name + ".log"
+			if shouldProcess, _ := p.applyRedactingRules(msg); shouldProcess {
+				kept++
+			}
+		}
+		return kept
+	}
+	assert.Equal(t, 2, send(api, 5))
+	assert.Equal(t, 2, send(web, 3))
+	assert.Equal(t, "3", metrics.ProcessingRulesDropped.Get("api/rate_limit_api").String())
+	assert.Equal(t, "1", metrics.ProcessingRulesDropped.Get("web/rate_limit_api").String())
+
+	// the window did not end
+	p.sendRateLimitSummaries()
+	assert.Len(t, outputChan, 0)
+
+	// the flood stopped: the summaries are sent once the window ended, one
+	// at a time as the output channel is full
+	clk.Add(time.Second)
+	summaries := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		p.sendRateLimitSummaries()
+		require.Len(t, outputChan, 1)
+		summary := <-outputChan
+		assert.Equal(t, message.StatusWarning, summary.GetStatus())
+		assert.Equal(t, "", summary.Origin.Identifier)
+		summaries[summary.Origin.LogSource.Name] = string(summary.Content)
+	}
+	assert.Contains(t, summaries["api"], "rate_limit processing rule rate_limit_api dropped 3 log lines")
+	assert.Contains(t, summaries["web"], "rate_limit processing rule rate_limit_api dropped 1 log lines")
+
+	// the dropped lines are reported once
+	p.sendRateLimitSummaries()
+	assert.Len(t, outputChan, 0)
+	assert.Empty(t, p.rateLimited)
+}
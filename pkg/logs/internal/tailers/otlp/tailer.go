@@ -0,0 +1,235 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/grpc"
+
+	coreConfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// logsPath is the path of the OTLP/HTTP logs endpoint
+	logsPath = "/v1/logs"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+
+	// maxRequestSize is the maximum size of an uncompressed OTLP/HTTP request
+	maxRequestSize = 16 * 1024 * 1024
+)
+
+// Tailer receives logs over OTLP, on gRPC and/or HTTP depending on the ports
+// of the source, and forwards them to the pipeline.
+type Tailer struct {
+	source     *sources.LogSource
+	outputChan chan *message.Message
+
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+	httpServer   *http.Server
+	httpListener net.Listener
+
+	// ctx is cancelled when the tailer stops, to release the requests
+	// blocked on a full pipeline.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewTailer returns a new Tailer
+func NewTailer(source *sources.LogSource, outputChan chan *message.Message) *Tailer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tailer{
+		source:     source,
+		outputChan: outputChan,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// listenAddress returns the address to listen on for a port, on the bind_host
+// interface as the other listeners of the agent.
+func listenAddress(port int) string {
+	return net.JoinHostPort(coreConfig.GetBindHost(), strconv.Itoa(port))
+}
+
+// Start opens the listeners and starts serving the OTLP requests.
+func (t *Tailer) Start() error {
+	if port := t.source.Config.Port; port != 0 {
+		ln, err := net.Listen("tcp", listenAddress(port))
+		if err != nil {
+			return fmt.Errorf("can't listen for OTLP/gRPC on port %d: %v", port, err)
+		}
+		t.grpcListener = ln
+		t.grpcServer = grpc.NewServer()
+		plogotlp.RegisterServer(t.grpcServer, &grpcServer{tailer: t})
+	}
+
+	if port := t.source.Config.HTTPPort; port != 0 {
+		ln, err := net.Listen("tcp", listenAddress(port))
+		if err != nil {
+			if t.grpcListener != nil {
+				t.grpcListener.Close()
+			}
+			return fmt.Errorf("can't listen for OTLP/HTTP on port %d: %v", port, err)
+		}
+		t.httpListener = ln
+		mux := http.NewServeMux()
+		mux.HandleFunc(logsPath, t.handleHTTP)
+		t.httpServer = &http.Server{
+			Handler:     mux,
+			ReadTimeout: 30 * time.Second,
+		}
+	}
+
+	if t.grpcServer != nil {
+		go func() {
+			if err := t.grpcServer.Serve(t.grpcListener); err != nil {
+				log.Errorf("Error serving OTLP/gRPC logs on %s: %v", t.grpcListener.Addr(), err)
+			}
+		}()
+	}
+	if t.httpServer != nil {
+		go func() {
+			if err := t.httpServer.Serve(t.httpListener); err != nil && err != http.ErrServerClosed {
+				log.Errorf("Error serving OTLP/HTTP logs on %s: %v", t.httpListener.Addr(), err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Stop closes the listeners and the pending requests.
+func (t *Tailer) Stop() {
+	t.cancel()
+	if t.grpcServer != nil {
+		t.grpcServer.Stop()
+	}
+	if t.httpServer != nil {
+		t.httpServer.Close()
+	}
+}
+
+// consume forwards the logs to the pipeline, it returns an error if the
+// tailer stopped or the request was cancelled before all of them were sent.
+func (t *Tailer) consume(ctx context.Context, logs plog.Logs) error {
+	var err error
+	translateLogs(logs, t.source, func(msg *message.Message) bool {
+		select {
+		case t.outputChan <- msg:
+			t.source.RecordBytes(int64(len(msg.Content)))
+			return true
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-t.ctx.Done():
+			err = fmt.Errorf("the OTLP logs source is stopped")
+		}
+		return false
+	})
+	return err
+}
+
+// grpcServer implements the OTLP/gRPC logs service.
+type grpcServer struct {
+	tailer *Tailer
+}
+
+// Export implements plogotlp.Server
+func (s *grpcServer) Export(ctx context.Context, request plogotlp.Request) (plogotlp.Response, error) {
+	if err := s.tailer.consume(ctx, request.Logs()); err != nil {
+		return plogotlp.NewResponse(), err
+	}
+	return plogotlp.NewResponse(), nil
+}
+
+// handleHTTP implements the OTLP/HTTP logs endpoint, the response is encoded
+// the same way as the request.
+func (t *Tailer) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType := contentTypeProtobuf
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid content type: %v", err), http.StatusBadRequest)
+			return
+		}
+		contentType = mediaType
+	}
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid gzip payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(body, maxRequestSize+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(payload) > maxRequestSize {
+		http.Error(w, fmt.Sprintf("payload exceeds %d bytes", maxRequestSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	request := plogotlp.NewRequest()
+	if contentType == contentTypeJSON {
+		err = request.UnmarshalJSON(payload)
+	} else {
+		err = request.UnmarshalProto(payload)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid OTLP payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := t.consume(r.Context(), request.Logs()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response []byte
+	if contentType == contentTypeJSON {
+		response, err = plogotlp.NewResponse().MarshalJSON()
+	} else {
+		response, err = plogotlp.NewResponse().MarshalProto()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(response) //nolint:errcheck
+}
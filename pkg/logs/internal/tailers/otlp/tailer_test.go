@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/grpc"
+
+	coreConfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func startTestTailer(t *testing.T) (*Tailer, chan *message.Message) {
+	source := sources.NewLogSource("otlp", &config.LogsConfig{
+		Type:     config.OTLPType,
+		Port:     freePort(t),
+		HTTPPort: freePort(t),
+	})
+	outputChan := make(chan *message.Message, 10)
+	tailer := NewTailer(source, outputChan)
+	require.NoError(t, tailer.Start())
+	t.Cleanup(tailer.Stop)
+	return tailer, outputChan
+}
+
+func receive(t *testing.T, outputChan chan *message.Message) *message.Message {
+	select {
+	case msg := <-outputChan:
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no message received")
+	}
+	return nil
+}
+
+func TestTailerReceivesOverGRPC(t *testing.T) {
+	tailer, outputChan := startTestTailer(t)
+
+	conn, err := grpc.Dial(fmt.Sprintf("127.0.0.1:%d", tailer.source.Config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = plogotlp.NewClient(conn).Export(ctx, plogotlp.NewRequestFromLogs(newTestLogs()))
+	require.NoError(t, err)
+
+	assert.Equal(t, "payment failed", string(receive(t, outputChan).Content))
+	assert.Equal(t, "order received", string(receive(t, outputChan).Content))
+}
+
+func TestTailerDefaultBindHost(t *testing.T) {
+	coreConfig.Mock(t)
+	tailer, _ := startTestTailer(t)
+	for _, ln := range []net.Listener{tailer.grpcListener, tailer.httpListener} {
+		assert.True(t, ln.Addr().(*net.TCPAddr).IP.IsLoopback(), ln.Addr().String())
+	}
+}
+
+func TestTailerBindHost(t *testing.T) {
+	mockConfig := coreConfig.Mock(t)
+	mockConfig.Set("bind_host", "127.0.0.1")
+	tailer, _ := startTestTailer(t)
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%d", tailer.source.Config.Port), tailer.grpcListener.Addr().String())
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%d", tailer.source.Config.HTTPPort), tailer.httpListener.Addr().String())
+}
+
+func TestTailerReceivesOverHTTP(t *testing.T) {
+	tailer, outputChan := startTestTailer(t)
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", tailer.source.Config.HTTPPort, logsPath)
+
+	request := plogotlp.NewRequestFromLogs(newTestLogs())
+	for _, contentType := range []string{contentTypeProtobuf, contentTypeJSON} {
+		var payload []byte
+		var err error
+		if contentType == contentTypeJSON {
+			payload, err = request.MarshalJSON()
+		} else {
+			payload, err = request.MarshalProto()
+		}
+		require.NoError(t, err)
+
+		resp, err := http.Post(url, contentType, bytes.NewReader(payload))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, contentType)
+		assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+
+		msg := receive(t, outputChan)
+		assert.Equal(t, "payment failed", string(msg.Content))
+		assert.Equal(t, message.StatusError, msg.GetStatus())
+		assert.Equal(t, "order received", string(receive(t, outputChan).Content))
+	}
+}
+
+func TestTailerRejectsInvalidHTTPRequests(t *testing.T) {
+	tailer, _ := startTestTailer(t)
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", tailer.source.Config.HTTPPort, logsPath)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(url, "text/plain", bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, err = http.Post(url, contentTypeProtobuf, bytes.NewReader([]byte("not protobuf")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
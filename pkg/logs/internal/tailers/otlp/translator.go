@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+// OpenTelemetry semantic conventions for the resource attributes mapped to
+// the log metadata instead of tags.
+const (
+	attributeServiceName = "service.name"
+	attributeHostName    = "host.name"
+)
+
+// translateLogs converts the OTLP log records to messages, calling send for
+// each of them until it returns false.
+func translateLogs(logs plog.Logs, source *sources.LogSource, send func(*message.Message) bool) {
+	ingestionTimestamp := time.Now().UnixNano()
+
+	resourceLogs := logs.ResourceLogs()
+	for i := 0; i < resourceLogs.Len(); i++ {
+		resourceLog := resourceLogs.At(i)
+		service, hostname, resourceTags := translateResource(resourceLog.Resource())
+
+		scopeLogs := resourceLog.ScopeLogs()
+		for j := 0; j < scopeLogs.Len(); j++ {
+			records := scopeLogs.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				msg := translateLogRecord(records.At(k), source, ingestionTimestamp)
+				msg.Hostname = hostname
+				if service != "" {
+					msg.Origin.SetService(service)
+				}
+				msg.Origin.AddTags(resourceTags...)
+				if !send(msg) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// translateResource returns the service, the hostname and the tags of a resource.
+func translateResource(resource pcommon.Resource) (string, string, []string) {
+	var service, hostname string
+	var tags []string
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		switch k {
+		case attributeServiceName:
+			service = v.AsString()
+		case attributeHostName:
+			hostname = v.AsString()
+		default:
+			tags = appendAttributeTag(tags, k, v)
+		}
+		return true
+	})
+	return service, hostname, tags
+}
+
+// translateLogRecord converts a log record to a message, its attributes are
+// added as tags.
+func translateLogRecord(record plog.LogRecord, source *sources.LogSource, ingestionTimestamp int64) *message.Message {
+	status := statusFromSeverity(record.SeverityNumber(), record.SeverityText())
+	msg := message.NewMessageWithSource([]byte(record.Body().AsString()), status, source, ingestionTimestamp)
+
+	if ts := record.Timestamp(); ts != 0 {
+		msg.Timestamp = ts.AsTime().UTC()
+	} else if ts := record.ObservedTimestamp(); ts != 0 {
+		msg.Timestamp = ts.AsTime().UTC()
+	}
+
+	var tags []string
+	record.Attributes().Range(func(k string, v pcommon.Value) bool {
+		tags = appendAttributeTag(tags, k, v)
+		return true
+	})
+	msg.Origin.AddTags(tags...)
+
+	return msg
+}
+
+// appendAttributeTag appends the `key:value` tag of an attribute, attributes
+// with an empty value are ignored.
+func appendAttributeTag(tags []string, k string, v pcommon.Value) []string {
+	value := v.AsString()
+	if value == "" {
+		return tags
+	}
+	return append(tags, k+":"+value)
+}
+
+// statusFromSeverity maps the severity of a log record to a status, the
+// severity text is only used when the severity number is not set.
+func statusFromSeverity(number plog.SeverityNumber, text string) string {
+	switch {
+	case number >= plog.SeverityNumberFatal:
+		return message.StatusCritical
+	case number >= plog.SeverityNumberError:
+		return message.StatusError
+	case number >= plog.SeverityNumberWarn:
+		return message.StatusWarning
+	case number >= plog.SeverityNumberInfo:
+		return message.StatusInfo
+	case number >= plog.SeverityNumberTrace:
+		return message.StatusDebug
+	}
+
+	switch strings.ToLower(text) {
+	case "emergency", "emerg":
+		return message.StatusEmergency
+	case "alert":
+		return message.StatusAlert
+	case "fatal", "critical", "crit":
+		return message.StatusCritical
+	case "error", "err":
+		return message.StatusError
+	case "warn", "warning":
+		return message.StatusWarning
+	case "notice":
+		return message.StatusNotice
+	case "debug", "trace":
+		return message.StatusDebug
+	}
+	return message.StatusInfo
+}
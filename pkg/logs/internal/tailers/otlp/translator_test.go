@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func newTestLogs() plog.Logs {
+	logs := plog.NewLogs()
+	resourceLogs := logs.ResourceLogs().AppendEmpty()
+	resourceLogs.Resource().Attributes().UpsertString("service.name", "checkout")
+	resourceLogs.Resource().Attributes().UpsertString("host.name", "remote-host")
+	resourceLogs.Resource().Attributes().UpsertString("deployment.environment", "prod")
+
+	records := resourceLogs.ScopeLogs().AppendEmpty().LogRecords()
+
+	record := records.AppendEmpty()
+	record.Body().SetStringVal("payment failed")
+	record.SetSeverityNumber(plog.SeverityNumberError2)
+	record.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(1600000000, 0)))
+	record.Attributes().UpsertString("order_id", "42")
+	record.Attributes().UpsertString("empty", "")
+
+	record = records.AppendEmpty()
+	record.Body().SetStringVal("order received")
+	record.SetSeverityText("WARNING")
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Unix(1600000001, 0)))
+
+	return logs
+}
+
+func TestTranslateLogs(t *testing.T) {
+	source := sources.NewLogSource("otlp", &config.LogsConfig{Type: config.OTLPType, Source: "otel", Tags: []string{"team:shop"}})
+
+	var messages []*message.Message
+	translateLogs(newTestLogs(), source, func(msg *message.Message) bool {
+		messages = append(messages, msg)
+		return true
+	})
+	require.Len(t, messages, 2)
+
+	msg := messages[0]
+	assert.Equal(t, "payment failed", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, time.Unix(1600000000, 0).UTC(), msg.Timestamp)
+	assert.Equal(t, "remote-host", msg.GetHostname())
+	assert.Equal(t, "checkout", msg.Origin.Service())
+	assert.Equal(t, "otel", msg.Origin.Source())
+	assert.ElementsMatch(t, []string{"team:shop", "deployment.environment:prod", "order_id:42"}, msg.Origin.Tags())
+	assert.Equal(t, "", msg.Origin.Identifier)
+
+	msg = messages[1]
+	assert.Equal(t, "order received", string(msg.Content))
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+	assert.Equal(t, time.Unix(1600000001, 0).UTC(), msg.Timestamp)
+	assert.ElementsMatch(t, []string{"team:shop", "deployment.environment:prod"}, msg.Origin.Tags())
+}
+
+func TestTranslateLogsStopsWhenSendFails(t *testing.T) {
+	source := sources.NewLogSource("otlp", &config.LogsConfig{Type: config.OTLPType})
+
+	sent := 0
+	translateLogs(newTestLogs(), source, func(msg *message.Message) bool {
+		sent++
+		return false
+	})
+	assert.Equal(t, 1, sent)
+}
+
+func TestStatusFromSeverity(t *testing.T) {
+	tests := []struct {
+		number plog.SeverityNumber
+		text   string
+		status string
+	}{
+		{plog.SeverityNumberTrace, "", message.StatusDebug},
+		{plog.SeverityNumberDebug4, "", message.StatusDebug},
+		{plog.SeverityNumberInfo, "ERROR", message.StatusInfo},
+		{plog.SeverityNumberWarn3, "", message.StatusWarning},
+		{plog.SeverityNumberError, "", message.StatusError},
+		{plog.SeverityNumberFatal4, "", message.StatusCritical},
+		{plog.SeverityNumberUndefined, "Error", message.StatusError},
+		{plog.SeverityNumberUndefined, "emerg", message.StatusEmergency},
+		{plog.SeverityNumberUndefined, "notice", message.StatusNotice},
+		{plog.SeverityNumberUndefined, "unknown", message.StatusInfo},
+		{plog.SeverityNumberUndefined, "", message.StatusInfo},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.status, statusFromSeverity(test.number, test.text), "%v %q", test.number, test.text)
+	}
+}
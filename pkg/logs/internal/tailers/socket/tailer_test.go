@@ -9,6 +9,7 @@ import (
 	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -40,6 +41,28 @@ func TestReadAndForwardShouldSucceedWithSuccessfulRead(t *testing.T) {
 	tailer.Stop()
 }
 
+func TestReadAndForwardSyslogMessages(t *testing.T) {
+	msgChan := make(chan *message.Message)
+	r, w := net.Pipe()
+	tailer := NewTailer(sources.NewLogSource("", &config.LogsConfig{Format: config.SyslogFormat}), r, msgChan, read)
+	tailer.Start()
+
+	// octet-counted and newline-terminated messages can be mixed
+	go w.Write([]byte("56 <11>1 2003-10-11T22:14:15.003Z host app 12 - - disk full<14>1 - - - - - - hello\n"))
+
+	msg := <-msgChan
+	assert.JSONEq(t, `{"message":"disk full","syslog":{"facility":1,"severity":3,"version":1,"hostname":"host","appname":"app","procid":"12"}}`, string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC), msg.Timestamp)
+
+	msg = <-msgChan
+	assert.JSONEq(t, `{"message":"hello","syslog":{"facility":1,"severity":6,"version":1}}`, string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+	assert.True(t, msg.Timestamp.IsZero())
+
+	tailer.Stop()
+}
+
 func TestReadShouldFailWithError(t *testing.T) {
 	msgChan := make(chan *message.Message)
 	r, w := net.Pipe()
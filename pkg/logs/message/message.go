@@ -37,6 +37,8 @@ type Message struct {
 	// Optional.
 	// Used in the Serverless Agent
 	Lambda *Lambda
+	// Optional. Overrides the hostname of the agent, for logs coming from other hosts.
+	Hostname string
 }
 
 // Lambda is a struct storing information about the Lambda function and function execution.
@@ -84,6 +86,11 @@ func (m *Message) GetStatus() string {
 	return m.status
 }
 
+// SetStatus sets the status of the message.
+func (m *Message) SetStatus(status string) {
+	m.status = status
+}
+
 // GetLatency returns the latency delta from ingestion time until now
 func (m *Message) GetLatency() int64 {
 	return time.Now().UnixNano() - m.IngestionTimestamp
@@ -94,6 +101,9 @@ func (m *Message) GetHostname() string {
 	if m.Lambda != nil {
 		return m.Lambda.ARN
 	}
+	if m.Hostname != "" {
+		return m.Hostname
+	}
 	hname, err := hostname.Get(context.TODO())
 	if err != nil {
 		// this scenario is not likely to happen since
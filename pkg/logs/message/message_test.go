@@ -38,3 +38,10 @@ func TestGetHostname(t *testing.T) {
 	message := Message{Content: []byte("hello")}
 	assert.Equal(t, "testHostnameFromEnvVar", message.GetHostname())
 }
+
+func TestGetHostnameOverride(t *testing.T) {
+	os.Setenv("DD_HOSTNAME", "testHostnameFromEnvVar")
+	defer os.Unsetenv("DD_HOSTNAME")
+	message := Message{Content: []byte("hello"), Hostname: "remote-host"}
+	assert.Equal(t, "remote-host", message.GetHostname())
+}
@@ -64,6 +64,23 @@ func TestSetTagsWithConfigTags(t *testing.T) {
 	assert.Equal(t, "[dd ddsource=\"a\"][dd ddsourcecategory=\"b\"][dd ddtags=\"c:d,e,foo:bar,baz\"]", string(origin.TagsPayload()))
 }
 
+func TestAddTags(t *testing.T) {
+	cfg := &config.LogsConfig{
+		Tags: []string{"c:d"},
+	}
+	source := sources.NewLogSource("", cfg)
+	tailerTags := make([]string, 1, 2)
+	tailerTags[0] = "a:b"
+
+	origin := NewOrigin(source)
+	origin.SetTags(tailerTags)
+	origin.AddTags("e:f")
+	assert.Equal(t, []string{"a:b", "e:f", "c:d"}, origin.Tags())
+
+	// the tags set by the tailer are not modified
+	assert.Equal(t, []string{"a:b", ""}, tailerTags[:2])
+}
+
 func TestDefaultSourceValueIsSourceFromConfig(t *testing.T) {
 	var cfg *config.LogsConfig
 	var source *sources.LogSource
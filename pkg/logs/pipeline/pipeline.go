@@ -92,13 +92,16 @@ func getDestinations(endpoints *config.Endpoints, destinationsContext *client.De
 	additionals := []client.Destination{}
 
 	if endpoints.UseHTTP {
+		payloadEncoding := sender.NewPayloadContentEncoding(endpoints)
 		for i, endpoint := range endpoints.GetReliableEndpoints() {
 			telemetryName := fmt.Sprintf("logs_%d_reliable_%d", pipelineID, i)
-			reliable = append(reliable, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, true, telemetryName))
+			destination := http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, true, telemetryName)
+			reliable = append(reliable, sender.NewEncodingDestination(destination, endpoint, payloadEncoding))
 		}
 		for i, endpoint := range endpoints.GetUnReliableEndpoints() {
 			telemetryName := fmt.Sprintf("logs_%d_unreliable_%d", pipelineID, i)
-			additionals = append(additionals, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, false, telemetryName))
+			destination := http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend, false, telemetryName)
+			additionals = append(additionals, sender.NewEncodingDestination(destination, endpoint, payloadEncoding))
 		}
 		return client.NewDestinations(reliable, additionals)
 	}
@@ -113,10 +116,7 @@ func getDestinations(endpoints *config.Endpoints, destinationsContext *client.De
 
 func getStrategy(inputChan chan *message.Message, outputChan chan *message.Payload, endpoints *config.Endpoints, serverless bool, pipelineID int) sender.Strategy {
 	if endpoints.UseHTTP || serverless {
-		encoder := sender.IdentityContentType
-		if endpoints.Main.UseCompression {
-			encoder = sender.NewGzipContentEncoding(endpoints.Main.CompressionLevel)
-		}
+		encoder := sender.NewPayloadContentEncoding(endpoints)
 		return sender.NewBatchStrategy(inputChan, outputChan, sender.ArraySerializer, endpoints.BatchWait, endpoints.BatchMaxSize, endpoints.BatchMaxContentSize, "logs", encoder)
 	}
 	return sender.NewStreamStrategy(inputChan, outputChan)
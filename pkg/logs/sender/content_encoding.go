@@ -8,8 +8,15 @@ package sender
 import (
 	"bytes"
 	"compress/gzip"
+
+	"github.com/DataDog/zstd"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
 )
 
+// zstdMaxCompressionLevel is the highest compression level supported by zstd
+const zstdMaxCompressionLevel = 22
+
 // ContentEncoding encodes the payload
 type ContentEncoding interface {
 	name() string
@@ -71,3 +78,67 @@ func (c *GzipContentEncoding) encode(payload []byte) ([]byte, error) {
 	}
 	return compressedPayload.Bytes(), nil
 }
+
+// ZstdContentEncoding encodes the payload using zstd algorithm
+type ZstdContentEncoding struct {
+	level int
+}
+
+// NewZstdContentEncoding creates a new Zstd content type
+func NewZstdContentEncoding(level int) *ZstdContentEncoding {
+	if level < zstd.BestSpeed {
+		level = zstd.BestSpeed
+	} else if level > zstdMaxCompressionLevel {
+		level = zstdMaxCompressionLevel
+	}
+
+	return &ZstdContentEncoding{
+		level,
+	}
+}
+
+func (c *ZstdContentEncoding) name() string {
+	return "zstd"
+}
+
+func (c *ZstdContentEncoding) encode(payload []byte) ([]byte, error) {
+	return zstd.CompressLevel(nil, payload, c.level)
+}
+
+// NewContentEncoding returns the content encoding configured for an endpoint
+func NewContentEncoding(endpoint config.Endpoint) ContentEncoding {
+	if !endpoint.UseCompression {
+		return IdentityContentType
+	}
+	if endpoint.CompressionKind == config.ZstdCompressionKind {
+		return NewZstdContentEncoding(endpoint.CompressionLevel)
+	}
+	return NewGzipContentEncoding(endpoint.CompressionLevel)
+}
+
+// NewPayloadContentEncoding returns the content encoding the payloads must be
+// built with to be sent to the given endpoints. It's the encoding of the main
+// endpoint when all the endpoints share it, otherwise the payloads are not
+// encoded and the destinations encode them, see NewEncodingDestination.
+func NewPayloadContentEncoding(endpoints *config.Endpoints) ContentEncoding {
+	main := NewContentEncoding(endpoints.Main)
+	for _, endpoint := range endpoints.Endpoints {
+		if !sameContentEncoding(main, NewContentEncoding(endpoint)) {
+			return IdentityContentType
+		}
+	}
+	return main
+}
+
+// sameContentEncoding returns true if both encodings produce the same payloads
+func sameContentEncoding(a, b ContentEncoding) bool {
+	switch a := a.(type) {
+	case *GzipContentEncoding:
+		b, ok := b.(*GzipContentEncoding)
+		return ok && a.level == b.level
+	case *ZstdContentEncoding:
+		b, ok := b.(*ZstdContentEncoding)
+		return ok && a.level == b.level
+	}
+	return a == b
+}
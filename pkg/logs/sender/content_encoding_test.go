@@ -10,7 +10,10 @@ import (
 	"compress/gzip"
 	"testing"
 
+	"github.com/DataDog/zstd"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
 )
 
 func TestIdentityContentType(t *testing.T) {
@@ -42,6 +45,48 @@ func TestGzipContentEncodingName(t *testing.T) {
 	assert.Equal(t, NewGzipContentEncoding(gzip.BestCompression).name(), "gzip")
 }
 
+func TestZstdContentEncoding(t *testing.T) {
+	payload := []byte("my payload")
+
+	encodedPayload, err := NewZstdContentEncoding(zstd.BestCompression).encode(payload)
+	assert.Nil(t, err)
+
+	decompressedPayload, err := zstd.Decompress(nil, encodedPayload)
+	assert.Nil(t, err)
+
+	assert.Equal(t, payload, decompressedPayload)
+}
+
+func TestZstdContentEncodingName(t *testing.T) {
+	assert.Equal(t, NewZstdContentEncoding(zstd.DefaultCompression).name(), "zstd")
+}
+
+func TestZstdContentEncodingLevel(t *testing.T) {
+	assert.Equal(t, zstd.BestSpeed, NewZstdContentEncoding(-1).level)
+	assert.Equal(t, zstdMaxCompressionLevel, NewZstdContentEncoding(100).level)
+}
+
+func TestNewContentEncoding(t *testing.T) {
+	assert.Equal(t, IdentityContentType, NewContentEncoding(config.Endpoint{UseCompression: false, CompressionKind: config.ZstdCompressionKind}))
+	assert.Equal(t, NewGzipContentEncoding(6), NewContentEncoding(config.Endpoint{UseCompression: true, CompressionLevel: 6}))
+	assert.Equal(t, NewGzipContentEncoding(6), NewContentEncoding(config.Endpoint{UseCompression: true, CompressionKind: config.GzipCompressionKind, CompressionLevel: 6}))
+	assert.Equal(t, NewZstdContentEncoding(3), NewContentEncoding(config.Endpoint{UseCompression: true, CompressionKind: config.ZstdCompressionKind, CompressionLevel: 3}))
+}
+
+func TestNewPayloadContentEncoding(t *testing.T) {
+	gzip6 := config.Endpoint{UseCompression: true, CompressionKind: config.GzipCompressionKind, CompressionLevel: 6}
+	gzip2 := config.Endpoint{UseCompression: true, CompressionKind: config.GzipCompressionKind, CompressionLevel: 2}
+	zstd6 := config.Endpoint{UseCompression: true, CompressionKind: config.ZstdCompressionKind, CompressionLevel: 6}
+
+	// all the endpoints share the same encoding
+	assert.Equal(t, NewGzipContentEncoding(6), NewPayloadContentEncoding(config.NewEndpoints(gzip6, []config.Endpoint{gzip6}, false, true)))
+	assert.Equal(t, NewZstdContentEncoding(6), NewPayloadContentEncoding(config.NewEndpoints(zstd6, nil, false, true)))
+
+	// the destinations encode the payloads
+	assert.Equal(t, IdentityContentType, NewPayloadContentEncoding(config.NewEndpoints(gzip6, []config.Endpoint{gzip2}, false, true)))
+	assert.Equal(t, IdentityContentType, NewPayloadContentEncoding(config.NewEndpoints(gzip6, []config.Endpoint{zstd6}, false, true)))
+}
+
 func decompress(payload []byte) ([]byte, error) {
 	reader, err := gzip.NewReader(bytes.NewReader(payload))
 	if err != nil {
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// encodingDestination encodes the payloads before handing them over to the
+// destination of an endpoint whose content encoding differs from the one of
+// the payloads.
+type encodingDestination struct {
+	destination     client.Destination
+	contentEncoding ContentEncoding
+}
+
+// NewEncodingDestination returns the destination of an endpoint, wrapped so
+// that it encodes the payloads itself if the endpoint needs another content
+// encoding than the one of the payloads. The payloads must not be encoded in
+// that case, see NewPayloadContentEncoding.
+func NewEncodingDestination(destination client.Destination, endpoint config.Endpoint, payloadEncoding ContentEncoding) client.Destination {
+	contentEncoding := NewContentEncoding(endpoint)
+	if sameContentEncoding(contentEncoding, payloadEncoding) {
+		return destination
+	}
+	return &encodingDestination{
+		destination:     destination,
+		contentEncoding: contentEncoding,
+	}
+}
+
+// Start implements client.Destination
+func (d *encodingDestination) Start(input chan *message.Payload, output chan *message.Payload, isRetrying chan bool) (stopChan <-chan struct{}) {
+	encoded := make(chan *message.Payload)
+	stopChan = d.destination.Start(encoded, output, isRetrying)
+	go func() {
+		defer close(encoded)
+		for payload := range input {
+			if payload := d.encode(payload); payload != nil {
+				encoded <- payload
+			}
+		}
+	}()
+	return stopChan
+}
+
+// encode returns a copy of the payload with the content encoding of the
+// destination, or nil if the encoding failed.
+func (d *encodingDestination) encode(payload *message.Payload) *message.Payload {
+	encodedPayload, err := d.contentEncoding.encode(payload.Encoded)
+	if err != nil {
+		log.Warn("Encoding failed - dropping payload", err)
+		return nil
+	}
+	return &message.Payload{
+		Messages:      payload.Messages,
+		Encoded:       encodedPayload,
+		Encoding:      d.contentEncoding.name(),
+		UnencodedSize: payload.UnencodedSize,
+	}
+}
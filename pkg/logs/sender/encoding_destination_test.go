@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"testing"
+
+	"github.com/DataDog/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestNewEncodingDestinationWithSameEncoding(t *testing.T) {
+	dest := &mockDestination{}
+	endpoint := config.Endpoint{UseCompression: true, CompressionKind: config.GzipCompressionKind, CompressionLevel: 6}
+	assert.Equal(t, dest, NewEncodingDestination(dest, endpoint, NewGzipContentEncoding(6)))
+}
+
+func TestEncodingDestination(t *testing.T) {
+	dest := &mockDestination{}
+	endpoint := config.Endpoint{UseCompression: true, CompressionKind: config.ZstdCompressionKind, CompressionLevel: 3}
+	encodingDest := NewEncodingDestination(dest, endpoint, IdentityContentType)
+	require.IsType(t, &encodingDestination{}, encodingDest)
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload)
+	stopChan := encodingDest.Start(input, output, nil)
+	assert.Equal(t, (<-chan struct{})(dest.stopChan), stopChan)
+
+	messages := []*message.Message{message.NewMessage([]byte("my payload"), nil, message.StatusInfo, 0)}
+	input <- &message.Payload{Messages: messages, Encoded: []byte("my payload"), Encoding: "identity", UnencodedSize: 10}
+
+	payload := <-dest.input
+	assert.Equal(t, messages, payload.Messages)
+	assert.Equal(t, "zstd", payload.Encoding)
+	assert.Equal(t, 10, payload.UnencodedSize)
+	decompressed, err := zstd.Decompress(nil, payload.Encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "my payload", string(decompressed))
+
+	// closing the input stops the destination
+	close(input)
+	_, ok := <-dest.input
+	assert.False(t, ok)
+}
@@ -49,6 +49,8 @@ func (b *Builder) BuildStatus() Status {
 		Warnings:      b.getWarnings(),
 		Errors:        b.getErrors(),
 		UseHTTP:       b.getUseHTTP(),
+
+		ProcessingRulesDropped: b.getProcessingRulesDropped(),
 	}
 }
 
@@ -144,6 +146,14 @@ func (b *Builder) toDictionary(c *config.LogsConfig) map[string]interface{} {
 	switch c.Type {
 	case config.TCPType, config.UDPType:
 		dictionary["Port"] = c.Port
+		dictionary["Format"] = c.Format
+	case config.OTLPType:
+		if c.Port != 0 {
+			dictionary["Port"] = c.Port
+		}
+		if c.HTTPPort != 0 {
+			dictionary["HTTPPort"] = c.HTTPPort
+		}
 	case config.FileType:
 		dictionary["Path"] = c.Path
 		dictionary["TailingMode"] = c.TailingMode
@@ -180,3 +190,16 @@ func (b *Builder) getMetricsStatus() map[string]int64 {
 	metrics["EncodedBytesSent"] = b.logsExpVars.Get("EncodedBytesSent").(*expvar.Int).Value()
 	return metrics
 }
+
+// getProcessingRulesDropped exposes the number of logs dropped by the sample and rate_limit processing rules, per source
+func (b *Builder) getProcessingRulesDropped() map[string]int64 {
+	dropped := make(map[string]int64)
+	if rules, ok := b.logsExpVars.Get("ProcessingRulesDropped").(*expvar.Map); ok {
+		rules.Do(func(kv expvar.KeyValue) {
+			if count, ok := kv.Value.(*expvar.Int); ok {
+				dropped[kv.Key] = count.Value()
+			}
+		})
+	}
+	return dropped
+}
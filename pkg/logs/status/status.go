@@ -65,6 +65,8 @@ type Status struct {
 	Errors        []string         `json:"errors"`
 	Warnings      []string         `json:"warnings"`
 	UseHTTP       bool             `json:"use_http"`
+	// ProcessingRulesDropped is the number of logs dropped by each sample and rate_limit rule, per source
+	ProcessingRulesDropped map[string]int64 `json:"processing_rules_dropped"`
 }
 
 // Init instantiates the builder that builds the status on the fly.
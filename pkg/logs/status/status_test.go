@@ -94,13 +94,13 @@ func TestStatusDeduplicateErrorsAndWarnings(t *testing.T) {
 func TestMetrics(t *testing.T) {
 	defer Clear()
 	Clear()
-	var expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "", "HttpDestinationStats": {}, "IsRunning": false, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "SenderLatency": 0, "Warnings": ""}`
+	var expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "", "HttpDestinationStats": {}, "IsRunning": false, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "ProcessingRulesDropped": {}, "SenderLatency": 0, "Warnings": ""}`
 	assert.Equal(t, expected, metrics.LogsExpvars.String())
 
 	initStatus()
 	AddGlobalWarning("bar", "Unique Warning")
 	AddGlobalError("bar", "I am an error")
-	expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "I am an error", "HttpDestinationStats": {}, "IsRunning": true, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "SenderLatency": 0, "Warnings": "Unique Warning"}`
+	expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "I am an error", "HttpDestinationStats": {}, "IsRunning": true, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "ProcessingRulesDropped": {}, "SenderLatency": 0, "Warnings": "Unique Warning"}`
 	assert.Equal(t, expected, metrics.LogsExpvars.String())
 }
 
@@ -131,6 +131,21 @@ func TestStatusMetrics(t *testing.T) {
 	assert.Equal(t, int64(math.MinInt64), status.StatusMetrics["LogsProcessed"])
 }
 
+func TestStatusProcessingRulesDropped(t *testing.T) {
+	defer Clear()
+	defer metrics.ProcessingRulesDropped.Init()
+	initStatus()
+
+	status := Get()
+	assert.Empty(t, status.ProcessingRulesDropped)
+
+	metrics.ProcessingRulesDropped.Add("api/sample_debug", 3)
+	metrics.ProcessingRulesDropped.Add("api/rate_limit", 1)
+	metrics.ProcessingRulesDropped.Add("web/rate_limit", 2)
+	status = Get()
+	assert.Equal(t, map[string]int64{"api/sample_debug": 3, "api/rate_limit": 1, "web/rate_limit": 2}, status.ProcessingRulesDropped)
+}
+
 func TestStatusEndpoints(t *testing.T) {
 	defer Clear()
 	initStatus()
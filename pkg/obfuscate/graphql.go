@@ -0,0 +1,400 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import "strings"
+
+// GraphQLConfig holds the configuration settings for GraphQL obfuscation.
+type GraphQLConfig struct {
+	// RemoveAliases specifies whether field aliases should be removed from the
+	// obfuscated query, so that queries differing only in their aliases are grouped.
+	RemoveAliases bool
+}
+
+// ObfuscatedGraphQLQuery specifies information about an obfuscated GraphQL query.
+type ObfuscatedGraphQLQuery struct {
+	Query    string          `json:"query"`    // the obfuscated GraphQL query
+	Metadata GraphQLMetadata `json:"metadata"` // metadata extracted from the GraphQL query
+}
+
+// GraphQLMetadata holds metadata collected throughout the obfuscation of a GraphQL document.
+// It describes the first operation of the document.
+type GraphQLMetadata struct {
+	// OperationType is the type of the operation: query, mutation or subscription.
+	OperationType string `json:"operation_type"`
+	// OperationName is the name of the operation, if it has one.
+	OperationName string `json:"operation_name"`
+	// FieldsCSV is a comma-separated list of the top-level fields selected by the operation.
+	FieldsCSV string `json:"fields_csv"`
+}
+
+// ObfuscateGraphQLString obfuscates and normalizes the GraphQL document query. Literal values
+// are replaced by "?", lists of literals are collapsed into "[?]", comments are removed and
+// whitespace is normalized. Aliases are also removed when configured to.
+//
+// An error is returned when the document can not be tokenized, in which case it can not
+// be safely obfuscated.
+func (o *Obfuscator) ObfuscateGraphQLString(query string) (*ObfuscatedGraphQLQuery, error) {
+	toks, err := tokenizeGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	g := graphqlObfuscator{
+		toks:          toks,
+		removeAliases: o.opts.GraphQL.RemoveAliases,
+		out:           make([]byte, 0, len(query)),
+	}
+	g.obfuscate()
+	return &ObfuscatedGraphQLQuery{
+		Query:    string(g.out),
+		Metadata: g.meta,
+	}, nil
+}
+
+// graphqlScope specifies the kind of a bracketed section of a GraphQL document.
+type graphqlScope int
+
+const (
+	// graphqlSelectionSet is a selection set: { field alias: field ...Fragment }
+	graphqlSelectionSet graphqlScope = iota
+	// graphqlArguments holds the arguments of a field or a directive: (name: value)
+	graphqlArguments
+	// graphqlVariableDefinitions holds the variables of an operation: ($name: Type = value)
+	graphqlVariableDefinitions
+	// graphqlObjectValue is an input object value: {name: value}
+	graphqlObjectValue
+	// graphqlListValue is a list value: [value]
+	graphqlListValue
+	// graphqlListType is a list type, in variable definitions: [Type]
+	graphqlListType
+)
+
+// graphqlFrame holds the state of an open bracketed section.
+type graphqlFrame struct {
+	scope graphqlScope
+	// items counts the arguments, fields, variables or values written in the section.
+	items int
+	// literals reports whether all the values of a list are literals.
+	literals bool
+	// start is the offset of the opening bracket in the output.
+	start int
+}
+
+// graphqlDefinition specifies the kind of the top-level definition being obfuscated.
+type graphqlDefinition int
+
+const (
+	graphqlNoDefinition graphqlDefinition = iota
+	graphqlOperationDefinition
+	graphqlFragmentDefinition
+)
+
+// graphqlObfuscator obfuscates a tokenized GraphQL document. It does not validate the document:
+// string and number values are replaced wherever they are found, and the brackets are tracked
+// to know where other values, such as booleans, are expected.
+type graphqlObfuscator struct {
+	toks          []graphqlToken
+	removeAliases bool
+
+	out   []byte
+	last  string         // last token written to out
+	stack []graphqlFrame // open bracketed sections
+	value bool           // reports whether a value is expected
+
+	def     graphqlDefinition // kind of the current top-level definition
+	first   bool              // reports whether the current definition is the first operation
+	seenOp  bool              // reports whether an operation was found
+	meta    GraphQLMetadata
+	fields  []string
+	seenFld map[string]bool
+}
+
+func (g *graphqlObfuscator) obfuscate() {
+	for i := 0; i < len(g.toks); i++ {
+		tok := g.toks[i]
+		if len(g.stack) == 0 {
+			g.trackDefinition(i)
+		}
+		switch tok.kind {
+		case graphqlNumber, graphqlString:
+			g.writeValue("?", true)
+		case graphqlVariable:
+			if g.value {
+				g.writeValue(tok.text, false)
+				continue
+			}
+			if top := g.top(); top != nil && top.scope == graphqlVariableDefinitions {
+				g.writeItem(top, tok.text)
+				continue
+			}
+			g.write(tok.text)
+		case graphqlName:
+			if g.value {
+				switch tok.text {
+				case "true", "false", "null":
+					g.writeValue("?", true)
+				default:
+					// enum value
+					g.writeValue(tok.text, false)
+				}
+				continue
+			}
+			top := g.top()
+			if top == nil {
+				g.write(tok.text)
+				continue
+			}
+			switch top.scope {
+			case graphqlArguments, graphqlObjectValue:
+				g.writeItem(top, tok.text)
+			case graphqlSelectionSet:
+				if i+1 < len(g.toks) && g.toks[i+1].is(":") {
+					// alias
+					if g.removeAliases {
+						i++
+						continue
+					}
+				} else if g.isField(i) {
+					g.addField(tok.text)
+				}
+				g.write(tok.text)
+			default:
+				g.write(tok.text)
+			}
+		case graphqlPunctuator:
+			g.punctuator(i)
+		}
+	}
+	g.meta.FieldsCSV = strings.Join(g.fields, ",")
+}
+
+// punctuator obfuscates the punctuator at index i.
+func (g *graphqlObfuscator) punctuator(i int) {
+	switch tok := g.toks[i]; tok.text {
+	case "{":
+		if g.value {
+			g.beginValue()
+			g.write("{")
+			g.push(graphqlObjectValue)
+			g.value = false
+			return
+		}
+		g.write("{")
+		g.push(graphqlSelectionSet)
+	case "}":
+		g.write("}")
+		f, ok := g.pop()
+		if !ok {
+			return
+		}
+		switch {
+		case f.scope == graphqlObjectValue:
+			g.endValue(false)
+		case len(g.stack) == 0:
+			// end of the definition
+			g.def = graphqlNoDefinition
+			g.first = false
+		}
+	case "(":
+		g.write("(")
+		directive := i >= 2 && g.toks[i-1].kind == graphqlName && g.toks[i-2].is("@")
+		if len(g.stack) == 0 && !directive {
+			g.push(graphqlVariableDefinitions)
+		} else {
+			g.push(graphqlArguments)
+		}
+		g.value = false
+	case ")":
+		g.write(")")
+		g.pop()
+		g.value = false
+	case "[":
+		if !g.value {
+			g.write("[")
+			g.push(graphqlListType)
+			return
+		}
+		g.beginValue()
+		g.write("[")
+		g.push(graphqlListValue)
+		g.top().literals = true
+	case "]":
+		f, ok := g.pop()
+		if !ok || f.scope != graphqlListValue {
+			g.write("]")
+			return
+		}
+		collapse := f.literals && f.items > 0
+		if collapse {
+			g.out = append(g.out[:f.start], "[?"...)
+		}
+		g.write("]")
+		g.endValue(f.literals)
+	case ":":
+		g.write(":")
+		if top := g.top(); top != nil && (top.scope == graphqlArguments || top.scope == graphqlObjectValue) {
+			g.value = true
+		}
+	case "=":
+		g.write("=")
+		if top := g.top(); top != nil && top.scope == graphqlVariableDefinitions {
+			g.value = true
+		}
+	default:
+		g.write(tok.text)
+	}
+}
+
+// trackDefinition collects the metadata of the first operation from the top-level token
+// at index i.
+func (g *graphqlObfuscator) trackDefinition(i int) {
+	tok := g.toks[i]
+	switch {
+	case g.def == graphqlNoDefinition && tok.kind == graphqlName:
+		switch tok.text {
+		case "query", "mutation", "subscription":
+			g.beginOperation(tok.text)
+		case "fragment":
+			g.def = graphqlFragmentDefinition
+		}
+	case g.def == graphqlNoDefinition && tok.is("{"):
+		// query shorthand
+		g.beginOperation("query")
+	case g.first && tok.kind == graphqlName && i > 0 && g.toks[i-1].kind == graphqlName && g.toks[i-1].text == g.meta.OperationType:
+		g.meta.OperationName = tok.text
+	}
+}
+
+// beginOperation starts an operation definition of type typ.
+func (g *graphqlObfuscator) beginOperation(typ string) {
+	g.def = graphqlOperationDefinition
+	if g.seenOp {
+		return
+	}
+	g.seenOp = true
+	g.first = true
+	g.meta.OperationType = typ
+}
+
+// isField reports whether the name at index i, which must not be an alias, is a top-level
+// field of the first operation.
+func (g *graphqlObfuscator) isField(i int) bool {
+	if !g.first || len(g.stack) != 1 || i == 0 {
+		return false
+	}
+	switch prev := g.toks[i-1]; {
+	case prev.is("...") || prev.is("@"):
+		// fragment spread or directive
+		return false
+	case prev.is("on") && i >= 2 && g.toks[i-2].is("..."):
+		// type condition of an inline fragment
+		return false
+	}
+	return true
+}
+
+// addField adds name to the top-level fields of the first operation.
+func (g *graphqlObfuscator) addField(name string) {
+	if g.seenFld[name] {
+		return
+	}
+	if g.seenFld == nil {
+		g.seenFld = make(map[string]bool)
+	}
+	g.seenFld[name] = true
+	g.fields = append(g.fields, name)
+}
+
+// top returns the innermost open section, or nil if there is none.
+func (g *graphqlObfuscator) top() *graphqlFrame {
+	if len(g.stack) == 0 {
+		return nil
+	}
+	return &g.stack[len(g.stack)-1]
+}
+
+// push opens a section of the given scope, whose opening bracket was just written.
+func (g *graphqlObfuscator) push(scope graphqlScope) {
+	g.stack = append(g.stack, graphqlFrame{scope: scope, start: len(g.out) - 1})
+}
+
+// pop closes the innermost section. It returns false if there is none.
+func (g *graphqlObfuscator) pop() (graphqlFrame, bool) {
+	if len(g.stack) == 0 {
+		return graphqlFrame{}, false
+	}
+	f := g.stack[len(g.stack)-1]
+	g.stack = g.stack[:len(g.stack)-1]
+	return f, true
+}
+
+// writeItem writes s, which starts a new argument, object field or variable definition of f.
+func (g *graphqlObfuscator) writeItem(f *graphqlFrame, s string) {
+	if f.items > 0 {
+		g.write(",")
+	}
+	f.items++
+	g.write(s)
+}
+
+// writeValue writes the value s. literal reports whether it is a literal.
+func (g *graphqlObfuscator) writeValue(s string, literal bool) {
+	if !g.value {
+		// not a value, such as a description
+		g.write(s)
+		return
+	}
+	g.beginValue()
+	g.write(s)
+	g.endValue(literal)
+}
+
+// beginValue starts writing a value.
+func (g *graphqlObfuscator) beginValue() {
+	if top := g.top(); top != nil && top.scope == graphqlListValue {
+		if top.items > 0 {
+			g.write(",")
+		}
+		top.items++
+	}
+}
+
+// endValue finishes writing a value. literal reports whether it is a literal.
+func (g *graphqlObfuscator) endValue(literal bool) {
+	top := g.top()
+	if top == nil || top.scope != graphqlListValue {
+		g.value = false
+		return
+	}
+	// the next list item is also a value
+	top.literals = top.literals && literal
+	g.value = true
+}
+
+// write writes the token s to the output, preceded by a space when needed.
+func (g *graphqlObfuscator) write(s string) {
+	if len(g.out) > 0 && graphqlNeedsSpace(g.last, s) {
+		g.out = append(g.out, ' ')
+	}
+	g.out = append(g.out, s...)
+	g.last = s
+}
+
+// graphqlNeedsSpace reports whether the tokens prev and next should be separated by a space.
+func graphqlNeedsSpace(prev, next string) bool {
+	switch prev {
+	case "(", "[", "@":
+		return false
+	case "...":
+		// "...Fragment", but "... on Type", "... @directive" and "... {"
+		return next == "on" || next == "@" || next == "{"
+	}
+	switch next {
+	case ")", "]", ":", "!", ",", "(":
+		return false
+	}
+	return true
+}
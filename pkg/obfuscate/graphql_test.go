@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObfuscateGraphQL(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, out string
+		meta          GraphQLMetadata
+	}{
+		{
+			name: "shorthand",
+			in:   `{ user(id: 4) { name } }`,
+			out:  `{ user(id: ?) { name } }`,
+			meta: GraphQLMetadata{OperationType: "query", FieldsCSV: "user"},
+		},
+		{
+			name: "literals",
+			in: `query GetUser {
+				user(id: "4", active: true, score: -1.5e3, deleted: null, role: ADMIN) {
+					name
+					friends(first: 10) { name }
+				}
+			}`,
+			out:  `query GetUser { user(id: ?, active: ?, score: ?, deleted: ?, role: ADMIN) { name friends(first: ?) { name } } }`,
+			meta: GraphQLMetadata{OperationType: "query", OperationName: "GetUser", FieldsCSV: "user"},
+		},
+		{
+			name: "variables",
+			in:   `query Q($id: ID!, $ids: [Int!]! = [1, 2, 3], $f: Filter = {status: "open", tags: ["a"]}) { node(id: $id) { id } nodes(ids: $ids, filter: $f) { id } }`,
+			out:  `query Q($id: ID!, $ids: [Int!]! = [?], $f: Filter = { status: ?, tags: [?] }) { node(id: $id) { id } nodes(ids: $ids, filter: $f) { id } }`,
+			meta: GraphQLMetadata{OperationType: "query", OperationName: "Q", FieldsCSV: "node,nodes"},
+		},
+		{
+			name: "lists",
+			in:   `{ a(x: [], y: [[1, 2], [3]], z: [RED, GREEN], w: [$v, 1], o: [{k: 1}, {k: 2}]) }`,
+			out:  `{ a(x: [], y: [?], z: [RED, GREEN], w: [$v, ?], o: [{ k: ? }, { k: ? }]) }`,
+			meta: GraphQLMetadata{OperationType: "query", FieldsCSV: "a"},
+		},
+		{
+			name: "strings",
+			in: `mutation { login(password: "s3cr\"et", note: """multi "quoted"
+line \""" text""") { token } }`,
+			out:  `mutation { login(password: ?, note: ?) { token } }`,
+			meta: GraphQLMetadata{OperationType: "mutation", FieldsCSV: "login"},
+		},
+		{
+			name: "comments and commas",
+			in: `# fetch the user
+			query   Q { # trailing
+				a,,b , c
+			}`,
+			out:  `query Q { a b c }`,
+			meta: GraphQLMetadata{OperationType: "query", OperationName: "Q", FieldsCSV: "a,b,c"},
+		},
+		{
+			name: "aliases",
+			in:   `query { me: user(id: 1) { n: name } them: user(id: 2) { name } }`,
+			out:  `query { me: user(id: ?) { n: name } them: user(id: ?) { name } }`,
+			meta: GraphQLMetadata{OperationType: "query", FieldsCSV: "user"},
+		},
+		{
+			name: "fragments and directives",
+			in: `subscription OnEvent($skip: Boolean!) @live(throttle: 100) {
+				...Base
+				... on Event @include(if: true) { id }
+				event(kind: "x") @skip(if: $skip) { ...Details }
+			}
+			fragment Details on Event { payload(limit: 5) }`,
+			out:  `subscription OnEvent($skip: Boolean!) @live(throttle: ?) { ...Base ... on Event @include(if: ?) { id } event(kind: ?) @skip(if: $skip) { ...Details } } fragment Details on Event { payload(limit: ?) }`,
+			meta: GraphQLMetadata{OperationType: "subscription", OperationName: "OnEvent", FieldsCSV: "event"},
+		},
+		{
+			name: "multiple operations",
+			in:   `query A { a } mutation B { b(x: 1) }`,
+			out:  `query A { a } mutation B { b(x: ?) }`,
+			meta: GraphQLMetadata{OperationType: "query", OperationName: "A", FieldsCSV: "a"},
+		},
+		{
+			name: "fragment first",
+			in:   `fragment F on User { id(x: 1) } query { ...F me { id } }`,
+			out:  `fragment F on User { id(x: ?) } query { ...F me { id } }`,
+			meta: GraphQLMetadata{OperationType: "query", FieldsCSV: "me"},
+		},
+		{
+			name: "not a document",
+			in:   `GetUser`,
+			out:  `GetUser`,
+		},
+		{
+			name: "unbalanced",
+			in:   `query { a(x: 1) } } ] b("c"`,
+			out:  `query { a(x: ?) } }] b(?`,
+			meta: GraphQLMetadata{OperationType: "query", FieldsCSV: "a"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewObfuscator(Config{})
+			oq, err := o.ObfuscateGraphQLString(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, oq.Query)
+			assert.Equal(t, tt.meta, oq.Metadata)
+		})
+	}
+
+	t.Run("remove-aliases", func(t *testing.T) {
+		o := NewObfuscator(Config{GraphQL: GraphQLConfig{RemoveAliases: true}})
+		oq, err := o.ObfuscateGraphQLString(`query { me: user(id: 1) { n: name } them: user(id: 2) { name } }`)
+		require.NoError(t, err)
+		assert.Equal(t, `query { user(id: ?) { name } user(id: ?) { name } }`, oq.Query)
+		assert.Equal(t, "user", oq.Metadata.FieldsCSV)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		o := NewObfuscator(Config{})
+		for _, in := range []string{
+			`{ user(name: "unterminated) { id } }`,
+			`{ user(name: """unterminated) { id } }`,
+			"{ user(name: \"new\nline\") { id } }",
+			`{ user(id: 1.) { id } }`,
+			`{ user(id: 12abc) { id } }`,
+			`{ user(id: $) { id } }`,
+			`{ user(id: ;) { id } }`,
+			`{ ..user }`,
+		} {
+			_, err := o.ObfuscateGraphQLString(in)
+			assert.Error(t, err, in)
+		}
+	})
+}
+
+func BenchmarkObfuscateGraphQL(b *testing.B) {
+	o := NewObfuscator(Config{})
+	query := `query GetUser($id: ID!) { user(id: $id) { name friends(first: 10, after: "abc") { edges { node { name tags(in: [1, 2, 3]) } } } } }`
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := o.ObfuscateGraphQLString(query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
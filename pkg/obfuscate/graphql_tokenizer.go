@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graphqlTokenKind specifies the kind of a token returned by the GraphQL tokenizer.
+type graphqlTokenKind int
+
+const (
+	// graphqlPunctuator is one of the punctuators ! ( ) ... : = @ [ ] { | } &
+	graphqlPunctuator graphqlTokenKind = iota
+
+	// graphqlName is a name, such as a field, an argument or a keyword.
+	graphqlName
+
+	// graphqlVariable is a variable, including its leading "$".
+	graphqlVariable
+
+	// graphqlNumber is an integer or a float value.
+	graphqlNumber
+
+	// graphqlString is a string or a block string value.
+	graphqlString
+)
+
+// String implements fmt.Stringer.
+func (k graphqlTokenKind) String() string {
+	return map[graphqlTokenKind]string{
+		graphqlPunctuator: "punctuator",
+		graphqlName:       "name",
+		graphqlVariable:   "variable",
+		graphqlNumber:     "number",
+		graphqlString:     "string",
+	}[k]
+}
+
+// graphqlToken is a token of a GraphQL document.
+type graphqlToken struct {
+	kind graphqlTokenKind
+	text string
+}
+
+// is reports whether t is the punctuator or the name s.
+func (t graphqlToken) is(s string) bool {
+	return (t.kind == graphqlPunctuator || t.kind == graphqlName) && t.text == s
+}
+
+// graphqlTokenizer splits a GraphQL document into tokens, as described by the lexical
+// grammar of the specification: https://spec.graphql.org/October2021/#sec-Language.Source-Text
+// Ignored tokens (whitespace, line terminators, commas and comments) are skipped.
+type graphqlTokenizer struct {
+	data string
+	off  int
+}
+
+// tokenizeGraphQL returns the tokens of the GraphQL document query.
+func tokenizeGraphQL(query string) ([]graphqlToken, error) {
+	t := graphqlTokenizer{data: query}
+	var toks []graphqlToken
+	for {
+		tok, ok, err := t.scan()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return toks, nil
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// scan returns the next token. It returns false once the end of the document
+// has been reached.
+func (t *graphqlTokenizer) scan() (tok graphqlToken, ok bool, err error) {
+	t.skipIgnored()
+	if t.off >= len(t.data) {
+		return graphqlToken{}, false, nil
+	}
+	start := t.off
+	switch ch := t.data[t.off]; {
+	case strings.IndexByte("!()[]{}:=@|&", ch) != -1:
+		t.off++
+		return graphqlToken{kind: graphqlPunctuator, text: t.data[start:t.off]}, true, nil
+	case ch == '.':
+		if !strings.HasPrefix(t.data[t.off:], "...") {
+			return graphqlToken{}, false, fmt.Errorf("unexpected character %q at position %d", ch, start)
+		}
+		t.off += 3
+		return graphqlToken{kind: graphqlPunctuator, text: "..."}, true, nil
+	case ch == '$':
+		t.off++
+		t.skipIgnored()
+		if t.off >= len(t.data) || !isGraphQLNameStart(t.data[t.off]) {
+			return graphqlToken{}, false, fmt.Errorf("expected variable name at position %d", t.off)
+		}
+		return graphqlToken{kind: graphqlVariable, text: "$" + t.scanName()}, true, nil
+	case isGraphQLNameStart(ch):
+		return graphqlToken{kind: graphqlName, text: t.scanName()}, true, nil
+	case ch == '-' || isDigit(rune(ch)):
+		if err := t.scanNumber(); err != nil {
+			return graphqlToken{}, false, err
+		}
+		return graphqlToken{kind: graphqlNumber, text: t.data[start:t.off]}, true, nil
+	case ch == '"':
+		if err := t.scanString(); err != nil {
+			return graphqlToken{}, false, err
+		}
+		return graphqlToken{kind: graphqlString, text: t.data[start:t.off]}, true, nil
+	default:
+		return graphqlToken{}, false, fmt.Errorf("unexpected character %q at position %d", ch, start)
+	}
+}
+
+// skipIgnored advances past whitespace, line terminators, commas, comments and
+// byte order marks.
+func (t *graphqlTokenizer) skipIgnored() {
+	for t.off < len(t.data) {
+		switch t.data[t.off] {
+		case ' ', '\t', '\n', '\r', ',':
+			t.off++
+		case '#':
+			for t.off < len(t.data) && t.data[t.off] != '\n' && t.data[t.off] != '\r' {
+				t.off++
+			}
+		default:
+			if strings.HasPrefix(t.data[t.off:], "\ufeff") {
+				t.off += len("\ufeff")
+				continue
+			}
+			return
+		}
+	}
+}
+
+// scanName scans a name, which must start at the current offset.
+func (t *graphqlTokenizer) scanName() string {
+	start := t.off
+	for t.off < len(t.data) && (isGraphQLNameStart(t.data[t.off]) || isDigit(rune(t.data[t.off]))) {
+		t.off++
+	}
+	return t.data[start:t.off]
+}
+
+// scanNumber scans an integer or a float value, which must start at the current offset.
+func (t *graphqlTokenizer) scanNumber() error {
+	start := t.off
+	if t.data[t.off] == '-' {
+		t.off++
+	}
+	if !t.scanDigits() {
+		return fmt.Errorf("invalid number at position %d", start)
+	}
+	if t.off < len(t.data) && t.data[t.off] == '.' {
+		t.off++
+		if !t.scanDigits() {
+			return fmt.Errorf("invalid number at position %d", start)
+		}
+	}
+	if t.off < len(t.data) && (t.data[t.off] == 'e' || t.data[t.off] == 'E') {
+		t.off++
+		if t.off < len(t.data) && (t.data[t.off] == '+' || t.data[t.off] == '-') {
+			t.off++
+		}
+		if !t.scanDigits() {
+			return fmt.Errorf("invalid number at position %d", start)
+		}
+	}
+	if t.off < len(t.data) && (isGraphQLNameStart(t.data[t.off]) || t.data[t.off] == '.') {
+		return fmt.Errorf("invalid number at position %d", start)
+	}
+	return nil
+}
+
+// scanDigits advances past a sequence of digits and reports whether it was not empty.
+func (t *graphqlTokenizer) scanDigits() bool {
+	start := t.off
+	for t.off < len(t.data) && isDigit(rune(t.data[t.off])) {
+		t.off++
+	}
+	return t.off > start
+}
+
+// scanString scans a string or a block string, which must start at the current offset.
+func (t *graphqlTokenizer) scanString() error {
+	start := t.off
+	if strings.HasPrefix(t.data[t.off:], `"""`) {
+		t.off += 3
+		for t.off < len(t.data) {
+			switch {
+			case strings.HasPrefix(t.data[t.off:], `\"""`):
+				t.off += 4
+			case strings.HasPrefix(t.data[t.off:], `"""`):
+				t.off += 3
+				return nil
+			default:
+				t.off++
+			}
+		}
+		return fmt.Errorf("unterminated block string at position %d", start)
+	}
+	t.off++
+	for t.off < len(t.data) {
+		switch t.data[t.off] {
+		case '\\':
+			t.off += 2
+		case '"':
+			t.off++
+			return nil
+		case '\n', '\r':
+			return fmt.Errorf("unterminated string at position %d", start)
+		default:
+			t.off++
+		}
+	}
+	return fmt.Errorf("unterminated string at position %d", start)
+}
+
+// isGraphQLNameStart reports whether ch can start a GraphQL name.
+func isGraphQLNameStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
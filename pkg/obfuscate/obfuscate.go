@@ -82,6 +82,9 @@ type Config struct {
 	// HTTP holds the obfuscation settings for HTTP URLs.
 	HTTP HTTPConfig
 
+	// GraphQL holds the obfuscation settings for GraphQL queries.
+	GraphQL GraphQLConfig
+
 	// Statsd specifies the statsd client to use for reporting metrics.
 	Statsd StatsClient
 
@@ -118,6 +121,10 @@ type SQLConfig struct {
 	// KeepSQLAlias reports whether SQL aliases ("AS") should be truncated.
 	KeepSQLAlias bool `json:"keep_sql_alias"`
 
+	// Signature specifies whether the obfuscator should compute the signature of the query and
+	// return it as SQL metadata when obfuscating.
+	Signature bool `json:"signature"`
+
 	// DollarQuotedFunc reports whether to treat "$func$" delimited dollar-quoted strings
 	// differently and not obfuscate them as a string. To read more about dollar quoted
 	// strings see:
@@ -141,6 +148,10 @@ type SQLMetadata struct {
 	Commands []string `json:"commands"`
 	// Comments holds comments in an SQL statement.
 	Comments []string `json:"comments"`
+	// Signature holds a hash of the normalized obfuscated query, identifying the queries
+	// which only differ by their literals, IN lists, whitespace, case and AS aliases. It is
+	// zero unless enabled.
+	Signature uint64 `json:"signature"`
 }
 
 // HTTPConfig holds the configuration settings for HTTP obfuscation.
@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PIIDetector finds a kind of personally identifiable information (PII), such as email
+// addresses or phone numbers, in strings.
+type PIIDetector struct {
+	name string
+	re   *regexp.Regexp
+	// bounded reports whether matches must not be surrounded by letters or digits.
+	bounded bool
+	// hint, when set, reports whether s may contain a match. It is used to skip
+	// running the regular expression.
+	hint func(s string) bool
+	// valid, when set, reports whether a match is valid, for example by verifying
+	// its checksum.
+	valid func(match string) bool
+}
+
+// builtinPIIDetectors holds the built-in detectors, by name.
+var builtinPIIDetectors = map[string]*PIIDetector{
+	"email": {
+		name: "email",
+		// "%40" is the URL encoded "@"
+		re: regexp.MustCompile(`[A-Za-z0-9._+\-]+(?:@|%40)[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		hint: func(s string) bool {
+			return strings.IndexByte(s, '@') != -1 || strings.Contains(s, "%40")
+		},
+	},
+	"phone": {
+		name:    "phone",
+		re:      regexp.MustCompile(`\+[1-9]\d{7,14}|(?:\+\d{1,3}[ .\-]?)?(?:\(\d{3}\)|\d{3})[ .\-]?\d{3}[ .\-]\d{4}`),
+		bounded: true,
+	},
+	"iban": {
+		name:    "iban",
+		re:      regexp.MustCompile(`[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?`),
+		bounded: true,
+		valid:   ibanValid,
+	},
+	"jwt": {
+		name: "jwt",
+		// the header and the payload are base64 encoded JSON objects, starting with `{"`
+		re:      regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+		bounded: true,
+		hint:    func(s string) bool { return strings.Contains(s, "eyJ") },
+	},
+	"aws_access_key": {
+		name:    "aws_access_key",
+		re:      regexp.MustCompile(`(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z2-7]{16}`),
+		bounded: true,
+	},
+	"us_ssn": {
+		name:    "us_ssn",
+		re:      regexp.MustCompile(`\d{3}-\d{2}-\d{4}`),
+		bounded: true,
+		valid:   ssnValid,
+	},
+	"uk_nino": {
+		name:    "uk_nino",
+		re:      regexp.MustCompile(`[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]`),
+		bounded: true,
+		valid:   ninoValid,
+	},
+}
+
+// BuiltinPIIDetectorNames returns the names of the built-in PII detectors.
+func BuiltinPIIDetectorNames() []string {
+	return []string{"email", "phone", "iban", "jwt", "aws_access_key", "us_ssn", "uk_nino"}
+}
+
+// BuiltinPIIDetector returns the built-in PII detector with the given name. It returns
+// false if there is none.
+func BuiltinPIIDetector(name string) (*PIIDetector, bool) {
+	d, ok := builtinPIIDetectors[name]
+	return d, ok
+}
+
+// NewPIIDetector returns a PII detector named name, matching the regular expression re.
+func NewPIIDetector(name string, re *regexp.Regexp) *PIIDetector {
+	return &PIIDetector{name: name, re: re}
+}
+
+// Name returns the name of the detector.
+func (d *PIIDetector) Name() string { return d.name }
+
+// Redact replaces the PII found in s with "?". It returns the resulting string, along
+// with the number of replacements.
+func (d *PIIDetector) Redact(s string) (string, int) {
+	if d.hint != nil && !d.hint(s) {
+		return s, 0
+	}
+	matches := d.re.FindAllStringIndex(s, -1)
+	if len(matches) == 0 {
+		return s, 0
+	}
+	var (
+		b    strings.Builder
+		last int
+		n    int
+	)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if d.bounded && (start > 0 && isAlphaNum(s[start-1]) || end < len(s) && isAlphaNum(s[end])) {
+			continue
+		}
+		if d.valid != nil && !d.valid(s[start:end]) {
+			continue
+		}
+		if n == 0 {
+			b.Grow(len(s))
+		}
+		b.WriteString(s[last:start])
+		b.WriteByte('?')
+		last = end
+		n++
+	}
+	if n == 0 {
+		return s, 0
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// isAlphaNum reports whether ch is an ASCII letter or digit.
+func isAlphaNum(ch byte) bool {
+	return isDigit(rune(ch)) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// ibanValid reports whether iban, which may contain spaces, has a valid length and
+// ISO 7064 mod 97-10 checksum.
+func ibanValid(iban string) bool {
+	iban = strings.Replace(iban, " ", "", -1)
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	// move the country code and the check digits to the end, convert the letters
+	// to numbers (A = 10, ..., Z = 35) and compute the remainder of the division by 97
+	// digit by digit
+	rem := 0
+	for _, ch := range []byte(iban[4:] + iban[:4]) {
+		switch {
+		case isDigit(rune(ch)):
+			rem = (rem*10 + int(ch-'0')) % 97
+		case ch >= 'A' && ch <= 'Z':
+			rem = (rem*100 + int(ch-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// ssnValid reports whether ssn, of the form AAA-GG-SSSS, is a US Social Security number
+// which may have been issued: the area can not be 000, 666 or 9XX, the group can not
+// be 00 and the serial can not be 0000.
+func ssnValid(ssn string) bool {
+	area, group, serial := ssn[:3], ssn[4:6], ssn[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// ninoValid reports whether nino is a UK National Insurance number which may have been
+// allocated, excluding the administrative prefixes.
+func ninoValid(nino string) bool {
+	switch nino[:2] {
+	case "BG", "GB", "KN", "NK", "NT", "TN", "ZZ":
+		return false
+	}
+	return true
+}
@@ -9,6 +9,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -57,9 +59,9 @@ func (f *metadataFinderFilter) Filter(token, lastToken TokenKind, buffer []byte)
 			// SELECT ... FROM [tableName]
 			// DELETE FROM [tableName]
 			// ... JOIN [tableName]
-			if r, _ := utf8.DecodeRune(buffer); !unicode.IsLetter(r) {
-				// first character in buffer is not a letter; we might have a nested
-				// query like SELECT * FROM (SELECT ...)
+			if r, _ := utf8.DecodeRune(buffer); !unicode.IsLetter(r) && !(token == ID && r == '[') {
+				// first character in buffer is not a letter nor the bracket of an SQL Server
+				// identifier; we might have a nested query like SELECT * FROM (SELECT ...)
 				break
 			}
 			fallthrough
@@ -285,6 +287,55 @@ func (f *groupingFilter) Reset() {
 	f.groupMulti = 0
 }
 
+// signatureFilter is a token filter which computes the signature of a query by hashing its
+// obfuscated tokens, lower-cased and separated by single spaces, leaving out the AS aliases.
+// It is meant to run after all the other filters, and does not change the tokens.
+type signatureFilter struct {
+	h hash.Hash64
+	// alias reports whether the next token is an alias, following AS.
+	alias bool
+	// empty reports whether nothing was hashed yet.
+	empty bool
+}
+
+func newSignatureFilter() *signatureFilter {
+	return &signatureFilter{h: fnv.New64a(), empty: true}
+}
+
+// Filter implements tokenFilter.
+func (f *signatureFilter) Filter(token, lastToken TokenKind, buffer []byte) (TokenKind, []byte, error) {
+	switch {
+	case token == As:
+		f.alias = true
+		return token, buffer, nil
+	case f.alias:
+		f.alias = false
+		return token, buffer, nil
+	case lastToken == FilteredBracketedIdentifier:
+		// closing bracket of an alias, e.g. AS [alias]
+		return token, buffer, nil
+	}
+	// the buffer may hold several words, e.g. when a group is cancelled
+	for _, w := range bytes.Fields(buffer) {
+		if !f.empty {
+			f.h.Write([]byte{' '})
+		}
+		f.h.Write(bytes.ToLower(w))
+		f.empty = false
+	}
+	return token, buffer, nil
+}
+
+// Sum returns the signature of the query.
+func (f *signatureFilter) Sum() uint64 { return f.h.Sum64() }
+
+// Reset implements tokenFilter.
+func (f *signatureFilter) Reset() {
+	f.h.Reset()
+	f.alias = false
+	f.empty = true
+}
+
 // ObfuscateSQLString quantizes and obfuscates the given input SQL query string. Quantization removes
 // some elements such as comments and aliases and obfuscation attempts to hide sensitive information
 // in strings and numbers by redacting them.
@@ -296,17 +347,33 @@ func (o *Obfuscator) ObfuscateSQLString(in string) (*ObfuscatedQuery, error) {
 // to quantize and obfuscate the given input SQL query string. Quantization removes some elements such as comments
 // and aliases and obfuscation attempts to hide sensitive information in strings and numbers by redacting them.
 func (o *Obfuscator) ObfuscateSQLStringWithOptions(in string, opts *SQLConfig) (*ObfuscatedQuery, error) {
-	if v, ok := o.queryCache.Get(in); ok {
+	key := in
+	if opts.DBMS != o.opts.SQL.DBMS {
+		// the same query may be tokenized differently for another DBMS
+		key = opts.DBMS + ":" + in
+	}
+	if v, ok := o.queryCache.Get(key); ok {
 		return v.(*ObfuscatedQuery), nil
 	}
 	oq, err := o.obfuscateSQLString(in, opts)
 	if err != nil {
 		return oq, err
 	}
-	o.queryCache.Set(in, oq, oq.Cost())
+	o.queryCache.Set(key, oq, oq.Cost())
 	return oq, nil
 }
 
+// ObfuscateSQLStringForDBMS quantizes and obfuscates the given input SQL query string like ObfuscateSQLString,
+// using the tokenizing rules of the given DBMS instead of the configured one, unless dbms is empty.
+func (o *Obfuscator) ObfuscateSQLStringForDBMS(in string, dbms string) (*ObfuscatedQuery, error) {
+	if dbms == "" {
+		return o.ObfuscateSQLString(in)
+	}
+	opts := o.opts.SQL
+	opts.DBMS = dbms
+	return o.ObfuscateSQLStringWithOptions(in, &opts)
+}
+
 func (o *Obfuscator) obfuscateSQLString(in string, opts *SQLConfig) (*ObfuscatedQuery, error) {
 	lesc := o.useSQLLiteralEscapes()
 	tok := NewSQLTokenizer(in, lesc, opts)
@@ -351,9 +418,13 @@ func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
 		}
 		discard  = discardFilter{keepSQLAlias: tokenizer.cfg.KeepSQLAlias}
 		replace  = replaceFilter{replaceDigits: tokenizer.cfg.ReplaceDigits}
-		grouping groupingFilter
+		grouping  groupingFilter
+		signature *signatureFilter
 	)
 	defer metadata.Reset()
+	if tokenizer.cfg.Signature {
+		signature = newSignatureFilter()
+	}
 	// call Scan() function until tokens are available or if a LEX_ERROR is raised. After
 	// retrieving a token, send it to the tokenFilter chains so that the token is discarded
 	// or replaced.
@@ -378,6 +449,11 @@ func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
 		if token, buff, err = grouping.Filter(token, lastToken, buff); err != nil {
 			return nil, err
 		}
+		if signature != nil {
+			if token, buff, err = signature.Filter(token, lastToken, buff); err != nil {
+				return nil, err
+			}
+		}
 		if buff != nil {
 			if out.Len() != 0 {
 				switch token {
@@ -400,10 +476,15 @@ func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
 	if out.Len() == 0 {
 		return nil, errors.New("result is empty")
 	}
-	return &ObfuscatedQuery{
+	oq := &ObfuscatedQuery{
 		Query:    out.String(),
 		Metadata: metadata.Results(),
-	}, nil
+	}
+	if signature != nil {
+		oq.Metadata.Signature = signature.Sum()
+		oq.Metadata.Size += 8
+	}
+	return oq, nil
 }
 
 // ObfuscateSQLExecPlan obfuscates query conditions in the provided JSON encoded execution plan. If normalize=True,
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"strconv"
 	"testing"
 
@@ -138,6 +139,86 @@ func TestScanDollarQuotedString(t *testing.T) {
 	})
 }
 
+func TestSQLSignature(t *testing.T) {
+	signature := func(t *testing.T, cfg SQLConfig, in string) uint64 {
+		cfg.Signature = true
+		oq, err := NewObfuscator(Config{SQL: cfg}).ObfuscateSQLString(in)
+		require.NoError(t, err)
+		return oq.Metadata.Signature
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		h := fnv.New64a()
+		h.Write([]byte("select * from users where id in ( ? )"))
+		assert.Equal(t, h.Sum64(), signature(t, SQLConfig{}, "SELECT * FROM users WHERE id IN (1, 2, 3)"))
+	})
+
+	for _, tt := range []struct {
+		cfg  SQLConfig
+		same []string
+	}{
+		{
+			same: []string{
+				"SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'jane'",
+				"select *\n  from Users\twhere ID in (4,5) and NAME = 'john';",
+				"/* controller:users */ SELECT * FROM users WHERE id IN ($1, $2) AND name = $3",
+				"SELECT * FROM users WHERE id IN (?) AND name = ?",
+			},
+		},
+		{
+			same: []string{
+				"SELECT u.name AS n, COUNT(*) AS total FROM users u GROUP BY u.name",
+				"SELECT u.name, COUNT(*) FROM users u GROUP BY u.name",
+				"SELECT u.name AS [user name], COUNT(*) AS \"total\" FROM users u GROUP BY u.name",
+			},
+		},
+		{
+			cfg: SQLConfig{KeepSQLAlias: true},
+			same: []string{
+				"SELECT u.name AS n, COUNT(*) AS total FROM users u GROUP BY u.name",
+				"SELECT u.name, COUNT(*) FROM users u GROUP BY u.name",
+			},
+		},
+		{
+			same: []string{
+				"INSERT INTO orders (id, total) VALUES (1, 9.99), (2, 19.99)",
+				"INSERT INTO orders (id, total) VALUES (3, 0.5)",
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			want := signature(t, tt.cfg, tt.same[0])
+			assert.NotZero(t, want)
+			for _, in := range tt.same[1:] {
+				assert.Equal(t, want, signature(t, tt.cfg, in), in)
+			}
+		})
+	}
+
+	t.Run("different", func(t *testing.T) {
+		seen := make(map[uint64]string)
+		for _, in := range []string{
+			"SELECT * FROM users",
+			"SELECT * FROM orders",
+			"SELECT id FROM users",
+			"DELETE FROM users",
+			"SELECT * FROM users WHERE id = ?",
+			"SELECT * FROM users WHERE id > ?",
+		} {
+			sig := signature(t, SQLConfig{}, in)
+			assert.NotContains(t, seen, sig, in)
+			seen[sig] = in
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		oq, err := NewObfuscator(Config{}).ObfuscateSQLString("SELECT * FROM users")
+		require.NoError(t, err)
+		assert.Zero(t, oq.Metadata.Signature)
+		assert.Zero(t, oq.Metadata.Size)
+	})
+}
+
 func TestSQLMetadata(t *testing.T) {
 	assert := assert.New(t)
 	for _, tt := range []struct {
@@ -1270,6 +1351,140 @@ func TestObfuscatorDBMSBehavior(t *testing.T) {
 	}
 }
 
+// dialectTestCase is a query of a SQL dialect with its expected obfuscation, or an expected error
+// when out is empty.
+type dialectTestCase struct {
+	in, out string
+}
+
+func testSQLDialect(t *testing.T, dbms string, cases []dialectTestCase) {
+	for _, tt := range cases {
+		t.Run("", func(t *testing.T) {
+			oq, err := NewObfuscator(Config{SQL: SQLConfig{DBMS: dbms}}).ObfuscateSQLString(tt.in)
+			if tt.out == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, oq.Query)
+		})
+	}
+}
+
+func TestSQLServerDialect(t *testing.T) {
+	testSQLDialect(t, DBMSSQLServer, []dialectTestCase{
+		{
+			"SELECT [user name], [o].[id] FROM [dbo].[users] WHERE [name] = 'bob'",
+			"SELECT [user name], [o].[id] FROM [dbo].[users] WHERE [name] = ?",
+		},
+		{
+			"SELECT [b].[BlogId] AS [Blog Id] FROM [Blogs] AS [b] ORDER BY [b].[Name]",
+			"SELECT [b].[BlogId] FROM [Blogs] ORDER BY [b].[Name]",
+		},
+		{
+			"SELECT * FROM [it's a table] WHERE [weird]]name] = 1",
+			"SELECT * FROM [it's a table] WHERE [weird]]name] = ?",
+		},
+		{
+			"SELECT * FROM [mydb]..[users] JOIN dbo.[orders] ON 1 = 1",
+			"SELECT * FROM [mydb]..[users] JOIN dbo.[orders] ON ? = ?",
+		},
+		{
+			"UPDATE [dbo].[users] SET name = N'Bob' WHERE alias = n'bobby'",
+			"UPDATE [dbo].[users] SET name = ? WHERE alias = ?",
+		},
+		{
+			"INSERT INTO #tmp VALUES (N'a', N'b'), (N'c', 'd')",
+			"INSERT INTO #tmp VALUES ( ? )",
+		},
+		{
+			"SELECT N FROM t WHERE N = 1",
+			"SELECT N FROM t WHERE N = ?",
+		},
+		{
+			"SELECT * FROM [users WHERE id = 1",
+			"",
+		},
+	})
+}
+
+func TestOracleDialect(t *testing.T) {
+	testSQLDialect(t, DBMSOracle, []dialectTestCase{
+		{
+			"SELECT * FROM users WHERE name = q'[O'Brien]' AND id = :id",
+			"SELECT * FROM users WHERE name = ? AND id = :id",
+		},
+		{
+			"SELECT q'{a}'||Q'(b)'||q'<c>'||q'!it's!' FROM dual",
+			"SELECT ? | | ? | | ? | | ? FROM dual",
+		},
+		{
+			"SELECT * FROM t WHERE a = nq'[x]' AND b = N'y' AND c = 'z'",
+			"SELECT * FROM t WHERE a = ? AND b = ? AND c = ?",
+		},
+		{
+			`UPDATE users SET name = :"Name", email = :2 WHERE id = :1`,
+			`UPDATE users SET name = :"Name", email = :2 WHERE id = :1`,
+		},
+		{
+			"SELECT * FROM employees@remote_db WHERE q = 1",
+			"SELECT * FROM employees@remote_db WHERE q = ?",
+		},
+		{
+			"SELECT * FROM t WHERE name = q'[unterminated]",
+			"",
+		},
+		{
+			`SELECT * FROM t WHERE id = :"id`,
+			"",
+		},
+	})
+}
+
+func TestSnowflakeDialect(t *testing.T) {
+	testSQLDialect(t, DBMSSnowflake, []dialectTestCase{
+		{
+			"SELECT src:customer.name::string FROM events WHERE src:id = 42",
+			"SELECT src:customer.name :: string FROM events WHERE src:id = ?",
+		},
+		{
+			`SELECT src:"first name", src:items[0].price, src['key']."sub key" FROM events`,
+			`SELECT src:"first name", src:items[0].price, src['key']."sub key" FROM events`,
+		},
+		{
+			"SELECT * FROM events WHERE body = $$it's a 'quoted' value$$",
+			"SELECT * FROM events WHERE body = ?",
+		},
+		{
+			"SELECT $1, $2 FROM @my_stage/path/file.csv",
+			"SELECT ? FROM @my_stage/path/file.csv",
+		},
+		{
+			"COPY INTO events FROM @~/staged FILE_FORMAT = (TYPE = 'JSON')",
+			"COPY INTO events FROM @~/staged FILE_FORMAT = ( TYPE = ? )",
+		},
+		{
+			`SELECT src:"unterminated FROM events`,
+			"",
+		},
+	})
+}
+
+func TestObfuscateSQLStringForDBMS(t *testing.T) {
+	o := NewObfuscator(Config{SQL: SQLConfig{Cache: true}})
+	defer o.Stop()
+	query := "SELECT [it's] FROM t WHERE id = 1"
+
+	oq, err := o.ObfuscateSQLStringForDBMS(query, DBMSSQLServer)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT [it's] FROM t WHERE id = ?", oq.Query)
+	o.queryCache.Wait()
+
+	// the query obfuscated for SQL Server must not be served from the cache
+	_, err = o.ObfuscateSQLStringForDBMS(query, "")
+	assert.Error(t, err)
+}
+
 func TestSQLTokenizerIgnoreEscapeFalse(t *testing.T) {
 	cases := []sqlTokenizerTestCase{
 		{
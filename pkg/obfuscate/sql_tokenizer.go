@@ -7,6 +7,7 @@ package obfuscate
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"unicode"
 	"unicode/utf8"
@@ -177,6 +178,10 @@ const (
 	DBMSSQLServer = "mssql"
 	// DBMSPostgres is a PostgreSQL Server
 	DBMSPostgres = "postgresql"
+	// DBMSOracle is an Oracle Database
+	DBMSOracle = "oracle"
+	// DBMSSnowflake is a Snowflake data warehouse
+	DBMSSnowflake = "snowflake"
 )
 
 const escapeCharacter = '\\'
@@ -322,7 +327,13 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 				}
 			}
 			fallthrough
-		case '=', ',', ';', '(', ')', '+', '*', '&', '|', '^', '[', ']':
+		case '[':
+			if tkn.cfg.DBMS == DBMSSQLServer {
+				// SQL Server delimits identifiers with brackets, e.g. [user name]
+				return tkn.scanBracketedIdentifier()
+			}
+			fallthrough
+		case '=', ',', ';', '(', ')', '+', '*', '&', '|', '^', ']':
 			return TokenKind(ch), tkn.bytes()
 		case '.':
 			if isDigit(tkn.lastChar) {
@@ -574,10 +585,36 @@ func toUpper(src, dst []byte) []byte {
 
 func (tkn *SQLTokenizer) scanIdentifier() (TokenKind, []byte) {
 	tkn.advance()
+	if tkn.cfg.DBMS == DBMSSnowflake && tkn.buf[0] == '@' {
+		return tkn.scanStageReference()
+	}
 	for isLetter(tkn.lastChar) || isDigit(tkn.lastChar) || tkn.lastChar == '.' || tkn.lastChar == '*' {
 		tkn.advance()
 	}
 
+	switch tkn.cfg.DBMS {
+	case DBMSSQLServer:
+		if tkn.lastChar == '\'' && isNationalStringPrefix(tkn.buf[:tkn.off-1]) {
+			return tkn.scanPrefixedString()
+		}
+		if tkn.lastChar == '[' && tkn.buf[tkn.off-2] == '.' {
+			// a multi-part name continuing with a bracketed identifier, e.g. dbo.[users]
+			tkn.advance()
+			return tkn.scanBracketedIdentifier()
+		}
+	case DBMSOracle:
+		if tkn.lastChar == '\'' && (isNationalStringPrefix(tkn.buf[:tkn.off-1]) || isQuoteOperatorPrefix(tkn.buf[:tkn.off-1])) {
+			return tkn.scanPrefixedString()
+		}
+	case DBMSSnowflake:
+		if tkn.lastChar == ':' || tkn.lastChar == '[' || tkn.lastChar == '"' {
+			if err := tkn.scanSemiStructuredPath(); err != nil {
+				tkn.setErr("%v", err)
+				return LexError, tkn.bytes()
+			}
+		}
+	}
+
 	t := tkn.bytes()
 	// Space allows us to upper-case identifiers 256 bytes long or less without allocating heap
 	// storage for them, since space is allocated on the stack. A size of 256 bytes was chosen
@@ -590,6 +627,130 @@ func (tkn *SQLTokenizer) scanIdentifier() (TokenKind, []byte) {
 	return ID, t
 }
 
+// scanBracketedIdentifier scans an SQL Server identifier delimited by brackets, the opening bracket
+// having been consumed, along with the following parts of a multi-part name, e.g. [db].dbo.[table name].
+// A closing bracket is escaped by doubling it.
+func (tkn *SQLTokenizer) scanBracketedIdentifier() (TokenKind, []byte) {
+	for {
+		for {
+			if tkn.lastChar == EndChar {
+				tkn.setErr("unexpected EOF in bracketed identifier")
+				return LexError, tkn.bytes()
+			}
+			ch := tkn.lastChar
+			tkn.advance()
+			if ch == ']' {
+				if tkn.lastChar != ']' {
+					break
+				}
+				tkn.advance()
+			}
+		}
+		if tkn.lastChar != '.' {
+			break
+		}
+		// the next parts of the name, dots may be repeated when a part is omitted, e.g. [db]..[table]
+		for tkn.lastChar == '.' {
+			tkn.advance()
+		}
+		for isLetter(tkn.lastChar) || isDigit(tkn.lastChar) || tkn.lastChar == '.' || tkn.lastChar == '*' {
+			tkn.advance()
+		}
+		if tkn.lastChar != '[' {
+			break
+		}
+		tkn.advance()
+	}
+	return ID, tkn.bytes()
+}
+
+// scanPrefixedString scans a string literal starting with the prefix that was just scanned as an identifier:
+// a national character string like N'text', or an Oracle alternative quoting like q'[text]', the closing
+// delimiter of which is the opening one, or its counterpart for brackets.
+func (tkn *SQLTokenizer) scanPrefixedString() (TokenKind, []byte) {
+	prefix := tkn.buf[:tkn.off-1]
+	tkn.advance()
+	if !isQuoteOperatorPrefix(prefix) {
+		return tkn.scanString('\'', String)
+	}
+
+	delim := tkn.lastChar
+	switch delim {
+	case EndChar, '\'', ' ', '\t', '\n', '\r':
+		tkn.setErr(`invalid quote delimiter "%c" (%d)`, delim, delim)
+		return LexError, tkn.bytes()
+	case '[':
+		delim = ']'
+	case '{':
+		delim = '}'
+	case '(':
+		delim = ')'
+	case '<':
+		delim = '>'
+	}
+	tkn.advance()
+	for !(tkn.lastChar == delim && tkn.peek() == '\'') {
+		if tkn.lastChar == EndChar {
+			tkn.setErr("unexpected EOF in string")
+			return LexError, tkn.bytes()
+		}
+		tkn.advance()
+	}
+	tkn.advance()
+	tkn.advance()
+	t := tkn.bytes()
+	return String, t[len(prefix)+2 : len(t)-2]
+}
+
+// scanSemiStructuredPath scans the path following a Snowflake column of semi-structured data,
+// e.g. src:customer[0]."first name", which is kept as part of the identifier. A path can't
+// start with "::", which is a cast.
+func (tkn *SQLTokenizer) scanSemiStructuredPath() error {
+	for {
+		switch {
+		case tkn.lastChar == ':' && tkn.peek() != ':' && tkn.peek() != '=':
+			tkn.advance()
+			if tkn.lastChar == '"' {
+				continue
+			}
+			if !isLeadingLetter(tkn.lastChar) {
+				return fmt.Errorf(`unexpected char "%c" (%d) in semi-structured data path`, tkn.lastChar, tkn.lastChar)
+			}
+		case tkn.lastChar == '"' && (tkn.buf[tkn.off-2] == '.' || tkn.buf[tkn.off-2] == ':'):
+			tkn.advance()
+			for tkn.lastChar != '"' {
+				if tkn.lastChar == EndChar {
+					return errors.New("unexpected EOF in semi-structured data path")
+				}
+				tkn.advance()
+			}
+			tkn.advance()
+		case tkn.lastChar == '[':
+			for tkn.lastChar != ']' {
+				if tkn.lastChar == EndChar {
+					return errors.New("unexpected EOF in semi-structured data path")
+				}
+				tkn.advance()
+			}
+			tkn.advance()
+		default:
+			return nil
+		}
+		for isLetter(tkn.lastChar) || isDigit(tkn.lastChar) || tkn.lastChar == '.' {
+			tkn.advance()
+		}
+	}
+}
+
+// scanStageReference scans a Snowflake stage reference, the leading '@' having been consumed,
+// e.g. @my_stage/path/file.csv, @~/staged or @%table.
+func (tkn *SQLTokenizer) scanStageReference() (TokenKind, []byte) {
+	for tkn.lastChar != EndChar && !unicode.IsSpace(tkn.lastChar) && tkn.lastChar != ')' && tkn.lastChar != ',' && tkn.lastChar != ';' {
+		tkn.advance()
+	}
+	return ID, tkn.bytes()
+}
+
 func (tkn *SQLTokenizer) scanVariableIdentifier(prefix rune) (TokenKind, []byte) {
 	for tkn.advance(); tkn.lastChar != ')' && tkn.lastChar != EndChar; tkn.advance() {
 	}
@@ -694,6 +855,17 @@ func (tkn *SQLTokenizer) scanBindVar() (TokenKind, []byte) {
 		token = ListArg
 		tkn.advance()
 	}
+	if tkn.lastChar == '"' && tkn.cfg.DBMS == DBMSOracle {
+		// Oracle allows quoted bind variable names, e.g. :"Name"
+		for tkn.advance(); tkn.lastChar != '"'; tkn.advance() {
+			if tkn.lastChar == EndChar {
+				tkn.setErr("unexpected EOF in bind variable")
+				return LexError, tkn.bytes()
+			}
+		}
+		tkn.advance()
+		return token, tkn.bytes()
+	}
 	if !isLetter(tkn.lastChar) && !isDigit(tkn.lastChar) {
 		tkn.setErr(`bind variables should start with letters or digits, got "%c" (%d)`, tkn.lastChar, tkn.lastChar)
 		return LexError, tkn.bytes()
@@ -859,6 +1031,16 @@ func (tkn *SQLTokenizer) advance() {
 	tkn.lastChar = ch
 }
 
+// peek returns the rune following tkn.lastChar without advancing the tokenizer, or EndChar
+// if there is none.
+func (tkn *SQLTokenizer) peek() rune {
+	if tkn.off >= len(tkn.buf) {
+		return EndChar
+	}
+	ch, _ := utf8.DecodeRune(tkn.buf[tkn.off:])
+	return ch
+}
+
 // bytes returns all the bytes that were advanced over since its last call.
 // This excludes tkn.lastChar, which will remain in the buffer
 func (tkn *SQLTokenizer) bytes() []byte {
@@ -888,6 +1070,23 @@ func isLetter(ch rune) bool {
 	return isLeadingLetter(ch) || ch == '#'
 }
 
+// isNationalStringPrefix returns true if prefix introduces a national character string, e.g. N'text'.
+func isNationalStringPrefix(prefix []byte) bool {
+	return len(prefix) == 1 && (prefix[0] == 'N' || prefix[0] == 'n')
+}
+
+// isQuoteOperatorPrefix returns true if prefix introduces an Oracle alternative quoting, e.g. q'[text]'
+// or nq'[text]'.
+func isQuoteOperatorPrefix(prefix []byte) bool {
+	switch len(prefix) {
+	case 1:
+		return prefix[0] == 'q' || prefix[0] == 'Q'
+	case 2:
+		return (prefix[0] == 'n' || prefix[0] == 'N') && (prefix[1] == 'q' || prefix[1] == 'Q')
+	}
+	return false
+}
+
 func digitVal(ch rune) int {
 	switch {
 	case '0' <= ch && ch <= '9':
@@ -0,0 +1,271 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package metrics
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/richardartoul/molecule"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// constants for the protobuf data we will be writing, taken from
+// https://github.com/prometheus/prometheus/blob/v2.37.0/prompb/remote.proto and
+// https://github.com/prometheus/prometheus/blob/v2.37.0/prompb/types.proto
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+const (
+	remoteWriteNameLabel     = "__name__"
+	remoteWriteQuantileLabel = "quantile"
+)
+
+// remoteWriteQuantiles are the quantiles of the sketches sent as a Prometheus summary
+var remoteWriteQuantiles = []float64{0, 0.5, 0.9, 0.95, 0.99, 1}
+
+// remoteWriteLabel is a Prometheus label
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+// remoteWriteSample is a Prometheus sample, its timestamp being in milliseconds
+type remoteWriteSample struct {
+	value     float64
+	timestamp int64
+}
+
+// remoteWriteEncoder writes Prometheus time series to snappy-compressed remote-write payloads,
+// holding at most maxSeriesPerPayload time series each.
+type remoteWriteEncoder struct {
+	maxSeriesPerPayload int
+	buf                 bytes.Buffer
+	ps                  *molecule.ProtoStream
+	seriesCount         int
+	pointCount          int
+	payloads            transaction.BytesPayloads
+}
+
+func newRemoteWriteEncoder(maxSeriesPerPayload int) *remoteWriteEncoder {
+	e := &remoteWriteEncoder{maxSeriesPerPayload: maxSeriesPerPayload}
+	e.ps = molecule.NewProtoStream(&e.buf)
+	return e
+}
+
+func (e *remoteWriteEncoder) writeTimeSeries(labels []remoteWriteLabel, samples []remoteWriteSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	err := e.ps.Embedded(writeRequestTimeseries, func(ps *molecule.ProtoStream) error {
+		for _, label := range labels {
+			err := ps.Embedded(timeSeriesLabels, func(ps *molecule.ProtoStream) error {
+				if err := ps.String(labelName, label.name); err != nil {
+					return err
+				}
+				return ps.String(labelValue, label.value)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, sample := range samples {
+			err := ps.Embedded(timeSeriesSamples, func(ps *molecule.ProtoStream) error {
+				if err := ps.Double(sampleValue, sample.value); err != nil {
+					return err
+				}
+				return ps.Int64(sampleTimestamp, sample.timestamp)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	e.seriesCount++
+	e.pointCount += len(samples)
+	if e.maxSeriesPerPayload > 0 && e.seriesCount >= e.maxSeriesPerPayload {
+		e.flush()
+	}
+	return nil
+}
+
+// flush compresses the pending time series into a new payload
+func (e *remoteWriteEncoder) flush() {
+	if e.seriesCount == 0 {
+		return
+	}
+	compressed := snappy.Encode(nil, e.buf.Bytes())
+	e.payloads = append(e.payloads, transaction.NewBytesPayload(compressed, e.pointCount))
+	e.buf.Reset()
+	e.seriesCount = 0
+	e.pointCount = 0
+}
+
+func (e *remoteWriteEncoder) close() transaction.BytesPayloads {
+	e.flush()
+	return e.payloads
+}
+
+// MarshalSeriesRemoteWrite converts the series to snappy-compressed Prometheus remote-write
+// payloads, holding at most maxSeriesPerPayload time series each (no limit if 0).
+func MarshalSeriesRemoteWrite(series metrics.Series, maxSeriesPerPayload int) (transaction.BytesPayloads, error) {
+	e := newRemoteWriteEncoder(maxSeriesPerPayload)
+	for _, serie := range series {
+		samples := make([]remoteWriteSample, 0, len(serie.Points))
+		for _, point := range serie.Points {
+			samples = append(samples, remoteWriteSample{value: point.Value, timestamp: int64(point.Ts * 1000)})
+		}
+		labels := remoteWriteLabels(remoteWriteTagLabels(serie.Host, serie.Device, serie.Tags), serie.Name, "")
+		if err := e.writeTimeSeries(labels, samples); err != nil {
+			return nil, err
+		}
+	}
+	return e.close(), nil
+}
+
+// MarshalSketchesRemoteWrite converts the sketches to snappy-compressed Prometheus remote-write
+// payloads, holding at most maxSeriesPerPayload time series each (no limit if 0). Each sketch is
+// sent as a Prometheus summary: a time series per quantile, plus the `_sum` and `_count` ones.
+func MarshalSketchesRemoteWrite(sketches metrics.SketchSeriesList, maxSeriesPerPayload int) (transaction.BytesPayloads, error) {
+	e := newRemoteWriteEncoder(maxSeriesPerPayload)
+	config := quantile.Default()
+	for _, sketch := range sketches {
+		tagLabels := remoteWriteTagLabels(sketch.Host, "", sketch.Tags)
+		for _, q := range remoteWriteQuantiles {
+			samples := make([]remoteWriteSample, 0, len(sketch.Points))
+			for _, point := range sketch.Points {
+				samples = append(samples, remoteWriteSample{value: point.Sketch.Quantile(config, q), timestamp: point.Ts * 1000})
+			}
+			quantileLabel := strconv.FormatFloat(q, 'g', -1, 64)
+			if err := e.writeTimeSeries(remoteWriteLabels(tagLabels, sketch.Name, quantileLabel), samples); err != nil {
+				return nil, err
+			}
+		}
+
+		sums := make([]remoteWriteSample, 0, len(sketch.Points))
+		counts := make([]remoteWriteSample, 0, len(sketch.Points))
+		for _, point := range sketch.Points {
+			sums = append(sums, remoteWriteSample{value: point.Sketch.Basic.Sum, timestamp: point.Ts * 1000})
+			counts = append(counts, remoteWriteSample{value: float64(point.Sketch.Basic.Cnt), timestamp: point.Ts * 1000})
+		}
+		if err := e.writeTimeSeries(remoteWriteLabels(tagLabels, sketch.Name+"_sum", ""), sums); err != nil {
+			return nil, err
+		}
+		if err := e.writeTimeSeries(remoteWriteLabels(tagLabels, sketch.Name+"_count", ""), counts); err != nil {
+			return nil, err
+		}
+	}
+	return e.close(), nil
+}
+
+// remoteWriteLabels returns the sorted labels of a time series, from the labels of its tags, its
+// name and its quantile, if any.
+func remoteWriteLabels(tagLabels []remoteWriteLabel, name string, quantile string) []remoteWriteLabel {
+	labels := make([]remoteWriteLabel, 0, len(tagLabels)+2)
+	labels = append(labels, remoteWriteLabel{name: remoteWriteNameLabel, value: sanitizeMetricName(name)})
+	for _, label := range tagLabels {
+		if quantile != "" && label.name == remoteWriteQuantileLabel {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	if quantile != "" {
+		labels = append(labels, remoteWriteLabel{name: remoteWriteQuantileLabel, value: quantile})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// remoteWriteTagLabels converts the host, device and tags of a metric to labels. The tag keys are
+// sanitized into label names, the values of the tags sharing a key are joined with commas and the
+// tags without value get the "true" value.
+func remoteWriteTagLabels(host string, device string, tags tagset.CompositeTags) []remoteWriteLabel {
+	values := make(map[string][]string)
+	if host != "" {
+		values["host"] = append(values["host"], host)
+	}
+	if device != "" {
+		values["device"] = append(values["device"], device)
+	}
+	tags.ForEach(func(tag string) {
+		key, value := tag, "true"
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		if key == "" || value == "" {
+			return
+		}
+		key = sanitizeLabelName(key)
+		values[key] = append(values[key], value)
+	})
+
+	labels := make([]remoteWriteLabel, 0, len(values))
+	for name, v := range values {
+		sort.Strings(v)
+		unique := v[:1]
+		for _, value := range v[1:] {
+			if value != unique[len(unique)-1] {
+				unique = append(unique, value)
+			}
+		}
+		labels = append(labels, remoteWriteLabel{name: name, value: strings.Join(unique, ",")})
+	}
+	return labels
+}
+
+// sanitizeMetricName replaces the characters not allowed in a Prometheus metric name with
+// underscores
+func sanitizeMetricName(name string) string {
+	return sanitizePrometheusName(name, true)
+}
+
+// sanitizeLabelName replaces the characters not allowed in a Prometheus label name with
+// underscores. The names starting with `__` being reserved, they get a `tag` prefix.
+func sanitizeLabelName(name string) string {
+	name = sanitizePrometheusName(name, false)
+	if strings.HasPrefix(name, "__") {
+		return "tag" + name
+	}
+	return name
+}
+
+func sanitizePrometheusName(name string, allowColon bool) string {
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', allowColon && r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
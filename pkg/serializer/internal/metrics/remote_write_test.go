@@ -0,0 +1,163 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/richardartoul/molecule"
+	"github.com/richardartoul/molecule/src/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+type decodedTimeSeries struct {
+	labels  []remoteWriteLabel
+	samples []remoteWriteSample
+}
+
+// decodeRemoteWrite decompresses and decodes the Prometheus remote-write payloads
+func decodeRemoteWrite(t *testing.T, payloads transaction.BytesPayloads) [][]decodedTimeSeries {
+	var decoded [][]decodedTimeSeries
+	for _, payload := range payloads {
+		raw, err := snappy.Decode(nil, payload.GetContent())
+		require.NoError(t, err)
+
+		var series []decodedTimeSeries
+		err = molecule.MessageEach(codec.NewBuffer(raw), func(fieldNum int32, value molecule.Value) (bool, error) {
+			require.EqualValues(t, writeRequestTimeseries, fieldNum)
+			var ts decodedTimeSeries
+			err := molecule.MessageEach(codec.NewBuffer(value.Bytes), func(fieldNum int32, value molecule.Value) (bool, error) {
+				switch fieldNum {
+				case timeSeriesLabels:
+					var label remoteWriteLabel
+					err := molecule.MessageEach(codec.NewBuffer(value.Bytes), func(fieldNum int32, value molecule.Value) (bool, error) {
+						if fieldNum == labelName {
+							label.name = string(value.Bytes)
+						} else {
+							label.value = string(value.Bytes)
+						}
+						return true, nil
+					})
+					ts.labels = append(ts.labels, label)
+					return true, err
+				case timeSeriesSamples:
+					var sample remoteWriteSample
+					err := molecule.MessageEach(codec.NewBuffer(value.Bytes), func(fieldNum int32, value molecule.Value) (bool, error) {
+						if fieldNum == sampleValue {
+							sample.value = math.Float64frombits(value.Number)
+						} else {
+							sample.timestamp = int64(value.Number)
+						}
+						return true, nil
+					})
+					ts.samples = append(ts.samples, sample)
+					return true, err
+				}
+				return true, nil
+			})
+			series = append(series, ts)
+			return true, err
+		})
+		require.NoError(t, err)
+		decoded = append(decoded, series)
+	}
+	return decoded
+}
+
+func TestMarshalSeriesRemoteWrite(t *testing.T) {
+	series := metrics.Series{
+		{
+			Name:   "system.load.1",
+			Points: []metrics.Point{{Ts: 1600000000, Value: 1.5}, {Ts: 1600000010.5, Value: 0}},
+			Tags:   tagset.CompositeTagsFromSlice([]string{"env:prod", "team:b", "team:a", "team:a", "9lives", "__internal:x", "kube-ns:default", "empty:"}),
+			Host:   "my-host",
+			Device: "sda",
+		},
+		{Name: "no.points"},
+		{Name: "1st.metric:total", Points: []metrics.Point{{Ts: 1600000000, Value: 3}}},
+	}
+
+	payloads, err := MarshalSeriesRemoteWrite(series, 0)
+	require.NoError(t, err)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, 3, payloads[0].GetPointCount())
+
+	decoded := decodeRemoteWrite(t, payloads)
+	require.Len(t, decoded[0], 2)
+	assert.Equal(t, []remoteWriteLabel{
+		{"_9lives", "true"},
+		{"__name__", "system_load_1"},
+		{"device", "sda"},
+		{"env", "prod"},
+		{"host", "my-host"},
+		{"kube_ns", "default"},
+		{"tag__internal", "x"},
+		{"team", "a,b"},
+	}, decoded[0][0].labels)
+	assert.Equal(t, []remoteWriteSample{{1.5, 1600000000000}, {0, 1600000010500}}, decoded[0][0].samples)
+	assert.Equal(t, []remoteWriteLabel{{"__name__", "_1st_metric:total"}}, decoded[0][1].labels)
+	assert.Equal(t, []remoteWriteSample{{3, 1600000000000}}, decoded[0][1].samples)
+
+	payloads, err = MarshalSeriesRemoteWrite(series, 1)
+	require.NoError(t, err)
+	require.Len(t, payloads, 2)
+	decoded = decodeRemoteWrite(t, payloads)
+	assert.Len(t, decoded[0], 1)
+	assert.Len(t, decoded[1], 1)
+}
+
+func TestMarshalSketchesRemoteWrite(t *testing.T) {
+	agent := &quantile.Agent{}
+	for i := 1; i <= 100; i++ {
+		agent.Insert(float64(i), 1)
+	}
+	sketch := agent.Finish()
+	sketches := metrics.SketchSeriesList{{
+		Name:   "request.latency",
+		Tags:   tagset.CompositeTagsFromSlice([]string{"quantile:user", "service:web"}),
+		Host:   "my-host",
+		Points: []metrics.SketchPoint{{Sketch: sketch, Ts: 1600000000}},
+	}}
+
+	payloads, err := MarshalSketchesRemoteWrite(sketches, 0)
+	require.NoError(t, err)
+	decoded := decodeRemoteWrite(t, payloads)
+	require.Len(t, decoded, 1)
+	require.Len(t, decoded[0], len(remoteWriteQuantiles)+2)
+
+	for i, q := range []string{"0", "0.5", "0.9", "0.95", "0.99", "1"} {
+		assert.Equal(t, []remoteWriteLabel{
+			{"__name__", "request_latency"},
+			{"host", "my-host"},
+			{"quantile", q},
+			{"service", "web"},
+		}, decoded[0][i].labels)
+		require.Len(t, decoded[0][i].samples, 1)
+		assert.Equal(t, sketch.Quantile(quantile.Default(), remoteWriteQuantiles[i]), decoded[0][i].samples[0].value)
+		assert.EqualValues(t, 1600000000000, decoded[0][i].samples[0].timestamp)
+	}
+	assert.Equal(t, float64(1), decoded[0][0].samples[0].value)
+	assert.Equal(t, float64(100), decoded[0][5].samples[0].value)
+
+	sum, count := decoded[0][len(remoteWriteQuantiles)], decoded[0][len(remoteWriteQuantiles)+1]
+	assert.Equal(t, []remoteWriteLabel{
+		{"__name__", "request_latency_sum"},
+		{"host", "my-host"},
+		{"quantile", "user"},
+		{"service", "web"},
+	}, sum.labels)
+	assert.Equal(t, []remoteWriteSample{{5050, 1600000000000}}, sum.samples)
+	assert.Equal(t, "request_latency_count", count.labels[0].value)
+	assert.Equal(t, []remoteWriteSample{{100, 1600000000000}}, count.samples)
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	metricsserializer "github.com/DataDog/datadog-agent/pkg/serializer/internal/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// prometheusRemoteWriter returns the forwarder if it duplicates the series and sketches to a
+// Prometheus remote-write endpoint, nil otherwise.
+func prometheusRemoteWriter(f forwarder.Forwarder) forwarder.PrometheusRemoteWriter {
+	if writer, ok := f.(forwarder.PrometheusRemoteWriter); ok && writer.PrometheusRemoteWriteEnabled() {
+		return writer
+	}
+	return nil
+}
+
+// sendSeriesRemoteWrite sends the series to the Prometheus remote-write endpoint, if enabled. The
+// errors are logged and counted apart: they must not fail the sending of the series to Datadog.
+func (s *Serializer) sendSeriesRemoteWrite(series metrics.Series) {
+	if s.remoteWriter == nil || len(series) == 0 {
+		return
+	}
+	payloads, err := metricsserializer.MarshalSeriesRemoteWrite(series, config.Datadog.GetInt("prometheus_remote_write.max_series_per_payload"))
+	if err != nil {
+		reportRemoteWriteError(fmt.Errorf("dropping Prometheus remote-write series payload: %s", err))
+		return
+	}
+	if err := s.remoteWriter.SubmitPrometheusRemoteWrite(payloads, prometheusRemoteWriteExtraHeaders); err != nil {
+		reportRemoteWriteError(fmt.Errorf("cannot submit the Prometheus remote-write series payload: %s", err))
+	}
+}
+
+// sendSketchesRemoteWrite sends the sketches to the Prometheus remote-write endpoint, if enabled.
+// The errors are logged and counted apart: they must not fail the sending of the sketches to
+// Datadog.
+func (s *Serializer) sendSketchesRemoteWrite(sketches metrics.SketchSeriesList) {
+	if s.remoteWriter == nil || len(sketches) == 0 {
+		return
+	}
+	payloads, err := metricsserializer.MarshalSketchesRemoteWrite(sketches, config.Datadog.GetInt("prometheus_remote_write.max_series_per_payload"))
+	if err != nil {
+		reportRemoteWriteError(fmt.Errorf("dropping Prometheus remote-write sketches payload: %s", err))
+		return
+	}
+	if err := s.remoteWriter.SubmitPrometheusRemoteWrite(payloads, prometheusRemoteWriteExtraHeaders); err != nil {
+		reportRemoteWriteError(fmt.Errorf("cannot submit the Prometheus remote-write sketches payload: %s", err))
+	}
+}
+
+func reportRemoteWriteError(err error) {
+	expvarsSendPrometheusRemoteWriteErrors.Add(1)
+	log.Error(err)
+}
@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package serializer
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+// metricRoutingRules returns the routing rules of the forwarder domains only receiving a subset of
+// the series and sketches, by domain.
+func metricRoutingRules(f forwarder.Forwarder) map[string]*resolver.RoutingRule {
+	if router, ok := f.(forwarder.MetricRouter); ok {
+		return router.MetricRoutingRules()
+	}
+	return nil
+}
+
+// metricRoutingRulesFor returns the routing rules of the domains only receiving a subset of the
+// series or sketches, among the domains receiving the given kind.
+func (s *Serializer) metricRoutingRulesFor(kind resolver.EndpointKind) map[string]*resolver.RoutingRule {
+	var rules map[string]*resolver.RoutingRule
+	for domain, rule := range s.metricRoutingRules {
+		if !rule.AcceptsKind(kind) {
+			continue
+		}
+		if rules == nil {
+			rules = make(map[string]*resolver.RoutingRule)
+		}
+		rules[domain] = rule
+	}
+	return rules
+}
+
+// routeTo restricts the payloads to the given domains, if any.
+func routeTo(payloads transaction.BytesPayloads, domains []string) transaction.BytesPayloads {
+	if domains != nil {
+		for _, p := range payloads {
+			p.SetDomains(domains)
+		}
+	}
+	return payloads
+}
+
+// serieSliceSource is a metrics.SerieSource reading a slice of series.
+type serieSliceSource struct {
+	series metrics.Series
+	index  int
+}
+
+func newSerieSliceSource(series metrics.Series) *serieSliceSource {
+	return &serieSliceSource{series: series, index: -1}
+}
+
+func (s *serieSliceSource) MoveNext() bool {
+	s.index++
+	return s.index < len(s.series)
+}
+
+func (s *serieSliceSource) Current() *metrics.Serie {
+	return s.series[s.index]
+}
+
+func (s *serieSliceSource) Count() uint64 {
+	return uint64(len(s.series))
+}
+
+// sketchesSliceSource is a metrics.SketchesSource reading a slice of sketches.
+type sketchesSliceSource struct {
+	sketches metrics.SketchSeriesList
+	index    int
+}
+
+func newSketchesSliceSource(sketches metrics.SketchSeriesList) *sketchesSliceSource {
+	return &sketchesSliceSource{sketches: sketches, index: -1}
+}
+
+func (s *sketchesSliceSource) MoveNext() bool {
+	s.index++
+	return s.index < len(s.sketches)
+}
+
+func (s *sketchesSliceSource) Current() *metrics.SketchSeries {
+	return s.sketches[s.index]
+}
+
+func (s *sketchesSliceSource) Count() uint64 {
+	return uint64(len(s.sketches))
+}
+
+func (s *sketchesSliceSource) WaitForValue() bool {
+	return s.index+1 < len(s.sketches)
+}
+
+// routingSerieSource is a metrics.SerieSource reading the series sent to all the domains. It keeps
+// the series routed to the domains only receiving a subset of them, by domain, and all the series
+// if keepAll is set, so that they are sent once read.
+type routingSerieSource struct {
+	metrics.SerieSource
+	rules   map[string]*resolver.RoutingRule
+	routed  map[string]metrics.Series
+	keepAll bool
+	all     metrics.Series
+}
+
+func newRoutingSerieSource(source metrics.SerieSource, rules map[string]*resolver.RoutingRule, keepAll bool) *routingSerieSource {
+	return &routingSerieSource{
+		SerieSource: source,
+		rules:       rules,
+		routed:      make(map[string]metrics.Series, len(rules)),
+		keepAll:     keepAll,
+	}
+}
+
+func (s *routingSerieSource) MoveNext() bool {
+	if !s.SerieSource.MoveNext() {
+		return false
+	}
+	serie := s.SerieSource.Current()
+	for domain, rule := range s.rules {
+		if rule.AcceptsMetric(serie.Name, serie.Tags) {
+			s.routed[domain] = append(s.routed[domain], serie)
+		}
+	}
+	if s.keepAll {
+		s.all = append(s.all, serie)
+	}
+	return true
+}
+
+// drain reads the series left if the serialization stopped early, so that the routed series are
+// complete.
+func (s *routingSerieSource) drain() {
+	for s.MoveNext() {
+	}
+}
+
+// routingSketchesSource is the metrics.SketchesSource counterpart of routingSerieSource.
+type routingSketchesSource struct {
+	metrics.SketchesSource
+	rules   map[string]*resolver.RoutingRule
+	routed  map[string]metrics.SketchSeriesList
+	keepAll bool
+	all     metrics.SketchSeriesList
+}
+
+func newRoutingSketchesSource(source metrics.SketchesSource, rules map[string]*resolver.RoutingRule, keepAll bool) *routingSketchesSource {
+	return &routingSketchesSource{
+		SketchesSource: source,
+		rules:          rules,
+		routed:         make(map[string]metrics.SketchSeriesList, len(rules)),
+		keepAll:        keepAll,
+	}
+}
+
+func (s *routingSketchesSource) MoveNext() bool {
+	if !s.SketchesSource.MoveNext() {
+		return false
+	}
+	sketch := s.SketchesSource.Current()
+	for domain, rule := range s.rules {
+		if rule.AcceptsMetric(sketch.Name, sketch.Tags) {
+			s.routed[domain] = append(s.routed[domain], sketch)
+		}
+	}
+	if s.keepAll {
+		s.all = append(s.all, sketch)
+	}
+	return true
+}
+
+func (s *routingSketchesSource) drain() {
+	for s.MoveNext() {
+	}
+}
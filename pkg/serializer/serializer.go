@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
@@ -46,15 +47,18 @@ var (
 	protobufExtraHeaders                http.Header
 	jsonExtraHeadersWithCompression     http.Header
 	protobufExtraHeadersWithCompression http.Header
+	prometheusRemoteWriteExtraHeaders   http.Header
 
 	expvars                                 = expvar.NewMap("serializer")
 	expvarsSendEventsErrItemTooBigs         = expvar.Int{}
 	expvarsSendEventsErrItemTooBigsFallback = expvar.Int{}
+	expvarsSendPrometheusRemoteWriteErrors  = expvar.Int{}
 )
 
 func init() {
 	expvars.Set("SendEventsErrItemTooBigs", &expvarsSendEventsErrItemTooBigs)
 	expvars.Set("SendEventsErrItemTooBigsFallback", &expvarsSendEventsErrItemTooBigsFallback)
+	expvars.Set("SendPrometheusRemoteWriteErrors", &expvarsSendPrometheusRemoteWriteErrors)
 	initExtraHeaders()
 }
 
@@ -82,6 +86,11 @@ func initExtraHeaders() {
 		jsonExtraHeadersWithCompression.Set("Content-Encoding", compression.ContentEncoding)
 		protobufExtraHeadersWithCompression.Set("Content-Encoding", compression.ContentEncoding)
 	}
+
+	prometheusRemoteWriteExtraHeaders = make(http.Header)
+	prometheusRemoteWriteExtraHeaders.Set("Content-Type", protobufContentType)
+	prometheusRemoteWriteExtraHeaders.Set("Content-Encoding", "snappy")
+	prometheusRemoteWriteExtraHeaders.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 }
 
 // MetricSerializer represents the interface of method needed by the aggregator to serialize its data
@@ -110,6 +119,14 @@ type Serializer struct {
 
 	seriesJSONPayloadBuilder *stream.JSONPayloadBuilder
 
+	// metricRoutingRules holds the routing rules of the forwarder domains only receiving a
+	// subset of the series and sketches, by domain. They receive dedicated payloads.
+	metricRoutingRules map[string]*resolver.RoutingRule
+
+	// remoteWriter duplicates the series and sketches to a Prometheus remote-write endpoint, nil if
+	// disabled
+	remoteWriter forwarder.PrometheusRemoteWriter
+
 	// Those variables allow users to blacklist any kind of payload
 	// from being sent by the agent. This was introduced for
 	// environment where, for example, events or serviceChecks
@@ -143,6 +160,8 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder, contlcy
 		enableServiceChecksJSONStream: stream.Available && config.Datadog.GetBool("enable_service_checks_stream_payload_serialization"),
 		enableEventsJSONStream:        stream.Available && config.Datadog.GetBool("enable_events_stream_payload_serialization"),
 		enableSketchProtobufStream:    stream.Available && config.Datadog.GetBool("enable_sketch_stream_payload_serialization"),
+		metricRoutingRules:            metricRoutingRules(forwarder),
+		remoteWriter:                  prometheusRemoteWriter(forwarder),
 	}
 
 	if !s.enableEvents {
@@ -314,6 +333,29 @@ func (s *Serializer) SendIterableSeries(serieSource metrics.SerieSource) error {
 		return nil
 	}
 
+	rules := s.metricRoutingRulesFor(resolver.SeriesKind)
+	if len(rules) == 0 && s.remoteWriter == nil {
+		return s.sendIterableSeries(serieSource, nil)
+	}
+
+	// the series are sent to all the domains as they are read. Only the ones routed to the domains
+	// receiving a subset of them are kept, to send them dedicated payloads, unless all of them are
+	// converted for the Prometheus remote-write endpoint.
+	routing := newRoutingSerieSource(serieSource, rules, s.remoteWriter != nil)
+	err := s.sendIterableSeries(routing, nil)
+	routing.drain()
+	for domain, routed := range routing.routed {
+		if routeErr := s.sendIterableSeries(newSerieSliceSource(routed), []string{domain}); routeErr != nil && err == nil {
+			err = fmt.Errorf("%s: %s", domain, routeErr)
+		}
+	}
+	s.sendSeriesRemoteWrite(routing.all)
+	return err
+}
+
+// sendIterableSeries serializes a list of series and sends the payload to the given domains of the
+// forwarder, or to all of them if nil
+func (s *Serializer) sendIterableSeries(serieSource metrics.SerieSource, domains []string) error {
 	seriesSerializer := metricsserializer.IterableSeries{SerieSource: serieSource}
 	useV1API := !config.Datadog.GetBool("use_v2_api.series")
 
@@ -334,6 +376,7 @@ func (s *Serializer) SendIterableSeries(serieSource metrics.SerieSource) error {
 		return fmt.Errorf("dropping series payload: %s", err)
 	}
 
+	seriesBytesPayloads = routeTo(seriesBytesPayloads, domains)
 	if useV1API {
 		return s.Forwarder.SubmitV1Series(seriesBytesPayloads, extraHeaders)
 	}
@@ -351,11 +394,34 @@ func (s *Serializer) SendSketch(sketches metrics.SketchesSource) error {
 		log.Debug("sketches payloads are disabled: dropping it")
 		return nil
 	}
+	rules := s.metricRoutingRulesFor(resolver.SketchesKind)
+	if len(rules) == 0 && s.remoteWriter == nil {
+		return s.sendSketch(sketches, nil)
+	}
+
+	// the sketches are sent to all the domains as they are read. Only the ones routed to the
+	// domains receiving a subset of them are kept, to send them dedicated payloads, unless all of
+	// them are converted for the Prometheus remote-write endpoint.
+	routing := newRoutingSketchesSource(sketches, rules, s.remoteWriter != nil)
+	err := s.sendSketch(routing, nil)
+	routing.drain()
+	for domain, routed := range routing.routed {
+		if routeErr := s.sendSketch(newSketchesSliceSource(routed), []string{domain}); routeErr != nil && err == nil {
+			err = fmt.Errorf("%s: %s", domain, routeErr)
+		}
+	}
+	s.sendSketchesRemoteWrite(routing.all)
+	return err
+}
+
+// sendSketch serializes a list of SketchSeriesList and sends the payload to the given domains of the
+// forwarder, or to all of them if nil
+func (s *Serializer) sendSketch(sketches metrics.SketchesSource, domains []string) error {
 	sketchesSerializer := metricsserializer.SketchSeriesList{SketchesSource: sketches}
 	if s.enableSketchProtobufStream {
 		payloads, err := sketchesSerializer.MarshalSplitCompress(marshaler.DefaultBufferContext())
 		if err == nil {
-			return s.Forwarder.SubmitSketchSeries(payloads, protobufExtraHeadersWithCompression)
+			return s.Forwarder.SubmitSketchSeries(routeTo(payloads, domains), protobufExtraHeadersWithCompression)
 		}
 		log.Warnf("Error: %v trying to stream compress SketchSeriesList - falling back to split/compress method", err)
 	}
@@ -366,7 +432,7 @@ func (s *Serializer) SendSketch(sketches metrics.SketchesSource) error {
 		return fmt.Errorf("dropping sketch payload: %s", err)
 	}
 
-	return s.Forwarder.SubmitSketchSeries(splitSketches, extraHeaders)
+	return s.Forwarder.SubmitSketchSeries(routeTo(splitSketches, domains), extraHeaders)
 }
 
 // SendMetadata serializes a metadata payload and sends it to the forwarder
@@ -420,7 +486,7 @@ func (s *Serializer) SendProcessesMetadata(data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("could not compress processes metadata payload: %s", err)
 	}
-	if err := s.Forwarder.SubmitV1Intake(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&compressedPayload}), jsonExtraHeadersWithCompression); err != nil {
+	if err := s.Forwarder.SubmitProcessesMetadata(transaction.NewBytesPayloadsWithoutMetaData([]*[]byte{&compressedPayload}), jsonExtraHeadersWithCompression); err != nil {
 		return err
 	}
 
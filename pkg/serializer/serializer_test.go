@@ -11,21 +11,29 @@ package serializer
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang/snappy"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/orchestrator"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
 	metricsserializer "github.com/DataDog/datadog-agent/pkg/serializer/internal/metrics"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
 	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
@@ -319,6 +327,179 @@ func TestSendSketch(t *testing.T) {
 	f.AssertExpectations(t)
 }
 
+// routedForwarder is a MockedForwarder only sending a subset of the series and sketches to some domains.
+type routedForwarder struct {
+	forwarder.MockedForwarder
+	rules map[string]*resolver.RoutingRule
+}
+
+func (f *routedForwarder) MetricRoutingRules() map[string]*resolver.RoutingRule {
+	return f.rules
+}
+
+func createRoutedPayloadMatcher(domains []string, contains []string, excludes []string) interface{} {
+	return mock.MatchedBy(func(payloads transaction.BytesPayloads) bool {
+		var content string
+		for _, p := range payloads {
+			if !reflect.DeepEqual(domains, p.GetDomains()) {
+				return false
+			}
+			payload, err := compression.Decompress(p.GetContent())
+			if err != nil {
+				return false
+			}
+			content += string(payload)
+		}
+		for _, c := range contains {
+			if !strings.Contains(content, c) {
+				return false
+			}
+		}
+		for _, e := range excludes {
+			if strings.Contains(content, e) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestSendWithMetricRoutingRules(t *testing.T) {
+	billing, err := resolver.NewRoutingRule(nil, []string{"billing."}, []string{"team:billing"})
+	require.NoError(t, err)
+	processOnly, err := resolver.NewRoutingRule([]string{"process"}, []string{"billing."}, nil)
+	require.NoError(t, err)
+	rules := map[string]*resolver.RoutingRule{"datadog.eu": billing, "datadog.process": processOnly}
+
+	t.Run("series", func(t *testing.T) {
+		f := &routedForwarder{rules: rules}
+		config.Datadog.Set("enable_stream_payload_serialization", false)
+		defer config.Datadog.Set("enable_stream_payload_serialization", nil)
+		f.On("SubmitV1Series", createRoutedPayloadMatcher(nil, []string{"billing.invoices", "system.cpu", "system.mem"}, nil), jsonExtraHeadersWithCompression).Return(nil).Times(1)
+		f.On("SubmitV1Series", createRoutedPayloadMatcher([]string{"datadog.eu"}, []string{"billing.invoices", "system.cpu"}, []string{"system.mem"}), jsonExtraHeadersWithCompression).Return(nil).Times(1)
+
+		s := NewSerializer(f, nil, nil)
+		err := s.SendIterableSeries(metricsserializer.CreateSerieSource(metrics.Series{
+			{Name: "billing.invoices"},
+			{Name: "system.cpu", Tags: tagset.CompositeTagsFromSlice([]string{"team:billing"})},
+			{Name: "system.mem", Tags: tagset.CompositeTagsFromSlice([]string{"team:infra"})},
+		}))
+		require.NoError(t, err)
+		f.AssertExpectations(t)
+	})
+
+	t.Run("sketches", func(t *testing.T) {
+		f := &routedForwarder{rules: rules}
+		f.On("SubmitSketchSeries", createRoutedPayloadMatcher(nil, []string{"billing.latency", "system.latency"}, nil), protobufExtraHeadersWithCompression).Return(nil).Times(1)
+		f.On("SubmitSketchSeries", createRoutedPayloadMatcher([]string{"datadog.eu"}, []string{"billing.latency"}, []string{"system.latency"}), protobufExtraHeadersWithCompression).Return(nil).Times(1)
+
+		sketches := metrics.NewSketchesSourceTest()
+		sketches.Append(&metrics.SketchSeries{Name: "billing.latency"})
+		sketches.Append(&metrics.SketchSeries{Name: "system.latency"})
+		s := NewSerializer(f, nil, nil)
+		remoteWriteErrors := expvarsSendPrometheusRemoteWriteErrors.Value()
+		err := s.SendSketch(sketches)
+		require.NoError(t, err)
+		assert.Equal(t, remoteWriteErrors, expvarsSendPrometheusRemoteWriteErrors.Value())
+		f.AssertExpectations(t)
+	})
+}
+
+func TestRoutingSerieSource(t *testing.T) {
+	billing, err := resolver.NewRoutingRule(nil, []string{"billing."}, nil)
+	require.NoError(t, err)
+	sketchesOnly, err := resolver.NewRoutingRule([]string{"sketches"}, []string{"system."}, nil)
+	require.NoError(t, err)
+	s := &Serializer{metricRoutingRules: map[string]*resolver.RoutingRule{"datadog.eu": billing, "datadog.sketches": sketchesOnly}}
+
+	rules := s.metricRoutingRulesFor(resolver.SeriesKind)
+	assert.Len(t, rules, 1)
+	assert.Contains(t, rules, "datadog.eu")
+	assert.Empty(t, (&Serializer{}).metricRoutingRulesFor(resolver.SeriesKind))
+
+	series := metrics.Series{{Name: "billing.invoices"}, {Name: "system.cpu"}, {Name: "system.mem"}}
+	routing := newRoutingSerieSource(metricsserializer.CreateSerieSource(series), rules, false)
+	// the serialization stopped after the first serie
+	require.True(t, routing.MoveNext())
+	routing.drain()
+	// only the routed series are kept
+	assert.Equal(t, map[string]metrics.Series{"datadog.eu": series[:1]}, routing.routed)
+	assert.Empty(t, routing.all)
+
+	routing = newRoutingSerieSource(metricsserializer.CreateSerieSource(series), rules, true)
+	routing.drain()
+	assert.Equal(t, series, routing.all)
+}
+
+// remoteWriteForwarder is a MockedForwarder duplicating the series and sketches to a Prometheus
+// remote-write endpoint.
+type remoteWriteForwarder struct {
+	forwarder.MockedForwarder
+}
+
+func (f *remoteWriteForwarder) PrometheusRemoteWriteEnabled() bool {
+	return true
+}
+
+func (f *remoteWriteForwarder) SubmitPrometheusRemoteWrite(payload transaction.BytesPayloads, extra http.Header) error {
+	return f.Called(payload, extra).Error(0)
+}
+
+func createRemoteWritePayloadMatcher(contains []string) interface{} {
+	return mock.MatchedBy(func(payloads transaction.BytesPayloads) bool {
+		var content string
+		for _, p := range payloads {
+			payload, err := snappy.Decode(nil, p.GetContent())
+			if err != nil {
+				return false
+			}
+			content += string(payload)
+		}
+		for _, c := range contains {
+			if !strings.Contains(content, c) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestSendWithPrometheusRemoteWrite(t *testing.T) {
+	t.Run("series", func(t *testing.T) {
+		f := &remoteWriteForwarder{}
+		config.Datadog.Set("enable_stream_payload_serialization", false)
+		defer config.Datadog.Set("enable_stream_payload_serialization", nil)
+		f.On("SubmitV1Series", createRoutedPayloadMatcher(nil, []string{"system.cpu", "system.mem"}, nil), jsonExtraHeadersWithCompression).Return(nil).Times(1)
+		f.On("SubmitPrometheusRemoteWrite", createRemoteWritePayloadMatcher([]string{"system_cpu", "system_mem", "team", "infra"}), prometheusRemoteWriteExtraHeaders).Return(fmt.Errorf("some error")).Times(1)
+
+		s := NewSerializer(f, nil, nil)
+		remoteWriteErrors := expvarsSendPrometheusRemoteWriteErrors.Value()
+		err := s.SendIterableSeries(metricsserializer.CreateSerieSource(metrics.Series{
+			{Name: "system.cpu", Points: []metrics.Point{{Ts: 1600000000, Value: 1}}},
+			{Name: "system.mem", Points: []metrics.Point{{Ts: 1600000000, Value: 2}}, Tags: tagset.CompositeTagsFromSlice([]string{"team:infra"})},
+		}))
+		// the remote-write errors do not fail the sending of the series to Datadog
+		require.NoError(t, err)
+		assert.Equal(t, remoteWriteErrors+1, expvarsSendPrometheusRemoteWriteErrors.Value())
+		f.AssertExpectations(t)
+	})
+
+	t.Run("sketches", func(t *testing.T) {
+		f := &remoteWriteForwarder{}
+		f.On("SubmitSketchSeries", createRoutedPayloadMatcher(nil, []string{"request.latency"}, nil), protobufExtraHeadersWithCompression).Return(nil).Times(1)
+		f.On("SubmitPrometheusRemoteWrite", createRemoteWritePayloadMatcher([]string{"request_latency", "request_latency_sum", "request_latency_count", "quantile"}), prometheusRemoteWriteExtraHeaders).Return(nil).Times(1)
+
+		agent := &quantile.Agent{}
+		agent.Insert(1, 1)
+		sketches := metrics.NewSketchesSourceTest()
+		sketches.Append(&metrics.SketchSeries{Name: "request.latency", Points: []metrics.SketchPoint{{Sketch: agent.Finish(), Ts: 1600000000}}})
+		s := NewSerializer(f, nil, nil)
+		err := s.SendSketch(sketches)
+		require.NoError(t, err)
+		f.AssertExpectations(t)
+	})
+}
+
 func TestSendMetadata(t *testing.T) {
 	f := &forwarder.MockedForwarder{}
 	f.On("SubmitMetadata", jsonPayloads, jsonExtraHeadersWithCompression).Return(nil).Times(1)
@@ -344,7 +525,7 @@ func TestSendProcessesMetadata(t *testing.T) {
 	f := &forwarder.MockedForwarder{}
 	payload := []byte("\"test\"")
 	payloads, _ := mkPayloads(payload, true)
-	f.On("SubmitV1Intake", payloads, jsonExtraHeadersWithCompression).Return(nil).Times(1)
+	f.On("SubmitProcessesMetadata", payloads, jsonExtraHeadersWithCompression).Return(nil).Times(1)
 
 	s := NewSerializer(f, nil, nil)
 
@@ -352,7 +533,7 @@ func TestSendProcessesMetadata(t *testing.T) {
 	require.Nil(t, err)
 	f.AssertExpectations(t)
 
-	f.On("SubmitV1Intake", payloads, jsonExtraHeadersWithCompression).Return(fmt.Errorf("some error")).Times(1)
+	f.On("SubmitProcessesMetadata", payloads, jsonExtraHeadersWithCompression).Return(fmt.Errorf("some error")).Times(1)
 	err = s.SendProcessesMetadata("test")
 	require.NotNil(t, err)
 	f.AssertExpectations(t)
@@ -362,6 +543,80 @@ func TestSendProcessesMetadata(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestSendWithEndpointRoutingRules(t *testing.T) {
+	mockConfig := config.Mock(t)
+
+	var m sync.Mutex
+	received := make(map[string][]string)
+	newServer := func(name string) *httptest.Server {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.Lock()
+			received[name] = append(received[name], r.URL.Path)
+			m.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	receivedBy := func(name string) []string {
+		m.Lock()
+		defer m.Unlock()
+		return append([]string(nil), received[name]...)
+	}
+	everything := newServer("everything")
+	seriesAndEvents := newServer("series and events")
+
+	mockConfig.Set("forwarder_routes", []map[string]interface{}{
+		{"domain": seriesAndEvents.URL, "endpoints": []string{"series", "events"}},
+	})
+	defer mockConfig.Set("forwarder_routes", nil)
+	mockConfig.Set("orchestrator_explorer.use_legacy_endpoint", true)
+	defer mockConfig.Set("orchestrator_explorer.use_legacy_endpoint", nil)
+
+	originalEncoder := processPayloadEncoder
+	processPayloadEncoder = func(m ProcessMessageBody) ([]byte, error) {
+		return []byte("orchestrator payload"), nil
+	}
+	defer func() { processPayloadEncoder = originalEncoder }()
+
+	f := forwarder.NewDefaultForwarder(forwarder.NewOptions(map[string][]string{
+		everything.URL:      {"api-key"},
+		seriesAndEvents.URL: {"api-key"},
+	}))
+	require.NoError(t, f.Start())
+	defer f.Stop()
+	s := NewSerializer(f, f, f)
+
+	for _, tt := range []struct {
+		name  string
+		route string
+		send  func() error
+	}{
+		{"processes metadata", "/intake/", func() error {
+			return s.SendProcessesMetadata("test")
+		}},
+		{"container lifecycle", "/api/v2/contlcycle", func() error {
+			return s.SendContainerLifecycleEvent([]ContainerLifecycleMessage{{}}, "host")
+		}},
+		{"legacy orchestrator", "/api/v1/orchestrator", func() error {
+			return s.SendOrchestratorMetadata(make([]ProcessMessageBody, 1), "host", "cluster-id", int(orchestrator.K8sPod))
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.send())
+			assert.Eventually(t, func() bool {
+				for _, path := range receivedBy("everything") {
+					if path == tt.route {
+						return true
+					}
+				}
+				return false
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.NotContains(t, receivedBy("series and events"), tt.route)
+		})
+	}
+}
+
 func TestSendWithDisabledKind(t *testing.T) {
 	mockConfig := config.Mock(t)
 
@@ -41,18 +41,24 @@ type Agent struct {
 	ClientStatsAggregator *stats.ClientStatsAggregator
 	Blacklister           *filters.Blacklister
 	Replacer              *filters.Replacer
+	SpanFilter            *filters.SpanFilter
 	PrioritySampler       *sampler.PrioritySampler
 	ErrorsSampler         *sampler.ErrorsSampler
 	RareSampler           *sampler.RareSampler
+	LatencySampler        *sampler.LatencySampler
 	NoPrioritySampler     *sampler.NoPrioritySampler
 	EventProcessor        *event.Processor
 	TraceWriter           *writer.TraceWriter
 	StatsWriter           *writer.StatsWriter
+	// OTLPWriter sends the sampled traces to an OpenTelemetry collector. It is nil
+	// unless the OTLP exporter is enabled.
+	OTLPWriter *writer.OTLPWriter
 
 	// obfuscator is used to obfuscate sensitive data from various span
 	// tags based on their type.
 	obfuscator     *obfuscate.Obfuscator
 	cardObfuscator *ccObfuscator
+	piiRedactor    *piiRedactor
 
 	// DiscardSpan will be called on all spans, if non-nil. If it returns true, the span will be deleted before processing.
 	DiscardSpan func(*pb.Span) bool
@@ -86,19 +92,28 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig) *Agent {
 		ClientStatsAggregator: stats.NewClientStatsAggregator(conf, statsChan),
 		Blacklister:           filters.NewBlacklister(conf.Ignore["resource"]),
 		Replacer:              filters.NewReplacer(conf.ReplaceTags),
+		SpanFilter:            filters.NewSpanFilter(conf.FilterSpans),
 		PrioritySampler:       sampler.NewPrioritySampler(conf, dynConf),
 		ErrorsSampler:         sampler.NewErrorsSampler(conf),
 		RareSampler:           sampler.NewRareSampler(conf),
+		LatencySampler:        sampler.NewLatencySampler(conf),
 		NoPrioritySampler:     sampler.NewNoPrioritySampler(conf),
 		EventProcessor:        newEventProcessor(conf),
 		TraceWriter:           writer.NewTraceWriter(conf),
 		StatsWriter:           writer.NewStatsWriter(conf, statsChan),
 		obfuscator:            obfuscate.NewObfuscator(oconf),
 		cardObfuscator:        newCreditCardsObfuscator(conf.Obfuscation.CreditCards),
+		piiRedactor:           newPIIRedactor(conf.Obfuscation.PII),
 		In:                    in,
 		conf:                  conf,
 		ctx:                   ctx,
 	}
+	if conf.LatencySamplerEnabled {
+		agnt.Concentrator.OnFlush = agnt.LatencySampler.UpdateThresholds
+	}
+	if conf.OTLPExporter.Enabled() {
+		agnt.OTLPWriter = writer.NewOTLPWriter(conf)
+	}
 	agnt.Receiver = api.NewHTTPReceiver(conf, dynConf, in, agnt)
 	agnt.OTLPReceiver = api.NewOTLPReceiver(in, conf)
 	return agnt
@@ -121,6 +136,9 @@ func (a *Agent) Run() {
 
 	go a.TraceWriter.Run()
 	go a.StatsWriter.Run()
+	if a.OTLPWriter != nil {
+		go a.OTLPWriter.Run()
+	}
 
 	for i := 0; i < runtime.NumCPU(); i++ {
 		go a.work()
@@ -173,15 +191,18 @@ func (a *Agent) loop() {
 				a.ClientStatsAggregator,
 				a.TraceWriter,
 				a.StatsWriter,
+				a.OTLPWriter,
 				a.PrioritySampler,
 				a.ErrorsSampler,
 				a.NoPrioritySampler,
 				a.RareSampler,
+				a.LatencySampler,
 				a.EventProcessor,
 				a.OTLPReceiver,
 				a.obfuscator,
 				a.obfuscator,
 				a.cardObfuscator,
+				a.piiRedactor,
 			} {
 				stopper.Stop()
 			}
@@ -255,6 +276,12 @@ func (a *Agent) Process(p *api.Payload) {
 			continue
 		}
 
+		if spans, n := a.SpanFilter.Filter(chunk.Spans, root); n > 0 {
+			log.Debugf("Dropped %d spans matching the span filter rules. root: %v", n, root)
+			ts.SpansFiltered.Add(int64(n))
+			chunk.Spans = spans
+		}
+
 		// Extra sanitization steps of the trace.
 		for _, span := range chunk.Spans {
 			for k, v := range a.conf.GlobalTags {
@@ -268,6 +295,7 @@ func (a *Agent) Process(p *api.Payload) {
 				a.ModifySpan(span)
 			}
 			a.obfuscateSpan(span)
+			a.piiRedactor.Redact(span)
 			Truncate(span)
 			if p.ClientComputedTopLevel {
 				traceutil.UpdateTracerTopLevel(span)
@@ -334,20 +362,29 @@ func (a *Agent) Process(p *api.Payload) {
 			ss.TracerPayload = p.TracerPayload.Cut(i)
 			i = 0
 			ss.TracerPayload.Chunks = newChunksArray(ss.TracerPayload.Chunks)
-			a.TraceWriter.In <- ss
+			a.writeChunks(ss)
 			ss = new(writer.SampledChunks)
 		}
 	}
 	ss.TracerPayload = p.TracerPayload
 	ss.TracerPayload.Chunks = newChunksArray(p.TracerPayload.Chunks)
 	if ss.Size > 0 {
-		a.TraceWriter.In <- ss
+		a.writeChunks(ss)
 	}
 	if len(statsInput.Traces) > 0 {
 		a.Concentrator.In <- statsInput
 	}
 }
 
+// writeChunks sends the sampled chunks ss to the trace writer and, if the OTLP exporter
+// is enabled, to the OTLP writer.
+func (a *Agent) writeChunks(ss *writer.SampledChunks) {
+	a.TraceWriter.In <- ss
+	if a.OTLPWriter != nil {
+		a.OTLPWriter.In <- ss
+	}
+}
+
 // newChunksArray creates a new array which will point only to sampled chunks.
 
 // The underlying array behind TracePayload.Chunks points to unsampled chunks
@@ -477,7 +514,8 @@ func (a *Agent) runSamplers(now time.Time, pt traceutil.ProcessedTrace, hasPrior
 
 // samplePriorityTrace samples traces with priority set on them. PrioritySampler and
 // ErrorSampler are run in parallel. The RareSampler catches traces with rare top-level
-// or measured spans that are not caught by PrioritySampler and ErrorSampler.
+// or measured spans that are not caught by PrioritySampler and ErrorSampler. The
+// LatencySampler catches the slow traces that are not caught by any of them.
 func (a *Agent) samplePriorityTrace(now time.Time, pt traceutil.ProcessedTrace) bool {
 	var rare bool
 	if a.conf.RareSamplerDisabled {
@@ -490,18 +528,27 @@ func (a *Agent) samplePriorityTrace(now time.Time, pt traceutil.ProcessedTrace)
 		return true
 	}
 	if traceContainsError(pt.TraceChunk.Spans) {
-		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv)
+		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) || a.sampleLatency(now, pt)
 	}
-	return rare
+	return rare || a.sampleLatency(now, pt)
 }
 
 // sampleNoPriorityTrace samples traces with no priority set on them. The traces
-// get sampled by either the score sampler or the error sampler if they have an error.
+// get sampled by either the score sampler or the error sampler if they have an error,
+// and by the latency sampler if they are slow.
 func (a *Agent) sampleNoPriorityTrace(now time.Time, pt traceutil.ProcessedTrace) bool {
 	if traceContainsError(pt.TraceChunk.Spans) {
-		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv)
+		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) || a.sampleLatency(now, pt)
+	}
+	return a.NoPrioritySampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) || a.sampleLatency(now, pt)
+}
+
+// sampleLatency runs the LatencySampler on pt when it is enabled.
+func (a *Agent) sampleLatency(now time.Time, pt traceutil.ProcessedTrace) bool {
+	if !a.conf.LatencySamplerEnabled {
+		return false
 	}
-	return a.NoPrioritySampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv)
+	return a.LatencySampler.Sample(now, pt.TraceChunk, pt.TracerEnv)
 }
 
 func traceContainsError(trace pb.Trace) bool {
@@ -204,6 +204,63 @@ func TestProcess(t *testing.T) {
 		assert.Equal("unnamed_operation", span.Name)
 	})
 
+	t.Run("SpanFilter", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.FilterSpans = []*config.SpanFilterRule{{
+			NameRe:     regexp.MustCompile("^redis"),
+			MetricsCmp: map[string]config.MetricCondition{"cached": {Op: "==", Value: 1}},
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		now := time.Now()
+		span := func(id, parentID uint64, name string, cached float64) *pb.Span {
+			return &pb.Span{
+				TraceID:  1,
+				SpanID:   id,
+				ParentID: parentID,
+				Service:  "web",
+				Name:     name,
+				Resource: name,
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (500 * time.Millisecond).Nanoseconds(),
+				Metrics:  map[string]float64{"cached": cached},
+			}
+		}
+		chunk := testutil.TraceChunkWithSpans([]*pb.Span{
+			span(1, 0, "redis.root", 1),
+			span(2, 1, "redis.get", 1),
+			span(3, 2, "redis.get", 1),
+			span(4, 3, "http.request", 1),
+			span(5, 1, "redis.get", 0),
+		})
+		chunk.Priority = int32(sampler.PriorityUserKeep)
+
+		want := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayloadWithChunk(chunk),
+			Source:        want,
+		})
+		assert := assert.New(t)
+		assert.EqualValues(0, want.TracesFiltered.Load())
+		assert.EqualValues(2, want.SpansFiltered.Load())
+		select {
+		case ss := <-agnt.TraceWriter.In:
+			spans := ss.TracerPayload.Chunks[0].Spans
+			require.Len(t, spans, 3)
+			parents := make(map[uint64]uint64)
+			for _, s := range spans {
+				parents[s.SpanID] = s.ParentID
+			}
+			// the root is kept and the children of dropped spans are re-parented
+			assert.Equal(map[uint64]uint64{1: 0, 4: 1, 5: 1}, parents)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout: Expected one valid trace, but none were received.")
+		}
+	})
+
 	t.Run("Stats/Priority", func(t *testing.T) {
 		cfg := config.New()
 		cfg.Endpoints[0].APIKey = "test"
@@ -940,6 +997,48 @@ func TestSampling(t *testing.T) {
 	}
 }
 
+func TestLatencySampling(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.RareSamplerDisabled = true
+	cfg.LatencySamplerEnabled = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agnt := NewAgent(ctx, cfg)
+	defer agnt.LatencySampler.Stop()
+	require.NotNil(t, agnt.Concentrator.OnFlush)
+
+	// learn the latencies from stats computed a minute ago
+	start := time.Now().Add(-time.Minute)
+	c := stats.NewConcentrator(cfg, make(chan pb.StatsPayload, 1), start)
+	c.OnFlush = agnt.Concentrator.OnFlush
+	traceWithDuration := func(d time.Duration) traceutil.ProcessedTrace {
+		root := &pb.Span{
+			Service:  "serv1",
+			Name:     "web.request",
+			Resource: "GET /",
+			Start:    start.UnixNano(),
+			Duration: d.Nanoseconds(),
+			Metrics:  map[string]float64{"_top_level": 1},
+		}
+		pt := traceutil.ProcessedTrace{TraceChunk: testutil.TraceChunkWithSpan(root), Root: root}
+		pt.TraceChunk.Priority = int32(sampler.PriorityAutoDrop)
+		return pt
+	}
+	var in stats.Input
+	for i := 1; i <= 1000; i++ {
+		in.Traces = append(in.Traces, traceWithDuration(time.Duration(i)*time.Millisecond))
+	}
+	c.Add(in)
+	c.Flush()
+
+	assert.True(t, agnt.runSamplers(time.Now(), traceWithDuration(2*time.Second), true))
+	assert.False(t, agnt.runSamplers(time.Now(), traceWithDuration(100*time.Millisecond), true))
+
+	cfg.LatencySamplerEnabled = false
+	assert.False(t, agnt.runSamplers(time.Now(), traceWithDuration(2*time.Second), true))
+}
+
 func TestPartialSamplingFree(t *testing.T) {
 	cfg := &config.AgentConfig{RareSamplerDisabled: true, BucketInterval: 10 * time.Second}
 	statsChan := make(chan pb.StatsPayload, 100)
@@ -950,6 +1049,7 @@ func TestPartialSamplingFree(t *testing.T) {
 		Concentrator:      stats.NewConcentrator(cfg, statsChan, time.Now()),
 		Blacklister:       filters.NewBlacklister(cfg.Ignore["resource"]),
 		Replacer:          filters.NewReplacer(cfg.ReplaceTags),
+		SpanFilter:        filters.NewSpanFilter(cfg.FilterSpans),
 		NoPrioritySampler: sampler.NewNoPrioritySampler(cfg),
 		ErrorsSampler:     sampler.NewErrorsSampler(cfg),
 		PrioritySampler:   sampler.NewPrioritySampler(cfg, &sampler.DynamicConfig{}),
@@ -6,6 +6,7 @@
 package agent
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
@@ -21,11 +22,20 @@ const (
 	tagMongoDBQuery     = "mongodb.query"
 	tagElasticBody      = "elasticsearch.body"
 	tagSQLQuery         = "sql.query"
+	tagSQLSignature     = "sql.signature"
 	tagHTTPURL          = "http.url"
+	tagDBSystem         = "db.system"
+	tagDBType           = "db.type"
+
+	tagGraphQLSource        = "graphql.source"
+	tagGraphQLOperationType = "graphql.operation.type"
+	tagGraphQLOperationName = "graphql.operation.name"
+	tagGraphQLFields        = "graphql.fields"
 )
 
 const (
-	textNonParsable = "Non-parsable SQL query"
+	textNonParsable        = "Non-parsable SQL query"
+	textNonParsableGraphQL = "Non-parsable GraphQL query"
 )
 
 func (a *Agent) obfuscateSpan(span *pb.Span) {
@@ -35,7 +45,7 @@ func (a *Agent) obfuscateSpan(span *pb.Span) {
 		if span.Resource == "" {
 			return
 		}
-		oq, err := o.ObfuscateSQLString(span.Resource)
+		oq, err := o.ObfuscateSQLStringForDBMS(span.Resource, spanDBMS(span))
 		if err != nil {
 			// we have an error, discard the SQL to avoid polluting user resources.
 			log.Debugf("Error parsing SQL query: %v. Resource: %q", err, span.Resource)
@@ -54,6 +64,9 @@ func (a *Agent) obfuscateSpan(span *pb.Span) {
 		if len(oq.Metadata.TablesCSV) > 0 {
 			traceutil.SetMeta(span, "sql.tables", oq.Metadata.TablesCSV)
 		}
+		if oq.Metadata.Signature != 0 {
+			traceutil.SetMeta(span, tagSQLSignature, strconv.FormatUint(oq.Metadata.Signature, 16))
+		}
 		if span.Meta != nil && span.Meta[tagSQLQuery] != "" {
 			// "sql.query" tag already set by user, do not change it.
 			return
@@ -97,7 +110,73 @@ func (a *Agent) obfuscateSpan(span *pb.Span) {
 			return
 		}
 		span.Meta[tagElasticBody] = o.ObfuscateElasticSearchString(v)
+	case "graphql":
+		if a.conf.Obfuscation.GraphQL.Enabled {
+			a.obfuscateGraphQLSpan(span)
+		}
+	}
+}
+
+// obfuscateGraphQLSpan obfuscates the GraphQL query found in the resource and in the
+// "graphql.source" tag of span, and tags it with the metadata of the query unless the
+// tracer already did.
+func (a *Agent) obfuscateGraphQLSpan(span *pb.Span) {
+	o := a.obfuscator
+	if span.Resource != "" {
+		oq, err := o.ObfuscateGraphQLString(span.Resource)
+		if err != nil {
+			log.Debugf("Error parsing GraphQL query: %v. Resource: %q", err, span.Resource)
+			span.Resource = textNonParsableGraphQL
+		} else {
+			span.Resource = oq.Query
+			setGraphQLMetadata(span, oq.Metadata)
+		}
+	}
+	v, ok := span.Meta[tagGraphQLSource]
+	if !ok || v == "" {
+		return
 	}
+	oq, err := o.ObfuscateGraphQLString(v)
+	if err != nil {
+		log.Debugf("Error parsing GraphQL query: %v. Source: %q", err, v)
+		span.Meta[tagGraphQLSource] = textNonParsableGraphQL
+		return
+	}
+	span.Meta[tagGraphQLSource] = oq.Query
+	setGraphQLMetadata(span, oq.Metadata)
+}
+
+// setGraphQLMetadata sets the tags of span describing the GraphQL operation md, leaving
+// alone the ones which are already set.
+func setGraphQLMetadata(span *pb.Span, md obfuscate.GraphQLMetadata) {
+	for k, v := range map[string]string{
+		tagGraphQLOperationType: md.OperationType,
+		tagGraphQLOperationName: md.OperationName,
+		tagGraphQLFields:        md.FieldsCSV,
+	} {
+		if v == "" || span.Meta[k] != "" {
+			continue
+		}
+		traceutil.SetMeta(span, k, v)
+	}
+}
+
+// spanDBMS returns the DBMS whose SQL dialect the obfuscator should use for the span, based on the
+// database system reported by the tracer. It returns an empty string to use the configured DBMS.
+func spanDBMS(span *pb.Span) string {
+	system, ok := span.Meta[tagDBSystem]
+	if !ok {
+		system = span.Meta[tagDBType]
+	}
+	switch strings.ToLower(system) {
+	case "mssql", "sqlserver":
+		return obfuscate.DBMSSQLServer
+	case "oracle":
+		return obfuscate.DBMSOracle
+	case "snowflake":
+		return obfuscate.DBMSSnowflake
+	}
+	return ""
 }
 
 func (a *Agent) obfuscateStatsGroup(b *pb.ClientGroupedStats) {
@@ -113,6 +192,17 @@ func (a *Agent) obfuscateStatsGroup(b *pb.ClientGroupedStats) {
 		}
 	case "redis":
 		b.Resource = o.QuantizeRedisString(b.Resource)
+	case "graphql":
+		if !a.conf.Obfuscation.GraphQL.Enabled {
+			return
+		}
+		oq, err := o.ObfuscateGraphQLString(b.Resource)
+		if err != nil {
+			log.Errorf("Error obfuscating stats group resource %q: %v", b.Resource, err)
+			b.Resource = textNonParsableGraphQL
+		} else {
+			b.Resource = oq.Query
+		}
 	}
 }
 
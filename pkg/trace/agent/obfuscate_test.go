@@ -64,6 +64,7 @@ func TestObfuscateStatsGroup(t *testing.T) {
 		{statsGroup("sql", "SELECT 1 FROM db"), "SELECT ? FROM db"},
 		{statsGroup("sql", "SELECT 1\nFROM Blogs AS [b\nORDER BY [b]"), textNonParsable},
 		{statsGroup("redis", "ADD 1, 2"), "ADD"},
+		{statsGroup("graphql", `{ user(id: 1) { name } }`), `{ user(id: 1) { name } }`},
 		{statsGroup("other", "ADD 1, 2"), "ADD 1, 2"},
 	} {
 		agnt, stop := agentWithDefaults()
@@ -306,6 +307,100 @@ ORDER BY [b].[Name]`,
 	}
 }
 
+func TestSQLResourceDBMS(t *testing.T) {
+	agnt, stop := agentWithDefaults()
+	defer stop()
+	for _, tt := range []struct {
+		meta     map[string]string
+		resource string
+		out      string
+	}{
+		{
+			map[string]string{"db.system": "oracle"},
+			"SELECT * FROM users WHERE name = q'[O'Brien]'",
+			"SELECT * FROM users WHERE name = ?",
+		},
+		{
+			map[string]string{"db.type": "sqlserver"},
+			"SELECT [user name] FROM [dbo].[users] WHERE name = N'bob'",
+			"SELECT [user name] FROM [dbo].[users] WHERE name = ?",
+		},
+		{
+			map[string]string{"db.system": "snowflake"},
+			`SELECT src:"first name" FROM events WHERE id = 42`,
+			`SELECT src:"first name" FROM events WHERE id = ?`,
+		},
+	} {
+		span := &pb.Span{
+			Resource: tt.resource,
+			Type:     "sql",
+			Meta:     tt.meta,
+		}
+		agnt.obfuscateSpan(span)
+		assert.Equal(t, tt.out, span.Resource)
+	}
+}
+
+func TestObfuscateGraphQL(t *testing.T) {
+	newAgent := func(enabled bool) (*Agent, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Obfuscation.GraphQL.Enabled = enabled
+		return NewAgent(ctx, cfg), cancel
+	}
+	query := `query GetUser { user(id: 42) { name } }`
+
+	t.Run("span", func(t *testing.T) {
+		agnt, stop := newAgent(true)
+		defer stop()
+		span := &pb.Span{
+			Type:     "graphql",
+			Resource: query,
+			Meta:     map[string]string{"graphql.source": query, "graphql.operation.name": "custom"},
+		}
+		agnt.obfuscateSpan(span)
+		obfuscated := `query GetUser { user(id: ?) { name } }`
+		assert.Equal(t, obfuscated, span.Resource)
+		assert.Equal(t, map[string]string{
+			"graphql.source":         obfuscated,
+			"graphql.operation.type": "query",
+			"graphql.operation.name": "custom",
+			"graphql.fields":         "user",
+		}, span.Meta)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		agnt, stop := newAgent(true)
+		defer stop()
+		span := &pb.Span{
+			Type:     "graphql",
+			Resource: `{ user(name: "unterminated) }`,
+			Meta:     map[string]string{"graphql.source": `{ user(name: "unterminated) }`},
+		}
+		agnt.obfuscateSpan(span)
+		assert.Equal(t, textNonParsableGraphQL, span.Resource)
+		assert.Equal(t, map[string]string{"graphql.source": textNonParsableGraphQL}, span.Meta)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		agnt, stop := newAgent(true)
+		defer stop()
+		b := &pb.ClientGroupedStats{Type: "graphql", Resource: query}
+		agnt.obfuscateStatsGroup(b)
+		assert.Equal(t, `query GetUser { user(id: ?) { name } }`, b.Resource)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		agnt, stop := newAgent(false)
+		defer stop()
+		span := &pb.Span{Type: "graphql", Resource: query}
+		agnt.obfuscateSpan(span)
+		assert.Equal(t, query, span.Resource)
+		assert.Empty(t, span.Meta)
+	})
+}
+
 func TestSQLTableNames(t *testing.T) {
 	t.Run("on", func(t *testing.T) {
 		defer testutil.WithFeatures("table_names")()
@@ -331,3 +426,27 @@ func TestSQLTableNames(t *testing.T) {
 		assert.Empty(t, span.Meta["sql.tables"])
 	})
 }
+
+func TestSQLSignature(t *testing.T) {
+	t.Run("on", func(t *testing.T) {
+		defer testutil.WithFeatures("sql_signature")()
+		agnt, stop := agentWithDefaults()
+		defer stop()
+		span := &pb.Span{Resource: "SELECT * FROM users WHERE id IN (1, 2)", Type: "sql"}
+		agnt.obfuscateSpan(span)
+		sig := span.Meta["sql.signature"]
+		assert.NotEmpty(t, sig)
+
+		span = &pb.Span{Resource: "select * from Users where id in (3)", Type: "sql"}
+		agnt.obfuscateSpan(span)
+		assert.Equal(t, sig, span.Meta["sql.signature"])
+	})
+
+	t.Run("off", func(t *testing.T) {
+		agnt, stop := agentWithDefaults()
+		defer stop()
+		span := &pb.Span{Resource: "SELECT * FROM users WHERE id IN (1, 2)", Type: "sql"}
+		agnt.obfuscateSpan(span)
+		assert.NotContains(t, span.Meta, "sql.signature")
+	})
+}
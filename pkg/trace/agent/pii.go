@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/obfuscate"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// defaultPIITags holds the tags scanned for PII when none are configured.
+var defaultPIITags = []string{"http.url", "http.query.string", "error.msg", "error.message"}
+
+// piiRedactor redacts personally identifiable information (PII) found in span tags.
+type piiRedactor struct {
+	detectors []*obfuscate.PIIDetector
+	// counts holds the number of redactions made by each detector since the last report.
+	counts []*atomic.Int64
+	// tags holds the scanned tags, or nil if all tags are scanned.
+	tags []string
+	// keep holds the tags which are never scanned.
+	keep map[string]bool
+
+	tickStats *time.Ticker
+}
+
+// newPIIRedactor returns a new piiRedactor using the given configuration, or nil if
+// it is disabled. The configuration is expected to have been validated.
+func newPIIRedactor(cfg config.PIIObfuscationConfig) *piiRedactor {
+	if !cfg.Enabled {
+		return nil
+	}
+	r := &piiRedactor{
+		tags:      cfg.Tags,
+		keep:      make(map[string]bool, len(cfg.KeepTags)),
+		tickStats: time.NewTicker(10 * time.Second),
+	}
+	if len(r.tags) == 0 {
+		r.tags = defaultPIITags
+	}
+	for _, k := range r.tags {
+		if k == "*" {
+			r.tags = nil
+			break
+		}
+	}
+	for _, k := range cfg.KeepTags {
+		r.keep[k] = true
+	}
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = obfuscate.BuiltinPIIDetectorNames()
+	}
+	for _, name := range names {
+		if d, ok := obfuscate.BuiltinPIIDetector(name); ok {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	for _, p := range cfg.CustomPatterns {
+		r.detectors = append(r.detectors, obfuscate.NewPIIDetector(p.Name, p.Re))
+	}
+	r.counts = make([]*atomic.Int64, len(r.detectors))
+	for i := range r.counts {
+		r.counts[i] = atomic.NewInt64(0)
+	}
+	go func() {
+		for range r.tickStats.C {
+			r.report()
+		}
+	}()
+	return r
+}
+
+// Redact redacts the PII found in the scanned tags of span.
+func (r *piiRedactor) Redact(span *pb.Span) {
+	if r == nil || len(span.Meta) == 0 {
+		return
+	}
+	if r.tags != nil {
+		for _, k := range r.tags {
+			if v, ok := span.Meta[k]; ok && !r.keep[k] {
+				span.Meta[k] = r.redact(v)
+			}
+		}
+		return
+	}
+	for k, v := range span.Meta {
+		if r.keep[k] || strings.HasPrefix(k, "_dd") {
+			continue
+		}
+		span.Meta[k] = r.redact(v)
+	}
+}
+
+// redact returns v with the PII found by all detectors replaced by "?".
+func (r *piiRedactor) redact(v string) string {
+	for i, d := range r.detectors {
+		var n int
+		if v, n = d.Redact(v); n > 0 {
+			r.counts[i].Add(int64(n))
+		}
+	}
+	return v
+}
+
+func (r *piiRedactor) report() {
+	for i, d := range r.detectors {
+		if n := r.counts[i].Swap(0); n > 0 {
+			metrics.Count("datadog.trace_agent.obfuscation.pii.redacted", n, []string{"detector:" + d.Name()}, 1)
+		}
+	}
+}
+
+// Stop stops reporting the redaction counts.
+func (r *piiRedactor) Stop() {
+	if r == nil {
+		return
+	}
+	r.tickStats.Stop()
+	r.report()
+}
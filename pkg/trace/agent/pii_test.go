@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+func TestPIIRedactor(t *testing.T) {
+	meta := func() map[string]string {
+		return map[string]string{
+			"http.url":      "https://example.com/users?email=jane%40example.com",
+			"error.message": "no account for jane@example.com, call +33612345678",
+			"user.email":    "jane@example.com",
+			"http.route":    "/users/jane@example.com",
+			"_dd.origin":    "jane@example.com",
+			"employee":      "EMP-123456",
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		r := newPIIRedactor(config.PIIObfuscationConfig{})
+		assert.Nil(t, r)
+		span := &pb.Span{Meta: meta()}
+		r.Redact(span)
+		assert.Equal(t, meta(), span.Meta)
+		r.Stop()
+	})
+
+	t.Run("default", func(t *testing.T) {
+		r := newPIIRedactor(config.PIIObfuscationConfig{Enabled: true})
+		defer r.Stop()
+		span := &pb.Span{Meta: meta()}
+		r.Redact(span)
+		want := meta()
+		want["http.url"] = "https://example.com/users?email=?"
+		want["error.message"] = "no account for ?, call ?"
+		assert.Equal(t, want, span.Meta)
+		counts := make(map[string]int64)
+		for i, d := range r.detectors {
+			counts[d.Name()] = r.counts[i].Load()
+		}
+		assert.Equal(t, int64(2), counts["email"])
+		assert.Equal(t, int64(1), counts["phone"])
+		assert.Equal(t, int64(0), counts["iban"])
+	})
+
+	t.Run("all", func(t *testing.T) {
+		r := newPIIRedactor(config.PIIObfuscationConfig{
+			Enabled:        true,
+			Detectors:      []string{"email"},
+			CustomPatterns: []*config.PIIPattern{{Name: "employee_id", Re: regexp.MustCompile(`EMP-\d{6}`)}},
+			Tags:           []string{"*"},
+			KeepTags:       []string{"http.route"},
+		})
+		defer r.Stop()
+		span := &pb.Span{Meta: meta()}
+		r.Redact(span)
+		assert.Equal(t, map[string]string{
+			"http.url":      "https://example.com/users?email=?",
+			"error.message": "no account for ?, call +33612345678",
+			"user.email":    "?",
+			"http.route":    "/users/jane@example.com",
+			"_dd.origin":    "jane@example.com",
+			"employee":      "?",
+		}, span.Meta)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		r := newPIIRedactor(config.PIIObfuscationConfig{
+			Enabled:  true,
+			Tags:     []string{"user.email", "http.route", "missing"},
+			KeepTags: []string{"http.route"},
+		})
+		defer r.Stop()
+		span := &pb.Span{Meta: meta()}
+		r.Redact(span)
+		want := meta()
+		want["user.email"] = "?"
+		assert.Equal(t, want, span.Meta)
+		assert.NotContains(t, span.Meta, "missing")
+	})
+}
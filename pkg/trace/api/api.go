@@ -77,6 +77,7 @@ type HTTPReceiver struct {
 	statsProcessor      StatsProcessor
 	appsecHandler       http.Handler
 	containerIDProvider IDProvider
+	capture             *trafficCapture
 
 	rateLimiterResponse int // HTTP status code when refusing
 
@@ -107,6 +108,7 @@ func NewHTTPReceiver(conf *config.AgentConfig, dynConf *sampler.DynamicConfig, o
 		dynConf:             dynConf,
 		appsecHandler:       appsecHandler,
 		containerIDProvider: NewIDProvider(conf.ContainerProcRoot),
+		capture:             newTrafficCapture(),
 
 		rateLimiterResponse: rateLimiterResponse,
 
@@ -125,7 +127,11 @@ func (r *HTTPReceiver) buildMux() *http.ServeMux {
 		if e.IsEnabled != nil && !e.IsEnabled(r.conf) {
 			continue
 		}
-		mux.Handle(e.Pattern, replyWithVersion(hash, r.conf.AgentVersion, e.Handler(r)))
+		h := e.Handler(r)
+		if isCapturedPattern(e.Pattern) {
+			h = r.captureHandler(h)
+		}
+		mux.Handle(e.Pattern, replyWithVersion(hash, r.conf.AgentVersion, h))
 	}
 	mux.HandleFunc("/info", infoHandler)
 
@@ -244,6 +250,8 @@ func (r *HTTPReceiver) attachDebugHandlers(mux *http.ServeMux) {
 		runtime.SetBlockProfileRate(0)
 	})
 
+	mux.Handle("/debug/capture", r.captureDebugHandler())
+
 	mux.Handle("/debug/vars", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		// allow the GUI to call this endpoint so that the status can be reported
 		w.Header().Set("Access-Control-Allow-Origin", "http://127.0.0.1:"+r.conf.GUIPort)
@@ -299,6 +307,7 @@ func (r *HTTPReceiver) Stop() error {
 	<-r.exit
 
 	r.RateLimiter.Stop()
+	r.capture.stop()
 
 	expiry := time.Now().Add(5 * time.Second) // give it 5 seconds
 	ctx, cancel := context.WithDeadline(context.Background(), expiry)
@@ -585,14 +594,17 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 		tp.Tags[tagContainersTags] = ctags
 	}
 
-	payload := &Payload{
+	r.enqueue(&Payload{
 		Source:                 ts,
 		TracerPayload:          tp,
 		ClientComputedTopLevel: req.Header.Get(headerComputedTopLevel) != "",
 		ClientComputedStats:    req.Header.Get(headerComputedStats) != "",
 		ClientDroppedP0s:       droppedTracesFromHeader(req.Header, ts),
-	}
+	})
+}
 
+// enqueue sends the payload to the agent, without ever dropping it.
+func (r *HTTPReceiver) enqueue(payload *Payload) {
 	select {
 	case r.out <- payload:
 		// ok
@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/replay"
+)
+
+const (
+	// defaultCaptureDuration is the duration of a capture when none is requested.
+	defaultCaptureDuration = time.Minute
+	// maxCaptureDuration is the maximum duration of a capture.
+	maxCaptureDuration = time.Hour
+)
+
+// errCaptureOngoing is returned when starting a capture while another one is ongoing.
+var errCaptureOngoing = errors.New("a capture is already ongoing")
+
+// trafficCapture records the requests received by the trace and stats endpoints
+// to a capture file, which can be replayed using the replay package.
+type trafficCapture struct {
+	active *atomic.Bool // reports whether a capture is ongoing
+
+	mu      sync.Mutex
+	f       *os.File
+	w       *replay.Writer
+	timer   *time.Timer
+	size    int64 // size of the records written to f
+	maxSize int64 // size of the records above which the capture stops
+}
+
+func newTrafficCapture() *trafficCapture {
+	return &trafficCapture{active: atomic.NewBool(false)}
+}
+
+// start starts capturing to a new file in dir for the duration d, or until maxSize
+// bytes of records are captured. It returns the path of the file.
+func (c *trafficCapture) start(dir string, d time.Duration, maxSize int64) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.f != nil {
+		return "", errCaptureOngoing
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("trace-agent-capture-%d.gz", time.Now().Unix())
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	w, err := replay.NewWriter(f)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	c.f, c.w = f, w
+	c.size, c.maxSize = 0, maxSize
+	c.timer = time.AfterFunc(d, c.stop)
+	c.active.Store(true)
+	log.Infof("Capturing the trace-agent traffic to %s for %s", f.Name(), d)
+	return f.Name(), nil
+}
+
+// stop stops the ongoing capture, if any.
+func (c *trafficCapture) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+}
+
+// stopLocked stops the ongoing capture, if any. c.mu must be held.
+func (c *trafficCapture) stopLocked() {
+	if c.f == nil {
+		return
+	}
+	c.active.Store(false)
+	c.timer.Stop()
+	if err := c.w.Close(); err != nil {
+		log.Errorf("Error writing capture file %s: %v", c.f.Name(), err)
+	}
+	if err := c.f.Close(); err != nil {
+		log.Errorf("Error closing capture file %s: %v", c.f.Name(), err)
+	}
+	log.Infof("Capture of the trace-agent traffic written to %s", c.f.Name())
+	c.f, c.w, c.timer = nil, nil, nil
+}
+
+// record writes rec to the capture file, if a capture is ongoing. The capture
+// stops once rec would exceed its maximum size.
+func (c *trafficCapture) record(rec *replay.Record) {
+	// encode outside of the lock, which serializes the receiver handlers
+	b := replay.EncodeRecord(rec)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.w == nil {
+		return
+	}
+	if c.size+int64(len(b)) > c.maxSize {
+		log.Warnf("Capture of the trace-agent traffic reached its maximum size of %d bytes, stopping it", c.maxSize)
+		c.stopLocked()
+		return
+	}
+	c.size += int64(len(b))
+	if err := c.w.WriteEncoded(b); err != nil {
+		log.Errorf("Error writing to capture file %s: %v", c.f.Name(), err)
+	}
+}
+
+// isCapturedPattern reports whether requests to the endpoint registered with pattern
+// hold traces or stats, and should thus be captured.
+func isCapturedPattern(pattern string) bool {
+	for _, suffix := range []string{"/spans", "/services", "/traces", "/stats"} {
+		if strings.HasSuffix(pattern, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureHandler returns an http.Handler which records the requests to h while a
+// capture is ongoing.
+func (r *HTTPReceiver) captureHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.capture.active.Load() {
+			h.ServeHTTP(w, req)
+			return
+		}
+		rec := &replay.Record{
+			Time:        time.Now(),
+			Method:      req.Method,
+			Path:        req.URL.RequestURI(),
+			Header:      req.Header.Clone(),
+			ContainerID: r.containerIDProvider.GetContainerID(req.Context(), req.Header),
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, r.conf.MaxRequestBytes+1))
+		// let the handler deal with both read errors and oversized bodies
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err == nil && int64(len(body)) <= r.conf.MaxRequestBytes {
+			rec.Body = body
+			r.capture.record(rec)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// captureDebugHandler returns the handler of the /debug/capture endpoint. A POST
+// starts a capture, for the duration given by the optional duration query parameter,
+// and replies with the path of the capture file. A DELETE stops the ongoing capture.
+// The captures hold the payloads of every client, so the endpoint only serves requests
+// from the host of the agent, even when the receiver accepts non-local traffic.
+func (r *HTTPReceiver) captureDebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isLocalRequest(req) {
+			http.Error(w, "captures can only be controlled from the host of the agent", http.StatusForbidden)
+			return
+		}
+		switch req.Method {
+		case http.MethodPost:
+		case http.MethodDelete:
+			r.capture.stop()
+			return
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		d := defaultCaptureDuration
+		if v := req.URL.Query().Get("duration"); v != "" {
+			var err error
+			if d, err = time.ParseDuration(v); err != nil || d <= 0 || d > maxCaptureDuration {
+				http.Error(w, fmt.Sprintf("duration must be a positive duration of at most %s", maxCaptureDuration), http.StatusBadRequest)
+				return
+			}
+		}
+		dir := r.conf.CapturePath
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		path, err := r.capture.start(dir, d, r.conf.CaptureMaxSize)
+		switch err {
+		case nil:
+		case errCaptureOngoing:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Path     string `json:"path"`
+			Duration string `json:"duration"`
+		}{path, d.String()})
+	})
+}
+
+// isLocalRequest reports whether req was received on a loopback address, a unix
+// socket or a named pipe.
+func isLocalRequest(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		// not a TCP connection
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
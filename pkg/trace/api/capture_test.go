@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/replay"
+	"github.com/DataDog/datadog-agent/pkg/trace/testutil"
+)
+
+func TestCapture(t *testing.T) {
+	conf := newTestReceiverConfig()
+	conf.CapturePath = t.TempDir()
+	r := newTestReceiverFromConfig(conf)
+	mux := r.buildMux()
+	serve := func(method, uri string, body []byte, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, bytes.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:40000"
+		for k, vs := range header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	traces, err := testutil.GetTestTraces(2, 3, false).MarshalMsg(nil)
+	require.NoError(t, err)
+	header := http.Header{
+		"Content-Type":          {"application/msgpack"},
+		"Datadog-Meta-Lang":     {"go"},
+		"X-Datadog-Trace-Count": {"2"},
+		headerContainerID:       {"abcdef"},
+	}
+
+	// not captured, no capture is ongoing
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v0.4/traces", traces, header).Code)
+
+	w := serve(http.MethodPost, "/debug/capture?duration=1m", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var out struct{ Path, Duration string }
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.Equal(t, "1m0s", out.Duration)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/debug/capture", nil, nil).Code)
+
+	// captured, and still processed
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v0.4/traces", traces, header).Code)
+	// not captured, not a traces or stats endpoint
+	serve(http.MethodPost, "/telemetry/proxy/api/v2/apmtelemetry", []byte("{}"), nil)
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/debug/capture", nil, nil).Code)
+	// not captured, the capture is over
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v0.4/traces", traces, header).Code)
+	assert.Len(t, r.out, 3)
+
+	f, err := os.Open(out.Path)
+	require.NoError(t, err)
+	defer f.Close()
+	cr, err := replay.NewReader(f)
+	require.NoError(t, err)
+	rec, err := cr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, rec.Method)
+	assert.Equal(t, "/v0.4/traces", rec.Path)
+	assert.Equal(t, "abcdef", rec.ContainerID)
+	assert.Equal(t, "go", rec.Header.Get("Datadog-Meta-Lang"))
+	assert.Equal(t, traces, rec.Body)
+	_, err = cr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/debug/capture?duration=2h", nil, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/debug/capture?duration=soon", nil, nil).Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/debug/capture", nil, nil).Code)
+	})
+}
+
+func TestCaptureMaxSize(t *testing.T) {
+	traces, err := testutil.GetTestTraces(2, 3, false).MarshalMsg(nil)
+	require.NoError(t, err)
+	conf := newTestReceiverConfig()
+	conf.CapturePath = t.TempDir()
+	// room for a single request
+	conf.CaptureMaxSize = int64(len(traces)) * 3 / 2
+	r := newTestReceiverFromConfig(conf)
+	mux := r.buildMux()
+	serve := func(method, uri string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, bytes.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("Content-Type", "application/msgpack")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/debug/capture", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var out struct{ Path string }
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v0.4/traces", traces).Code)
+	assert.True(t, r.capture.active.Load())
+	// stops the capture, and is still processed
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v0.4/traces", traces).Code)
+	assert.False(t, r.capture.active.Load())
+	assert.Len(t, r.out, 2)
+
+	f, err := os.Open(out.Path)
+	require.NoError(t, err)
+	defer f.Close()
+	cr, err := replay.NewReader(f)
+	require.NoError(t, err)
+	rec, err := cr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, traces, rec.Body)
+	_, err = cr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCaptureNonLocalRequest(t *testing.T) {
+	conf := newTestReceiverConfig()
+	conf.CapturePath = t.TempDir()
+	r := newTestReceiverFromConfig(conf)
+	mux := r.buildMux()
+	serve := func(method, remoteAddr string) int {
+		req := httptest.NewRequest(method, "/debug/capture", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "10.0.0.1:40000"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "[2001:db8::1]:40000"))
+	assert.False(t, r.capture.active.Load())
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "[::1]:40000"))
+	assert.True(t, r.capture.active.Load())
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "10.0.0.1:40000"))
+	assert.True(t, r.capture.active.Load())
+	// unix sockets and named pipes are local
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "@"))
+	assert.False(t, r.capture.active.Load())
+}
@@ -0,0 +1,546 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+// jaegerThrift is the version of the Jaeger intake endpoint, accepting batches
+// of spans encoded with the Thrift binary protocol, as sent over HTTP by the
+// Jaeger clients to the Jaeger collector.
+const jaegerThrift Version = "jaeger_thrift"
+
+// Jaeger span flags
+const (
+	jaegerFlagSampled = 1
+	jaegerFlagDebug   = 2
+)
+
+// jaegerTagType is the type of the value of a jaegerTag.
+type jaegerTagType int32
+
+const (
+	jaegerTagString jaegerTagType = iota
+	jaegerTagDouble
+	jaegerTagBool
+	jaegerTagLong
+	jaegerTagBinary
+)
+
+// jaegerBatch, and the types it is made of, follow the definitions of
+// https://github.com/jaegertracing/jaeger-idl/blob/master/thrift/jaeger.thrift
+type jaegerBatch struct {
+	process jaegerProcess
+	spans   []jaegerSpan
+}
+
+type jaegerProcess struct {
+	serviceName string
+	tags        []jaegerTag
+}
+
+type jaegerSpan struct {
+	traceIDLow    uint64
+	spanID        uint64
+	parentSpanID  uint64
+	operationName string
+	references    []jaegerSpanRef
+	flags         int32
+	startTime     int64 // microseconds
+	duration      int64 // microseconds
+	tags          []jaegerTag
+	logs          []jaegerLog
+}
+
+type jaegerSpanRef struct {
+	refType    int32 // 0 is CHILD_OF, 1 is FOLLOWS_FROM
+	traceIDLow uint64
+	spanID     uint64
+}
+
+type jaegerLog struct {
+	timestamp int64 // microseconds
+	fields    []jaegerTag
+}
+
+type jaegerTag struct {
+	key     string
+	vType   jaegerTagType
+	vStr    string
+	vDouble float64
+	vBool   bool
+	vLong   int64
+	vBinary []byte
+}
+
+// String returns the value of the tag as a string.
+func (t *jaegerTag) String() string {
+	switch t.vType {
+	case jaegerTagDouble:
+		return strconv.FormatFloat(t.vDouble, 'f', -1, 64)
+	case jaegerTagBool:
+		return strconv.FormatBool(t.vBool)
+	case jaegerTagLong:
+		return strconv.FormatInt(t.vLong, 10)
+	case jaegerTagBinary:
+		return base64.StdEncoding.EncodeToString(t.vBinary)
+	default:
+		return t.vStr
+	}
+}
+
+// decodeJaegerPayload decodes a Jaeger batch of spans.
+func decodeJaegerPayload(mediaType string, body []byte) (*pb.TracerPayload, error) {
+	switch mediaType {
+	case "application/x-thrift", "application/vnd.apache.thrift.binary":
+	default:
+		return nil, errUnsupportedMediaType
+	}
+	batch, err := unmarshalJaegerBatch(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostname string
+	processMeta := make(map[string]string, len(batch.process.tags))
+	for _, t := range batch.process.tags {
+		processMeta[t.key] = t.String()
+		if t.key == "hostname" {
+			hostname = t.vStr
+		}
+	}
+	spans := make([]*pb.Span, 0, len(batch.spans))
+	priorities := make([]int32, 0, len(batch.spans))
+	for i := range batch.spans {
+		s := &batch.spans[i]
+		spans = append(spans, s.convert(batch.process.serviceName, processMeta))
+		switch {
+		case s.flags&jaegerFlagDebug != 0:
+			priorities = append(priorities, int32(sampler.PriorityUserKeep))
+		case s.flags&jaegerFlagSampled != 0:
+			priorities = append(priorities, int32(sampler.PriorityAutoKeep))
+		default:
+			priorities = append(priorities, int32(sampler.PriorityAutoDrop))
+		}
+	}
+	return &pb.TracerPayload{
+		Hostname: hostname,
+		Chunks:   translatedChunks(spans, priorities),
+	}, nil
+}
+
+// convert returns the Datadog span of a Jaeger span, the tags of its process
+// being added to its own.
+func (s *jaegerSpan) convert(service string, processMeta map[string]string) *pb.Span {
+	span := &pb.Span{
+		TraceID:  s.traceIDLow,
+		SpanID:   s.spanID,
+		ParentID: s.parentSpanID,
+		Service:  service,
+		Start:    s.startTime * 1000,
+		Duration: s.duration * 1000,
+		Meta:     make(map[string]string, len(processMeta)+len(s.tags)),
+		Metrics:  map[string]float64{},
+	}
+	if span.ParentID == 0 {
+		for _, ref := range s.references {
+			if ref.refType == 0 && ref.traceIDLow == s.traceIDLow {
+				span.ParentID = ref.spanID
+				break
+			}
+		}
+	}
+	for k, v := range processMeta {
+		span.Meta[k] = v
+	}
+
+	var kind, peerHost string
+	var peerPort int
+	for i := range s.tags {
+		t := &s.tags[i]
+		switch t.key {
+		case "error":
+			if t.vBool || t.vStr == "true" {
+				span.Error = 1
+			}
+		case tagSpanKind:
+			kind = t.String()
+			span.Meta[tagSpanKind] = kind
+		case "peer.hostname", "peer.ipv6":
+			peerHost = t.String()
+			span.Meta[t.key] = peerHost
+		case "peer.ipv4":
+			// the OpenTracing conventions allow both an integer and a string
+			if t.vType == jaegerTagLong {
+				ip := make(net.IP, net.IPv4len)
+				binary.BigEndian.PutUint32(ip, uint32(t.vLong))
+				peerHost = ip.String()
+			} else {
+				peerHost = t.String()
+			}
+			span.Meta[t.key] = peerHost
+		case "http.status_code":
+			// kept as a tag, as expected by the stats
+			span.Meta[t.key] = t.String()
+		case "peer.port":
+			peerPort, _ = strconv.Atoi(t.String())
+			span.Meta[t.key] = t.String()
+		default:
+			switch t.vType {
+			case jaegerTagDouble:
+				span.Metrics[t.key] = t.vDouble
+			case jaegerTagLong:
+				span.Metrics[t.key] = float64(t.vLong)
+			default:
+				span.Meta[t.key] = t.String()
+			}
+		}
+	}
+	setPeerTags(span.Meta, "", peerHost, peerPort)
+
+	if len(s.logs) > 0 {
+		events := make([]translatedEvent, 0, len(s.logs))
+		for _, l := range s.logs {
+			e := translatedEvent{
+				TimeUnixNano: uint64(l.timestamp) * 1000,
+				Attributes:   make(map[string]string, len(l.fields)),
+			}
+			for i := range l.fields {
+				e.Attributes[l.fields[i].key] = l.fields[i].String()
+			}
+			e.Name = e.Attributes["event"]
+			if e.Name == "error" && span.Error != 0 {
+				setJaegerErrorTags(span.Meta, e.Attributes)
+			}
+			events = append(events, e)
+		}
+		span.Meta["events"] = marshalTranslatedEvents(events)
+	}
+
+	span.Name = translatedSpanName("jaeger", kind)
+	if span.Resource = resourceFromTags(span.Meta); span.Resource == "" {
+		span.Resource = s.operationName
+	}
+	span.Type = translatedSpanType(kind, span.Meta)
+	return span
+}
+
+// setJaegerErrorTags sets the error tags of a span from the fields of an error
+// log, following the OpenTracing conventions.
+func setJaegerErrorTags(meta map[string]string, fields map[string]string) {
+	for tag, field := range map[string]string{
+		"error.msg":   "message",
+		"error.type":  "error.kind",
+		"error.stack": "stack",
+	} {
+		if v := fields[field]; v != "" {
+			meta[tag] = v
+		}
+	}
+	if _, ok := meta["error.msg"]; !ok {
+		if v := fields["error.object"]; v != "" {
+			meta["error.msg"] = v
+		}
+	}
+}
+
+// Thrift types, see https://github.com/apache/thrift/blob/master/doc/specs/thrift-binary-protocol.md
+const (
+	thriftStop   = 0
+	thriftBool   = 2
+	thriftByte   = 3
+	thriftDouble = 4
+	thriftI16    = 6
+	thriftI32    = 8
+	thriftI64    = 10
+	thriftString = 11
+	thriftStruct = 12
+	thriftMap    = 13
+	thriftSet    = 14
+	thriftList   = 15
+)
+
+var errInvalidThrift = errors.New("invalid thrift payload")
+
+// thriftMaxDepth is the maximum nesting of the structs and containers of a payload, above which
+// the payload is rejected instead of overflowing the stack.
+const thriftMaxDepth = 64
+
+// thriftReader reads values encoded with the Thrift binary protocol.
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) next(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		r.err = errInvalidThrift
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) readByte() byte {
+	if v := r.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *thriftReader) readI16() int16 {
+	if v := r.next(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) readI32() int32 {
+	if v := r.next(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) readI64() int64 {
+	if v := r.next(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) readDouble() float64 {
+	return math.Float64frombits(uint64(r.readI64()))
+}
+
+func (r *thriftReader) readBinary() []byte {
+	return r.next(int(r.readI32()))
+}
+
+func (r *thriftReader) readString() string {
+	return string(r.readBinary())
+}
+
+// readStruct calls f with the ID and the type of each field of a struct,
+// f being responsible for reading or skipping the value.
+func (r *thriftReader) readStruct(f func(id int16, typ byte)) {
+	for r.err == nil {
+		typ := r.readByte()
+		if typ == thriftStop {
+			return
+		}
+		id := r.readI16()
+		if r.err != nil {
+			return
+		}
+		f(id, typ)
+	}
+}
+
+// readList calls f for each element of a list of elements of type elemType,
+// and skips the list if its elements are of another type.
+func (r *thriftReader) readList(elemType byte, f func()) {
+	typ := r.readByte()
+	size := int(r.readI32())
+	if size < 0 || size > len(r.b) {
+		r.err = errInvalidThrift
+	}
+	for i := 0; i < size && r.err == nil; i++ {
+		if typ == elemType {
+			f()
+		} else {
+			r.skip(typ)
+		}
+	}
+}
+
+// skip skips a value of type typ.
+func (r *thriftReader) skip(typ byte) {
+	r.skipNested(typ, 0)
+}
+
+// skipNested skips a value of type typ nested in depth structs or containers.
+func (r *thriftReader) skipNested(typ byte, depth int) {
+	if depth > thriftMaxDepth {
+		r.err = errInvalidThrift
+		return
+	}
+	switch typ {
+	case thriftBool, thriftByte:
+		r.next(1)
+	case thriftI16:
+		r.next(2)
+	case thriftI32:
+		r.next(4)
+	case thriftDouble, thriftI64:
+		r.next(8)
+	case thriftString:
+		r.readBinary()
+	case thriftStruct:
+		r.readStruct(func(_ int16, typ byte) { r.skipNested(typ, depth+1) })
+	case thriftMap:
+		keyType, valueType := r.readByte(), r.readByte()
+		size := int(r.readI32())
+		if size < 0 || size > len(r.b) {
+			r.err = errInvalidThrift
+		}
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skipNested(keyType, depth+1)
+			r.skipNested(valueType, depth+1)
+		}
+	case thriftSet, thriftList:
+		elemType := r.readByte()
+		size := int(r.readI32())
+		if size < 0 || size > len(r.b) {
+			r.err = errInvalidThrift
+		}
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skipNested(elemType, depth+1)
+		}
+	default:
+		r.err = errInvalidThrift
+	}
+}
+
+// unmarshalJaegerBatch unmarshals a jaeger.Batch struct.
+func unmarshalJaegerBatch(b []byte) (jaegerBatch, error) {
+	var batch jaegerBatch
+	r := &thriftReader{b: b}
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftStruct:
+			batch.process = r.readJaegerProcess()
+		case id == 2 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				batch.spans = append(batch.spans, r.readJaegerSpan())
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+	return batch, r.err
+}
+
+func (r *thriftReader) readJaegerProcess() jaegerProcess {
+	var p jaegerProcess
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftString:
+			p.serviceName = r.readString()
+		case id == 2 && typ == thriftList:
+			p.tags = r.readJaegerTags()
+		default:
+			r.skip(typ)
+		}
+	})
+	return p
+}
+
+func (r *thriftReader) readJaegerSpan() jaegerSpan {
+	var s jaegerSpan
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI64:
+			s.traceIDLow = uint64(r.readI64())
+		case id == 3 && typ == thriftI64:
+			s.spanID = uint64(r.readI64())
+		case id == 4 && typ == thriftI64:
+			s.parentSpanID = uint64(r.readI64())
+		case id == 5 && typ == thriftString:
+			s.operationName = r.readString()
+		case id == 6 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				s.references = append(s.references, r.readJaegerSpanRef())
+			})
+		case id == 7 && typ == thriftI32:
+			s.flags = r.readI32()
+		case id == 8 && typ == thriftI64:
+			s.startTime = r.readI64()
+		case id == 9 && typ == thriftI64:
+			s.duration = r.readI64()
+		case id == 10 && typ == thriftList:
+			s.tags = r.readJaegerTags()
+		case id == 11 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				s.logs = append(s.logs, r.readJaegerLog())
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+	return s
+}
+
+func (r *thriftReader) readJaegerSpanRef() jaegerSpanRef {
+	var ref jaegerSpanRef
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI32:
+			ref.refType = r.readI32()
+		case id == 2 && typ == thriftI64:
+			ref.traceIDLow = uint64(r.readI64())
+		case id == 4 && typ == thriftI64:
+			ref.spanID = uint64(r.readI64())
+		default:
+			r.skip(typ)
+		}
+	})
+	return ref
+}
+
+func (r *thriftReader) readJaegerLog() jaegerLog {
+	var l jaegerLog
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI64:
+			l.timestamp = r.readI64()
+		case id == 2 && typ == thriftList:
+			l.fields = r.readJaegerTags()
+		default:
+			r.skip(typ)
+		}
+	})
+	return l
+}
+
+func (r *thriftReader) readJaegerTags() []jaegerTag {
+	var tags []jaegerTag
+	r.readList(thriftStruct, func() {
+		var t jaegerTag
+		r.readStruct(func(id int16, typ byte) {
+			switch {
+			case id == 1 && typ == thriftString:
+				t.key = r.readString()
+			case id == 2 && typ == thriftI32:
+				t.vType = jaegerTagType(r.readI32())
+			case id == 3 && typ == thriftString:
+				t.vStr = r.readString()
+			case id == 4 && typ == thriftDouble:
+				t.vDouble = r.readDouble()
+			case id == 5 && typ == thriftBool:
+				t.vBool = r.readByte() != 0
+			case id == 6 && typ == thriftI64:
+				t.vLong = r.readI64()
+			case id == 7 && typ == thriftString:
+				t.vBinary = r.readBinary()
+			default:
+				r.skip(typ)
+			}
+		})
+		tags = append(tags, t)
+	})
+	return tags
+}
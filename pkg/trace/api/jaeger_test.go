@@ -0,0 +1,276 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+// thriftWriter encodes values with the Thrift binary protocol.
+type thriftWriter struct {
+	bytes.Buffer
+}
+
+func (w *thriftWriter) field(typ byte, id int16) {
+	w.WriteByte(typ)
+	binary.Write(w, binary.BigEndian, id)
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(thriftI32, id)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(thriftI64, id)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) str(id int16, v string) {
+	w.field(thriftString, id)
+	binary.Write(w, binary.BigEndian, int32(len(v)))
+	w.WriteString(v)
+}
+
+func (w *thriftWriter) list(id int16, size int, elem func(i int)) {
+	w.field(thriftList, id)
+	w.WriteByte(thriftStruct)
+	binary.Write(w, binary.BigEndian, int32(size))
+	for i := 0; i < size; i++ {
+		elem(i)
+		w.WriteByte(thriftStop)
+	}
+}
+
+func (w *thriftWriter) tags(id int16, tags []jaegerTag) {
+	w.list(id, len(tags), func(i int) {
+		t := tags[i]
+		w.str(1, t.key)
+		w.i32(2, int32(t.vType))
+		switch t.vType {
+		case jaegerTagString:
+			w.str(3, t.vStr)
+		case jaegerTagDouble:
+			w.field(thriftDouble, 4)
+			binary.Write(w, binary.BigEndian, math.Float64bits(t.vDouble))
+		case jaegerTagBool:
+			w.field(thriftBool, 5)
+			if t.vBool {
+				w.WriteByte(1)
+			} else {
+				w.WriteByte(0)
+			}
+		case jaegerTagLong:
+			w.i64(6, t.vLong)
+		}
+	})
+}
+
+// jaegerThriftPayload returns a batch of a server span and its client span
+// in error, followed by an unsampled trace.
+func jaegerThriftPayload() []byte {
+	var w thriftWriter
+	// process
+	w.field(thriftStruct, 1)
+	w.str(1, "frontend")
+	w.tags(2, []jaegerTag{
+		{key: "hostname", vStr: "host-1"},
+		{key: "jaeger.version", vStr: "Go-2.30.0"},
+	})
+	w.WriteByte(thriftStop)
+
+	spans := []func(){
+		func() {
+			w.i64(1, 1)
+			w.i64(2, 0x5af7183fb1d4cf5f)
+			w.i64(3, 2)
+			w.i64(4, 0)
+			w.str(5, "HTTP GET /users/{id}")
+			w.i32(7, jaegerFlagSampled)
+			w.i64(8, 1556604172355737)
+			w.i64(9, 1431)
+			w.tags(10, []jaegerTag{
+				{key: "span.kind", vStr: "server"},
+				{key: "http.method", vStr: "GET"},
+				{key: "http.status_code", vType: jaegerTagLong, vLong: 200},
+				{key: "sampler.param", vType: jaegerTagDouble, vDouble: 0.5},
+			})
+			// an unknown field is skipped
+			w.field(thriftMap, 42)
+			w.WriteByte(thriftString)
+			w.WriteByte(thriftI32)
+			binary.Write(&w, binary.BigEndian, int32(1))
+			binary.Write(&w, binary.BigEndian, int32(1))
+			w.WriteString("k")
+			binary.Write(&w, binary.BigEndian, int32(7))
+		},
+		func() {
+			w.i64(1, 1)
+			w.i64(2, 0x5af7183fb1d4cf5f)
+			w.i64(3, 3)
+			w.i64(4, 0)
+			w.list(6, 1, func(int) {
+				w.i32(1, 0)
+				w.i64(2, 1)
+				w.i64(3, 0x5af7183fb1d4cf5f)
+				w.i64(4, 2)
+			})
+			w.str(5, "query")
+			w.i32(7, jaegerFlagSampled|jaegerFlagDebug)
+			w.i64(8, 1556604172355800)
+			w.i64(9, 800)
+			w.tags(10, []jaegerTag{
+				{key: "span.kind", vStr: "client"},
+				{key: "error", vType: jaegerTagBool, vBool: true},
+				{key: "peer.service", vStr: "users-db"},
+				{key: "peer.ipv4", vType: jaegerTagLong, vLong: 0x0a000003},
+				{key: "peer.port", vType: jaegerTagLong, vLong: 5432},
+			})
+			w.list(11, 1, func(int) {
+				w.i64(1, 1556604172356000)
+				w.tags(2, []jaegerTag{
+					{key: "event", vStr: "error"},
+					{key: "error.kind", vStr: "Timeout"},
+					{key: "message", vStr: "query timed out"},
+				})
+			})
+		},
+		func() {
+			w.i64(1, 4)
+			w.i64(2, 0)
+			w.i64(3, 5)
+			w.i64(4, 0)
+			w.str(5, "compute")
+			w.i32(7, 0)
+			w.i64(8, 1556604172355800)
+			w.i64(9, 10)
+		},
+	}
+	w.list(2, len(spans), func(i int) { spans[i]() })
+	w.WriteByte(thriftStop)
+	return w.Bytes()
+}
+
+func assertJaegerPayload(t *testing.T, tp *pb.TracerPayload) {
+	assert := assert.New(t)
+	assert.Equal("host-1", tp.Hostname)
+	require.Len(t, tp.Chunks, 2)
+
+	chunk := tp.Chunks[0]
+	assert.EqualValues(sampler.PriorityUserKeep, chunk.Priority)
+	require.Len(t, chunk.Spans, 2)
+	assert.Equal(&pb.Span{
+		Service:  "frontend",
+		Name:     "jaeger.server",
+		Resource: "GET",
+		TraceID:  1,
+		SpanID:   2,
+		Start:    1556604172355737000,
+		Duration: 1431000,
+		Meta: map[string]string{
+			"hostname":         "host-1",
+			"jaeger.version":   "Go-2.30.0",
+			"span.kind":        "server",
+			"http.method":      "GET",
+			"http.status_code": "200",
+		},
+		Metrics: map[string]float64{"sampler.param": 0.5},
+		Type:    "web",
+	}, chunk.Spans[0])
+	assert.Equal(&pb.Span{
+		Service:  "frontend",
+		Name:     "jaeger.client",
+		Resource: "query",
+		TraceID:  1,
+		SpanID:   3,
+		ParentID: 2,
+		Start:    1556604172355800000,
+		Duration: 800000,
+		Error:    1,
+		Meta: map[string]string{
+			"hostname":       "host-1",
+			"jaeger.version": "Go-2.30.0",
+			"span.kind":      "client",
+			"peer.service":   "users-db",
+			"peer.ipv4":      "10.0.0.3",
+			"peer.port":      "5432",
+			"out.host":       "10.0.0.3",
+			"out.port":       "5432",
+			"error.msg":      "query timed out",
+			"error.type":     "Timeout",
+			"events":         `[{"time_unix_nano":1556604172356000000,"name":"error","attributes":{"error.kind":"Timeout","event":"error","message":"query timed out"}}]`,
+		},
+		Metrics: map[string]float64{},
+		Type:    "http",
+	}, chunk.Spans[1])
+
+	chunk = tp.Chunks[1]
+	assert.EqualValues(sampler.PriorityAutoDrop, chunk.Priority)
+	require.Len(t, chunk.Spans, 1)
+	assert.Equal("jaeger.internal", chunk.Spans[0].Name)
+	assert.Equal("compute", chunk.Spans[0].Resource)
+}
+
+func TestDecodeJaegerPayload(t *testing.T) {
+	t.Run("thrift", func(t *testing.T) {
+		tp, err := decodeJaegerPayload("application/x-thrift", jaegerThriftPayload())
+		require.NoError(t, err)
+		assertJaegerPayload(t, tp)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		payload := jaegerThriftPayload()
+		for _, n := range []int{1, 10, 100, len(payload) - 1} {
+			_, err := decodeJaegerPayload("application/x-thrift", payload[:n])
+			assert.Error(t, err, n)
+		}
+		_, err := decodeJaegerPayload("application/json", payload)
+		assert.Equal(t, errUnsupportedMediaType, err)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		// unknown struct fields nested in each other
+		nested := func(depth int) []byte {
+			field := []byte{thriftStruct, 0, 99}
+			b := bytes.Repeat(field, depth)
+			return append(b, bytes.Repeat([]byte{thriftStop}, depth+1)...)
+		}
+		_, err := unmarshalJaegerBatch(nested(thriftMaxDepth))
+		assert.NoError(t, err)
+		_, err = unmarshalJaegerBatch(nested(thriftMaxDepth + 2))
+		assert.Equal(t, errInvalidThrift, err)
+		// would overflow the stack without a maximum depth
+		_, err = unmarshalJaegerBatch(bytes.Repeat([]byte{thriftStruct, 0, 99}, 5000000))
+		assert.Equal(t, errInvalidThrift, err)
+	})
+}
+
+func TestJaegerEndpoint(t *testing.T) {
+	conf := newTestReceiverConfig()
+	conf.JaegerReceiverEnabled = true
+	r := newTestReceiverFromConfig(conf)
+	req := httptest.NewRequest(http.MethodPost, "/api/traces", bytes.NewReader(jaegerThriftPayload()))
+	req.Header.Set("Content-Type", "application/vnd.apache.thrift.binary")
+	w := httptest.NewRecorder()
+	r.buildMux().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.Len(t, r.out, 1)
+	p := <-r.out
+	assertJaegerPayload(t, p.TracerPayload)
+	assert.Equal(t, "jaeger_thrift", p.Source.EndpointVersion)
+}
@@ -0,0 +1,215 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api/apiutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// Tags set on the spans translated from third party formats, describing the
+// remote side of the operation.
+const (
+	tagSpanKind    = "span.kind"
+	tagPeerService = "peer.service"
+	tagOutHost     = "out.host"
+	tagOutPort     = "out.port"
+)
+
+// errUnsupportedMediaType is returned by the translated traces decoders when
+// the content type of the request is not supported.
+var errUnsupportedMediaType = fmt.Errorf("unsupported media type")
+
+// translatedTracesDecoder decodes the body of a request sent with a third
+// party tracing format into a tracer payload.
+type translatedTracesDecoder func(mediaType string, body []byte) (*pb.TracerPayload, error)
+
+// handleTranslatedTraces returns the handler of an endpoint receiving traces in
+// a third party format v, which are decoded by decode and then processed like
+// any other traces.
+func (r *HTTPReceiver) handleTranslatedTraces(v Version, decode translatedTracesDecoder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("method %s is not allowed", req.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		ts := r.Stats.GetTagStats(info.Tags{
+			Lang:            req.Header.Get(headerLang),
+			TracerVersion:   req.Header.Get(headerTracerVersion),
+			EndpointVersion: string(v),
+		})
+		start := time.Now()
+		req.Body = apiutil.NewLimitedReader(req.Body, r.conf.MaxRequestBytes)
+
+		tp, err := decodeTranslatedTraces(req, r.conf.MaxRequestBytes, decode)
+		defer func(err error) {
+			tags := append(ts.AsTags(), fmt.Sprintf("success:%v", err == nil))
+			metrics.Histogram("datadog.trace_agent.receiver.serve_traces_ms", float64(time.Since(start))/float64(time.Millisecond), tags, 1)
+		}(err)
+		if err == errUnsupportedMediaType {
+			httpFormatError(w, v, fmt.Errorf("unsupported media type: %q", getMediaType(req)))
+			return
+		}
+		if err != nil {
+			httpDecodingError(err, []string{"handler:traces", fmt.Sprintf("v:%s", v)}, w)
+			switch err {
+			case apiutil.ErrLimitedReaderLimitReached:
+				ts.TracesDropped.PayloadTooLarge.Inc()
+			case io.EOF, io.ErrUnexpectedEOF:
+				ts.TracesDropped.EOF.Inc()
+			default:
+				if err, ok := err.(net.Error); ok && err.Timeout() {
+					ts.TracesDropped.Timeout.Inc()
+				} else {
+					ts.TracesDropped.DecodingError.Inc()
+				}
+			}
+			log.Errorf("Cannot decode %s traces payload: %v", v, err)
+			return
+		}
+		if r.rateLimited(int64(len(tp.Chunks))) {
+			w.WriteHeader(r.rateLimiterResponse)
+			ts.PayloadRefused.Inc()
+			return
+		}
+		runMetaHook(tp.Chunks)
+		w.WriteHeader(http.StatusAccepted)
+
+		ts.TracesReceived.Add(int64(len(tp.Chunks)))
+		ts.TracesBytes.Add(req.Body.(*apiutil.LimitedReader).Count)
+		ts.PayloadAccepted.Inc()
+
+		tp.LanguageName = ts.Lang
+		tp.TracerVersion = ts.TracerVersion
+		tp.ContainerID = r.containerIDProvider.GetContainerID(req.Context(), req.Header)
+		if ctags := getContainerTags(r.conf.ContainerTags, tp.ContainerID); ctags != "" {
+			if tp.Tags == nil {
+				tp.Tags = make(map[string]string)
+			}
+			tp.Tags[tagContainersTags] = ctags
+		}
+		r.enqueue(&Payload{Source: ts, TracerPayload: tp})
+	})
+}
+
+// decodeTranslatedTraces reads the body of the request, which may be gzipped,
+// and decodes it. The uncompressed body is limited to maxBytes.
+func decodeTranslatedTraces(req *http.Request, maxBytes int64, decode translatedTracesDecoder) (*pb.TracerPayload, error) {
+	body := io.Reader(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = apiutil.NewLimitedReader(io.NopCloser(gz), maxBytes)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := io.Copy(buf, body); err != nil {
+		return nil, err
+	}
+	return decode(getMediaType(req), buf.Bytes())
+}
+
+// translatedEvent is an event of a translated span, marshalled in the "events"
+// tag the same way as the events of the OTLP spans.
+type translatedEvent struct {
+	TimeUnixNano uint64            `json:"time_unix_nano,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// marshalTranslatedEvents marshals events into JSON.
+func marshalTranslatedEvents(events []translatedEvent) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(events); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// setPeerTags sets the tags describing the remote side of the operation of a
+// span, keeping the ones already set.
+func setPeerTags(meta map[string]string, service, host string, port int) {
+	for k, v := range map[string]string{
+		tagPeerService: service,
+		tagOutHost:     host,
+	} {
+		if _, ok := meta[k]; !ok && v != "" {
+			meta[k] = v
+		}
+	}
+	if _, ok := meta[tagOutPort]; !ok && port != 0 {
+		meta[tagOutPort] = strconv.Itoa(port)
+	}
+}
+
+// translatedSpanName returns the name of a translated span, built from the
+// name of the format and the span kind, the name of the operation being used
+// as resource like for OTLP spans.
+func translatedSpanName(format, kind string) string {
+	if kind == "" {
+		kind = "internal"
+	}
+	return format + "." + kind
+}
+
+// translatedSpanType returns the type of a translated span from its kind and
+// its tags.
+func translatedSpanType(kind string, meta map[string]string) string {
+	switch kind {
+	case "server":
+		return "web"
+	case "client":
+		switch meta["db.system"] {
+		case "":
+			return "http"
+		case "redis", "memcached":
+			return "cache"
+		default:
+			return "db"
+		}
+	default:
+		return "custom"
+	}
+}
+
+// translatedChunks groups the spans by trace ID, the priority of each chunk
+// being the highest one of its spans.
+func translatedChunks(spans []*pb.Span, priorities []int32) []*pb.TraceChunk {
+	chunks := make([]*pb.TraceChunk, 0, 1)
+	byID := make(map[uint64]*pb.TraceChunk)
+	for i, span := range spans {
+		chunk, ok := byID[span.TraceID]
+		if !ok {
+			chunk = &pb.TraceChunk{Priority: priorities[i]}
+			byID[span.TraceID] = chunk
+			chunks = append(chunks, chunk)
+		}
+		if priorities[i] > chunk.Priority {
+			chunk.Priority = priorities[i]
+		}
+		chunk.Spans = append(chunk.Spans, span)
+	}
+	return chunks
+}
@@ -0,0 +1,311 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+// zipkinV2 is the version of the Zipkin v2 intake endpoint, accepting lists
+// of spans encoded in JSON or protobuf.
+const zipkinV2 Version = "zipkin_v2"
+
+// zipkinSpan is a Zipkin v2 span.
+// See https://zipkin.io/zipkin-api/#/default/post_spans
+type zipkinSpan struct {
+	TraceID        zipkinID           `json:"traceId"`
+	ID             zipkinID           `json:"id"`
+	ParentID       zipkinID           `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"` // microseconds
+	Duration       uint64             `json:"duration"`  // microseconds
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+	Debug          bool               `json:"debug"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"` // microseconds
+	Value     string `json:"value"`
+}
+
+// zipkinID is a trace or span ID, encoded in JSON as 16 or 32 hex characters.
+// Only the lower 64 bits of the 128-bit trace IDs are kept.
+type zipkinID uint64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (id *zipkinID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) > 32 {
+		return fmt.Errorf("invalid zipkin ID %q", s)
+	}
+	if len(s) > 16 {
+		s = s[len(s)-16:]
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil && s != "" {
+		return fmt.Errorf("invalid zipkin ID %q", s)
+	}
+	*id = zipkinID(v)
+	return nil
+}
+
+// decodeZipkinPayload decodes a list of Zipkin v2 spans.
+func decodeZipkinPayload(mediaType string, body []byte) (*pb.TracerPayload, error) {
+	var spans []zipkinSpan
+	switch mediaType {
+	case "application/json", "text/json":
+		if err := json.Unmarshal(body, &spans); err != nil {
+			return nil, err
+		}
+	case "application/x-protobuf", "application/protobuf":
+		var err error
+		if spans, err = unmarshalZipkinSpans(body); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errUnsupportedMediaType
+	}
+
+	ddspans := make([]*pb.Span, 0, len(spans))
+	priorities := make([]int32, 0, len(spans))
+	for i := range spans {
+		ddspans = append(ddspans, spans[i].convert())
+		if spans[i].Debug {
+			priorities = append(priorities, int32(sampler.PriorityUserKeep))
+		} else {
+			priorities = append(priorities, int32(sampler.PriorityAutoKeep))
+		}
+	}
+	return &pb.TracerPayload{Chunks: translatedChunks(ddspans, priorities)}, nil
+}
+
+// convert returns the Datadog span of a Zipkin span.
+func (s *zipkinSpan) convert() *pb.Span {
+	kind := strings.ToLower(s.Kind)
+	span := &pb.Span{
+		TraceID:  uint64(s.TraceID),
+		SpanID:   uint64(s.ID),
+		ParentID: uint64(s.ParentID),
+		Name:     translatedSpanName("zipkin", kind),
+		Start:    int64(s.Timestamp) * 1000,
+		Duration: int64(s.Duration) * 1000,
+		Meta:     make(map[string]string, len(s.Tags)+1),
+		Metrics:  map[string]float64{},
+	}
+	if s.LocalEndpoint != nil {
+		span.Service = s.LocalEndpoint.ServiceName
+	}
+	for k, v := range s.Tags {
+		if k == "error" {
+			// the value of the error tag is the error message, if any
+			span.Error = 1
+			if v != "" && v != "true" {
+				span.Meta["error.msg"] = v
+			}
+			continue
+		}
+		span.Meta[k] = v
+	}
+	if kind != "" {
+		span.Meta[tagSpanKind] = kind
+	}
+	if e := s.RemoteEndpoint; e != nil {
+		host := e.IPv4
+		if host == "" {
+			host = e.IPv6
+		}
+		setPeerTags(span.Meta, e.ServiceName, host, e.Port)
+	}
+	if len(s.Annotations) > 0 {
+		events := make([]translatedEvent, 0, len(s.Annotations))
+		for _, a := range s.Annotations {
+			events = append(events, translatedEvent{TimeUnixNano: a.Timestamp * 1000, Name: a.Value})
+		}
+		span.Meta["events"] = marshalTranslatedEvents(events)
+	}
+	if span.Resource = resourceFromTags(span.Meta); span.Resource == "" {
+		span.Resource = s.Name
+	}
+	span.Type = translatedSpanType(kind, span.Meta)
+	return span
+}
+
+// zipkinKinds maps the span kinds of the Zipkin protobuf encoding.
+var zipkinKinds = [...]string{"", "CLIENT", "SERVER", "PRODUCER", "CONSUMER"}
+
+var errInvalidZipkinProto = errors.New("invalid zipkin protobuf payload")
+
+// unmarshalZipkinSpans unmarshals a zipkin.proto3.ListOfSpans message.
+// See https://github.com/openzipkin/zipkin-api/blob/master/zipkin.proto
+func unmarshalZipkinSpans(b []byte) ([]zipkinSpan, error) {
+	var spans []zipkinSpan
+	err := rangeProtoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		span, err := unmarshalZipkinSpan(v)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, span)
+		return nil
+	})
+	return spans, err
+}
+
+func unmarshalZipkinSpan(b []byte) (zipkinSpan, error) {
+	var s zipkinSpan
+	err := rangeProtoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		var err error
+		switch num {
+		case 1:
+			err = unmarshalZipkinID(v, 16, &s.TraceID)
+		case 2:
+			err = unmarshalZipkinID(v, 8, &s.ParentID)
+		case 3:
+			err = unmarshalZipkinID(v, 8, &s.ID)
+		case 4:
+			if n < uint64(len(zipkinKinds)) {
+				s.Kind = zipkinKinds[n]
+			}
+		case 5:
+			s.Name = string(v)
+		case 6:
+			s.Timestamp = n
+		case 7:
+			s.Duration = n
+		case 8:
+			s.LocalEndpoint, err = unmarshalZipkinEndpoint(v)
+		case 9:
+			s.RemoteEndpoint, err = unmarshalZipkinEndpoint(v)
+		case 10:
+			var a zipkinAnnotation
+			err = rangeProtoFields(v, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+				switch num {
+				case 1:
+					a.Timestamp = n
+				case 2:
+					a.Value = string(v)
+				}
+				return nil
+			})
+			s.Annotations = append(s.Annotations, a)
+		case 11:
+			var k, val string
+			err = rangeProtoFields(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					k = string(v)
+				case 2:
+					val = string(v)
+				}
+				return nil
+			})
+			if s.Tags == nil {
+				s.Tags = make(map[string]string)
+			}
+			s.Tags[k] = val
+		case 12:
+			s.Debug = n != 0
+		}
+		return err
+	})
+	return s, err
+}
+
+func unmarshalZipkinEndpoint(b []byte) (*zipkinEndpoint, error) {
+	var e zipkinEndpoint
+	err := rangeProtoFields(b, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			e.ServiceName = string(v)
+		case 2:
+			if len(v) == net.IPv4len {
+				e.IPv4 = net.IP(v).String()
+			}
+		case 3:
+			if len(v) == net.IPv6len {
+				e.IPv6 = net.IP(v).String()
+			}
+		case 4:
+			e.Port = int(int32(n))
+		}
+		return nil
+	})
+	return &e, err
+}
+
+// unmarshalZipkinID unmarshals an ID of 8 bytes, or up to maxLen bytes of
+// which the lower 8 are kept.
+func unmarshalZipkinID(b []byte, maxLen int, id *zipkinID) error {
+	if len(b) != 8 && len(b) != maxLen {
+		return errInvalidZipkinProto
+	}
+	*id = zipkinID(binary.BigEndian.Uint64(b[len(b)-8:]))
+	return nil
+}
+
+// rangeProtoFields calls f with each field of the protobuf message b: the
+// content of the length-delimited fields is passed as v, and the value of the
+// other ones as n.
+func rangeProtoFields(b []byte, f func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return errInvalidZipkinProto
+		}
+		b = b[l:]
+		var v []byte
+		var n uint64
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var n32 uint32
+			n32, l = protowire.ConsumeFixed32(b)
+			n = uint64(n32)
+		case protowire.BytesType:
+			v, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if l < 0 {
+			return errInvalidZipkinProto
+		}
+		b = b[l:]
+		if err := f(num, typ, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+const zipkinJSONPayload = `[
+	{
+		"traceId": "5af7183fb1d4cf5f0000000000000001",
+		"id": "0000000000000002",
+		"name": "get /users/{id}",
+		"kind": "SERVER",
+		"timestamp": 1556604172355737,
+		"duration": 1431,
+		"localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306},
+		"tags": {"http.method": "GET", "http.route": "/users/{id}", "http.status_code": "500", "error": "Internal Server Error"},
+		"annotations": [{"timestamp": 1556604172355800, "value": "wr"}],
+		"debug": true
+	},
+	{
+		"traceId": "5af7183fb1d4cf5f0000000000000001",
+		"parentId": "0000000000000002",
+		"id": "0000000000000003",
+		"name": "query",
+		"kind": "CLIENT",
+		"timestamp": 1556604172355800,
+		"duration": 800,
+		"localEndpoint": {"serviceName": "frontend"},
+		"remoteEndpoint": {"serviceName": "users-db", "ipv4": "10.0.0.3", "port": 5432},
+		"tags": {"db.system": "postgresql"}
+	},
+	{
+		"traceId": "0000000000000004",
+		"id": "0000000000000005",
+		"name": "compute",
+		"timestamp": 1556604172355800,
+		"duration": 10,
+		"localEndpoint": {"serviceName": "backend"}
+	}
+]`
+
+// zipkinProtoPayload returns the protobuf encoding of zipkinJSONPayload.
+func zipkinProtoPayload() []byte {
+	bytesField := func(b []byte, num protowire.Number, v []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	}
+	id := func(v ...byte) []byte {
+		return append(make([]byte, 8-len(v)%8), v...)
+	}
+	endpoint := func(service string, ip string, port uint64) []byte {
+		b := bytesField(nil, 1, []byte(service))
+		if ip != "" {
+			b = bytesField(b, 2, net.ParseIP(ip).To4())
+		}
+		if port != 0 {
+			b = protowire.AppendTag(b, 4, protowire.VarintType)
+			b = protowire.AppendVarint(b, port)
+		}
+		return b
+	}
+	tag := func(k, v string) []byte {
+		return bytesField(bytesField(nil, 1, []byte(k)), 2, []byte(v))
+	}
+	span := func(traceID, parentID, spanID []byte, kind uint64, name string, ts, duration uint64, fields ...[]byte) []byte {
+		b := bytesField(nil, 1, traceID)
+		if parentID != nil {
+			b = bytesField(b, 2, parentID)
+		}
+		b = bytesField(b, 3, spanID)
+		if kind != 0 {
+			b = protowire.AppendTag(b, 4, protowire.VarintType)
+			b = protowire.AppendVarint(b, kind)
+		}
+		b = bytesField(b, 5, []byte(name))
+		b = protowire.AppendTag(b, 6, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, ts)
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, duration)
+		for _, f := range fields {
+			b = append(b, f...)
+		}
+		return b
+	}
+
+	annotation := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+	annotation = protowire.AppendFixed64(annotation, 1556604172355800)
+	annotation = bytesField(annotation, 2, []byte("wr"))
+	debug := protowire.AppendTag(nil, 12, protowire.VarintType)
+	debug = protowire.AppendVarint(debug, 1)
+
+	var b []byte
+	b = bytesField(b, 1, span(
+		[]byte{0x5a, 0xf7, 0x18, 0x3f, 0xb1, 0xd4, 0xcf, 0x5f, 0, 0, 0, 0, 0, 0, 0, 1}, nil, id(2),
+		2, "get /users/{id}", 1556604172355737, 1431,
+		bytesField(nil, 8, endpoint("frontend", "192.168.99.1", 3306)),
+		bytesField(nil, 10, annotation),
+		bytesField(nil, 11, tag("http.method", "GET")),
+		bytesField(nil, 11, tag("http.route", "/users/{id}")),
+		bytesField(nil, 11, tag("http.status_code", "500")),
+		bytesField(nil, 11, tag("error", "Internal Server Error")),
+		debug,
+	))
+	b = bytesField(b, 1, span(
+		append(id(), id(1)...), id(2), id(3),
+		1, "query", 1556604172355800, 800,
+		bytesField(nil, 8, endpoint("frontend", "", 0)),
+		bytesField(nil, 9, endpoint("users-db", "10.0.0.3", 5432)),
+		bytesField(nil, 11, tag("db.system", "postgresql")),
+	))
+	b = bytesField(b, 1, span(
+		id(4), nil, id(5),
+		0, "compute", 1556604172355800, 10,
+		bytesField(nil, 8, endpoint("backend", "", 0)),
+	))
+	return b
+}
+
+func assertZipkinPayload(t *testing.T, tp *pb.TracerPayload) {
+	assert := assert.New(t)
+	require.Len(t, tp.Chunks, 2)
+
+	chunk := tp.Chunks[0]
+	assert.EqualValues(sampler.PriorityUserKeep, chunk.Priority)
+	require.Len(t, chunk.Spans, 2)
+	assert.Equal(&pb.Span{
+		Service:  "frontend",
+		Name:     "zipkin.server",
+		Resource: "GET /users/{id}",
+		TraceID:  1,
+		SpanID:   2,
+		Start:    1556604172355737000,
+		Duration: 1431000,
+		Error:    1,
+		Meta: map[string]string{
+			"http.method":      "GET",
+			"http.route":       "/users/{id}",
+			"http.status_code": "500",
+			"error.msg":        "Internal Server Error",
+			"span.kind":        "server",
+			"events":           `[{"time_unix_nano":1556604172355800000,"name":"wr"}]`,
+		},
+		Metrics: map[string]float64{},
+		Type:    "web",
+	}, chunk.Spans[0])
+	assert.Equal(&pb.Span{
+		Service:  "frontend",
+		Name:     "zipkin.client",
+		Resource: "query",
+		TraceID:  1,
+		SpanID:   3,
+		ParentID: 2,
+		Start:    1556604172355800000,
+		Duration: 800000,
+		Meta: map[string]string{
+			"db.system":    "postgresql",
+			"span.kind":    "client",
+			"peer.service": "users-db",
+			"out.host":     "10.0.0.3",
+			"out.port":     "5432",
+		},
+		Metrics: map[string]float64{},
+		Type:    "db",
+	}, chunk.Spans[1])
+
+	chunk = tp.Chunks[1]
+	assert.EqualValues(sampler.PriorityAutoKeep, chunk.Priority)
+	require.Len(t, chunk.Spans, 1)
+	assert.Equal("zipkin.internal", chunk.Spans[0].Name)
+	assert.Equal("compute", chunk.Spans[0].Resource)
+	assert.Equal("custom", chunk.Spans[0].Type)
+}
+
+func TestDecodeZipkinPayload(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		tp, err := decodeZipkinPayload("application/json", []byte(zipkinJSONPayload))
+		require.NoError(t, err)
+		assertZipkinPayload(t, tp)
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		tp, err := decodeZipkinPayload("application/x-protobuf", zipkinProtoPayload())
+		require.NoError(t, err)
+		assertZipkinPayload(t, tp)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := decodeZipkinPayload("application/json", []byte(`[{"traceId": "not-hex"}]`))
+		assert.Error(t, err)
+		_, err = decodeZipkinPayload("application/x-protobuf", zipkinProtoPayload()[:100])
+		assert.Error(t, err)
+		_, err = decodeZipkinPayload("application/msgpack", []byte(zipkinJSONPayload))
+		assert.Equal(t, errUnsupportedMediaType, err)
+	})
+}
+
+func TestZipkinEndpoint(t *testing.T) {
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(b)
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	for name, tc := range map[string]struct {
+		enabled     bool
+		contentType string
+		gzip        bool
+		body        []byte
+		status      int
+	}{
+		"json":        {true, "application/json", false, []byte(zipkinJSONPayload), http.StatusAccepted},
+		"protobuf":    {true, "application/x-protobuf", false, zipkinProtoPayload(), http.StatusAccepted},
+		"gzip":        {true, "application/json", true, []byte(zipkinJSONPayload), http.StatusAccepted},
+		"invalid":     {true, "application/json", false, []byte("[{"), http.StatusBadRequest},
+		"unsupported": {true, "application/thrift", false, []byte(zipkinJSONPayload), http.StatusUnsupportedMediaType},
+		"disabled":    {false, "application/json", false, []byte(zipkinJSONPayload), http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := newTestReceiverConfig()
+			conf.ZipkinReceiverEnabled = tc.enabled
+			r := newTestReceiverFromConfig(conf)
+			body := tc.body
+			if tc.gzip {
+				body = gzipped(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader(body))
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			r.buildMux().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status != http.StatusAccepted {
+				assert.Len(t, r.out, 0)
+				return
+			}
+			require.Len(t, r.out, 1)
+			p := <-r.out
+			assertZipkinPayload(t, p.TracerPayload)
+			assert.Equal(t, "zipkin_v2", p.Source.EndpointVersion)
+			assert.EqualValues(t, 2, p.Source.TracesReceived.Load())
+		})
+	}
+}
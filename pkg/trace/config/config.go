@@ -8,10 +8,13 @@ package config
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
@@ -67,6 +70,33 @@ type OTLP struct {
 	UsePreviewHostnameLogic bool `mapstructure:"-"`
 }
 
+// OTLPExporterConfig holds the configuration of the exporter sending the sampled traces
+// to an OpenTelemetry collector, in addition to Datadog.
+type OTLPExporterConfig struct {
+	// Endpoint specifies the OTLP endpoint to send the traces to: the URL of the traces
+	// endpoint when using HTTP (e.g. "http://localhost:4318/v1/traces"), or its address
+	// when using gRPC (e.g. "localhost:4317"). The exporter is disabled when it is empty.
+	Endpoint string
+
+	// Protocol specifies the protocol used to send the traces: "http" (default) or "grpc".
+	Protocol string
+
+	// Headers specifies additional headers (or gRPC metadata) sent along with the traces.
+	Headers map[string]string `json:"-"`
+
+	// Insecure disables TLS when using gRPC.
+	Insecure bool
+
+	// QueueSize specifies the maximum number of payloads queued in memory while the
+	// endpoint is unreachable. It defaults to 100.
+	QueueSize int
+}
+
+// Enabled reports whether the OTLP exporter is enabled.
+func (c *OTLPExporterConfig) Enabled() bool {
+	return c != nil && c.Endpoint != ""
+}
+
 // ObfuscationConfig holds the configuration for obfuscating sensitive data
 // for various span types.
 type ObfuscationConfig struct {
@@ -98,8 +128,16 @@ type ObfuscationConfig struct {
 	// for spans of type "memcached".
 	Memcached Enablable `mapstructure:"memcached"`
 
+	// GraphQL holds the configuration for obfuscating the resource and the "graphql.source"
+	// tag for spans of type "graphql".
+	GraphQL GraphQLObfuscationConfig `mapstructure:"graphql"`
+
 	// CreditCards holds the configuration for obfuscating credit cards.
 	CreditCards CreditCardsConfig `mapstructure:"credit_cards"`
+
+	// PII holds the configuration for redacting personally identifiable information
+	// from span tags.
+	PII PIIObfuscationConfig `mapstructure:"pii"`
 }
 
 // AppSecConfig ...
@@ -122,6 +160,7 @@ func (o *ObfuscationConfig) Export() obfuscate.Config {
 			ReplaceDigits:    features.Has("quantize_sql_tables") || features.Has("replace_sql_digits"),
 			KeepSQLAlias:     features.Has("keep_sql_alias"),
 			DollarQuotedFunc: features.Has("dollar_quoted_func"),
+			Signature:        features.Has("sql_signature"),
 			Cache:            features.Has("sql_cache"),
 		},
 		ES: obfuscate.JSONConfig{
@@ -148,6 +187,9 @@ func (o *ObfuscationConfig) Export() obfuscate.Config {
 			RemoveQueryString: o.HTTP.RemoveQueryString,
 			RemovePathDigits:  o.HTTP.RemovePathDigits,
 		},
+		GraphQL: obfuscate.GraphQLConfig{
+			RemoveAliases: o.GraphQL.RemoveAliases,
+		},
 		Logger: new(debugLogger),
 	}
 }
@@ -170,6 +212,39 @@ type CreditCardsConfig struct {
 	Luhn bool `mapstructure:"luhn"`
 }
 
+// PIIObfuscationConfig holds the configuration for redacting personally identifiable
+// information (PII), such as email addresses or phone numbers, from (Meta) tags.
+type PIIObfuscationConfig struct {
+	// Enabled specifies whether this feature should be enabled.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Detectors lists the names of the built-in detectors to use. All the built-in
+	// detectors are used when empty.
+	Detectors []string `mapstructure:"detectors"`
+
+	// CustomPatterns holds additional detectors based on regular expressions.
+	CustomPatterns []*PIIPattern `mapstructure:"custom_patterns"`
+
+	// Tags lists the tags to scan for PII. "*" scans all tags. A default set of tags
+	// known to carry user input is scanned when empty.
+	Tags []string `mapstructure:"tags"`
+
+	// KeepTags lists tags which are never scanned, even when all tags are.
+	KeepTags []string `mapstructure:"keep_tags"`
+}
+
+// PIIPattern specifies a custom PII detector.
+type PIIPattern struct {
+	// Name identifies the detector in the telemetry.
+	Name string `mapstructure:"name"`
+
+	// Pattern specifies the regular expression matching the PII.
+	Pattern string `mapstructure:"pattern"`
+
+	// Re holds the compiled Pattern and is only used internally.
+	Re *regexp.Regexp `mapstructure:"-"`
+}
+
 // HTTPObfuscationConfig holds the configuration settings for HTTP obfuscation.
 type HTTPObfuscationConfig struct {
 	// RemoveQueryStrings determines query strings to be removed from HTTP URLs.
@@ -179,6 +254,15 @@ type HTTPObfuscationConfig struct {
 	RemovePathDigits bool `mapstructure:"remove_paths_with_digits" json:"remove_path_digits"`
 }
 
+// GraphQLObfuscationConfig holds the configuration settings for GraphQL obfuscation.
+type GraphQLObfuscationConfig struct {
+	// Enabled specifies whether GraphQL queries should be obfuscated.
+	Enabled bool `mapstructure:"enabled"`
+
+	// RemoveAliases specifies whether field aliases should be removed from the queries.
+	RemoveAliases bool `mapstructure:"remove_aliases"`
+}
+
 // Enablable can represent any option that has an "enabled" boolean sub-field.
 type Enablable struct {
 	Enabled bool `mapstructure:"enabled"`
@@ -223,6 +307,76 @@ type ReplaceRule struct {
 	Repl string `mapstructure:"repl"`
 }
 
+// SpanFilterRule specifies a rule dropping individual spans. A span matches the rule when
+// it satisfies all of the rule's conditions.
+type SpanFilterRule struct {
+	// Name, Service and Resource specify regular expressions which the span name,
+	// service and resource must match. Empty ones are not checked.
+	Name     string `mapstructure:"name"`
+	Service  string `mapstructure:"service"`
+	Resource string `mapstructure:"resource"`
+
+	// Meta maps meta keys to regular expressions which their values must match.
+	Meta map[string]string `mapstructure:"meta"`
+
+	// Metrics maps metric keys to comparisons which their values must satisfy, such
+	// as "< 0.5" or ">= 100". The supported operators are ==, !=, <, <=, > and >=; a
+	// number alone is compared with ==.
+	Metrics map[string]string `mapstructure:"metrics"`
+
+	// The fields below hold the compiled conditions and are only used internally.
+	NameRe     *regexp.Regexp             `mapstructure:"-"`
+	ServiceRe  *regexp.Regexp             `mapstructure:"-"`
+	ResourceRe *regexp.Regexp             `mapstructure:"-"`
+	MetaRe     map[string]*regexp.Regexp  `mapstructure:"-"`
+	MetricsCmp map[string]MetricCondition `mapstructure:"-"`
+}
+
+// MetricCondition specifies a comparison of a metric value with a number.
+type MetricCondition struct {
+	// Op is the comparison operator: one of ==, !=, <, <=, > and >=.
+	Op string
+	// Value is the number the metric value is compared with.
+	Value float64
+}
+
+// ParseMetricCondition parses a comparison such as "< 0.5" into a MetricCondition.
+func ParseMetricCondition(s string) (MetricCondition, error) {
+	s = strings.TrimSpace(s)
+	op := "=="
+	// two-characters operators come first so that they take precedence
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(s, o) {
+			op, s = o, strings.TrimSpace(s[len(o):])
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return MetricCondition{}, fmt.Errorf("invalid comparison value %q", s)
+	}
+	return MetricCondition{Op: op, Value: v}, nil
+}
+
+// Matches reports whether v satisfies the condition.
+func (c MetricCondition) Matches(v float64) bool {
+	switch c.Op {
+	case "==":
+		return v == c.Value
+	case "!=":
+		return v != c.Value
+	case "<":
+		return v < c.Value
+	case "<=":
+		return v <= c.Value
+	case ">":
+		return v > c.Value
+	case ">=":
+		return v >= c.Value
+	}
+	return false
+}
+
 // WriterConfig specifies configuration for an API writer.
 type WriterConfig struct {
 	// ConnectionLimit specifies the maximum number of concurrent outgoing
@@ -309,7 +463,10 @@ type AgentConfig struct {
 
 	// Concentrator
 	BucketInterval   time.Duration // the size of our pre-aggregation per bucket
-	ExtraAggregators []string
+	ExtraAggregators []string      // span tags used as additional stats aggregation dimensions
+	// ExtraAggregatorsMaxValues is the maximum number of distinct values of each extra
+	// aggregator in a stats bucket, the following ones being aggregated as "overflow".
+	ExtraAggregatorsMaxValues int
 
 	// Sampler configuration
 	ExtraSampleRate float64
@@ -324,6 +481,11 @@ type AgentConfig struct {
 	RareSamplerCooldownPeriod time.Duration
 	RareSamplerCardinality    int
 
+	// Latency Sampler configuration
+	LatencySamplerEnabled    bool
+	LatencySamplerPercentile float64 // percentile of the span durations above which a trace is kept
+	LatencySamplerTPS        float64
+
 	// Receiver
 	ReceiverHost    string
 	ReceiverPort    int
@@ -338,12 +500,27 @@ type AgentConfig struct {
 
 	GUIPort string // the port of the Datadog Agent GUI (for control access)
 
+	// CapturePath specifies the directory where the captures of the traffic received
+	// by the trace-agent are written. It defaults to the temporary directory.
+	CapturePath string
+	// CaptureMaxSize specifies the maximum size, in bytes, of the requests recorded by a
+	// capture. The capture stops when it is reached.
+	CaptureMaxSize int64
+
 	// Writers
 	SynchronousFlushing     bool // Mode where traces are only submitted when FlushAsync is called, used for Serverless Extension
 	StatsWriter             *WriterConfig
 	TraceWriter             *WriterConfig
 	ConnectionResetInterval time.Duration // frequency at which outgoing connections are reset. 0 means no reset is performed
 
+	// DiskRetryPath specifies the directory where the writers spill the payloads they
+	// can not keep in memory, for example during an intake outage. They are replayed
+	// once the intake is reachable again. An empty path disables the disk retry buffer.
+	DiskRetryPath string
+	// DiskRetryMaxSize specifies the maximum size, in bytes, of the disk retry buffer
+	// of each writer endpoint. The oldest payloads are dropped when it is reached.
+	DiskRetryMaxSize int64
+
 	// internal telemetry
 	StatsdEnabled  bool
 	StatsdHost     string
@@ -371,6 +548,9 @@ type AgentConfig struct {
 	// It maps tag keys to a set of replacements. Only supported in A6.
 	ReplaceTags []*ReplaceRule
 
+	// FilterSpans holds the rules matching the individual spans to drop from traces.
+	FilterSpans []*SpanFilterRule
+
 	// GlobalTags list metadata that will be added to all spans
 	GlobalTags map[string]string
 
@@ -393,6 +573,16 @@ type AgentConfig struct {
 	// OTLPReceiver holds the configuration for OpenTelemetry receiver.
 	OTLPReceiver *OTLP
 
+	// OTLPExporter holds the configuration of the exporter sending the sampled traces
+	// to an OpenTelemetry collector.
+	OTLPExporter *OTLPExporterConfig
+
+	// ZipkinReceiverEnabled enables the Zipkin v2 intake endpoint, /api/v2/spans.
+	ZipkinReceiverEnabled bool
+
+	// JaegerReceiverEnabled enables the Jaeger Thrift over HTTP intake endpoint, /api/traces.
+	JaegerReceiverEnabled bool
+
 	// ProfilingProxy specifies settings for the profiling proxy.
 	ProfilingProxy ProfilingProxyConfig
 
@@ -449,7 +639,8 @@ func New() *AgentConfig {
 		Site:                "datadoghq.com",
 		MaxCatalogEntries:   5000,
 
-		BucketInterval: time.Duration(10) * time.Second,
+		BucketInterval:            time.Duration(10) * time.Second,
+		ExtraAggregatorsMaxValues: 100,
 
 		ExtraSampleRate: 1.0,
 		TargetTPS:       10,
@@ -462,6 +653,10 @@ func New() *AgentConfig {
 		RareSamplerCooldownPeriod: 5 * time.Minute,
 		RareSamplerCardinality:    200,
 
+		LatencySamplerEnabled:    false,
+		LatencySamplerPercentile: 0.99,
+		LatencySamplerTPS:        5,
+
 		ReceiverHost:           "localhost",
 		ReceiverPort:           8126,
 		MaxRequestBytes:        50 * 1024 * 1024, // 50MB
@@ -471,7 +666,9 @@ func New() *AgentConfig {
 
 		StatsWriter:             new(WriterConfig),
 		TraceWriter:             new(WriterConfig),
-		ConnectionResetInterval: 0, // disabled
+		ConnectionResetInterval: 0,                 // disabled
+		DiskRetryMaxSize:        100 * 1024 * 1024, // 100MB
+		CaptureMaxSize:          500 * 1024 * 1024, // 500MB
 
 		StatsdHost:    "localhost",
 		StatsdPort:    8125,
@@ -492,6 +689,7 @@ func New() *AgentConfig {
 
 		Proxy:         http.ProxyFromEnvironment,
 		OTLPReceiver:  &OTLP{},
+		OTLPExporter:  &OTLPExporterConfig{Protocol: "http"},
 		ContainerTags: noopContainerTagsFunc,
 		TelemetryConfig: &TelemetryConfig{
 			Endpoints: []*Endpoint{{Host: TelemetryEndpointPrefix + "datadoghq.com"}},
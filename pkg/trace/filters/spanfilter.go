@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// SpanFilter is a filter which drops the individual spans matching its rules.
+type SpanFilter struct {
+	rules []*config.SpanFilterRule
+}
+
+// NewSpanFilter returns a new SpanFilter which will use the given set of compiled rules.
+func NewSpanFilter(rules []*config.SpanFilterRule) *SpanFilter {
+	return &SpanFilter{rules: rules}
+}
+
+// Filter drops the spans of trace matching any of the rules, except for root. The
+// children of dropped spans are re-parented to their closest kept ancestor so that
+// the trace remains valid. It returns the filtered trace, which reuses the storage
+// of trace, along with the number of dropped spans.
+func (f *SpanFilter) Filter(trace pb.Trace, root *pb.Span) (pb.Trace, int) {
+	if len(f.rules) == 0 {
+		return trace, 0
+	}
+	var dropped map[uint64]uint64 // dropped span ID -> parent ID
+	for _, s := range trace {
+		if s == root || !f.matches(s) {
+			continue
+		}
+		if dropped == nil {
+			dropped = make(map[uint64]uint64)
+		}
+		dropped[s.SpanID] = s.ParentID
+	}
+	if len(dropped) == 0 {
+		return trace, 0
+	}
+	kept := trace[:0]
+	for _, s := range trace {
+		if _, ok := dropped[s.SpanID]; ok && s != root {
+			continue
+		}
+		// walk up the dropped ancestors; the number of steps is bounded in
+		// case of a cycle in malformed traces.
+		for i := 0; i < len(dropped); i++ {
+			parentID, ok := dropped[s.ParentID]
+			if !ok {
+				break
+			}
+			s.ParentID = parentID
+		}
+		kept = append(kept, s)
+	}
+	n := len(trace) - len(kept)
+	for i := len(kept); i < len(trace); i++ {
+		// release the dropped spans
+		trace[i] = nil
+	}
+	return kept, n
+}
+
+// matches reports whether s matches any of the rules.
+func (f *SpanFilter) matches(s *pb.Span) bool {
+	for _, r := range f.rules {
+		if matchesRule(r, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRule reports whether s satisfies all the conditions of rule r.
+func matchesRule(r *config.SpanFilterRule, s *pb.Span) bool {
+	if r.NameRe != nil && !r.NameRe.MatchString(s.Name) {
+		return false
+	}
+	if r.ServiceRe != nil && !r.ServiceRe.MatchString(s.Service) {
+		return false
+	}
+	if r.ResourceRe != nil && !r.ResourceRe.MatchString(s.Resource) {
+		return false
+	}
+	for k, re := range r.MetaRe {
+		v, ok := s.Meta[k]
+		if !ok || !re.MatchString(v) {
+			return false
+		}
+	}
+	for k, cmp := range r.MetricsCmp {
+		v, ok := s.Metrics[k]
+		if !ok || !cmp.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+func TestSpanFilter(t *testing.T) {
+	healthcheck := &config.SpanFilterRule{
+		ResourceRe: regexp.MustCompile("^GET /health"),
+	}
+	cacheHit := &config.SpanFilterRule{
+		NameRe:     regexp.MustCompile(`^(redis|memcached)\.`),
+		ServiceRe:  regexp.MustCompile("-cache$"),
+		MetaRe:     map[string]*regexp.Regexp{"cache.op": regexp.MustCompile("^get$")},
+		MetricsCmp: map[string]config.MetricCondition{"cache.hit": {Op: "==", Value: 1}},
+	}
+	fast := &config.SpanFilterRule{
+		MetricsCmp: map[string]config.MetricCondition{"db.rows": {Op: "<", Value: 1}},
+	}
+
+	t.Run("match", func(t *testing.T) {
+		for _, tt := range []struct {
+			span *pb.Span
+			want bool
+		}{
+			{&pb.Span{Resource: "GET /healthz"}, true},
+			{&pb.Span{Resource: "POST /health"}, false},
+			{&pb.Span{
+				Name:    "redis.command",
+				Service: "users-cache",
+				Meta:    map[string]string{"cache.op": "get"},
+				Metrics: map[string]float64{"cache.hit": 1},
+			}, true},
+			{&pb.Span{
+				Name:    "redis.command",
+				Service: "users-cache",
+				Meta:    map[string]string{"cache.op": "get"},
+				Metrics: map[string]float64{"cache.hit": 0},
+			}, false},
+			{&pb.Span{
+				Name:    "redis.command",
+				Service: "users-cache",
+				Metrics: map[string]float64{"cache.hit": 1},
+			}, false},
+			{&pb.Span{
+				Name:    "redis.command",
+				Service: "users",
+				Meta:    map[string]string{"cache.op": "get"},
+				Metrics: map[string]float64{"cache.hit": 1},
+			}, false},
+			{&pb.Span{Metrics: map[string]float64{"db.rows": 0}}, true},
+			{&pb.Span{Metrics: map[string]float64{"db.rows": 3}}, false},
+			{&pb.Span{}, false},
+		} {
+			f := NewSpanFilter([]*config.SpanFilterRule{healthcheck, cacheHit, fast})
+			assert.Equal(t, tt.want, f.matches(tt.span), tt.span)
+		}
+	})
+
+	t.Run("reparent", func(t *testing.T) {
+		assert := assert.New(t)
+		root := &pb.Span{SpanID: 1, Resource: "GET /health"}
+		trace := pb.Trace{
+			{SpanID: 2, ParentID: 1, Resource: "GET /health"},
+			{SpanID: 3, ParentID: 2, Resource: "GET /health"},
+			{SpanID: 4, ParentID: 3, Resource: "SELECT"},
+			root,
+			{SpanID: 5, ParentID: 4, Resource: "GET /health"},
+			{SpanID: 6, ParentID: 5, Resource: "SELECT"},
+			{SpanID: 7, ParentID: 1, Resource: "SELECT"},
+		}
+		f := NewSpanFilter([]*config.SpanFilterRule{healthcheck})
+		kept, n := f.Filter(trace, root)
+		assert.Equal(3, n)
+		parents := make(map[uint64]uint64)
+		for _, s := range kept {
+			parents[s.SpanID] = s.ParentID
+		}
+		assert.Equal(map[uint64]uint64{4: 1, 1: 0, 6: 4, 7: 1}, parents)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		root := &pb.Span{SpanID: 1}
+		trace := pb.Trace{
+			root,
+			{SpanID: 2, ParentID: 3, Resource: "GET /health"},
+			{SpanID: 3, ParentID: 2, Resource: "GET /health"},
+			{SpanID: 4, ParentID: 3},
+		}
+		kept, n := NewSpanFilter([]*config.SpanFilterRule{healthcheck}).Filter(trace, root)
+		assert.Equal(t, 2, n)
+		assert.Len(t, kept, 2)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		trace := pb.Trace{{SpanID: 1, Resource: "GET /health"}, {SpanID: 2, ParentID: 1}}
+		kept, n := NewSpanFilter(nil).Filter(trace, trace[0])
+		assert.Zero(t, n)
+		assert.Equal(t, trace, kept)
+	})
+}
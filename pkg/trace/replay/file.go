@@ -0,0 +1,242 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package replay implements the capture file format holding the requests received
+// by the trace-agent, along with a replayer sending them back to a trace-agent.
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// fileHeader starts every capture file, before compression. Its last byte is the
+// version of the file format.
+var fileHeader = []byte{'D', 'D', 'T', 'R', 'A', 'C', 'E', 'C', 'A', 'P', fileVersion}
+
+const (
+	// fileVersion is the current version of the capture file format.
+	fileVersion = 1
+
+	// maxStringLen is the maximum length of the strings of a record.
+	maxStringLen = 1 << 20
+	// maxBodyLen is the maximum length of the body of a record.
+	maxBodyLen = 1 << 30
+)
+
+// ErrInvalidFile is returned when reading a file which is not a capture file.
+var ErrInvalidFile = errors.New("not a trace-agent capture file")
+
+// Record holds a request received by the trace-agent.
+type Record struct {
+	// Time is the time at which the request was received.
+	Time time.Time
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the path of the request URL, including its query string.
+	Path string
+	// Header holds the HTTP headers of the request.
+	Header http.Header
+	// ContainerID is the ID of the container which sent the request, if any.
+	ContainerID string
+	// Body is the raw body of the request.
+	Body []byte
+}
+
+// Writer writes records to a gzip-compressed capture file. It is not safe for
+// concurrent use.
+type Writer struct {
+	zw  *gzip.Writer
+	bw  *bufio.Writer
+	buf []byte
+}
+
+// NewWriter returns a new Writer writing to w. It writes the file header right away.
+func NewWriter(w io.Writer) (*Writer, error) {
+	zw := gzip.NewWriter(w)
+	cw := &Writer{zw: zw, bw: bufio.NewWriter(zw)}
+	if _, err := cw.bw.Write(fileHeader); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write writes rec to the file.
+func (w *Writer) Write(rec *Record) error {
+	w.buf = appendRecordHeader(w.buf[:0], rec)
+	if _, err := w.bw.Write(w.buf); err != nil {
+		return err
+	}
+	_, err := w.bw.Write(rec.Body)
+	return err
+}
+
+// EncodeRecord returns the encoding of rec, to be written with WriteEncoded. It lets
+// concurrent callers encode their records before synchronizing on the Writer.
+func EncodeRecord(rec *Record) []byte {
+	b := appendRecordHeader(make([]byte, 0, 256+len(rec.Body)), rec)
+	return append(b, rec.Body...)
+}
+
+// WriteEncoded writes a record encoded by EncodeRecord to the file.
+func (w *Writer) WriteEncoded(b []byte) error {
+	_, err := w.bw.Write(b)
+	return err
+}
+
+// appendRecordHeader appends the encoding of rec, except its body, to b.
+func appendRecordHeader(b []byte, rec *Record) []byte {
+	b = appendVarint(b, rec.Time.UnixNano())
+	b = appendString(b, rec.Method)
+	b = appendString(b, rec.Path)
+	b = appendString(b, rec.ContainerID)
+	keys := make([]string, 0, len(rec.Header))
+	n := 0
+	for k, vs := range rec.Header {
+		keys = append(keys, k)
+		n += len(vs)
+	}
+	sort.Strings(keys)
+	b = appendUvarint(b, uint64(n))
+	for _, k := range keys {
+		for _, v := range rec.Header[k] {
+			b = appendString(b, k)
+			b = appendString(b, v)
+		}
+	}
+	return appendUvarint(b, uint64(len(rec.Body)))
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Flush()
+}
+
+// Close flushes the file and writes the gzip footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// Reader reads the records of a capture file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a new Reader reading the capture file from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+	br := bufio.NewReader(zr)
+	hdr := make([]byte, len(fileHeader))
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, ErrInvalidFile
+	}
+	for i := 0; i < len(fileHeader)-1; i++ {
+		if hdr[i] != fileHeader[i] {
+			return nil, ErrInvalidFile
+		}
+	}
+	if v := hdr[len(hdr)-1]; v != fileVersion {
+		return nil, fmt.Errorf("unsupported capture file version %d", v)
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record of the file. It returns io.EOF at the end of the file.
+func (r *Reader) Next() (*Record, error) {
+	ts, err := binary.ReadVarint(r.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, r.error(err)
+	}
+	rec := Record{Time: time.Unix(0, ts)}
+	for _, s := range []*string{&rec.Method, &rec.Path, &rec.ContainerID} {
+		if *s, err = r.readString(); err != nil {
+			return nil, r.error(err)
+		}
+	}
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, r.error(err)
+	}
+	rec.Header = make(http.Header)
+	for i := uint64(0); i < n; i++ {
+		k, err := r.readString()
+		if err != nil {
+			return nil, r.error(err)
+		}
+		v, err := r.readString()
+		if err != nil {
+			return nil, r.error(err)
+		}
+		// keys were canonicalized when received
+		rec.Header[k] = append(rec.Header[k], v)
+	}
+	if rec.Body, err = r.readBytes(maxBodyLen); err != nil {
+		return nil, r.error(err)
+	}
+	return &rec, nil
+}
+
+func (r *Reader) readString() (string, error) {
+	b, err := r.readBytes(maxStringLen)
+	return string(b), err
+}
+
+func (r *Reader) readBytes(max uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > max {
+		return nil, fmt.Errorf("field length %d exceeds maximum of %d", n, max)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// error wraps an error which occurred while reading a record.
+func (r *Reader) error(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("invalid capture record: %v", err)
+}
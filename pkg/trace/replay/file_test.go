@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRecords returns records spread over 3 seconds.
+func testRecords() []*Record {
+	start := time.Unix(1600000000, 0)
+	return []*Record{
+		{
+			Time:   start,
+			Method: http.MethodPut,
+			Path:   "/v0.4/traces",
+			Header: http.Header{
+				"Content-Type":                  {"application/msgpack"},
+				"Datadog-Meta-Lang":             {"go"},
+				"X-Datadog-Trace-Count":         {"2"},
+				"Datadog-Client-Computed-Stats": {"yes", "true"},
+			},
+			ContainerID: "abcdef",
+			Body:        []byte{0x92, 0x90, 0x90},
+		},
+		{
+			Time:   start.Add(time.Second),
+			Method: http.MethodPost,
+			Path:   "/v0.6/stats?source=test",
+			Header: http.Header{"Content-Type": {"application/msgpack"}},
+			Body:   bytes.Repeat([]byte("stats"), 1000),
+		},
+		{
+			Time:   start.Add(3 * time.Second),
+			Method: http.MethodPut,
+			Path:   "/v0.3/traces",
+			Header: http.Header{},
+			Body:   []byte{},
+		},
+	}
+}
+
+// testFile returns a capture file holding recs.
+func testFile(t *testing.T, recs []*Record) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	for _, rec := range recs {
+		require.NoError(t, w.Write(rec))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestFile(t *testing.T) {
+	t.Run("roundtrip", func(t *testing.T) {
+		recs := testRecords()
+		r, err := NewReader(bytes.NewReader(testFile(t, recs)))
+		require.NoError(t, err)
+		for _, want := range recs {
+			got, err := r.Next()
+			require.NoError(t, err)
+			assert.True(t, want.Time.Equal(got.Time))
+			got.Time = want.Time
+			assert.Equal(t, want, got)
+		}
+		_, err = r.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("encoded", func(t *testing.T) {
+		recs := testRecords()
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf)
+		require.NoError(t, err)
+		for _, rec := range recs {
+			require.NoError(t, w.WriteEncoded(EncodeRecord(rec)))
+		}
+		require.NoError(t, w.Close())
+		assert.Equal(t, testFile(t, recs), buf.Bytes())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		r, err := NewReader(bytes.NewReader(testFile(t, nil)))
+		require.NoError(t, err)
+		_, err = r.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(testRecords()[1]))
+		require.NoError(t, w.Flush())
+		data := buf.Bytes()
+
+		// decompress and cut the record in half
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		raw, _ := io.ReadAll(zr)
+		var cut bytes.Buffer
+		zw := gzip.NewWriter(&cut)
+		zw.Write(raw[:len(raw)/2])
+		zw.Close()
+
+		r, err := NewReader(&cut)
+		require.NoError(t, err)
+		_, err = r.Next()
+		assert.Error(t, err)
+		assert.NotEqual(t, io.EOF, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewReader(bytes.NewReader([]byte("not gzip")))
+		assert.Equal(t, ErrInvalidFile, err)
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte("DDOTHERFORMAT"))
+		zw.Close()
+		_, err = NewReader(&buf)
+		assert.Equal(t, ErrInvalidFile, err)
+
+		buf.Reset()
+		zw = gzip.NewWriter(&buf)
+		zw.Write(append(fileHeader[:len(fileHeader)-1:len(fileHeader)-1], fileVersion+1))
+		zw.Close()
+		_, err = NewReader(&buf)
+		assert.EqualError(t, err, "unsupported capture file version 2")
+	})
+}
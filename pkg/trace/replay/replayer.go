@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+)
+
+// headerContainerID is the header holding the container ID of the replayed requests.
+const headerContainerID = "Datadog-Container-ID"
+
+// Replayer sends the records of a capture file to a trace-agent.
+type Replayer struct {
+	// URL is the base URL of the trace-agent, such as http://localhost:8126.
+	URL string
+	// Speed is the speed of the replay relative to the capture: at 2 the requests
+	// are sent twice as fast as they were received. At 0 they are sent as fast
+	// as possible.
+	Speed float64
+	// Client is the HTTP client used to send the requests.
+	Client *http.Client
+
+	// sleep waits for d, unless ctx is done first. It is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Stats holds the outcome of a replay.
+type Stats struct {
+	// Sent is the number of requests sent.
+	Sent int
+	// Failed is the number of requests which could not be sent, or which were
+	// answered with an error status code.
+	Failed int
+}
+
+// Replay sends all the records of r, keeping their original pace scaled by the
+// speed of the replayer. It stops at the end of the file, when the file is invalid,
+// or when ctx is done.
+func (rp *Replayer) Replay(ctx context.Context, r *Reader) (Stats, error) {
+	var stats Stats
+	sleep := rp.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	client := rp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var first time.Time
+	start := time.Now()
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		if first.IsZero() {
+			first = rec.Time
+		}
+		if rp.Speed > 0 {
+			offset := time.Duration(float64(rec.Time.Sub(first)) / rp.Speed)
+			if err := sleep(ctx, time.Until(start.Add(offset))); err != nil {
+				return stats, err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if err := rp.send(ctx, client, rec); err != nil {
+			log.Debugf("Error replaying request: %v", err)
+			stats.Failed++
+		}
+		stats.Sent++
+	}
+}
+
+// send sends the request held by rec.
+func (rp *Replayer) send(ctx context.Context, client *http.Client, rec *Record) error {
+	url := strings.TrimSuffix(rp.URL, "/") + rec.Path
+	req, err := http.NewRequestWithContext(ctx, rec.Method, url, bytes.NewReader(rec.Body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range rec.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	// the body of the record is the raw body of the request
+	req.Header.Del("Content-Length")
+	if rec.ContainerID != "" {
+		req.Header.Set(headerContainerID, rec.ContainerID)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", rec.Method, rec.Path, resp.Status)
+	}
+	return nil
+}
+
+// sleepContext waits for d, unless ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayer(t *testing.T) {
+	type request struct {
+		method, uri, containerID, contentType string
+		body                                  []byte
+	}
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		requests = append(requests, request{
+			method:      req.Method,
+			uri:         req.URL.RequestURI(),
+			containerID: req.Header.Get(headerContainerID),
+			contentType: req.Header.Get("Content-Type"),
+			body:        body,
+		})
+		mu.Unlock()
+		if req.URL.Path == "/v0.3/traces" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	replayAt := func(speed float64) (Stats, []time.Duration, error) {
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+		r, err := NewReader(bytes.NewReader(testFile(t, testRecords())))
+		require.NoError(t, err)
+		var sleeps []time.Duration
+		start := time.Now()
+		rp := Replayer{
+			URL:   srv.URL + "/",
+			Speed: speed,
+			sleep: func(_ context.Context, d time.Duration) error {
+				// report the offsets from the start of the replay
+				sleeps = append(sleeps, time.Since(start)+d)
+				return nil
+			},
+		}
+		stats, err := rp.Replay(context.Background(), r)
+		return stats, sleeps, err
+	}
+
+	t.Run("requests", func(t *testing.T) {
+		assert := assert.New(t)
+		stats, _, err := replayAt(0)
+		assert.NoError(err)
+		assert.Equal(Stats{Sent: 3, Failed: 1}, stats)
+		recs := testRecords()
+		require.Len(t, requests, 3)
+		assert.Equal(request{
+			method:      http.MethodPut,
+			uri:         "/v0.4/traces",
+			containerID: "abcdef",
+			contentType: "application/msgpack",
+			body:        recs[0].Body,
+		}, requests[0])
+		assert.Equal(request{
+			method:      http.MethodPost,
+			uri:         "/v0.6/stats?source=test",
+			contentType: "application/msgpack",
+			body:        recs[1].Body,
+		}, requests[1])
+		assert.Equal("/v0.3/traces", requests[2].uri)
+	})
+
+	t.Run("speed", func(t *testing.T) {
+		_, sleeps, err := replayAt(2)
+		assert.NoError(t, err)
+		require.Len(t, sleeps, 3)
+		for i, want := range []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond} {
+			assert.InDelta(t, want, sleeps[i], float64(100*time.Millisecond), i)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		r, err := NewReader(bytes.NewReader(testFile(t, testRecords())))
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rp := Replayer{URL: srv.URL, Speed: 1}
+		stats, err := rp.Replay(ctx, r)
+		assert.Equal(t, context.Canceled, err)
+		assert.Zero(t, stats.Sent)
+	})
+}
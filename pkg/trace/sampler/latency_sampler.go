@@ -0,0 +1,245 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/DataDog/sketches-go/ddsketch/pb/sketchpb"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+)
+
+const (
+	// latencySamplerBurst sizes the token store used by the rate limiter.
+	latencySamplerBurst = 50
+	// latencyWindow is the period over which the latency distributions are
+	// accumulated. The distributions not updated during a whole window are
+	// forgotten.
+	latencyWindow = 5 * time.Minute
+	// latencyMinHits is the number of spans a distribution needs before its
+	// percentile is used as a threshold.
+	latencyMinHits = 100
+	latencyKey     = "_dd.latency"
+)
+
+// LatencySampler samples traces that are not caught by the Priority sampler
+// and which contain a span slower than most of the spans of its aggregation.
+// It keeps the traces with a top level or measured span whose duration exceeds
+// the configured percentile of the durations of the spans sharing its
+// (env, service, name, resource, error) combination. The percentiles are
+// learned from the distributions computed by the Concentrator, the ones of
+// the spans in error being distinct from the others.
+// The sampled spans are flagged with a latencyKey metric set at 1.
+type LatencySampler struct {
+	hits   *atomic.Int64
+	misses *atomic.Int64
+
+	tickStats  *time.Ticker
+	limiter    *rate.Limiter
+	percentile float64
+	defaultEnv string
+
+	mu          sync.RWMutex
+	windowStart time.Time
+	// distributions holds the latency distribution of each signature
+	distributions map[latencySignature]*latencyDistribution
+}
+
+// latencySignature identifies the spans sharing a latency distribution.
+type latencySignature struct {
+	env      string
+	service  string
+	name     string
+	resource string
+	error    bool
+}
+
+// latencyDistribution accumulates the durations of the spans of a signature
+// during the current window.
+type latencyDistribution struct {
+	sketch *ddsketch.DDSketch
+	// threshold is the duration, in nanoseconds, above which a span is
+	// considered slow. It is 0 until the sketch has enough hits.
+	threshold float64
+	lastSeen  time.Time
+}
+
+// NewLatencySampler returns a LatencySampler keeping the traces with spans
+// slower than the configured percentile of their distribution.
+func NewLatencySampler(conf *config.AgentConfig) *LatencySampler {
+	s := &LatencySampler{
+		hits:          atomic.NewInt64(0),
+		misses:        atomic.NewInt64(0),
+		limiter:       rate.NewLimiter(rate.Limit(conf.LatencySamplerTPS), latencySamplerBurst),
+		percentile:    conf.LatencySamplerPercentile,
+		defaultEnv:    conf.DefaultEnv,
+		windowStart:   time.Now(),
+		distributions: make(map[latencySignature]*latencyDistribution),
+		tickStats:     time.NewTicker(10 * time.Second),
+	}
+	go func() {
+		for range s.tickStats.C {
+			s.report()
+		}
+	}()
+	return s
+}
+
+// Sample a trace and returns true if trace was sampled (should be kept)
+func (s *LatencySampler) Sample(now time.Time, t *pb.TraceChunk, env string) bool {
+	if env == "" {
+		env = s.defaultEnv
+	}
+	for _, span := range t.Spans {
+		if !traceutil.HasTopLevel(span) && !traceutil.IsMeasured(span) {
+			continue
+		}
+		if !s.isSlow(env, span) {
+			continue
+		}
+		if !s.limiter.AllowN(now, 1) {
+			s.misses.Inc()
+			return false
+		}
+		s.hits.Inc()
+		traceutil.SetMetric(span, latencyKey, 1)
+		return true
+	}
+	return false
+}
+
+// isSlow returns whether the duration of the span exceeds the threshold of its
+// signature.
+func (s *LatencySampler) isSlow(env string, span *pb.Span) bool {
+	sig := latencySignature{
+		env:      env,
+		service:  span.Service,
+		name:     span.Name,
+		resource: span.Resource,
+		error:    span.Error != 0,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.distributions[sig]
+	return ok && d.threshold > 0 && float64(span.Duration) > d.threshold
+}
+
+// UpdateThresholds updates the latency distributions with the stats flushed
+// by the Concentrator.
+func (s *LatencySampler) UpdateThresholds(p pb.StatsPayload) {
+	s.updateThresholds(time.Now(), p)
+}
+
+func (s *LatencySampler) updateThresholds(now time.Time, p pb.StatsPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.windowStart) >= latencyWindow {
+		s.rotate()
+		s.windowStart = now
+	}
+	updated := make(map[*latencyDistribution]struct{})
+	for _, payload := range p.Stats {
+		for _, bucket := range payload.Stats {
+			for _, group := range bucket.Stats {
+				sig := latencySignature{
+					env:      payload.Env,
+					service:  group.Service,
+					name:     group.Name,
+					resource: group.Resource,
+				}
+				if d := s.merge(now, sig, group.OkSummary); d != nil {
+					updated[d] = struct{}{}
+				}
+				sig.error = true
+				if d := s.merge(now, sig, group.ErrorSummary); d != nil {
+					updated[d] = struct{}{}
+				}
+			}
+		}
+	}
+	for d := range updated {
+		if d.sketch.GetCount() < latencyMinHits {
+			continue
+		}
+		threshold, err := d.sketch.GetValueAtQuantile(s.percentile)
+		if err != nil {
+			log.Debugf("Error computing the latency threshold: %v", err)
+			continue
+		}
+		d.threshold = threshold
+	}
+}
+
+// merge adds an encoded sketch to the distribution of sig, and returns the
+// distribution if it was updated. Callers must guard!
+func (s *LatencySampler) merge(now time.Time, sig latencySignature, summary []byte) *latencyDistribution {
+	if len(summary) == 0 {
+		return nil
+	}
+	var msg sketchpb.DDSketch
+	if err := proto.Unmarshal(summary, &msg); err != nil {
+		log.Debugf("Error decoding latency distribution: %v", err)
+		return nil
+	}
+	sketch, err := ddsketch.FromProto(&msg)
+	if err != nil {
+		log.Debugf("Error decoding latency distribution: %v", err)
+		return nil
+	}
+	if sketch.IsEmpty() {
+		return nil
+	}
+	d, ok := s.distributions[sig]
+	if !ok {
+		d = &latencyDistribution{sketch: sketch, lastSeen: now}
+		s.distributions[sig] = d
+		return d
+	}
+	if err := d.sketch.MergeWith(sketch); err != nil {
+		log.Debugf("Error merging latency distributions: %v", err)
+		return nil
+	}
+	d.lastSeen = now
+	return d
+}
+
+// rotate starts a new window: the distributions not updated during the last
+// one are removed, the others are reset while keeping their threshold until
+// they get enough hits again. Callers must guard!
+func (s *LatencySampler) rotate() {
+	for sig, d := range s.distributions {
+		if d.lastSeen.Before(s.windowStart) {
+			delete(s.distributions, sig)
+			continue
+		}
+		d.sketch.Clear()
+	}
+}
+
+// Stop stops reporting stats
+func (s *LatencySampler) Stop() {
+	s.tickStats.Stop()
+}
+
+func (s *LatencySampler) report() {
+	metrics.Count("datadog.trace_agent.sampler.latency.hits", s.hits.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.sampler.latency.misses", s.misses.Swap(0), nil, 1)
+	s.mu.RLock()
+	size := len(s.distributions)
+	s.mu.RUnlock()
+	metrics.Gauge("datadog.trace_agent.sampler.latency.distributions", float64(size), nil, 1)
+}
@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+func encodedSketch(t *testing.T, values ...float64) []byte {
+	sketch, err := ddsketch.LogCollapsingLowestDenseDDSketch(0.01, 2048)
+	require.NoError(t, err)
+	for _, v := range values {
+		require.NoError(t, sketch.Add(v))
+	}
+	b, err := proto.Marshal(sketch.ToProto())
+	require.NoError(t, err)
+	return b
+}
+
+// latencyStats returns the stats of a group whose ok spans last from 1 to
+// 1000ms, and the error spans 5s.
+func latencyStats(t *testing.T, env, resource string) pb.StatsPayload {
+	var ok, errors []float64
+	for i := 1; i <= 1000; i++ {
+		ok = append(ok, float64(time.Duration(i)*time.Millisecond))
+		errors = append(errors, float64(5*time.Second))
+	}
+	return pb.StatsPayload{Stats: []pb.ClientStatsPayload{{
+		Env: env,
+		Stats: []pb.ClientStatsBucket{{
+			Stats: []pb.ClientGroupedStats{{
+				Service:      "s1",
+				Name:         "n1",
+				Resource:     resource,
+				OkSummary:    encodedSketch(t, ok...),
+				ErrorSummary: encodedSketch(t, errors...),
+			}},
+		}},
+	}}}
+}
+
+func latencySpan(resource string, duration time.Duration, isError bool) *pb.Span {
+	s := &pb.Span{
+		Service:  "s1",
+		Name:     "n1",
+		Resource: resource,
+		Duration: duration.Nanoseconds(),
+		Metrics:  map[string]float64{"_top_level": 1},
+	}
+	if isError {
+		s.Error = 1
+	}
+	return s
+}
+
+func TestLatencySampler(t *testing.T) {
+	conf := config.New()
+	conf.DefaultEnv = "prod"
+	s := NewLatencySampler(conf)
+	s.Stop()
+	now := time.Now()
+	s.updateThresholds(now, latencyStats(t, "prod", "r1"))
+
+	for _, tc := range []struct {
+		name     string
+		span     *pb.Span
+		env      string
+		expected bool
+	}{
+		{"slow", latencySpan("r1", 2*time.Second, false), "prod", true},
+		{"slow-default-env", latencySpan("r1", 2*time.Second, false), "", true},
+		{"fast", latencySpan("r1", 500*time.Millisecond, false), "prod", false},
+		{"other-env", latencySpan("r1", 2*time.Second, false), "staging", false},
+		{"other-resource", latencySpan("r2", 2*time.Second, false), "prod", false},
+		{"error-fast", latencySpan("r1", 2*time.Second, true), "prod", false},
+		{"error-slow", latencySpan("r1", 6*time.Second, true), "prod", true},
+		{"not-top-level", &pb.Span{Service: "s1", Name: "n1", Resource: "r1", Duration: int64(2 * time.Second)}, "prod", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			chunk := getTraceChunkWithSpanAndPriority(tc.span, PriorityAutoDrop)
+			assert.Equal(tc.expected, s.Sample(now, chunk, tc.env))
+			if tc.expected {
+				assert.EqualValues(1, tc.span.Metrics[latencyKey])
+			} else {
+				assert.NotContains(tc.span.Metrics, latencyKey)
+			}
+		})
+	}
+}
+
+func TestLatencySamplerMinHits(t *testing.T) {
+	conf := config.New()
+	conf.DefaultEnv = "prod"
+	s := NewLatencySampler(conf)
+	s.Stop()
+	now := time.Now()
+	stats := func(values ...float64) pb.StatsPayload {
+		return pb.StatsPayload{Stats: []pb.ClientStatsPayload{{
+			Env: "prod",
+			Stats: []pb.ClientStatsBucket{{
+				Stats: []pb.ClientGroupedStats{{Service: "s1", Name: "n1", Resource: "r1", OkSummary: encodedSketch(t, values...)}},
+			}},
+		}}}
+	}
+	var values []float64
+	for i := 0; i < latencyMinHits/2; i++ {
+		values = append(values, float64(time.Millisecond))
+	}
+	chunk := func() *pb.TraceChunk {
+		return getTraceChunkWithSpanAndPriority(latencySpan("r1", time.Second, false), PriorityAutoDrop)
+	}
+
+	s.updateThresholds(now, stats(values...))
+	assert.False(t, s.Sample(now, chunk(), ""))
+
+	// the distributions are merged across flushes
+	s.updateThresholds(now, stats(values...))
+	assert.True(t, s.Sample(now, chunk(), ""))
+}
+
+func TestLatencySamplerWindow(t *testing.T) {
+	assert := assert.New(t)
+	conf := config.New()
+	conf.DefaultEnv = "prod"
+	s := NewLatencySampler(conf)
+	s.Stop()
+	now := time.Now()
+	s.windowStart = now
+	s.updateThresholds(now, latencyStats(t, "prod", "r1"))
+	s.updateThresholds(now, latencyStats(t, "prod", "r2"))
+	assert.Len(s.distributions, 4)
+
+	// the thresholds are kept in the next window, until the distributions
+	// get enough hits
+	now = now.Add(latencyWindow)
+	s.updateThresholds(now, latencyStats(t, "prod", "r1"))
+	assert.Len(s.distributions, 4)
+	assert.True(s.Sample(now, getTraceChunkWithSpanAndPriority(latencySpan("r2", 2*time.Second, false), PriorityAutoDrop), ""))
+
+	// the distributions not updated during a whole window are removed
+	now = now.Add(latencyWindow)
+	s.updateThresholds(now, latencyStats(t, "prod", "r1"))
+	assert.Len(s.distributions, 2)
+	assert.False(s.Sample(now, getTraceChunkWithSpanAndPriority(latencySpan("r2", 2*time.Second, false), PriorityAutoDrop), ""))
+	assert.True(s.Sample(now, getTraceChunkWithSpanAndPriority(latencySpan("r1", 2*time.Second, false), PriorityAutoDrop), ""))
+}
+
+func TestLatencySamplerRateLimit(t *testing.T) {
+	conf := config.New()
+	conf.DefaultEnv = "prod"
+	conf.LatencySamplerTPS = 1
+	s := NewLatencySampler(conf)
+	s.Stop()
+	now := time.Now()
+	s.updateThresholds(now, latencyStats(t, "prod", "r1"))
+
+	var sampled int
+	for i := 0; i < 2*latencySamplerBurst; i++ {
+		if s.Sample(now, getTraceChunkWithSpanAndPriority(latencySpan("r1", 2*time.Second, false), PriorityAutoDrop), "") {
+			sampled++
+		}
+	}
+	assert.Equal(t, latencySamplerBurst, sampled)
+	assert.EqualValues(t, latencySamplerBurst, s.misses.Load())
+}
@@ -33,6 +33,9 @@ type BucketsAggregationKey struct {
 	Type       string
 	StatusCode uint32
 	Synthetics bool
+	// ExtraDimensionsHash identifies the values of the extra dimensions
+	// configured with extra_aggregators.
+	ExtraDimensionsHash uint64
 }
 
 // PayloadAggregationKey specifies the key by which a payload is aggregated.
@@ -86,6 +89,8 @@ func NewAggregationFromGroup(g pb.ClientGroupedStats) Aggregation {
 			Name:       g.Name,
 			StatusCode: g.HTTPStatusCode,
 			Synthetics: g.Synthetics,
+
+			ExtraDimensionsHash: extraDimensionsHash(g.ExtraDimensions),
 		},
 	}
 }
@@ -45,6 +45,11 @@ type ClientStatsAggregator struct {
 	agentHostname string
 	agentVersion  string
 
+	// extraAggregators are the tags kept as extra aggregation dimensions,
+	// with at most maxExtraValues distinct values each per bucket.
+	extraAggregators []string
+	maxExtraValues   int
+
 	exit chan struct{}
 	done chan struct{}
 }
@@ -62,6 +67,9 @@ func NewClientStatsAggregator(conf *config.AgentConfig, out chan pb.StatsPayload
 		oldestTs:      alignAggTs(time.Now().Add(bucketDuration - oldestBucketStart)),
 		exit:          make(chan struct{}),
 		done:          make(chan struct{}),
+
+		extraAggregators: normalizeExtraDimensionKeys(conf.ExtraAggregators),
+		maxExtraValues:   conf.ExtraAggregatorsMaxValues,
 	}
 }
 
@@ -131,9 +139,16 @@ func (a *ClientStatsAggregator) add(now time.Time, p pb.ClientStatsPayload) {
 		}
 		b, ok := a.buckets[ts.Unix()]
 		if !ok {
-			b = &bucket{ts: ts}
+			b = &bucket{
+				ts:              ts,
+				extraDimensions: newExtraDimensions(a.extraAggregators, a.maxExtraValues),
+			}
 			a.buckets[ts.Unix()] = b
 		}
+		for i := range clientBucket.Stats {
+			g := &clientBucket.Stats[i]
+			g.ExtraDimensions = b.extraDimensions.fromTags(g.ExtraDimensions)
+		}
 		p.Stats = []pb.ClientStatsBucket{clientBucket}
 		a.flush(b.add(p))
 	}
@@ -171,6 +186,8 @@ type bucket struct {
 	n int
 	// agg contains the aggregated Hits/Errors/Duration counts
 	agg map[PayloadAggregationKey]map[BucketsAggregationKey]*aggregatedCounts
+	// extraDimensions limits the extra dimensions of the payloads matching the bucket
+	extraDimensions *extraDimensions
 }
 
 func (b *bucket) add(p pb.ClientStatsPayload) []pb.ClientStatsPayload {
@@ -208,7 +225,7 @@ func (b *bucket) aggregateCounts(p pb.ClientStatsPayload) {
 			aggKey := newBucketAggregationKey(sb)
 			agg, ok := payloadAgg[aggKey]
 			if !ok {
-				agg = &aggregatedCounts{}
+				agg = &aggregatedCounts{extraDimensions: sb.ExtraDimensions}
 				payloadAgg[aggKey] = agg
 			}
 			agg.hits += sb.Hits
@@ -231,15 +248,16 @@ func (b *bucket) aggregationToPayloads() []pb.ClientStatsPayload {
 		stats := make([]pb.ClientGroupedStats, 0, len(aggrCounts))
 		for aggrKey, counts := range aggrCounts {
 			stats = append(stats, pb.ClientGroupedStats{
-				Service:        aggrKey.Service,
-				Name:           aggrKey.Name,
-				Resource:       aggrKey.Resource,
-				HTTPStatusCode: aggrKey.StatusCode,
-				Type:           aggrKey.Type,
-				Synthetics:     aggrKey.Synthetics,
-				Hits:           counts.hits,
-				Errors:         counts.errors,
-				Duration:       counts.duration,
+				Service:         aggrKey.Service,
+				Name:            aggrKey.Name,
+				Resource:        aggrKey.Resource,
+				HTTPStatusCode:  aggrKey.StatusCode,
+				Type:            aggrKey.Type,
+				Synthetics:      aggrKey.Synthetics,
+				Hits:            counts.hits,
+				Errors:          counts.errors,
+				Duration:        counts.duration,
+				ExtraDimensions: counts.extraDimensions,
 			})
 		}
 		clientBuckets := []pb.ClientStatsBucket{
@@ -271,6 +289,8 @@ func newBucketAggregationKey(b pb.ClientGroupedStats) BucketsAggregationKey {
 		Type:       b.Type,
 		Synthetics: b.Synthetics,
 		StatusCode: b.HTTPStatusCode,
+
+		ExtraDimensionsHash: extraDimensionsHash(b.ExtraDimensions),
 	}
 }
 
@@ -291,4 +311,5 @@ func trimCounts(p pb.ClientStatsPayload) pb.ClientStatsPayload {
 // Distributions and TopLevelCount will stay on the initial payload
 type aggregatedCounts struct {
 	hits, errors, duration uint64
+	extraDimensions        []string
 }
@@ -71,6 +71,10 @@ func getTestStatsWithStart(start time.Time) pb.ClientStatsPayload {
 	b := pb.ClientStatsBucket{}
 	fuzzer.Fuzz(&b)
 	b.Start = uint64(start.UnixNano())
+	for i := range b.Stats {
+		// the extra dimensions are discarded when no extra aggregator is configured
+		b.Stats[i].ExtraDimensions = nil
+	}
 	p := pb.ClientStatsPayload{}
 	fuzzer.Fuzz(&p)
 	p.Tags = nil
@@ -333,6 +337,37 @@ func TestCountAggregation(t *testing.T) {
 	}
 }
 
+func TestExtraDimensionsAggregation(t *testing.T) {
+	assert := assert.New(t)
+	a := newTestAggregator()
+	a.extraAggregators = []string{"peer.service"}
+	testTime := time.Unix(time.Now().Unix(), 0)
+
+	k := BucketsAggregationKey{Service: "s"}
+	withDimensions := func(p pb.ClientStatsPayload, dims ...string) pb.ClientStatsPayload {
+		p.Stats[0].Stats[0].ExtraDimensions = dims
+		return p
+	}
+	c1 := withDimensions(payloadWithCounts(testTime, k, 1, 0, 10), "peer.service:billing", "other:dropped")
+	c2 := withDimensions(payloadWithCounts(testTime, k, 2, 0, 20), "peer.service:billing")
+	c3 := withDimensions(payloadWithCounts(testTime, k, 4, 0, 40), "peer.service:orders")
+
+	a.add(testTime, c1)
+	a.add(testTime, c2)
+	a.add(testTime, c3)
+	a.flushOnTime(testTime.Add(oldestBucketStart + time.Nanosecond))
+	assert.Len(a.out, 3)
+	<-a.out
+	<-a.out
+	aggCounts := <-a.out
+	assertAggCountsPayload(t, aggCounts)
+
+	assert.ElementsMatch([]pb.ClientGroupedStats{
+		{Service: "s", Hits: 3, Duration: 30, ExtraDimensions: []string{"peer.service:billing"}},
+		{Service: "s", Hits: 4, Duration: 40, ExtraDimensions: []string{"peer.service:orders"}},
+	}, aggCounts.Stats[0].Stats[0].Stats)
+}
+
 func deepCopy(p pb.ClientStatsPayload) pb.ClientStatsPayload {
 	new := p
 	new.Stats = deepCopyStatsBucket(p.Stats)
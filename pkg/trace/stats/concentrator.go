@@ -29,6 +29,10 @@ type Concentrator struct {
 	In  chan Input
 	Out chan pb.StatsPayload
 
+	// OnFlush will be called with the stats of every flush, if non-nil, before
+	// they are sent to Out. It must not modify them.
+	OnFlush func(pb.StatsPayload)
+
 	// bucket duration in nanoseconds
 	bsize int64
 	// Timestamp of the oldest time bucket for which we allow data.
@@ -46,6 +50,11 @@ type Concentrator struct {
 	agentEnv      string
 	agentHostname string
 	agentVersion  string
+
+	// extraAggregators are the span tags used as extra aggregation dimensions,
+	// with at most maxExtraValues distinct values each per bucket.
+	extraAggregators []string
+	maxExtraValues   int
 }
 
 // NewConcentrator initializes a new concentrator ready to be started
@@ -65,6 +74,9 @@ func NewConcentrator(conf *config.AgentConfig, out chan pb.StatsPayload, now tim
 		agentEnv:      conf.DefaultEnv,
 		agentHostname: conf.Hostname,
 		agentVersion:  conf.AgentVersion,
+
+		extraAggregators: normalizeExtraDimensionKeys(conf.ExtraAggregators),
+		maxExtraValues:   conf.ExtraAggregatorsMaxValues,
 	}
 	return &c
 }
@@ -178,6 +190,7 @@ func (c *Concentrator) addNow(pt *traceutil.ProcessedTrace, containerID string)
 		b, ok := c.buckets[btime]
 		if !ok {
 			b = NewRawBucket(uint64(btime), uint64(c.bsize))
+			b.extraDimensions = newExtraDimensions(c.extraAggregators, c.maxExtraValues)
 			c.buckets[btime] = b
 		}
 		b.HandleSpan(s, weight, isTop, pt.TraceChunk.Origin, aggKey)
@@ -186,7 +199,11 @@ func (c *Concentrator) addNow(pt *traceutil.ProcessedTrace, containerID string)
 
 // Flush deletes and returns complete statistic buckets
 func (c *Concentrator) Flush() pb.StatsPayload {
-	return c.flushNow(time.Now().UnixNano())
+	p := c.flushNow(time.Now().UnixNano())
+	if c.OnFlush != nil {
+		c.OnFlush(p)
+	}
+	return p
 }
 
 func (c *Concentrator) flushNow(now int64) pb.StatsPayload {
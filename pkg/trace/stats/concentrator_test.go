@@ -8,6 +8,7 @@ package stats
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -526,3 +527,38 @@ func TestIgnoresPartialSpans(t *testing.T) {
 	stats := c.flushNow(now.UnixNano() + int64(c.bufferLen)*testBucketInterval)
 	assert.Empty(stats.GetStats())
 }
+
+func TestConcentratorExtraAggregators(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	c := NewTestConcentrator(now)
+	c.extraAggregators = []string{"peer.service"}
+	c.maxExtraValues = 2
+
+	var spans []*pb.Span
+	for i, peer := range []string{"billing", "billing", "orders", "users", ""} {
+		span := testSpan(uint64(i+1), 0, 50, 5, "A1", "resource1", 0)
+		if peer != "" {
+			span.Meta = map[string]string{"peer.service": peer}
+		}
+		spans = append(spans, span)
+	}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "none", ""), "")
+
+	stats := c.flushNow(now.UnixNano() + int64(c.bufferLen)*testBucketInterval)
+	assert.Len(stats.Stats, 1)
+	hits := make(map[string]uint64)
+	for _, b := range stats.Stats[0].Stats {
+		for _, g := range b.Stats {
+			assert.Equal("resource1", g.Resource)
+			hits[strings.Join(g.ExtraDimensions, ",")] += g.Hits
+		}
+	}
+	assert.Equal(map[string]uint64{
+		"peer.service:billing":  2,
+		"peer.service:orders":   1,
+		"peer.service:overflow": 1,
+		"":                      1,
+	}, hits)
+}
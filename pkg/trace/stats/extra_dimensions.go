@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// overflowDimensionValue replaces the values of an extra dimension once its
+// maximum number of distinct values has been reached in a stats bucket.
+const overflowDimensionValue = "overflow"
+
+// extraDimensions computes the additional dimensions on which the stats of a
+// bucket are aggregated, from the span tags configured with extra_aggregators.
+// The dimensions are "key:value" tags sorted by key.
+//
+// It caps the number of distinct values of each dimension, so that a tag with
+// an unexpectedly high cardinality can't blow up the size of the stats
+// payloads: the values over the limit are aggregated together under the
+// overflowDimensionValue.
+//
+// A nil *extraDimensions computes no dimension. It is not thread safe, it's
+// meant to be used by a single bucket.
+type extraDimensions struct {
+	keys      []string
+	maxValues int
+	// values holds the distinct values seen for each key
+	values map[string]map[string]struct{}
+}
+
+// newExtraDimensions returns the extraDimensions of a bucket, or nil if there
+// are no keys. A maxValues lower than 1 disables the cardinality limit.
+func newExtraDimensions(keys []string, maxValues int) *extraDimensions {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &extraDimensions{
+		keys:      keys,
+		maxValues: maxValues,
+		values:    make(map[string]map[string]struct{}, len(keys)),
+	}
+}
+
+// normalizeExtraDimensionKeys returns the sorted and deduplicated non-empty keys.
+func normalizeExtraDimensionKeys(keys []string) []string {
+	var normalized []string
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			normalized = append(normalized, k)
+		}
+	}
+	sort.Strings(normalized)
+	n := 0
+	for i, k := range normalized {
+		if i > 0 && k == normalized[n-1] {
+			continue
+		}
+		normalized[n] = k
+		n++
+	}
+	return normalized[:n]
+}
+
+// fromSpan returns the dimensions of a span, taken from its meta.
+func (d *extraDimensions) fromSpan(s *pb.Span) []string {
+	if d == nil || len(s.Meta) == 0 {
+		return nil
+	}
+	var dims []string
+	for _, k := range d.keys {
+		if v := s.Meta[k]; v != "" {
+			dims = append(dims, k+":"+d.limit(k, v))
+		}
+	}
+	return dims
+}
+
+// fromTags returns the dimensions of grouped stats computed elsewhere, given
+// as "key:value" tags. The tags whose key isn't configured are discarded.
+func (d *extraDimensions) fromTags(tags []string) []string {
+	if d == nil || len(tags) == 0 {
+		return nil
+	}
+	var dims []string
+	for _, k := range d.keys {
+		for _, tag := range tags {
+			if v := strings.TrimPrefix(tag, k+":"); len(v) < len(tag) && v != "" {
+				dims = append(dims, k+":"+d.limit(k, v))
+				break
+			}
+		}
+	}
+	return dims
+}
+
+// limit returns the value of a dimension, or overflowDimensionValue if it is
+// a new one and the maximum number of distinct values has been reached.
+func (d *extraDimensions) limit(key, value string) string {
+	if d.maxValues < 1 {
+		return value
+	}
+	values, ok := d.values[key]
+	if !ok {
+		values = make(map[string]struct{})
+		d.values[key] = values
+	}
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) >= d.maxValues {
+		return overflowDimensionValue
+	}
+	values[value] = struct{}{}
+	return value
+}
+
+// extraDimensionsHash returns the hash of the dimensions, used in the
+// aggregation key. It is 0 when there are no dimensions.
+func extraDimensionsHash(dims []string) uint64 {
+	if len(dims) == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	for _, dim := range dims {
+		h.Write([]byte(dim))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+func TestNormalizeExtraDimensionKeys(t *testing.T) {
+	assert.Nil(t, normalizeExtraDimensionKeys(nil))
+	assert.Equal(t,
+		[]string{"db.instance", "peer.service", "tenant"},
+		normalizeExtraDimensionKeys([]string{"tenant", " peer.service", "", "db.instance", "tenant"}),
+	)
+}
+
+func TestExtraDimensionsFromSpan(t *testing.T) {
+	d := newExtraDimensions([]string{"db.instance", "peer.service"}, 0)
+
+	span := &pb.Span{Meta: map[string]string{
+		"peer.service": "billing",
+		"db.instance":  "orders",
+		"other":        "ignored",
+	}}
+	assert.Equal(t, []string{"db.instance:orders", "peer.service:billing"}, d.fromSpan(span))
+
+	span = &pb.Span{Meta: map[string]string{"peer.service": "billing", "db.instance": ""}}
+	assert.Equal(t, []string{"peer.service:billing"}, d.fromSpan(span))
+
+	assert.Nil(t, d.fromSpan(&pb.Span{}))
+
+	var disabled *extraDimensions
+	assert.Nil(t, disabled.fromSpan(span))
+	assert.Nil(t, newExtraDimensions(nil, 10))
+}
+
+func TestExtraDimensionsFromTags(t *testing.T) {
+	d := newExtraDimensions([]string{"db.instance", "peer.service"}, 0)
+
+	assert.Equal(t,
+		[]string{"db.instance:orders", "peer.service:billing"},
+		d.fromTags([]string{"peer.service:billing", "other:ignored", "db.instance:orders"}),
+	)
+	assert.Equal(t,
+		[]string{"peer.service:billing"},
+		d.fromTags([]string{"peer.service:billing", "db.instance:", "db.instance.name:orders"}),
+	)
+	assert.Nil(t, d.fromTags(nil))
+}
+
+func TestExtraDimensionsMaxValues(t *testing.T) {
+	d := newExtraDimensions([]string{"peer.service", "tenant"}, 2)
+	dims := func(service, tenant string) []string {
+		return d.fromSpan(&pb.Span{Meta: map[string]string{"peer.service": service, "tenant": tenant}})
+	}
+
+	assert.Equal(t, []string{"peer.service:a", "tenant:1"}, dims("a", "1"))
+	assert.Equal(t, []string{"peer.service:b", "tenant:1"}, dims("b", "1"))
+	assert.Equal(t, []string{"peer.service:overflow", "tenant:2"}, dims("c", "2"))
+	assert.Equal(t, []string{"peer.service:a", "tenant:overflow"}, dims("a", "3"))
+	assert.Equal(t, []string{"peer.service:overflow", "tenant:2"}, d.fromTags([]string{"peer.service:d", "tenant:2"}))
+}
+
+func TestExtraDimensionsHash(t *testing.T) {
+	assert.Zero(t, extraDimensionsHash(nil))
+	assert.Equal(t, extraDimensionsHash([]string{"a:b"}), extraDimensionsHash([]string{"a:b"}))
+	assert.NotEqual(t, extraDimensionsHash([]string{"a:b"}), extraDimensionsHash([]string{"a:c"}))
+	assert.NotEqual(t, extraDimensionsHash([]string{"a:b", "c:d"}), extraDimensionsHash([]string{"a:bc:d"}))
+}
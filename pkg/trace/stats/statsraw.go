@@ -36,6 +36,9 @@ type groupedStats struct {
 	duration        float64
 	okDistribution  *ddsketch.DDSketch
 	errDistribution *ddsketch.DDSketch
+	// extraDimensions are the values of the extra dimensions of the
+	// aggregation, only its hash being part of the aggregation key.
+	extraDimensions []string
 }
 
 // round a float to an int, uniformly choosing
@@ -60,18 +63,19 @@ func (s *groupedStats) export(a Aggregation) (pb.ClientGroupedStats, error) {
 		return pb.ClientGroupedStats{}, err
 	}
 	return pb.ClientGroupedStats{
-		Service:        a.Service,
-		Name:           a.Name,
-		Resource:       a.Resource,
-		HTTPStatusCode: a.StatusCode,
-		Type:           a.Type,
-		Hits:           round(s.hits),
-		Errors:         round(s.errors),
-		Duration:       round(s.duration),
-		TopLevelHits:   round(s.topLevelHits),
-		OkSummary:      okSummary,
-		ErrorSummary:   errSummary,
-		Synthetics:     a.Synthetics,
+		Service:         a.Service,
+		Name:            a.Name,
+		Resource:        a.Resource,
+		HTTPStatusCode:  a.StatusCode,
+		Type:            a.Type,
+		Hits:            round(s.hits),
+		Errors:          round(s.errors),
+		Duration:        round(s.duration),
+		TopLevelHits:    round(s.topLevelHits),
+		OkSummary:       okSummary,
+		ErrorSummary:    errSummary,
+		Synthetics:      a.Synthetics,
+		ExtraDimensions: s.extraDimensions,
 	}, nil
 }
 
@@ -101,6 +105,10 @@ type RawBucket struct {
 
 	// this should really remain private as it's subject to refactoring
 	data map[Aggregation]*groupedStats
+
+	// extraDimensions computes the extra dimensions of the spans, it is nil
+	// when no extra aggregator is configured.
+	extraDimensions *extraDimensions
 }
 
 // NewRawBucket opens a new calculation bucket for time ts and initializes it properly
@@ -149,15 +157,18 @@ func (sb *RawBucket) HandleSpan(s *pb.Span, weight float64, isTop bool, origin s
 		panic("env should never be empty")
 	}
 	aggr := NewAggregationFromSpan(s, origin, aggKey)
-	sb.add(s, weight, isTop, aggr)
+	dims := sb.extraDimensions.fromSpan(s)
+	aggr.ExtraDimensionsHash = extraDimensionsHash(dims)
+	sb.add(s, weight, isTop, aggr, dims)
 }
 
-func (sb *RawBucket) add(s *pb.Span, weight float64, isTop bool, aggr Aggregation) {
+func (sb *RawBucket) add(s *pb.Span, weight float64, isTop bool, aggr Aggregation, dims []string) {
 	var gs *groupedStats
 	var ok bool
 
 	if gs, ok = sb.data[aggr]; !ok {
 		gs = newGroupedStats()
+		gs.extraDimensions = dims
 		sb.data[aggr] = gs
 	}
 	if isTop {
@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskQueueExtension is the extension of the files holding spilled payloads.
+const diskQueueExtension = ".payload"
+
+// diskQueue is a size-bounded, on-disk FIFO queue of payloads. The sender spills
+// payloads to it when they can not be kept in memory and replays them, oldest
+// first, once the destination is reachable again. Each payload is stored in its
+// own file, so that the queue survives restarts of the agent.
+type diskQueue struct {
+	path    string // directory holding the payload files
+	maxSize int64  // maximum total size of the payload files, in bytes
+
+	mu    sync.Mutex
+	files []string // payload file names, oldest first
+	sizes []int64  // size of each file in files
+	size  int64    // total size of all files
+	seq   uint64   // sequence number of the last written file
+}
+
+// newDiskQueue returns a new diskQueue storing at most maxSize bytes in the directory
+// at path. Payloads previously stored in this directory are reloaded.
+func newDiskQueue(path string, maxSize int64) (*diskQueue, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid maximum size: %d", maxSize)
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	q := &diskQueue{path: path, maxSize: maxSize}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || filepath.Ext(entry.Name()) != diskQueueExtension {
+			continue
+		}
+		q.files = append(q.files, entry.Name())
+		q.sizes = append(q.sizes, entry.Size())
+		q.size += entry.Size()
+	}
+	// file names start with their zero-padded creation time, so they sort in order
+	sort.Sort(byName{q})
+	return q, nil
+}
+
+// byName sorts the files of a diskQueue by name.
+type byName struct{ q *diskQueue }
+
+func (s byName) Len() int           { return len(s.q.files) }
+func (s byName) Less(i, j int) bool { return s.q.files[i] < s.q.files[j] }
+func (s byName) Swap(i, j int) {
+	s.q.files[i], s.q.files[j] = s.q.files[j], s.q.files[i]
+	s.q.sizes[i], s.q.sizes[j] = s.q.sizes[j], s.q.sizes[i]
+}
+
+// Len returns the number of payloads in the queue.
+func (q *diskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.files)
+}
+
+// Size returns the total size of the queue on disk, in bytes.
+func (q *diskQueue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Put writes p at the end of the queue. If the queue is full, the oldest payloads
+// are removed to make room for it; Put then returns the number of payloads removed
+// along with their total size in bytes.
+func (q *diskQueue) Put(p *payload) (dropped int, droppedBytes int64, err error) {
+	data := encodeDiskPayload(p)
+	size := int64(len(data))
+	if size > q.maxSize {
+		return 0, 0, fmt.Errorf("payload of %d bytes exceeds the maximum size of %d bytes", size, q.maxSize)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.files) > 0 && q.size+size > q.maxSize {
+		droppedBytes += q.sizes[0]
+		dropped++
+		if err := q.removeOldest(); err != nil {
+			return dropped, droppedBytes, err
+		}
+	}
+	q.seq++
+	name := fmt.Sprintf("%020d_%010d%s", time.Now().UnixNano(), q.seq, diskQueueExtension)
+	// write to a temporary file first so that a crash never leaves a truncated
+	// payload behind.
+	tmp := filepath.Join(q.path, name+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return dropped, droppedBytes, err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.path, name)); err != nil {
+		os.Remove(tmp)
+		return dropped, droppedBytes, err
+	}
+	q.files = append(q.files, name)
+	q.sizes = append(q.sizes, size)
+	q.size += size
+	return dropped, droppedBytes, nil
+}
+
+// errDiskQueueEmpty is returned by Peek when the queue is empty.
+var errDiskQueueEmpty = errors.New("disk queue is empty")
+
+// Peek returns the oldest payload in the queue, without removing it. If the oldest
+// payload can not be decoded, it is removed from the queue and an error is returned.
+func (q *diskQueue) Peek() (*payload, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.files) == 0 {
+		return nil, errDiskQueueEmpty
+	}
+	data, err := ioutil.ReadFile(filepath.Join(q.path, q.files[0]))
+	if err == nil {
+		var p *payload
+		if p, err = decodeDiskPayload(data); err == nil {
+			return p, nil
+		}
+	}
+	if rerr := q.removeOldest(); rerr != nil {
+		return nil, rerr
+	}
+	return nil, err
+}
+
+// Pop removes the oldest payload from the queue.
+func (q *diskQueue) Pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.files) == 0 {
+		return errDiskQueueEmpty
+	}
+	return q.removeOldest()
+}
+
+// removeOldest removes the oldest file of the queue. It must be called with q.mu held.
+func (q *diskQueue) removeOldest() error {
+	name, size := q.files[0], q.sizes[0]
+	// remove the file from the queue even if it can not be deleted, so that we
+	// do not get stuck on it.
+	q.files = q.files[1:]
+	q.sizes = q.sizes[1:]
+	q.size -= size
+	if err := os.Remove(filepath.Join(q.path, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// encodeDiskPayload encodes the headers and body of p. The headers are written first,
+// preceded by their count and each of their keys and values by its length, all
+// as big-endian uint32. The body makes up the rest of the data.
+func encodeDiskPayload(p *payload) []byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(p.headers)))
+	keys := make([]string, 0, len(p.headers))
+	for k := range p.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeString(k)
+		writeString(p.headers[k])
+	}
+	buf.Write(p.body.Bytes())
+	return buf.Bytes()
+}
+
+// decodeDiskPayload decodes a payload encoded by encodeDiskPayload.
+func decodeDiskPayload(data []byte) (*payload, error) {
+	r := bytes.NewReader(data)
+	readUint32 := func() (uint32, error) {
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	}
+	readString := func() (string, error) {
+		n, err := readUint32()
+		if err != nil {
+			return "", err
+		}
+		if int64(n) > int64(r.Len()) {
+			return "", io.ErrUnexpectedEOF
+		}
+		var sb strings.Builder
+		if _, err := io.CopyN(&sb, r, int64(n)); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	}
+	n, err := readUint32()
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	if int64(n) > int64(r.Len()) {
+		return nil, fmt.Errorf("invalid payload: %d headers", n)
+	}
+	headers := make(map[string]string, n)
+	for i := uint32(0); i < n; i++ {
+		k, err := readString()
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload header: %v", err)
+		}
+		v, err := readString()
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload header: %v", err)
+		}
+		headers[k] = v
+	}
+	p := newPayload(headers)
+	if _, err := p.body.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
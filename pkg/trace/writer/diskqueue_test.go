@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskQueue(t *testing.T) {
+	newTestPayload := func(body string) *payload {
+		p := newPayload(map[string]string{
+			"Content-Type":       "application/msgpack",
+			headerLanguages:      "go|python",
+			"X-Datadog-Reported": "",
+		})
+		p.body.WriteString(body)
+		return p
+	}
+	// size of the encoding of newTestPayload("")
+	emptySize := int64(len(encodeDiskPayload(newTestPayload(""))))
+
+	t.Run("fifo", func(t *testing.T) {
+		assert := assert.New(t)
+		q, err := newDiskQueue(t.TempDir(), 1024*1024)
+		require.NoError(t, err)
+
+		for _, body := range []string{"1", "2", "3"} {
+			dropped, _, err := q.Put(newTestPayload(body))
+			assert.NoError(err)
+			assert.Zero(dropped)
+		}
+		assert.Equal(3, q.Len())
+		assert.Equal(3*(emptySize+1), q.Size())
+
+		for _, body := range []string{"1", "2", "3"} {
+			p, err := q.Peek()
+			require.NoError(t, err)
+			assert.Equal(body, p.body.String())
+			assert.Equal(newTestPayload(body).headers, p.headers)
+			// peeking does not remove the payload
+			p, err = q.Peek()
+			require.NoError(t, err)
+			assert.Equal(body, p.body.String())
+			assert.NoError(q.Pop())
+		}
+		_, err = q.Peek()
+		assert.Equal(errDiskQueueEmpty, err)
+		assert.Equal(errDiskQueueEmpty, q.Pop())
+		assert.Zero(q.Size())
+	})
+
+	t.Run("full", func(t *testing.T) {
+		assert := assert.New(t)
+		q, err := newDiskQueue(t.TempDir(), 3*(emptySize+1))
+		require.NoError(t, err)
+
+		for _, body := range []string{"1", "2", "3"} {
+			_, _, err := q.Put(newTestPayload(body))
+			assert.NoError(err)
+		}
+		dropped, droppedBytes, err := q.Put(newTestPayload("45"))
+		assert.NoError(err)
+		assert.Equal(2, dropped)
+		assert.Equal(2*(emptySize+1), droppedBytes)
+		assert.Equal(2, q.Len())
+
+		p, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal("3", p.body.String())
+
+		_, _, err = q.Put(newTestPayload(strings.Repeat("x", int(3*emptySize))))
+		assert.Error(err)
+		assert.Equal(2, q.Len())
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		assert := assert.New(t)
+		dir := t.TempDir()
+		q, err := newDiskQueue(dir, 1024*1024)
+		require.NoError(t, err)
+		for _, body := range []string{"1", "2", "3"} {
+			_, _, err := q.Put(newTestPayload(body))
+			assert.NoError(err)
+		}
+		// files which do not hold payloads are ignored
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("data"), 0600))
+
+		q, err = newDiskQueue(dir, 1024*1024)
+		require.NoError(t, err)
+		assert.Equal(3, q.Len())
+		assert.Equal(3*(emptySize+1), q.Size())
+		for _, body := range []string{"1", "2", "3"} {
+			p, err := q.Peek()
+			require.NoError(t, err)
+			assert.Equal(body, p.body.String())
+			assert.NoError(q.Pop())
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		assert := assert.New(t)
+		dir := t.TempDir()
+		q, err := newDiskQueue(dir, 1024*1024)
+		require.NoError(t, err)
+		_, _, err = q.Put(newTestPayload("1"))
+		assert.NoError(err)
+		_, _, err = q.Put(newTestPayload("2"))
+		assert.NoError(err)
+		assert.NoError(os.Truncate(filepath.Join(dir, q.files[0]), 10))
+
+		_, err = q.Peek()
+		assert.Error(err)
+		assert.Equal(1, q.Len())
+		p, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal("2", p.body.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := newDiskQueue(t.TempDir(), 0)
+		assert.Error(t, err)
+	})
+}
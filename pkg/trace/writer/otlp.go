@@ -0,0 +1,475 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	semconv "go.opentelemetry.io/collector/semconv/v1.6.1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics/timing"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+const (
+	// otlpDefaultQueueSize is the default maximum number of payloads queued by the OTLP writer.
+	otlpDefaultQueueSize = 100
+	// otlpMaxConns is the maximum number of concurrent requests made by the OTLP writer.
+	otlpMaxConns = 10
+	// otlpTimeout is the timeout of the gRPC export requests.
+	otlpTimeout = 10 * time.Second
+	// otlpScopeName is the name of the instrumentation scope of the exported spans.
+	otlpScopeName = "datadog-agent"
+)
+
+// OTLPWriter buffers sampled traces, flushing them to an OpenTelemetry collector using
+// OTLP, over HTTP or gRPC.
+type OTLPWriter struct {
+	// In receives sampled spans to be processed by the OTLP writer.
+	// Channel should only be received from when testing.
+	In chan *SampledChunks
+
+	hostname string
+	env      string
+	grpc     bool
+	sender   *sender
+	conn     *grpc.ClientConn // gRPC connection, if grpc is set
+	client   ptraceotlp.Client
+	md       metadata.MD // gRPC metadata sent with each export
+	stop     chan struct{}
+	stats    *info.TraceWriterInfo
+	wg       sync.WaitGroup // waits for encoders
+	tick     time.Duration  // flush frequency
+
+	tracerPayloads []*pb.TracerPayload // tracer payloads buffered
+	bufferedSize   int                 // estimated buffer size
+
+	easylog *log.ThrottledLogger
+}
+
+// NewOTLPWriter returns a new OTLPWriter, sending to the OTLP exporter endpoint of the given
+// agent configuration. The configuration is expected to have been validated.
+func NewOTLPWriter(cfg *config.AgentConfig) *OTLPWriter {
+	ecfg := cfg.OTLPExporter
+	w := &OTLPWriter{
+		In:       make(chan *SampledChunks, 1000),
+		hostname: cfg.Hostname,
+		env:      cfg.DefaultEnv,
+		grpc:     ecfg.Protocol == "grpc",
+		stats:    &info.TraceWriterInfo{},
+		stop:     make(chan struct{}),
+		tick:     5 * time.Second,
+		easylog:  log.NewThrottled(5, 10*time.Second), // no more than 5 messages every 10 seconds
+	}
+	if s := cfg.TraceWriter.FlushPeriodSeconds; s != 0 {
+		w.tick = time.Duration(s*1000) * time.Millisecond
+	}
+	qsize := ecfg.QueueSize
+	if qsize <= 0 {
+		qsize = otlpDefaultQueueSize
+	}
+	userAgent := fmt.Sprintf("Datadog Trace Agent/%s/%s", cfg.AgentVersion, cfg.GitCommit)
+	scfg := &senderConfig{
+		client:    cfg.NewHTTPClient(),
+		maxConns:  otlpMaxConns,
+		maxQueued: qsize,
+		recorder:  w,
+		userAgent: userAgent,
+	}
+	if w.grpc {
+		creds := insecure.NewCredentials()
+		if !ecfg.Insecure {
+			creds = credentials.NewTLS(cfg.NewHTTPTransport().TLSClientConfig)
+		}
+		conn, err := grpc.Dial(ecfg.Endpoint, grpc.WithTransportCredentials(creds), grpc.WithUserAgent(userAgent))
+		if err != nil {
+			log.Criticalf("Invalid OTLP exporter endpoint %q: %v", ecfg.Endpoint, err)
+			os.Exit(1)
+		}
+		w.conn = conn
+		w.client = ptraceotlp.NewClient(conn)
+		w.md = metadata.New(ecfg.Headers)
+		scfg.url = &url.URL{Scheme: "grpc", Host: ecfg.Endpoint}
+		scfg.send = w.export
+	} else {
+		u, err := url.Parse(ecfg.Endpoint)
+		if err != nil {
+			log.Criticalf("Invalid OTLP exporter endpoint %q: %v", ecfg.Endpoint, err)
+			os.Exit(1)
+		}
+		scfg.url = u
+		scfg.headers = ecfg.Headers
+	}
+	scfg.disk = newSenderDiskQueue(cfg, "otlp_traces", 0, scfg.url)
+	w.sender = newSender(scfg)
+	log.Debugf("OTLP writer initialized (endpoint=%s protocol=%s qsize=%d)", ecfg.Endpoint, ecfg.Protocol, qsize)
+	return w
+}
+
+// Stop stops the OTLPWriter and attempts to flush whatever is left in the sender's buffer.
+func (w *OTLPWriter) Stop() {
+	if w == nil {
+		return
+	}
+	log.Debug("Exiting OTLP writer. Trying to flush whatever is left...")
+	w.stop <- struct{}{}
+	<-w.stop
+	stopSenders([]*sender{w.sender})
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// Run starts the OTLPWriter.
+func (w *OTLPWriter) Run() {
+	t := time.NewTicker(w.tick)
+	defer t.Stop()
+	defer close(w.stop)
+	for {
+		select {
+		case pkg := <-w.In:
+			w.addSpans(pkg)
+		case <-w.stop:
+			w.drainAndFlush()
+			return
+		case <-t.C:
+			w.report()
+			w.flush()
+		}
+	}
+}
+
+func (w *OTLPWriter) addSpans(pkg *SampledChunks) {
+	w.stats.Spans.Add(pkg.SpanCount)
+	w.stats.Traces.Add(int64(len(pkg.TracerPayload.Chunks)))
+
+	if pkg.Size+w.bufferedSize > MaxPayloadSize {
+		// reached maximum allowed buffered size
+		w.flush()
+	}
+	if len(pkg.TracerPayload.Chunks) > 0 {
+		w.tracerPayloads = append(w.tracerPayloads, pkg.TracerPayload)
+	}
+	w.bufferedSize += pkg.Size
+}
+
+func (w *OTLPWriter) drainAndFlush() {
+outer:
+	for {
+		select {
+		case pkg := <-w.In:
+			w.addSpans(pkg)
+		default:
+			break outer
+		}
+	}
+	w.flush()
+	// Wait for encoding/compression to complete on each payload,
+	// and submission to the sender
+	w.wg.Wait()
+}
+
+func (w *OTLPWriter) resetBuffer() {
+	w.bufferedSize = 0
+	w.tracerPayloads = make([]*pb.TracerPayload, 0, len(w.tracerPayloads))
+}
+
+func (w *OTLPWriter) flush() {
+	if len(w.tracerPayloads) == 0 {
+		// nothing to do
+		return
+	}
+
+	defer timing.Since("datadog.trace_agent.otlp_writer.encode_ms", time.Now())
+	defer w.resetBuffer()
+
+	log.Debugf("Converting %d tracer payloads to OTLP.", len(w.tracerPayloads))
+	td := tracerPayloadsToOTLP(w.hostname, w.env, w.tracerPayloads)
+	b, err := ptraceotlp.NewRequestFromTraces(td).MarshalProto()
+	if err != nil {
+		log.Errorf("Failed to serialize OTLP payload, data dropped: %v", err)
+		return
+	}
+
+	w.stats.BytesUncompressed.Add(int64(len(b)))
+
+	if w.grpc {
+		// the request is unmarshalled again when exported
+		p := newPayload(nil)
+		p.body.Write(b)
+		w.sender.Push(p)
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer timing.Since("datadog.trace_agent.otlp_writer.compress_ms", time.Now())
+		defer w.wg.Done()
+		p := newPayload(map[string]string{
+			"Content-Type":     "application/x-protobuf",
+			"Content-Encoding": "gzip",
+		})
+		gzipw, err := gzip.NewWriterLevel(p.body, gzip.BestSpeed)
+		if err != nil {
+			// it will never happen, unless an invalid compression is chosen;
+			// we know gzip.BestSpeed is valid.
+			log.Errorf("gzip.NewWriterLevel: %d", err)
+			return
+		}
+		if _, err := gzipw.Write(b); err != nil {
+			log.Errorf("Error gzipping OTLP payload: %v", err)
+		}
+		if err := gzipw.Close(); err != nil {
+			log.Errorf("Error closing gzip stream when writing OTLP payload: %v", err)
+		}
+		w.sender.Push(p)
+	}()
+}
+
+// export sends the payload p, holding a serialized OTLP export request, over gRPC.
+func (w *OTLPWriter) export(p *payload) error {
+	req := ptraceotlp.NewRequest()
+	if err := req.UnmarshalProto(p.body.Bytes()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	_, err := w.client.Export(metadata.NewOutgoingContext(ctx, w.md), req)
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		// see https://github.com/open-telemetry/opentelemetry-proto/blob/main/docs/specification.md#failures
+		return &retriableError{err}
+	}
+	return err
+}
+
+func (w *OTLPWriter) report() {
+	metrics.Count("datadog.trace_agent.otlp_writer.payloads", w.stats.Payloads.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.bytes_uncompressed", w.stats.BytesUncompressed.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.retries", w.stats.Retries.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.bytes", w.stats.Bytes.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.errors", w.stats.Errors.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.traces", w.stats.Traces.Swap(0), nil, 1)
+	metrics.Count("datadog.trace_agent.otlp_writer.spans", w.stats.Spans.Swap(0), nil, 1)
+}
+
+var _ eventRecorder = (*OTLPWriter)(nil)
+
+// recordEvent implements eventRecorder.
+func (w *OTLPWriter) recordEvent(t eventType, data *eventData) {
+	if data != nil {
+		metrics.Histogram("datadog.trace_agent.otlp_writer.connection_fill", data.connectionFill, nil, 1)
+		metrics.Histogram("datadog.trace_agent.otlp_writer.queue_fill", data.queueFill, nil, 1)
+	}
+	switch t {
+	case eventTypeRetry:
+		log.Debugf("Retrying to flush OTLP payload; error: %s", data.err)
+		w.stats.Retries.Inc()
+
+	case eventTypeSent:
+		log.Debugf("Flushed traces to the OTLP endpoint; time: %s, bytes: %d", data.duration, data.bytes)
+		timing.Since("datadog.trace_agent.otlp_writer.flush_duration", time.Now().Add(-data.duration))
+		w.stats.Bytes.Add(int64(data.bytes))
+		w.stats.Payloads.Inc()
+
+	case eventTypeRejected:
+		log.Warnf("OTLP writer payload rejected: %v", data.err)
+		w.stats.Errors.Inc()
+
+	case eventTypeDropped:
+		w.easylog.Warn("OTLP writer queue full. Payload dropped (%.2fKB).", float64(data.bytes)/1024)
+		metrics.Count("datadog.trace_agent.otlp_writer.dropped", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.otlp_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpilled:
+		w.easylog.Warn("OTLP writer queue full. Payload spilled to disk (%.2fKB).", float64(data.bytes)/1024)
+		metrics.Count("datadog.trace_agent.otlp_writer.spilled", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.otlp_writer.spilled_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeReplayed:
+		log.Debugf("Replaying OTLP payload from disk; bytes: %d", data.bytes)
+		metrics.Count("datadog.trace_agent.otlp_writer.replayed", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.otlp_writer.replayed_bytes", int64(data.bytes), nil, 1)
+	}
+}
+
+// tracerPayloadsToOTLP converts the given tracer payloads to OTLP traces, using one resource
+// for each service of each tracer payload. The hostname and env are used when the tracer
+// payloads do not specify any.
+func tracerPayloadsToOTLP(hostname, env string, tps []*pb.TracerPayload) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, tp := range tps {
+		// scope spans by service
+		scopes := make(map[string]ptrace.ScopeSpans)
+		for _, chunk := range tp.Chunks {
+			if chunk.DroppedTrace {
+				// only the analyzed spans of the chunk were kept
+				continue
+			}
+			hi := traceIDHigh(chunk)
+			for _, span := range chunk.Spans {
+				ss, ok := scopes[span.Service]
+				if !ok {
+					rs := td.ResourceSpans().AppendEmpty()
+					setResourceOTLP(rs.Resource().Attributes(), tp, span.Service, hostname, env)
+					ss = rs.ScopeSpans().AppendEmpty()
+					ss.Scope().SetName(otlpScopeName)
+					scopes[span.Service] = ss
+				}
+				convertSpanOTLP(span, chunk, hi, ss.Spans().AppendEmpty())
+			}
+		}
+	}
+	return td
+}
+
+// setResourceOTLP sets the resource attributes of the spans of the given service, sent in
+// the tracer payload tp.
+func setResourceOTLP(attrs pcommon.Map, tp *pb.TracerPayload, service, hostname, env string) {
+	attrs.PutString(semconv.AttributeServiceName, service)
+	if tp.Env != "" {
+		env = tp.Env
+	}
+	if tp.Hostname != "" {
+		hostname = tp.Hostname
+	}
+	for k, v := range map[string]string{
+		semconv.AttributeDeploymentEnvironment: env,
+		semconv.AttributeHostName:              hostname,
+		semconv.AttributeServiceVersion:        tp.AppVersion,
+		semconv.AttributeTelemetrySDKLanguage:  tp.LanguageName,
+		semconv.AttributeTelemetrySDKVersion:   tp.TracerVersion,
+		semconv.AttributeContainerID:           tp.ContainerID,
+	} {
+		if v != "" {
+			attrs.PutString(k, v)
+		}
+	}
+}
+
+// traceIDHigh returns the upper 64 bits of the 128-bit trace ID of the chunk, as propagated
+// by the tracers in the "_dd.p.tid" tag, or 0 if there are none.
+func traceIDHigh(chunk *pb.TraceChunk) uint64 {
+	v, ok := chunk.Tags["_dd.p.tid"]
+	for i := 0; !ok && i < len(chunk.Spans); i++ {
+		v, ok = chunk.Spans[i].Meta["_dd.p.tid"]
+	}
+	hi, _ := strconv.ParseUint(v, 16, 64)
+	return hi
+}
+
+// convertSpanOTLP converts the span in, part of chunk, into the OTLP span out, using hi as the
+// upper 64 bits of its trace ID.
+func convertSpanOTLP(in *pb.Span, chunk *pb.TraceChunk, hi uint64, out ptrace.Span) {
+	var traceID [16]byte
+	if v, err := hex.DecodeString(in.Meta["otel.trace_id"]); err == nil && len(v) == 16 {
+		// the span was received through OTLP
+		copy(traceID[:], v)
+	} else {
+		binary.BigEndian.PutUint64(traceID[:8], hi)
+		binary.BigEndian.PutUint64(traceID[8:], in.TraceID)
+	}
+	out.SetTraceID(pcommon.TraceID(traceID))
+	out.SetSpanID(uint64ToSpanID(in.SpanID))
+	out.SetParentSpanID(uint64ToSpanID(in.ParentID))
+	out.SetName(in.Resource)
+	out.SetKind(spanKindOTLP(in))
+	out.SetStartTimestamp(pcommon.Timestamp(in.Start))
+	out.SetEndTimestamp(pcommon.Timestamp(in.Start + in.Duration))
+
+	attrs := out.Attributes()
+	attrs.EnsureCapacity(len(in.Meta) + len(in.Metrics) + 4)
+	for k, v := range in.Meta {
+		attrs.PutString(k, v)
+	}
+	for k, v := range in.Metrics {
+		if k == "_sampling_priority_v1" {
+			k = "sampling.priority"
+		}
+		attrs.PutDouble(k, v)
+	}
+	if chunk.Priority != int32(sampler.PriorityNone) {
+		attrs.PutDouble("sampling.priority", float64(chunk.Priority))
+	}
+	attrs.PutString("operation.name", in.Name)
+	attrs.PutString("resource.name", in.Resource)
+	if in.Type != "" {
+		attrs.PutString("span.type", in.Type)
+	}
+	if in.Error != 0 {
+		out.Status().SetCode(ptrace.StatusCodeError)
+		out.Status().SetMessage(in.Meta["error.msg"])
+		e := out.Events().AppendEmpty()
+		e.SetName("exception")
+		e.SetTimestamp(pcommon.Timestamp(in.Start + in.Duration))
+		for k, v := range map[string]string{
+			semconv.AttributeExceptionMessage:    in.Meta["error.msg"],
+			semconv.AttributeExceptionType:       in.Meta["error.type"],
+			semconv.AttributeExceptionStacktrace: in.Meta["error.stack"],
+		} {
+			if v != "" {
+				e.Attributes().PutString(k, v)
+			}
+		}
+	}
+}
+
+// uint64ToSpanID converts the Datadog span ID id to an OTLP span ID.
+func uint64ToSpanID(id uint64) pcommon.SpanID {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return pcommon.SpanID(b)
+}
+
+// spanKindOTLP returns the OTLP kind of the span s, based on its "span.kind" tag or, if
+// there is none, on its type.
+func spanKindOTLP(s *pb.Span) ptrace.SpanKind {
+	switch s.Meta["span.kind"] {
+	case "server":
+		return ptrace.SpanKindServer
+	case "client":
+		return ptrace.SpanKindClient
+	case "producer":
+		return ptrace.SpanKindProducer
+	case "consumer":
+		return ptrace.SpanKindConsumer
+	case "internal":
+		return ptrace.SpanKindInternal
+	}
+	switch s.Type {
+	case "web":
+		return ptrace.SpanKindServer
+	case "http", "db", "cache", "sql", "redis", "memcached", "mongodb", "cassandra", "elasticsearch":
+		return ptrace.SpanKindClient
+	}
+	return ptrace.SpanKindInternal
+}
@@ -0,0 +1,288 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// otlpTestPayload returns a tracer payload holding a web and a database span of a single
+// sampled trace, along with a dropped trace.
+func otlpTestPayload() *pb.TracerPayload {
+	return &pb.TracerPayload{
+		ContainerID:   "abc123",
+		LanguageName:  "go",
+		TracerVersion: "1.40.0",
+		AppVersion:    "v1.2",
+		Chunks: []*pb.TraceChunk{
+			{
+				Priority: 2,
+				Tags:     map[string]string{"_dd.p.tid": "640cfd8d00000000"},
+				Spans: []*pb.Span{
+					{
+						Service:  "web-store",
+						Name:     "http.request",
+						Resource: "GET /users",
+						Type:     "web",
+						TraceID:  42,
+						SpanID:   1,
+						Start:    1000,
+						Duration: 500,
+						Error:    1,
+						Meta:     map[string]string{"http.method": "GET", "error.msg": "boom", "error.type": "panic"},
+						Metrics:  map[string]float64{"_sampling_priority_v1": 2, "_top_level": 1},
+					},
+					{
+						Service:  "users-db",
+						Name:     "postgres.query",
+						Resource: "SELECT * FROM users",
+						Type:     "sql",
+						TraceID:  42,
+						SpanID:   2,
+						ParentID: 1,
+						Start:    1100,
+						Duration: 200,
+					},
+				},
+			},
+			{
+				Priority:     0,
+				DroppedTrace: true,
+				Spans:        []*pb.Span{{Service: "web-store", Name: "http.request", TraceID: 43, SpanID: 3}},
+			},
+		},
+	}
+}
+
+func TestTracerPayloadsToOTLP(t *testing.T) {
+	assert := assert.New(t)
+	td := tracerPayloadsToOTLP(testHostname, testEnv, []*pb.TracerPayload{otlpTestPayload()})
+	require.Equal(t, 2, td.ResourceSpans().Len())
+	assert.Equal(2, td.SpanCount())
+
+	rs := td.ResourceSpans().At(0)
+	assert.Equal(map[string]interface{}{
+		"service.name":           "web-store",
+		"deployment.environment": testEnv,
+		"host.name":              testHostname,
+		"service.version":        "v1.2",
+		"telemetry.sdk.language": "go",
+		"telemetry.sdk.version":  "1.40.0",
+		"container.id":           "abc123",
+	}, rs.Resource().Attributes().AsRaw())
+	assert.Equal(otlpScopeName, rs.ScopeSpans().At(0).Scope().Name())
+	span := rs.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal("640cfd8d00000000000000000000002a", span.TraceID().HexString())
+	assert.Equal("0000000000000001", span.SpanID().HexString())
+	assert.True(span.ParentSpanID().IsEmpty())
+	assert.Equal("GET /users", span.Name())
+	assert.Equal(ptrace.SpanKindServer, span.Kind())
+	assert.EqualValues(1000, span.StartTimestamp())
+	assert.EqualValues(1500, span.EndTimestamp())
+	assert.Equal(ptrace.StatusCodeError, span.Status().Code())
+	assert.Equal("boom", span.Status().Message())
+	assert.Equal(map[string]interface{}{
+		"http.method":       "GET",
+		"error.msg":         "boom",
+		"error.type":        "panic",
+		"sampling.priority": 2.0,
+		"_top_level":        1.0,
+		"operation.name":    "http.request",
+		"resource.name":     "GET /users",
+		"span.type":         "web",
+	}, span.Attributes().AsRaw())
+	require.Equal(t, 1, span.Events().Len())
+	assert.Equal("exception", span.Events().At(0).Name())
+	assert.Equal(map[string]interface{}{
+		"exception.message": "boom",
+		"exception.type":    "panic",
+	}, span.Events().At(0).Attributes().AsRaw())
+
+	rs = td.ResourceSpans().At(1)
+	v, _ := rs.Resource().Attributes().Get("service.name")
+	assert.Equal("users-db", v.AsString())
+	span = rs.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal("640cfd8d00000000000000000000002a", span.TraceID().HexString())
+	assert.Equal("0000000000000001", span.ParentSpanID().HexString())
+	assert.Equal(ptrace.SpanKindClient, span.Kind())
+	assert.Equal(ptrace.StatusCodeUnset, span.Status().Code())
+	assert.Equal(0, span.Events().Len())
+
+	// spans received through OTLP keep their 128-bit trace ID
+	tp := &pb.TracerPayload{Chunks: []*pb.TraceChunk{{
+		Priority: -128,
+		Spans: []*pb.Span{{
+			Service: "svc",
+			TraceID: 42,
+			Meta:    map[string]string{"otel.trace_id": "72df520af2bde7a5240031ead750e5f3", "span.kind": "producer"},
+		}},
+	}}}
+	td = tracerPayloadsToOTLP(testHostname, testEnv, []*pb.TracerPayload{tp})
+	span = td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal("72df520af2bde7a5240031ead750e5f3", span.TraceID().HexString())
+	assert.Equal(ptrace.SpanKindProducer, span.Kind())
+	_, ok := span.Attributes().Get("sampling.priority")
+	assert.False(ok)
+}
+
+func TestOTLPWriter(t *testing.T) {
+	testSpans := []*SampledChunks{
+		{TracerPayload: otlpTestPayload(), Size: 100, SpanCount: 2},
+		{TracerPayload: otlpTestPayload(), Size: 100, SpanCount: 2},
+	}
+
+	t.Run("http", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			received []ptrace.Traces
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/v1/traces", req.URL.Path)
+			assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+			assert.Equal(t, "apm", req.Header.Get("X-Tenant"))
+			assert.Empty(t, req.Header.Get(headerAPIKey))
+			r, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			otlpReq := ptraceotlp.NewRequest()
+			require.NoError(t, otlpReq.UnmarshalProto(body))
+			mu.Lock()
+			received = append(received, otlpReq.Traces())
+			mu.Unlock()
+		}))
+		defer srv.Close()
+
+		w := NewOTLPWriter(&config.AgentConfig{
+			Hostname:    testHostname,
+			DefaultEnv:  testEnv,
+			TraceWriter: &config.WriterConfig{},
+			OTLPExporter: &config.OTLPExporterConfig{
+				Endpoint: srv.URL + "/v1/traces",
+				Protocol: "http",
+				Headers:  map[string]string{"X-Tenant": "apm"},
+			},
+		})
+		w.In = make(chan *SampledChunks)
+		go w.Run()
+		for _, ss := range testSpans {
+			w.In <- ss
+		}
+		w.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, received, 1)
+		assert.Equal(t, 4, received[0].SpanCount())
+		assert.EqualValues(t, 1, w.stats.Payloads.Load())
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		srv := grpc.NewServer()
+		ts := &testOTLPServer{codes: []codes.Code{codes.Unavailable, codes.OK}}
+		ptraceotlp.RegisterServer(srv, ts)
+		go srv.Serve(ln)
+		defer srv.Stop()
+
+		w := NewOTLPWriter(&config.AgentConfig{
+			Hostname:    testHostname,
+			DefaultEnv:  testEnv,
+			TraceWriter: &config.WriterConfig{},
+			OTLPExporter: &config.OTLPExporterConfig{
+				Endpoint: ln.Addr().String(),
+				Protocol: "grpc",
+				Headers:  map[string]string{"x-tenant": "apm"},
+				Insecure: true,
+			},
+		})
+		w.In = make(chan *SampledChunks)
+		go w.Run()
+		for _, ss := range testSpans {
+			w.In <- ss
+		}
+		w.Stop()
+
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		assert.Equal(t, 2, ts.calls)
+		require.Len(t, ts.received, 1)
+		assert.Equal(t, 4, ts.received[0].SpanCount())
+		assert.Equal(t, []string{"apm"}, ts.md.Get("x-tenant"))
+		assert.EqualValues(t, 1, w.stats.Retries.Load())
+		assert.EqualValues(t, 1, w.stats.Payloads.Load())
+	})
+
+	t.Run("grpc-rejected", func(t *testing.T) {
+		w := &OTLPWriter{client: &testClient{&testOTLPServer{codes: []codes.Code{codes.InvalidArgument}}}}
+		p := newPayload(nil)
+		b, err := ptraceotlp.NewRequestFromTraces(ptrace.NewTraces()).MarshalProto()
+		require.NoError(t, err)
+		p.body.Write(b)
+		err = w.export(p)
+		assert.Error(t, err)
+		_, ok := err.(*retriableError)
+		assert.False(t, ok)
+
+		w.client = &testClient{&testOTLPServer{codes: []codes.Code{codes.ResourceExhausted}}}
+		assert.IsType(t, &retriableError{}, w.export(p))
+	})
+}
+
+// testOTLPServer is an OTLP traces server and client, responding to the successive
+// exports with the given codes, in rotation.
+type testOTLPServer struct {
+	codes []codes.Code
+
+	mu       sync.Mutex
+	calls    int
+	md       metadata.MD
+	received []ptrace.Traces
+}
+
+// Export implements ptraceotlp.Server.
+func (s *testOTLPServer) Export(ctx context.Context, req ptraceotlp.Request) (ptraceotlp.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := s.codes[s.calls%len(s.codes)]
+	s.calls++
+	if code != codes.OK {
+		return ptraceotlp.NewResponse(), status.Error(code, code.String())
+	}
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	s.received = append(s.received, req.Traces().Clone())
+	return ptraceotlp.NewResponse(), nil
+}
+
+var _ ptraceotlp.Client = (*testClient)(nil)
+
+// testClient adapts a ptraceotlp.Server into a ptraceotlp.Client.
+type testClient struct{ srv ptraceotlp.Server }
+
+// Export implements ptraceotlp.Client.
+func (c *testClient) Export(ctx context.Context, req ptraceotlp.Request, _ ...grpc.CallOption) (ptraceotlp.Response, error) {
+	return c.srv.Export(ctx, req)
+}
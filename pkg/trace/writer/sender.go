@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -27,8 +28,10 @@ import (
 )
 
 // newSenders returns a list of senders based on the given agent configuration, using climit
-// as the maximum number of concurrent outgoing connections, writing to path.
-func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, qsize int) []*sender {
+// as the maximum number of concurrent outgoing connections, writing to path. When the disk
+// retry buffer is enabled, each sender spills its payloads to a directory named after kind
+// and its endpoint.
+func newSenders(cfg *config.AgentConfig, r eventRecorder, kind, path string, climit, qsize int) []*sender {
 	if e := cfg.Endpoints; len(e) == 0 || e[0].Host == "" || e[0].APIKey == "" {
 		panic(errors.New("config was not properly validated"))
 	}
@@ -49,11 +52,30 @@ func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, q
 			apiKey:    endpoint.APIKey,
 			recorder:  r,
 			userAgent: fmt.Sprintf("Datadog Trace Agent/%s/%s", cfg.AgentVersion, cfg.GitCommit),
+			disk:      newSenderDiskQueue(cfg, kind, i, url),
 		})
 	}
 	return senders
 }
 
+// newSenderDiskQueue returns the disk retry buffer of the i-th sender of the given kind,
+// sending to url, or nil if the disk retry buffer is disabled.
+func newSenderDiskQueue(cfg *config.AgentConfig, kind string, i int, url *url.URL) *diskQueue {
+	if cfg.DiskRetryPath == "" {
+		return nil
+	}
+	dir := filepath.Join(cfg.DiskRetryPath, kind, fmt.Sprintf("%d_%s", i, url.Hostname()))
+	disk, err := newDiskQueue(dir, cfg.DiskRetryMaxSize)
+	if err != nil {
+		log.Errorf("Disk retry buffer disabled for %s: %v", url.Hostname(), err)
+		return nil
+	}
+	if n := disk.Len(); n > 0 {
+		log.Infof("Reloaded %d payloads (%d bytes) from the disk retry buffer in %s", n, disk.Size(), dir)
+	}
+	return disk
+}
+
 // eventRecorder implementations are able to take note of events happening in
 // the sender.
 type eventRecorder interface {
@@ -75,6 +97,12 @@ const (
 	// eventTypeDropped specifies that a payload had to be dropped to make room
 	// in the queue.
 	eventTypeDropped
+	// eventTypeSpilled specifies that a payload was moved from the queue to the
+	// disk retry buffer.
+	eventTypeSpilled
+	// eventTypeReplayed specifies that a payload was moved from the disk retry
+	// buffer back onto the queue.
+	eventTypeReplayed
 )
 
 var eventTypeStrings = map[eventType]string{
@@ -82,6 +110,8 @@ var eventTypeStrings = map[eventType]string{
 	eventTypeSent:     "eventTypeSent",
 	eventTypeRejected: "eventTypeRejected",
 	eventTypeDropped:  "eventTypeDropped",
+	eventTypeSpilled:  "eventTypeSpilled",
+	eventTypeReplayed: "eventTypeReplayed",
 }
 
 // String implements fmt.Stringer.
@@ -115,8 +145,13 @@ type senderConfig struct {
 	client *config.ResetClient
 	// url specifies the URL to send requests too.
 	url *url.URL
-	// apiKey specifies the Datadog API key to use.
+	// apiKey specifies the Datadog API key to use, if any.
 	apiKey string
+	// headers specifies additional headers to set on each request.
+	headers map[string]string
+	// send, when set, is used to send payloads instead of posting them to url. It
+	// must return a *retriableError when sending may be retried.
+	send func(p *payload) error
 	// maxConns specifies the maximum number of allowed concurrent ougoing
 	// connections.
 	maxConns int
@@ -128,6 +163,10 @@ type senderConfig struct {
 	recorder eventRecorder
 	// userAgent is the computed user agent we'll use when communicating with Datadog
 	userAgent string
+	// disk specifies the disk retry buffer. When set, payloads which would otherwise
+	// be dropped from the queue are spilled to it and replayed once sending succeeds
+	// again.
+	disk *diskQueue
 }
 
 // sender is responsible for sending payloads to a given URL. It uses a size-limited
@@ -135,10 +174,11 @@ type senderConfig struct {
 type sender struct {
 	cfg *senderConfig
 
-	queue    chan *payload // payload queue
-	climit   chan struct{} // semaphore for limiting concurrent connections
-	inflight *atomic.Int32 // inflight payloads
-	attempt  *atomic.Int32 // active retry attempt
+	queue     chan *payload // payload queue
+	climit    chan struct{} // semaphore for limiting concurrent connections
+	inflight  *atomic.Int32 // inflight payloads
+	attempt   *atomic.Int32 // active retry attempt
+	replaying *atomic.Bool  // reports whether payloads are being replayed from disk
 
 	mu     sync.RWMutex // guards closed
 	closed bool         // closed reports if the loop is stopped
@@ -147,11 +187,12 @@ type sender struct {
 // newSender returns a new sender based on the given config cfg.
 func newSender(cfg *senderConfig) *sender {
 	s := sender{
-		cfg:      cfg,
-		queue:    make(chan *payload, cfg.maxQueued),
-		climit:   make(chan struct{}, cfg.maxConns),
-		inflight: atomic.NewInt32(0),
-		attempt:  atomic.NewInt32(0),
+		cfg:       cfg,
+		queue:     make(chan *payload, cfg.maxQueued),
+		climit:    make(chan struct{}, cfg.maxConns),
+		inflight:  atomic.NewInt32(0),
+		attempt:   atomic.NewInt32(0),
+		replaying: atomic.NewBool(false),
 	}
 	go s.loop()
 	return &s
@@ -219,7 +260,7 @@ func (s *sender) Push(p *payload) {
 			// drop the oldest item in the queue to make room
 			select {
 			case p := <-s.queue:
-				s.releasePayload(p, eventTypeDropped, &eventData{
+				s.spillPayload(p, &eventData{
 					bytes: p.body.Len(),
 					count: 1,
 				})
@@ -234,13 +275,12 @@ func (s *sender) Push(p *payload) {
 
 // sendPayload sends the payload p to the destination URL.
 func (s *sender) sendPayload(p *payload) {
-	req, err := p.httpRequest(s.cfg.url)
-	if err != nil {
-		log.Errorf("http.Request: %s", err)
-		return
+	send := s.post
+	if s.cfg.send != nil {
+		send = s.cfg.send
 	}
 	start := time.Now()
-	err = s.do(req)
+	err := send(p)
 	stats := &eventData{
 		bytes:    p.body.Len(),
 		count:    1,
@@ -253,7 +293,8 @@ func (s *sender) sendPayload(p *payload) {
 		s.mu.RLock()
 		defer s.mu.RUnlock()
 		if s.closed {
-			// sender is stopped
+			// sender is stopped; keep the payload on disk for the next run
+			s.spillPayload(p, stats)
 			return
 		}
 		s.attempt.Inc()
@@ -270,7 +311,7 @@ func (s *sender) sendPayload(p *payload) {
 			return
 		default:
 			// queue is full; since this is the oldest payload, we drop it
-			s.releasePayload(p, eventTypeDropped, stats)
+			s.spillPayload(p, stats)
 		}
 	case nil:
 		// request was successful; the retry queue may have grown large - we should
@@ -283,6 +324,10 @@ func (s *sender) sendPayload(p *payload) {
 			}
 		}
 		s.releasePayload(p, eventTypeSent, stats)
+		if s.cfg.disk != nil && s.cfg.disk.Len() > 0 {
+			// connectivity is back; send what was spilled to disk meanwhile
+			s.replay()
+		}
 	default:
 		// this is a fatal error, we have to drop this payload
 		s.releasePayload(p, eventTypeRejected, stats)
@@ -310,6 +355,82 @@ func (s *sender) releasePayload(p *payload, t eventType, data *eventData) {
 	s.inflight.Dec()
 }
 
+// spillPayload moves the payload p, which can not be kept in the queue, to the disk
+// retry buffer. The payload is dropped if there is no disk retry buffer, or if it
+// fails to be written to it.
+func (s *sender) spillPayload(p *payload, data *eventData) {
+	if s.cfg.disk == nil {
+		s.releasePayload(p, eventTypeDropped, data)
+		return
+	}
+	dropped, droppedBytes, err := s.cfg.disk.Put(p)
+	if dropped > 0 {
+		// older payloads were removed from disk to make room for this one
+		s.recordEvent(eventTypeDropped, &eventData{
+			bytes: int(droppedBytes),
+			count: dropped,
+		})
+	}
+	if err != nil {
+		log.Errorf("Error writing payload to the disk retry buffer: %v", err)
+		s.releasePayload(p, eventTypeDropped, data)
+		return
+	}
+	s.releasePayload(p, eventTypeSpilled, data)
+}
+
+// replay starts moving payloads from the disk retry buffer back onto the queue,
+// oldest first, for as long as the queue is less than half full. It does nothing
+// if a replay is already in progress.
+func (s *sender) replay() {
+	if !s.replaying.CAS(false, true) {
+		return
+	}
+	go func() {
+		defer s.replaying.Store(false)
+		for s.replayOne() {
+		}
+	}()
+}
+
+// replayOne moves the oldest payload of the disk retry buffer onto the queue. It
+// reports whether the replay should carry on.
+func (s *sender) replayOne() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed || len(s.queue) > cap(s.queue)/2 {
+		return false
+	}
+	p, err := s.cfg.disk.Peek()
+	switch err {
+	case nil:
+	case errDiskQueueEmpty:
+		return false
+	default:
+		log.Errorf("Error reading payload from the disk retry buffer: %v", err)
+		s.recordEvent(eventTypeDropped, &eventData{count: 1})
+		return true
+	}
+	// the payload may be sent and released as soon as it is queued
+	size := p.body.Len()
+	select {
+	case s.queue <- p:
+		s.inflight.Inc()
+	default:
+		// the queue got filled up meanwhile; try again later
+		ppool.Put(p)
+		return false
+	}
+	if err := s.cfg.disk.Pop(); err != nil {
+		log.Errorf("Error removing payload from the disk retry buffer: %v", err)
+	}
+	s.recordEvent(eventTypeReplayed, &eventData{
+		bytes: size,
+		count: 1,
+	})
+	return true
+}
+
 // recordEvent records the occurrence of the given event type t. It additionally
 // passes on the data and augments it with additional information.
 func (s *sender) recordEvent(t eventType, data *eventData) {
@@ -333,9 +454,23 @@ const (
 	headerUserAgent = "User-Agent"
 )
 
+// post sends the payload p to the destination URL in an HTTP POST request.
+func (s *sender) post(p *payload) error {
+	req, err := p.httpRequest(s.cfg.url)
+	if err != nil {
+		return fmt.Errorf("http.Request: %s", err)
+	}
+	return s.do(req)
+}
+
 func (s *sender) do(req *http.Request) error {
-	req.Header.Set(headerAPIKey, s.cfg.apiKey)
+	if s.cfg.apiKey != "" {
+		req.Header.Set(headerAPIKey, s.cfg.apiKey)
+	}
 	req.Header.Set(headerUserAgent, s.cfg.userAgent)
+	for k, v := range s.cfg.headers {
+		req.Header.Set(k, v)
+	}
 	resp, err := s.cfg.client.Do(req)
 	if err != nil {
 		// request errors include timeouts or name resolution errors and
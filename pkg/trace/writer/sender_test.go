@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -262,6 +263,75 @@ func TestSender(t *testing.T) {
 			assert.True(time.Since(start)-failed[i].duration < time.Second)
 		}
 	})
+
+	t.Run("disk", func(t *testing.T) {
+		assert := assert.New(t)
+		server := newTestServerWithLatency(10 * time.Millisecond)
+		defer server.Close()
+		defer useBackoffDuration(0)()
+
+		disk, err := newDiskQueue(t.TempDir(), 1024*1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recorder mockRecorder
+		cfg := testSenderConfig(server.URL)
+		cfg.maxConns = 1
+		cfg.maxQueued = 2
+		cfg.recorder = &recorder
+		cfg.disk = disk
+		s := newSender(cfg)
+
+		// the queue overflows, but payloads are spilled to disk and replayed
+		// once sending succeeds
+		for i := 0; i < 20; i++ {
+			s.Push(expectResponses(200))
+		}
+		for start := time.Now(); server.Total() < 20 && time.Since(start) < 5*time.Second; {
+			time.Sleep(10 * time.Millisecond)
+		}
+		s.Stop()
+
+		assert.Equal(20, server.Total(), "total")
+		assert.Equal(20, server.Accepted(), "accepted")
+		assert.Zero(disk.Len())
+		assert.Empty(recorder.data(eventTypeDropped))
+		spilled := len(recorder.data(eventTypeSpilled))
+		assert.True(spilled > 0)
+		assert.Equal(spilled, len(recorder.data(eventTypeReplayed)))
+	})
+
+	t.Run("disk-stopped", func(t *testing.T) {
+		assert := assert.New(t)
+		server := newTestServer()
+		defer server.Close()
+		defer useBackoffDuration(0)()
+
+		dir := t.TempDir()
+		disk, err := newDiskQueue(dir, 1024*1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := testSenderConfig(server.URL)
+		cfg.disk = disk
+		s := newSender(cfg)
+		s.Push(expectResponses(503))
+		s.Stop()
+
+		// payloads still failing when the sender stops are kept on disk,
+		// to be replayed by the next sender
+		for start := time.Now(); disk.Len() == 0 && time.Since(start) < 5*time.Second; {
+			time.Sleep(10 * time.Millisecond)
+		}
+		disk, err = newDiskQueue(dir, 1024*1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(1, disk.Len())
+		p, err := disk.Peek()
+		assert.NoError(err)
+		assert.True(strings.HasSuffix(p.body.String(), "|503"))
+	})
 }
 
 func TestPayload(t *testing.T) {
@@ -316,8 +386,8 @@ func useBackoffDuration(d time.Duration) func() {
 
 // mockRecorder is a mock eventRecorder which records all calls to recordEvent.
 type mockRecorder struct {
-	mu                             sync.RWMutex
-	retry, sent, dropped, rejected []*eventData
+	mu                                                sync.RWMutex
+	retry, sent, dropped, rejected, spilled, replayed []*eventData
 }
 
 // data returns all call data for the given eventType.
@@ -333,6 +403,10 @@ func (r *mockRecorder) data(t eventType) []*eventData {
 		return r.dropped
 	case eventTypeRejected:
 		return r.rejected
+	case eventTypeSpilled:
+		return r.spilled
+	case eventTypeReplayed:
+		return r.replayed
 	default:
 		panic("unknown event")
 	}
@@ -351,5 +425,9 @@ func (r *mockRecorder) recordEvent(t eventType, data *eventData) {
 		r.dropped = append(r.dropped, data)
 	case eventTypeRejected:
 		r.rejected = append(r.rejected, data)
+	case eventTypeSpilled:
+		r.spilled = append(r.spilled, data)
+	case eventTypeReplayed:
+		r.replayed = append(r.replayed, data)
 	}
 }
@@ -82,7 +82,7 @@ func NewStatsWriter(cfg *config.AgentConfig, in <-chan pb.StatsPayload) *StatsWr
 		qsize = int(math.Max(1, maxmem/payloadSize))
 	}
 	log.Debugf("Stats writer initialized (climit=%d qsize=%d)", climit, qsize)
-	sw.senders = newSenders(cfg, sw, pathStats, climit, qsize)
+	sw.senders = newSenders(cfg, sw, "stats", pathStats, climit, qsize)
 	return sw
 }
 
@@ -350,7 +350,17 @@ func (w *StatsWriter) recordEvent(t eventType, data *eventData) {
 
 	case eventTypeDropped:
 		w.easylog.Warn("Stats writer queue full. Payload dropped (%.2fKB).", float64(data.bytes)/1024)
-		metrics.Count("datadog.trace_agent.stats_writer.dropped", 1, nil, 1)
+		metrics.Count("datadog.trace_agent.stats_writer.dropped", int64(data.count), nil, 1)
 		metrics.Count("datadog.trace_agent.stats_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpilled:
+		w.easylog.Warn("Stats writer queue full. Payload spilled to disk (%.2fKB).", float64(data.bytes)/1024)
+		metrics.Count("datadog.trace_agent.stats_writer.spilled", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.stats_writer.spilled_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeReplayed:
+		log.Debugf("Replaying stats payload from disk; bytes: %d", data.bytes)
+		metrics.Count("datadog.trace_agent.stats_writer.replayed", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.stats_writer.replayed_bytes", int64(data.bytes), nil, 1)
 	}
 }
@@ -107,7 +107,7 @@ func NewTraceWriter(cfg *config.AgentConfig) *TraceWriter {
 		tw.tick = time.Duration(s*1000) * time.Millisecond
 	}
 	log.Debugf("Trace writer initialized (climit=%d qsize=%d)", climit, qsize)
-	tw.senders = newSenders(cfg, tw, pathTraces, climit, qsize)
+	tw.senders = newSenders(cfg, tw, "traces", pathTraces, climit, qsize)
 	return tw
 }
 
@@ -305,7 +305,17 @@ func (w *TraceWriter) recordEvent(t eventType, data *eventData) {
 
 	case eventTypeDropped:
 		w.easylog.Warn("Trace writer queue full. Payload dropped (%.2fKB).", float64(data.bytes)/1024)
-		metrics.Count("datadog.trace_agent.trace_writer.dropped", 1, nil, 1)
+		metrics.Count("datadog.trace_agent.trace_writer.dropped", int64(data.count), nil, 1)
 		metrics.Count("datadog.trace_agent.trace_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpilled:
+		w.easylog.Warn("Trace writer queue full. Payload spilled to disk (%.2fKB).", float64(data.bytes)/1024)
+		metrics.Count("datadog.trace_agent.trace_writer.spilled", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.trace_writer.spilled_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeReplayed:
+		log.Debugf("Replaying trace payload from disk; bytes: %d", data.bytes)
+		metrics.Count("datadog.trace_agent.trace_writer.replayed", int64(data.count), nil, 1)
+		metrics.Count("datadog.trace_agent.trace_writer.replayed_bytes", int64(data.bytes), nil, 1)
 	}
 }